@@ -102,6 +102,7 @@ func BenchmarkVochain(b *testing.B) {
 	process := &types.NewProcessTx{
 		EntityID:       signerPub,
 		MkRoot:         mkRoot,
+		Nonce:          util.RandomHex(32),
 		NumberOfBlocks: numberOfBlocks,
 		ProcessID:      processID,
 		ProcessType:    types.PollVote,
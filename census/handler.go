@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -189,13 +190,21 @@ func (m *Manager) Handler(ctx context.Context, r *types.MetaRequest, isAuth bool
 		if isAuth && validAuthPrefix {
 			addedClaims := 0
 			var invalidClaims []int
+			if len(r.Weights) > 0 && len(r.Weights) != len(r.ClaimsData) {
+				resp.SetError("claimsData and weights size mismatch")
+				return resp
+			}
 			for i, c := range r.ClaimsData {
+				var value []byte
 				data, err := base64.StdEncoding.DecodeString(c)
+				if err == nil && len(r.Weights) > 0 {
+					value, err = claimValue(r.Weights[i])
+				}
 				if err == nil {
 					if !r.Digested {
 						data = snarks.Poseidon.Hash(data)
 					}
-					err = tr.AddClaim(data, []byte{})
+					err = tr.AddClaim(data, value)
 				}
 				if err != nil {
 					log.Warnf("error adding claim: %s", err)
@@ -224,7 +233,12 @@ func (m *Manager) Handler(ctx context.Context, r *types.MetaRequest, isAuth bool
 			if !r.Digested {
 				data = snarks.Poseidon.Hash(data)
 			}
-			err = tr.AddClaim(data, []byte{})
+			value, err := claimValue(r.Weight)
+			if err != nil {
+				resp.SetError(err)
+				return resp
+			}
+			err = tr.AddClaim(data, value)
 			if err != nil {
 				log.Warnf("error adding claim: %s", err)
 				resp.SetError(err)
@@ -239,7 +253,7 @@ func (m *Manager) Handler(ctx context.Context, r *types.MetaRequest, isAuth bool
 	case "importDump":
 		if isAuth && validAuthPrefix {
 			if len(r.ClaimsData) > 0 {
-				err := tr.ImportDump(r.ClaimsData)
+				err := tr.ImportDump(r.ClaimsData, r.ClaimsValues)
 				if err != nil {
 					log.Warnf("error importing dump: %s", err)
 					resp.SetError(err)
@@ -284,7 +298,7 @@ func (m *Manager) Handler(ctx context.Context, r *types.MetaRequest, isAuth bool
 		}
 		log.Infof("retrieved census with rootHash %s and size %d bytes", dump.RootHash, len(censusRaw))
 		if len(dump.ClaimsData) > 0 {
-			err = tr.ImportDump(dump.ClaimsData)
+			err = tr.ImportDump(dump.ClaimsData, dump.ClaimsValues)
 			if err != nil {
 				log.Warnf("error importing dump: %s", err)
 				resp.SetError("error importing census")
@@ -316,7 +330,12 @@ func (m *Manager) Handler(ctx context.Context, r *types.MetaRequest, isAuth bool
 		if !r.Digested {
 			data = snarks.Poseidon.Hash(data)
 		}
-		validProof, err := tree.CheckProof(root, r.ProofData, data, []byte{})
+		value, err := claimValue(r.Weight)
+		if err != nil {
+			resp.SetError(err)
+			return resp
+		}
+		validProof, err := tree.CheckProof(root, r.ProofData, data, value)
 		if err != nil {
 			resp.SetError(err)
 			return resp
@@ -368,21 +387,22 @@ func (m *Manager) Handler(ctx context.Context, r *types.MetaRequest, isAuth bool
 			return resp
 		}
 		// dump the claim data and return it
-		var dumpValues []string
+		var dumpClaims, dumpValues []string
 		root := r.RootHash
 		if len(root) < 1 {
 			root = tr.Root()
 		}
 		var err error
 		if r.Method == "dump" {
-			dumpValues, err = tr.Dump(root)
+			dumpClaims, dumpValues, err = tr.Dump(root)
 		} else {
-			dumpValues, _, err = tr.DumpPlain(root, true)
+			dumpClaims, _, err = tr.DumpPlain(root, true)
 		}
 		if err != nil {
 			resp.SetError(err)
 		} else {
-			resp.ClaimsData = dumpValues
+			resp.ClaimsData = dumpClaims
+			resp.ClaimsValues = dumpValues
 		}
 		return resp
 
@@ -398,7 +418,7 @@ func (m *Manager) Handler(ctx context.Context, r *types.MetaRequest, isAuth bool
 		var dump types.CensusDump
 		dump.RootHash = tr.Root()
 		var err error
-		dump.ClaimsData, err = tr.Dump(tr.Root())
+		dump.ClaimsData, dump.ClaimsValues, err = tr.Dump(tr.Root())
 		if err != nil {
 			resp.SetError(err)
 			log.Warnf("cannot dump census with root %s: %s", tr.Root(), err)
@@ -428,7 +448,7 @@ func (m *Manager) Handler(ctx context.Context, r *types.MetaRequest, isAuth bool
 			log.Warnf("error creating local published census: %s", err)
 		} else if err == nil {
 			log.Infof("import claims to new census")
-			err = tr2.ImportDump(dump.ClaimsData)
+			err = tr2.ImportDump(dump.ClaimsData, dump.ClaimsValues)
 			if err != nil {
 				m.DelNamespace(resp.Root)
 				log.Warn(err)
@@ -440,3 +460,16 @@ func (m *Manager) Handler(ctx context.Context, r *types.MetaRequest, isAuth bool
 	}
 	return resp
 }
+
+// claimValue returns the census claim value for a decimal voting weight.
+// An empty weight means the claim has no value (unweighted census).
+func claimValue(weight string) ([]byte, error) {
+	if weight == "" {
+		return []byte{}, nil
+	}
+	w, err := strconv.ParseUint(weight, 10, 64)
+	if err != nil || w == 0 {
+		return nil, fmt.Errorf("invalid weight %q", weight)
+	}
+	return types.EncodeCensusWeight(w), nil
+}
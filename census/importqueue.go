@@ -35,7 +35,7 @@ func (m *Manager) importTree(tree []byte, cid string) error {
 	} else if err != nil {
 		return fmt.Errorf("cannot create new census namespace: (%s)", err)
 	}
-	err = tr.ImportDump(dump.ClaimsData)
+	err = tr.ImportDump(dump.ClaimsData, dump.ClaimsValues)
 	if err != nil {
 		return fmt.Errorf("error importing dump: %s", err)
 	}
@@ -5,11 +5,14 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	ethbind "github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"golang.org/x/crypto/sha3"
 	"golang.org/x/net/idna"
@@ -23,30 +26,39 @@ import (
 
 // VOTING PROCESS WRAPPER
 
+// EthereumBackend is the part of the Ethereum client used by ProcessHandle, implemented
+// by ethclient.Client and by the go-ethereum simulated backend
+type EthereumBackend interface {
+	ethbind.ContractBackend
+	HeaderByNumber(ctx context.Context, number *big.Int) (*ethtypes.Header, error)
+}
+
 // These methods represent an exportable abstraction over raw contract bindings`
 // Use these methods, rather than those present in the contracts folder
 type ProcessHandle struct {
 	VotingProcess  *contracts.VotingProcess
-	EthereumClient *ethclient.Client
+	EthereumClient EthereumBackend
 }
 
 // Constructor for proc_transactor on node
 func NewVotingProcessHandle(contractAddressHex string, dialEndpoint string) (*ProcessHandle, error) {
 	var err error
+	var client *ethclient.Client
 	PH := new(ProcessHandle)
 
 	for i := 0; i < types.EthereumDialMaxRetry; i++ {
-		PH.EthereumClient, err = ethclient.Dial(dialEndpoint)
-		if err != nil || PH.EthereumClient == nil {
+		client, err = ethclient.Dial(dialEndpoint)
+		if err != nil || client == nil {
 			log.Warnf("cannot create a client connection: (%s), trying again (%d of %d)", err, i+1, types.EthereumDialMaxRetry)
 			time.Sleep(time.Second * 2)
 			continue
 		}
 		break
 	}
-	if err != nil || PH.EthereumClient == nil {
+	if err != nil || client == nil {
 		log.Fatalf("cannot create a client connection: (%s), tried %d times.", err, types.EthereumDialMaxRetry)
 	}
+	PH.EthereumClient = client
 	address := common.HexToAddress(contractAddressHex)
 
 	votingProcess, err := contracts.NewVotingProcess(address, PH.EthereumClient)
@@ -72,22 +84,56 @@ func (ph *ProcessHandle) ProcessTxArgs(ctx context.Context, pid [32]byte) (*type
 		return nil, fmt.Errorf("error decoding entity address: %s", err)
 	}
 	processTxArgs.EntityID = fmt.Sprintf("%x", ethereum.HashRaw(eid))
-	processTxArgs.MkRoot = processMeta.CensusMerkleRoot
-	processTxArgs.MkURI = processMeta.CensusMerkleTree
-	if processMeta.NumberOfBlocks != nil {
-		processTxArgs.NumberOfBlocks = processMeta.NumberOfBlocks.Int64()
+	if strings.HasPrefix(processMeta.CensusMerkleTree, TokenCensusURIPrefix) {
+		// token holders census, the state root is fetched from Ethereum
+		if processTxArgs.TokenCensus, err = ph.tokenCensusFromURI(ctx, processMeta.CensusMerkleTree); err != nil {
+			return nil, fmt.Errorf("cannot get token census: (%s)", err)
+		}
+	} else {
+		processTxArgs.MkRoot = processMeta.CensusMerkleRoot
+		processTxArgs.MkURI = processMeta.CensusMerkleTree
 	}
-	if processMeta.StartBlock != nil {
-		processTxArgs.StartBlock = processMeta.StartBlock.Int64()
+	if err := setProcessSchedule(processTxArgs, processMeta.StartBlock, processMeta.NumberOfBlocks); err != nil {
+		return nil, err
 	}
 	switch processMeta.ProcessType {
-	case types.SnarkVote, types.PollVote, types.PetitionSign, types.EncryptedPoll:
+	case types.SnarkVote, types.PollVote, types.PetitionSign, types.EncryptedPoll, types.WeightedPoll, types.OpenPoll:
 		processTxArgs.ProcessType = processMeta.ProcessType
 	}
 	processTxArgs.Type = "newProcess"
+	processTxArgs.Nonce = util.RandomHex(32)
 	return processTxArgs, nil
 }
 
+// TimeScheduleThreshold is the lowest contract startBlock interpreted as a unix timestamp.
+// The voting process contract only has the startBlock and numberOfBlocks fields: a startBlock
+// from this value (September 2001) is the process start time and numberOfBlocks its duration
+// in seconds, since the vochain will never reach such a block height.
+const TimeScheduleThreshold = 1000000000
+
+// setProcessSchedule sets the voting period of a process created on Ethereum, scheduled by
+// block heights or by time (see TimeScheduleThreshold)
+func setProcessSchedule(tx *types.NewProcessTx, startBlock, numberOfBlocks *big.Int) error {
+	if startBlock == nil || numberOfBlocks == nil {
+		return nil
+	}
+	if !startBlock.IsInt64() || !numberOfBlocks.IsInt64() {
+		return fmt.Errorf("process schedule out of range")
+	}
+	if startBlock.Int64() < TimeScheduleThreshold {
+		tx.StartBlock = startBlock.Int64()
+		tx.NumberOfBlocks = numberOfBlocks.Int64()
+		return nil
+	}
+	end := new(big.Int).Add(startBlock, numberOfBlocks)
+	if !end.IsInt64() {
+		return fmt.Errorf("process end time out of range")
+	}
+	tx.StartTime = startBlock.Int64()
+	tx.EndTime = end.Int64()
+	return nil
+}
+
 func (ph *ProcessHandle) CancelProcessTxArgs(ctx context.Context, pid [32]byte) (*types.CancelProcessTx, error) {
 	opts := &ethbind.CallOpts{Context: ctx}
 	_, err := ph.VotingProcess.Get(opts, pid)
@@ -97,9 +143,62 @@ func (ph *ProcessHandle) CancelProcessTxArgs(ctx context.Context, pid [32]byte)
 	cancelProcessTxArgs := new(types.CancelProcessTx)
 	cancelProcessTxArgs.ProcessID = fmt.Sprintf("%x", pid)
 	cancelProcessTxArgs.Type = "cancelProcess"
+	cancelProcessTxArgs.Nonce = util.RandomHex(32)
 	return cancelProcessTxArgs, nil
 }
 
+// TokenCensusURIPrefix is the census URI scheme of the token holders census processes.
+// The URI has the form erc20://<tokenAddress>?slot=<balancesSlot>&block=<blockNumber>&decimals=<decimals>
+const TokenCensusURIPrefix = "erc20://"
+
+// tokenCensusFromURI parses a token census URI and returns the token census
+func (ph *ProcessHandle) tokenCensusFromURI(ctx context.Context, uri string) (*types.TokenCensus, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	slot, err := strconv.ParseUint(query.Get("slot"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage slot: (%s)", err)
+	}
+	block, err := strconv.ParseUint(query.Get("block"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block number: (%s)", err)
+	}
+	var decimals uint64
+	if d := query.Get("decimals"); d != "" {
+		if decimals, err = strconv.ParseUint(d, 10, 32); err != nil {
+			return nil, fmt.Errorf("invalid decimals: (%s)", err)
+		}
+	}
+	return ph.TokenCensus(ctx, u.Host, slot, block, uint32(decimals))
+}
+
+// TokenCensus returns the census of the holders of a token contract whose balances mapping is stored
+// at slot, at the Ethereum block blockNumber. The voters prove their balance with a storage proof
+// (eth_getProof) against the state root of this block.
+func (ph *ProcessHandle) TokenCensus(ctx context.Context, token string, slot, blockNumber uint64,
+	decimals uint32) (*types.TokenCensus, error) {
+	if !common.IsHexAddress(token) {
+		return nil, fmt.Errorf("invalid token address %s", token)
+	}
+	header, err := ph.EthereumClient.HeaderByNumber(ctx, new(big.Int).SetUint64(blockNumber))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get block %d header: (%s)", blockNumber, err)
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+	return &types.TokenCensus{
+		BlockNumber:  blockNumber,
+		Decimals:     decimals,
+		StateRoot:    hex.EncodeToString(header.Root.Bytes()),
+		StorageSlot:  slot,
+		TokenAddress: common.HexToAddress(token).Hex(),
+	}, nil
+}
+
 func (ph *ProcessHandle) ProcessIndex(ctx context.Context, pid [32]byte) (*big.Int, error) {
 	opts := &ethbind.CallOpts{Context: ctx}
 	return ph.VotingProcess.GetProcessIndex(opts, pid)
@@ -0,0 +1,103 @@
+package chain
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+
+	"gitlab.com/vocdoni/go-dvote/types"
+)
+
+func TestTokenCensus(t *testing.T) {
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{}, 8000000)
+	backend.Commit()
+	ph := &ProcessHandle{EthereumClient: backend}
+	ctx := context.Background()
+
+	header, err := backend.HeaderByNumber(ctx, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := "0x5b38da6a701c568545dcfcb03fcb875f56beddc4"
+	tc, err := ph.TokenCensus(ctx, token, 3, 1, 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := types.TokenCensus{
+		BlockNumber:  1,
+		Decimals:     18,
+		StateRoot:    hex.EncodeToString(header.Root.Bytes()),
+		StorageSlot:  3,
+		TokenAddress: common.HexToAddress(token).Hex(),
+	}
+	if *tc != expected {
+		t.Fatalf("expected token census %+v, got %+v", expected, *tc)
+	}
+	if _, err := ph.TokenCensus(ctx, "0x1234", 3, 1, 18); err == nil {
+		t.Error("invalid token address accepted")
+	}
+	if _, err := ph.TokenCensus(ctx, token, 3, 100, 18); err == nil {
+		t.Error("token census of an unknown block created")
+	}
+
+	uri := fmt.Sprintf("%s%s?slot=3&block=1&decimals=18", TokenCensusURIPrefix, token)
+	if tc, err = ph.tokenCensusFromURI(ctx, uri); err != nil {
+		t.Fatal(err)
+	}
+	if *tc != expected {
+		t.Fatalf("expected token census %+v, got %+v", expected, *tc)
+	}
+	// decimals are optional
+	uri = fmt.Sprintf("%s%s?slot=3&block=1", TokenCensusURIPrefix, token)
+	if tc, err = ph.tokenCensusFromURI(ctx, uri); err != nil {
+		t.Fatal(err)
+	}
+	if tc.Decimals != 0 {
+		t.Errorf("expected no decimals, got %d", tc.Decimals)
+	}
+	for _, uri := range []string{
+		TokenCensusURIPrefix + token + "?block=1",
+		TokenCensusURIPrefix + token + "?slot=3",
+		TokenCensusURIPrefix + token + "?slot=-1&block=1",
+		TokenCensusURIPrefix + token + "?slot=3&block=1&decimals=4294967296",
+		TokenCensusURIPrefix + "0x1234?slot=3&block=1",
+	} {
+		if _, err := ph.tokenCensusFromURI(ctx, uri); err == nil {
+			t.Errorf("invalid token census URI %s accepted", uri)
+		}
+	}
+}
+
+func TestProcessSchedule(t *testing.T) {
+	tx := &types.NewProcessTx{}
+	if err := setProcessSchedule(tx, big.NewInt(100), big.NewInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+	if tx.StartBlock != 100 || tx.NumberOfBlocks != 1000 || tx.StartTime != 0 || tx.EndTime != 0 {
+		t.Errorf("wrong block schedule %+v", *tx)
+	}
+
+	// a start block from the threshold is a unix timestamp and the number of blocks a duration
+	tx = &types.NewProcessTx{}
+	if err := setProcessSchedule(tx, big.NewInt(1600000000), big.NewInt(3600)); err != nil {
+		t.Fatal(err)
+	}
+	if tx.StartTime != 1600000000 || tx.EndTime != 1600003600 || tx.StartBlock != 0 || tx.NumberOfBlocks != 0 {
+		t.Errorf("wrong time schedule %+v", *tx)
+	}
+
+	huge := new(big.Int).Lsh(big.NewInt(1), 64)
+	if err := setProcessSchedule(&types.NewProcessTx{}, huge, big.NewInt(1)); err == nil {
+		t.Error("start out of range accepted")
+	}
+	if err := setProcessSchedule(&types.NewProcessTx{}, big.NewInt(math.MaxInt64), big.NewInt(1)); err == nil {
+		t.Error("end time out of range accepted")
+	}
+}
@@ -2,6 +2,7 @@ package ethevents
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -108,6 +109,8 @@ func HandleVochainOracle(ctx context.Context, event *ethtypes.Log, e *EthereumEv
 			return nil
 		}
 
+		// the oracles must sign the same transaction for co-signing it
+		processTx.Nonce = eventNonce(event)
 		processTx.Signature, err = e.Signer.SignJSON(processTx)
 		if err != nil {
 			return fmt.Errorf("cannot sign oracle tx: (%s)", err)
@@ -118,7 +121,7 @@ func HandleVochainOracle(ctx context.Context, event *ethtypes.Log, e *EthereumEv
 		}
 		log.Debugf("broadcasting Vochain TX: %s", tx)
 
-		res, err := e.VochainApp.SendTX(tx)
+		res, err := e.VochainApp.SendOracleTx(tx)
 		if err != nil || res == nil {
 			log.Warnf("cannot broadcast tx: (%s)", err)
 			return fmt.Errorf("cannot broadcast tx: (%s), res: (%+v)", err, res)
@@ -147,6 +150,7 @@ func HandleVochainOracle(ctx context.Context, event *ethtypes.Log, e *EthereumEv
 			log.Infof("process already canceled, skipping")
 			return nil
 		}
+		cancelProcessTx.Nonce = eventNonce(event)
 		cancelProcessTx.Signature, err = e.Signer.SignJSON(cancelProcessTx)
 		if err != nil {
 			return fmt.Errorf("cannot sign oracle tx: (%s)", err)
@@ -157,7 +161,7 @@ func HandleVochainOracle(ctx context.Context, event *ethtypes.Log, e *EthereumEv
 		}
 		log.Debugf("broadcasting Vochain tx\n\t%s", string(tx))
 
-		res, err := e.VochainApp.SendTX(tx)
+		res, err := e.VochainApp.SendOracleTx(tx)
 		if err != nil || res == nil {
 			log.Warnf("cannot broadcast tx: (%s)", err)
 			return fmt.Errorf("cannot broadcast tx: (%s), res: (%+v)", err, res)
@@ -219,6 +223,14 @@ func HandleCensus(ctx context.Context, event *ethtypes.Log, e *EthereumEvents) e
 	return nil
 }
 
+// eventNonce returns the nonce of the transaction sent for an Ethereum event. It is
+// the same for all the oracles, so they can co-sign the transaction (see CoSignTx).
+func eventNonce(event *ethtypes.Log) string {
+	index := make([]byte, 8)
+	binary.BigEndian.PutUint64(index, uint64(event.Index))
+	return hex.EncodeToString(crypto.Keccak256(event.TxHash.Bytes(), index))
+}
+
 func processMeta(ctx context.Context, contractABI *abi.ABI, eventData []byte, ph *chain.ProcessHandle) (*types.NewProcessTx, error) {
 	var eventProcessCreated eventProcessCreated
 	err := contractABI.Unpack(&eventProcessCreated, "ProcessCreated", eventData)
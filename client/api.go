@@ -14,6 +14,7 @@ import (
 	"gitlab.com/vocdoni/go-dvote/crypto/snarks"
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/util"
 )
 
 type pkeys struct {
@@ -60,7 +61,7 @@ func (c *Client) GetProof(hexpubkey, root string) (string, error) {
 	return resp.Siblings, nil
 }
 
-func (c *Client) GetResults(pid string) ([][]uint32, string, error) {
+func (c *Client) GetResults(pid string) ([][]uint64, string, error) {
 	var req types.MetaRequest
 	req.Method = "getResults"
 	req.ProcessID = pid
@@ -159,10 +160,10 @@ func (c *Client) GetKeys(pid, eid string) (*pkeys, error) {
 		rev:  resp.RevealKeys}, nil
 }
 
-func (c *Client) TestResults(pid string, totalVotes int) ([][]uint32, error) {
+func (c *Client) TestResults(pid string, totalVotes int) ([][]uint64, error) {
 	log.Infof("waiting for results...")
 	var err error
-	var results [][]uint32
+	var results [][]uint64
 	var block int64
 	for {
 		block, err = c.GetCurrentBlock()
@@ -179,7 +180,7 @@ func (c *Client) TestResults(pid string, totalVotes int) ([][]uint32, error) {
 		}
 		log.Infof("no results yet at block %d", block+2)
 	}
-	total := uint32(totalVotes)
+	total := uint64(totalVotes)
 	if results[0][1] != total || results[1][2] != total || results[2][3] != total || results[3][4] != total {
 		return nil, fmt.Errorf("invalid results: %v", results)
 	}
@@ -376,6 +377,7 @@ func (c *Client) CreateProcess(oracle *ethereum.SignKeys, entityID, mkroot, mkur
 		EntityID:       entityID,
 		MkRoot:         mkroot,
 		MkURI:          mkuri,
+		Nonce:          util.RandomHex(32),
 		NumberOfBlocks: int64(duration),
 		ProcessID:      pid,
 		ProcessType:    ptype,
@@ -408,6 +410,7 @@ func (c *Client) CancelProcess(oracle *ethereum.SignKeys, pid string) error {
 	req.Method = "submitRawTx"
 	p := types.CancelProcessTx{
 		Type:      "cancelProcess",
+		Nonce:     util.RandomHex(32),
 		ProcessID: pid,
 	}
 	txBytes, err := json.Marshal(p)
@@ -432,6 +435,40 @@ func (c *Client) CancelProcess(oracle *ethereum.SignKeys, pid string) error {
 	return nil
 }
 
+// SetProcessPaused sends an oracle signed transaction for pausing or resuming a process
+func (c *Client) SetProcessPaused(oracle *ethereum.SignKeys, pid string, pause bool) error {
+	var req types.MetaRequest
+	req.Method = "submitRawTx"
+	p := types.PauseProcessTx{
+		Type:      types.TxResumeProcess,
+		Nonce:     util.RandomHex(32),
+		ProcessID: pid,
+	}
+	if pause {
+		p.Type = types.TxPauseProcess
+	}
+	txBytes, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if p.Signature, err = oracle.Sign(txBytes); err != nil {
+		return err
+	}
+	if txBytes, err = json.Marshal(p); err != nil {
+		return err
+	}
+	req.RawTx = base64.StdEncoding.EncodeToString(txBytes)
+
+	resp, err := c.Request(req, nil)
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("%s failed: %s", req.Method, resp.Message)
+	}
+	return nil
+}
+
 func (c *Client) GetCurrentBlock() (int64, error) {
 	var req types.MetaRequest
 	req.Method = "getBlockHeight"
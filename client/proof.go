@@ -0,0 +1,101 @@
+package client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/tendermint/go-amino"
+	"github.com/tendermint/tendermint/crypto/merkle"
+
+	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/vochain"
+)
+
+// EnvelopeProof contains a vote envelope as stored on the vochain state and the
+// merkle proof of its inclusion on the VoteTree
+type EnvelopeProof struct {
+	// Envelope is the amino encoded types.Vote stored on the VoteTree
+	Envelope []byte
+	// Proof is the data of the proof operation of the envelope on the VoteTree
+	Proof []byte
+	// ProofType is the type of the proof operation, it identifies the state backend
+	ProofType string
+	// StateRoots contains the hex encoded root of each state tree
+	StateRoots map[string]string
+	// Height is the height of the block header which commits to the state
+	Height int64
+	// AppHash is the AppHash included on the header at Height, as reported by the gateway.
+	// It is nil if the header was not yet available. It is not trusted, the proof must be
+	// verified against the AppHash of a verified header (see VerifyEnvelopeProof).
+	AppHash []byte
+}
+
+// GetEnvelopeProof fetches the envelope identified by pid and nullifier and its inclusion proof
+func (c *Client) GetEnvelopeProof(pid, nullifier string) (*EnvelopeProof, error) {
+	var req types.MetaRequest
+	req.Method = "getEnvelopeProof"
+	req.ProcessID = pid
+	req.Nullifier = nullifier
+	resp, err := c.Request(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Ok || resp.Height == nil {
+		return nil, fmt.Errorf("cannot get envelope proof: (%s)", resp.Message)
+	}
+	p := &EnvelopeProof{
+		ProofType:  resp.ProofType,
+		StateRoots: resp.StateRoots,
+		Height:     *resp.Height,
+	}
+	if p.Envelope, err = hex.DecodeString(resp.Envelope); err != nil {
+		return nil, fmt.Errorf("cannot decode envelope: (%s)", err)
+	}
+	if p.Proof, err = hex.DecodeString(resp.Proof); err != nil {
+		return nil, fmt.Errorf("cannot decode proof: (%s)", err)
+	}
+	if resp.AppHash != "" {
+		if p.AppHash, err = hex.DecodeString(resp.AppHash); err != nil {
+			return nil, fmt.Errorf("cannot decode app hash: (%s)", err)
+		}
+	}
+	return p, nil
+}
+
+// VerifyEnvelopeProof verifies offline that the envelope of p is the vote identified by pid
+// and nullifier and that it is part of the vochain state committed by trustedAppHash, using
+// the proof operators of vochain.ProofRuntime. It returns the decoded vote.
+// trustedAppHash is required and must be the AppHash of a verified block header at p.Height
+// (the block after the one which produced the state), never the AppHash reported by the
+// gateway on p.AppHash.
+func VerifyEnvelopeProof(pid, nullifier []byte, p *EnvelopeProof, trustedAppHash []byte) (*types.Vote, error) {
+	if p == nil {
+		return nil, fmt.Errorf("envelope proof is nil")
+	}
+	if len(trustedAppHash) == 0 {
+		return nil, fmt.Errorf("a trusted app hash is required to verify the envelope proof")
+	}
+	key := make([]byte, 0, len(pid)+len(nullifier))
+	key = append(key, pid...)
+	key = append(key, nullifier...)
+
+	// verify the envelope against the VoteTree root and the roots against the AppHash
+	proof := &merkle.Proof{Ops: []merkle.ProofOp{
+		{Type: p.ProofType, Key: key, Data: p.Proof},
+		vochain.NewStateRootsOp(vochain.VoteTree, p.StateRoots).ProofOp(),
+	}}
+	if err := vochain.ProofRuntime().VerifyValue(proof, trustedAppHash,
+		vochain.ProofKeyPath(vochain.VoteTree, key), p.Envelope); err != nil {
+		return nil, fmt.Errorf("envelope proof is not valid: (%s)", err)
+	}
+
+	var vote types.Vote
+	if err := amino.NewCodec().UnmarshalBinaryBare(p.Envelope, &vote); err != nil {
+		return nil, fmt.Errorf("cannot decode envelope: (%s)", err)
+	}
+	if !bytes.Equal(vote.ProcessID, pid) || !bytes.Equal(vote.Nullifier, nullifier) {
+		return nil, fmt.Errorf("envelope does not match processId and nullifier")
+	}
+	return &vote, nil
+}
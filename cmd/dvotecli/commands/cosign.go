@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
+	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/util"
+	"gitlab.com/vocdoni/go-dvote/vochain"
+)
+
+var cosignCmd = &cobra.Command{
+	Use:   "cosign",
+	Short: "Add an oracle signature to a vochain transaction",
+	Long: `Add an oracle signature to a vochain transaction (adminTx, newProcess, cancelProcess,
+pauseProcess, resumeProcess or updateCensus).
+
+If the transaction is not signed yet, the signature field is set. Otherwise the new
+signature is appended to the signatures field. All the oracles sign the same bytes, so
+the transaction can be passed around and co-signed offline until it has enough
+signatures to reach the oracle threshold of its class.
+Signed transactions can be sent to a gateway using the submitRawTx method.`,
+	RunE: cosign,
+}
+
+func init() {
+	rootCmd.AddCommand(cosignCmd)
+	cosignCmd.Flags().String("key", "", "hex encoded oracle private key (required)")
+	cosignCmd.Flags().String("tx", "-", "file containing the JSON transaction, - reads from stdin")
+	cosignCmd.Flags().String("out", "", "file where the co-signed transaction is written (default stdout)")
+	cosignCmd.MarkFlagRequired("key")
+}
+
+func cosign(cmd *cobra.Command, args []string) error {
+	key, _ := cmd.Flags().GetString("key")
+	txFile, _ := cmd.Flags().GetString("tx")
+	outFile, _ := cmd.Flags().GetString("out")
+
+	signKeys := ethereum.NewSignKeys()
+	if err := signKeys.AddHexKey(util.TrimHex(key)); err != nil {
+		return fmt.Errorf("cannot import private key: (%s)", err)
+	}
+
+	var txBytes []byte
+	var err error
+	if txFile == "-" {
+		txBytes, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		txBytes, err = ioutil.ReadFile(txFile)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read transaction: (%s)", err)
+	}
+
+	// get the bytes signed by the oracles
+	tx, err := vochain.UnmarshalTx(txBytes)
+	if err != nil {
+		return err
+	}
+	var signedBytes []byte
+	var signatures []string
+	switch t := tx.(type) {
+	case *types.AdminTx:
+		signedBytes, signatures = t.SignedBytes, append([]string{t.Signature}, t.Signatures...)
+	case *types.NewProcessTx:
+		signedBytes, signatures = t.SignedBytes, append([]string{t.Signature}, t.Signatures...)
+	case *types.CancelProcessTx:
+		signedBytes, signatures = t.SignedBytes, append([]string{t.Signature}, t.Signatures...)
+	case *types.PauseProcessTx:
+		signedBytes, signatures = t.SignedBytes, append([]string{t.Signature}, t.Signatures...)
+	case *types.UpdateCensusTx:
+		signedBytes, signatures = t.SignedBytes, append([]string{t.Signature}, t.Signatures...)
+	default:
+		return fmt.Errorf("transaction type %s does not require oracle signatures", tx.TxType())
+	}
+
+	// check the existing signatures and the signers
+	var signers []string
+	for _, s := range signatures {
+		if s == "" {
+			continue
+		}
+		addr, err := ethereum.AddrFromSignature(signedBytes, s)
+		if err != nil {
+			return fmt.Errorf("cannot extract address from signature: (%s)", err)
+		}
+		if addr == signKeys.Address() {
+			return fmt.Errorf("transaction already signed by %s", addr.Hex())
+		}
+		signers = append(signers, addr.Hex())
+	}
+	signature, err := signKeys.Sign(signedBytes)
+	if err != nil {
+		return fmt.Errorf("cannot sign transaction: (%s)", err)
+	}
+	signers = append(signers, signKeys.AddressString())
+
+	// add the signature keeping the rest of the transaction untouched
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(txBytes, &raw); err != nil {
+		return fmt.Errorf("cannot parse transaction: (%s)", err)
+	}
+	if signatures[0] == "" {
+		if raw["signature"], err = json.Marshal(signature); err != nil {
+			return err
+		}
+	} else {
+		if raw["signatures"], err = json.Marshal(append(signatures[1:], signature)); err != nil {
+			return err
+		}
+	}
+	cosigned, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	if outFile != "" {
+		if err := ioutil.WriteFile(outFile, cosigned, 0644); err != nil {
+			return fmt.Errorf("cannot write transaction: (%s)", err)
+		}
+	} else {
+		fmt.Printf("%s\n", cosigned)
+	}
+	fmt.Fprintf(os.Stderr, "Transaction signed by %d oracles:\n", len(signers))
+	for _, s := range signers {
+		fmt.Fprintf(os.Stderr, "  %s\n", s)
+	}
+	return nil
+}
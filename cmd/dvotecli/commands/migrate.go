@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"gitlab.com/vocdoni/go-dvote/vochain"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the vochain state to a different database backend",
+	Long: `Migrate the vochain state to a different database backend (iavl or graviton).
+
+The node must be stopped. The last committed state of dataDir is copied into the
+empty destDir, which can then replace the original state directory.
+Only the last committed state can be migrated: if height is set and it is not the
+last committed height, the migration is refused before opening any database.
+The migration is verified by comparing every key and value of the migrated state
+with the original one, not the app hashes.
+
+WARNING: the migrated state is NOT consensus compatible. Each backend computes
+different tree roots, so the app hash changes after the migration and a migrated
+node stops at the next block, whose header includes the app hash of the original
+backend. The migrated state can only be used on a network where every node switches
+to the new backend at the same height (e.g. a new chain started from that state).
+Validator nodes (whose private validator key, on the config directory next to
+dataDir, is on the state validators list) are refused.`,
+	RunE: migrate,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().String("dataDir", "", "vochain state directory to migrate, usually $HOME/.dvote/vochain/data (required)")
+	migrateCmd.Flags().String("destDir", "", "empty directory where the migrated state will be stored (required)")
+	migrateCmd.Flags().String("from", vochain.StateBackendIavl, "state backend of dataDir (iavl or graviton)")
+	migrateCmd.Flags().String("to", vochain.StateBackendGraviton, "state backend of destDir (iavl or graviton)")
+	migrateCmd.Flags().Int64("height", 0, "last committed height of the state to migrate, any other height is refused (0 skips the check)")
+	migrateCmd.MarkFlagRequired("dataDir")
+	migrateCmd.MarkFlagRequired("destDir")
+}
+
+func migrate(cmd *cobra.Command, args []string) error {
+	dataDir, _ := cmd.Flags().GetString("dataDir")
+	destDir, _ := cmd.Flags().GetString("destDir")
+	from, _ := cmd.Flags().GetString("from")
+	to, _ := cmd.Flags().GetString("to")
+	height, _ := cmd.Flags().GetInt64("height")
+	if from == to {
+		return fmt.Errorf("source and destination backends are the same")
+	}
+
+	m, err := vochain.MigrateState(dataDir, from, destDir, to, height)
+	if err != nil {
+		return err
+	}
+	prettyHeader(fmt.Sprintf("State migrated from %s to %s", from, to))
+	fmt.Printf("Height: %d\n", au.Yellow(m.Height))
+	for _, name := range []string{vochain.AppTree, vochain.ProcessTree, vochain.VoteTree} {
+		fmt.Printf("Tree %s: %d keys\n", name, au.Yellow(m.Keys[name]))
+	}
+	fmt.Printf("App hash (%s): %x\n", from, au.Yellow(m.SourceHash))
+	fmt.Printf("App hash (%s): %x\n", to, au.Yellow(m.DestinationHash))
+	return nil
+}
@@ -28,6 +28,7 @@ import (
 	vnet "gitlab.com/vocdoni/go-dvote/net"
 	"gitlab.com/vocdoni/go-dvote/service"
 	"gitlab.com/vocdoni/go-dvote/vochain"
+	"gitlab.com/vocdoni/go-dvote/vochain/indexer"
 	"gitlab.com/vocdoni/go-dvote/vochain/keykeeper"
 	"gitlab.com/vocdoni/go-dvote/vochain/scrutinizer"
 	"gitlab.com/vocdoni/go-dvote/vochain/vochaininfo"
@@ -70,12 +71,17 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	globalCfg.API.Vote = *flag.Bool("voteApi", true, "enable the vote API")
 	globalCfg.API.Tendermint = *flag.Bool("tendermintApi", true, "make the Tendermint API public available")
 	globalCfg.API.Results = *flag.Bool("resultsApi", true, "enable the results API")
+	globalCfg.API.Indexer = *flag.Bool("indexerApi", false, "enable the transaction indexer API (indexes every vochain transaction on the local database)")
 	globalCfg.API.Route = *flag.String("apiRoute", "/", "dvote API base route for HTTP and Websockets")
 	globalCfg.API.AllowPrivate = *flag.Bool("apiAllowPrivate", false, "allows private methods over the APIs")
 	globalCfg.API.AllowedAddrs = *flag.String("apiAllowedAddrs", "", "comma delimited list of allowed client ETH addresses for private methods")
 	globalCfg.API.ListenHost = *flag.String("listenHost", "0.0.0.0", "API endpoint listen address")
 	globalCfg.API.ListenPort = *flag.Int("listenPort", 9090, "API endpoint http port")
 	globalCfg.API.WebsocketsReadLimit = *flag.Int64("apiWsReadLimit", vnet.Web3WsReadLimit, "dvote websocket API read size limit in bytes")
+	globalCfg.API.EnvelopeLimitIP = *flag.Int("apiEnvelopeLimitIP", 120, "maximum number of envelopes per minute accepted from the same client IP (0 disables the limit)")
+	globalCfg.API.EnvelopeLimitAddress = *flag.Int("apiEnvelopeLimitAddress", 10, "maximum number of envelopes per minute accepted from the same voter (0 disables the limit)")
+	globalCfg.API.EnvelopeLimitProcess = *flag.Int("apiEnvelopeLimitProcess", 0, "maximum number of envelopes per minute accepted for the same process (0 disables the limit)")
+	globalCfg.API.TrustedProxies = *flag.StringArray("apiTrustedProxies", []string{}, "IPs or CIDR networks of the trusted reverse proxies, the client IP of their requests is taken from the X-Forwarded-For header")
 	// ssl
 	globalCfg.API.Ssl.Domain = *flag.String("sslDomain", "", "enable TLS secure domain with LetsEncrypt auto-generated certificate (listenPort=443 is required)")
 	// ethereum node
@@ -116,6 +122,14 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	globalCfg.VochainConfig.MempoolSize = *flag.Int("vochainMempoolSize", 20000, "vochain mempool size")
 	globalCfg.VochainConfig.KeyKeeperIndex = *flag.Int8("keyKeeperIndex", 0, "if this node is a key keeper, use this index slot")
 	globalCfg.VochainConfig.ImportPreviousCensus = *flag.Bool("importPreviousCensus", false, "if enabled the census downloader will import all existing census")
+	globalCfg.VochainConfig.StateBackend = *flag.String("vochainStateBackend", "iavl", "vochain state database backend (iavl or graviton)")
+	globalCfg.VochainConfig.PruneKeepRecent = *flag.Int64("vochainPruneKeepRecent", 0, "number of recent vochain state versions to keep, 0 disables the pruning (only iavl backend)")
+	globalCfg.VochainConfig.PruneKeepEvery = *flag.Int64("vochainPruneKeepEvery", 0, "keep every N vochain state versions as a checkpoint when pruning, 0 means no checkpoints")
+	globalCfg.VochainConfig.SnapshotInterval = *flag.Int64("vochainSnapshotInterval", 0, "create a vochain state snapshot every N blocks, 0 disables it (requires graviton backend)")
+	globalCfg.VochainConfig.SnapshotBootstrap = *flag.String("vochainSnapshotBootstrap", "", "file or ipfs:// URI of the snapshot used to bootstrap an empty vochain state (requires graviton backend, the block store must contain the blocks up to the snapshot height or vochainSnapshotTrustedRPC must be set)")
+	globalCfg.VochainConfig.SnapshotTrustedHeight = *flag.Int64("vochainSnapshotTrustedHeight", 0, "trusted height of the bootstrap snapshot")
+	globalCfg.VochainConfig.SnapshotTrustedHash = *flag.String("vochainSnapshotTrustedHash", "", "trusted app hash of the bootstrap snapshot (the app hash of the next block header)")
+	globalCfg.VochainConfig.SnapshotTrustedRPC = *flag.String("vochainSnapshotTrustedRPC", "", "tendermint RPC endpoint of a trusted vochain node, used to fetch the block and validators at the snapshot height if the block store is empty")
 	// metrics
 	globalCfg.Metrics.Enabled = *flag.Bool("metricsEnabled", false, "enable prometheus metrics")
 	globalCfg.Metrics.RefreshInterval = *flag.Int("metricsRefreshInterval", 5, "metrics refresh interval in seconds")
@@ -159,11 +173,16 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	// api
 	viper.BindPFlag("api.Websockets", flag.Lookup("apiws"))
 	viper.BindPFlag("api.WebsocketsReadLimit", flag.Lookup("apiWsReadLimit"))
+	viper.BindPFlag("api.EnvelopeLimitIP", flag.Lookup("apiEnvelopeLimitIP"))
+	viper.BindPFlag("api.EnvelopeLimitAddress", flag.Lookup("apiEnvelopeLimitAddress"))
+	viper.BindPFlag("api.EnvelopeLimitProcess", flag.Lookup("apiEnvelopeLimitProcess"))
+	viper.BindPFlag("api.TrustedProxies", flag.Lookup("apiTrustedProxies"))
 	viper.BindPFlag("api.Http", flag.Lookup("apihttp"))
 	viper.BindPFlag("api.File", flag.Lookup("fileApi"))
 	viper.BindPFlag("api.Census", flag.Lookup("censusApi"))
 	viper.BindPFlag("api.Vote", flag.Lookup("voteApi"))
 	viper.BindPFlag("api.Results", flag.Lookup("resultsApi"))
+	viper.BindPFlag("api.Indexer", flag.Lookup("indexerApi"))
 	viper.BindPFlag("api.Tendermint", flag.Lookup("tendermintApi"))
 	viper.BindPFlag("api.Route", flag.Lookup("apiRoute"))
 	viper.BindPFlag("api.AllowPrivate", flag.Lookup("apiAllowPrivate"))
@@ -217,6 +236,14 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	viper.BindPFlag("vochainConfig.MempoolSize", flag.Lookup("vochainMempoolSize"))
 	viper.BindPFlag("vochainConfig.KeyKeeperIndex", flag.Lookup("keyKeeperIndex"))
 	viper.BindPFlag("vochainConfig.ImportPreviousCensus", flag.Lookup("importPreviousCensus"))
+	viper.BindPFlag("vochainConfig.StateBackend", flag.Lookup("vochainStateBackend"))
+	viper.BindPFlag("vochainConfig.PruneKeepRecent", flag.Lookup("vochainPruneKeepRecent"))
+	viper.BindPFlag("vochainConfig.PruneKeepEvery", flag.Lookup("vochainPruneKeepEvery"))
+	viper.BindPFlag("vochainConfig.SnapshotInterval", flag.Lookup("vochainSnapshotInterval"))
+	viper.BindPFlag("vochainConfig.SnapshotBootstrap", flag.Lookup("vochainSnapshotBootstrap"))
+	viper.BindPFlag("vochainConfig.SnapshotTrustedHeight", flag.Lookup("vochainSnapshotTrustedHeight"))
+	viper.BindPFlag("vochainConfig.SnapshotTrustedHash", flag.Lookup("vochainSnapshotTrustedHash"))
+	viper.BindPFlag("vochainConfig.SnapshotTrustedRPC", flag.Lookup("vochainSnapshotTrustedRPC"))
 
 	// metrics
 	viper.BindPFlag("metrics.Enabled", flag.Lookup("metricsEnabled"))
@@ -351,6 +378,9 @@ func main() {
 	if !globalCfg.ValidMode() {
 		log.Fatalf("mode %s is invalid", globalCfg.Mode)
 	}
+	if err := globalCfg.VochainConfig.ValidSnapshots(); err != nil {
+		log.Fatal(err)
+	}
 
 	// Expose debugging profiles to localhost under a random unassigned
 	// port. This way, we never leak this information to the internet, and
@@ -378,6 +408,7 @@ func main() {
 	var vnode *vochain.BaseApplication
 	var vinfo *vochaininfo.VochainInfo
 	var sc *scrutinizer.Scrutinizer
+	var idx *indexer.Indexer
 	var kk *keykeeper.KeyKeeper
 	var ma *metrics.Agent
 
@@ -456,7 +487,8 @@ func main() {
 	}
 	if (globalCfg.Mode == "gateway" && globalCfg.API.Vote) || globalCfg.Mode == "miner" || globalCfg.Mode == "oracle" {
 		scrutinizer := (globalCfg.Mode == "gateway" && globalCfg.API.Results)
-		vnode, sc, vinfo, err = service.Vochain(globalCfg.VochainConfig, scrutinizer, !globalCfg.VochainConfig.NoWaitSync, ma, cm)
+		indexTxs := (globalCfg.Mode == "gateway" && globalCfg.API.Indexer)
+		vnode, sc, idx, vinfo, err = service.Vochain(globalCfg.VochainConfig, scrutinizer, indexTxs, !globalCfg.VochainConfig.NoWaitSync, ma, cm, storage)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -560,7 +592,7 @@ func main() {
 	if globalCfg.Mode == "gateway" {
 		// dvote API service
 		if globalCfg.API.File || globalCfg.API.Census || globalCfg.API.Vote {
-			if err := service.API(globalCfg.API, pxy, storage, cm, vnode, sc, vinfo, globalCfg.VochainConfig.RPCListen, signer, ma); err != nil {
+			if err := service.API(globalCfg.API, pxy, storage, cm, vnode, sc, idx, vinfo, globalCfg.VochainConfig.RPCListen, signer, ma); err != nil {
 				log.Fatal(err)
 			}
 		}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+
+	"gitlab.com/vocdoni/go-dvote/crypto/nacl"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Export and import encrypted backups of the keykeeper database",
+	Long: `Export and import encrypted backups of the keykeeper database.
+
+Backups are encrypted with a nacl public key (see backup keys), so the private key is
+only required for restoring them.`,
+}
+
+var backupKeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Generate a nacl key pair for encrypting the backups",
+	RunE:  backupKeys,
+}
+
+var backupExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export an encrypted backup of the keykeeper database",
+	RunE:  backupExport,
+}
+
+var backupImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Restore an encrypted backup of the keykeeper database",
+	Long: `Restore an encrypted backup of the keykeeper database. The backup must belong to
+the same keykeeper key and index. The existing entries of the database are kept: the
+reveals scheduled for the same block are merged, and the backup entries which differ
+from the stored ones (such as the keys of a process) are skipped and reported.`,
+	RunE: backupImport,
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupKeysCmd)
+	backupCmd.AddCommand(backupExportCmd)
+	backupCmd.AddCommand(backupImportCmd)
+	backupExportCmd.Flags().String("pubKey", "", "hex encoded nacl public key used for encrypting the backup (required)")
+	backupExportCmd.Flags().String("out", "", "file where the backup is written (required)")
+	backupExportCmd.MarkFlagRequired("pubKey")
+	backupExportCmd.MarkFlagRequired("out")
+	backupImportCmd.Flags().String("privKey", "", "hex encoded nacl private key used for decrypting the backup (required)")
+	backupImportCmd.Flags().String("in", "", "backup file (required)")
+	backupImportCmd.MarkFlagRequired("privKey")
+	backupImportCmd.MarkFlagRequired("in")
+}
+
+func backupKeys(cmd *cobra.Command, args []string) error {
+	priv, err := nacl.Generate(rand.Reader)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Public Key: %x\n", priv.Public().Bytes())
+	fmt.Printf("Private Key: %x\n", priv.Bytes())
+	return nil
+}
+
+func backupExport(cmd *cobra.Command, args []string) error {
+	pubKey, _ := cmd.Flags().GetString("pubKey")
+	outFile, _ := cmd.Flags().GetString("out")
+	kk, _, err := openKeyKeeper(cmd)
+	if err != nil {
+		return err
+	}
+	defer kk.Close()
+	data, err := kk.ExportBackup(pubKey)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(outFile, data, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("backup written to %s\n", outFile)
+	return nil
+}
+
+func backupImport(cmd *cobra.Command, args []string) error {
+	privKey, _ := cmd.Flags().GetString("privKey")
+	inFile, _ := cmd.Flags().GetString("in")
+	data, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		return fmt.Errorf("cannot read backup: (%s)", err)
+	}
+	kk, _, err := openKeyKeeper(cmd)
+	if err != nil {
+		return err
+	}
+	defer kk.Close()
+	n, conflicts, err := kk.ImportBackup(data, privKey)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d entries restored\n", n)
+	for _, c := range conflicts {
+		fmt.Printf("skipped process %x: %s\n", c.ProcessID, c.Issue)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the keykeeper database against the vochain state",
+	Long: `Check the keykeeper database against the vochain state.
+
+The keys stored on the database must match with the keykeeper key and with the
+encryption and commitment keys published on the processes. The keys published on the
+running processes must be stored and their reveal scheduled, and the keys of the
+finished processes must be revealed. The command fails if any issue is found.`,
+	RunE: check,
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+func check(cmd *cobra.Command, args []string) error {
+	kk, _, err := openKeyKeeper(cmd)
+	if err != nil {
+		return err
+	}
+	defer kk.Close()
+	issues, err := kk.CheckKeys()
+	if err != nil {
+		return err
+	}
+	for _, i := range issues {
+		fmt.Printf("%x %s\n", i.ProcessID, i.Issue)
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%d issues found", len(issues))
+	}
+	fmt.Println("no issues found")
+	return nil
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the pending keykeeper operations",
+}
+
+var listRevealsCmd = &cobra.Command{
+	Use:   "reveals",
+	Short: "List the scheduled reveals of process keys, by block and by time",
+	RunE:  listReveals,
+}
+
+var listUnpublishedCmd = &cobra.Command{
+	Use:   "unpublished",
+	Short: "List the processes requiring keys whose keys were never published by the keykeeper",
+	Long: `List the processes requiring keys, neither canceled nor finished, whose keys were
+never published by the keykeeper. The keys can be published with the publish command
+while the process is not started.`,
+	RunE: listUnpublished,
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.AddCommand(listRevealsCmd)
+	listCmd.AddCommand(listUnpublishedCmd)
+}
+
+func listReveals(cmd *cobra.Command, args []string) error {
+	kk, app, err := openKeyKeeper(cmd)
+	if err != nil {
+		return err
+	}
+	defer kk.Close()
+	reveals, err := kk.ScheduledReveals()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Current height: %d\n", app.State.Header(true).Height)
+	for _, r := range reveals {
+		if r.Height > 0 {
+			fmt.Printf("%x block %d\n", r.ProcessID, r.Height)
+		} else {
+			fmt.Printf("%x time %s\n", r.ProcessID, time.Unix(r.EndTime, 0).UTC().Format(time.RFC3339))
+		}
+	}
+	fmt.Printf("%d scheduled reveals\n", len(reveals))
+	return nil
+}
+
+func listUnpublished(cmd *cobra.Command, args []string) error {
+	kk, app, err := openKeyKeeper(cmd)
+	if err != nil {
+		return err
+	}
+	defer kk.Close()
+	pids, err := kk.UnpublishedKeys()
+	if err != nil {
+		return err
+	}
+	header := app.State.Header(true)
+	for _, pid := range pids {
+		process, err := app.State.Process(pid, true)
+		if err != nil {
+			return err
+		}
+		status := "not started"
+		if process.Started(header.Height, header.Time) {
+			status = "started, keys cannot be published anymore"
+		}
+		fmt.Printf("%x %s %s\n", pid, process.Type, status)
+	}
+	fmt.Printf("%d processes with unpublished keys\n", len(pids))
+	return nil
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+
+	"gitlab.com/vocdoni/go-dvote/client"
+	"gitlab.com/vocdoni/go-dvote/types"
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish <processId>",
+	Short: "Create the transaction publishing the keykeeper keys of a process",
+	Long: `Create the transaction publishing the keykeeper keys of a process, and store the keys
+on the keykeeper database if missing. The keys are deterministic, so they are the same
+the keykeeper would have published. Keys can only be published before the process starts.`,
+	RunE: publish,
+}
+
+var revealCmd = &cobra.Command{
+	Use:   "reveal <processId>",
+	Short: "Create the transaction revealing the keykeeper keys of a process",
+	Long: `Create the transaction revealing the keykeeper keys of a process, even if the reveal
+is not scheduled on the keykeeper database. The transaction is only accepted once the
+process is finished or canceled. On threshold encrypted processes the key share of the
+keykeeper is revealed.`,
+	RunE: reveal,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{publishCmd, revealCmd} {
+		rootCmd.AddCommand(cmd)
+		cmd.Flags().String("gateway", "", "gateway websocket URL where the transaction is sent (default none)")
+		cmd.Flags().String("out", "", "file where the signed transaction is written (default stdout)")
+	}
+}
+
+func publish(cmd *cobra.Command, args []string) error {
+	pid, err := processIDArg(args)
+	if err != nil {
+		return err
+	}
+	kk, _, err := openKeyKeeper(cmd)
+	if err != nil {
+		return err
+	}
+	defer kk.Close()
+	txBytes, err := kk.PublishKeysTx(pid)
+	if err != nil {
+		return err
+	}
+	return submitTx(cmd, txBytes)
+}
+
+func reveal(cmd *cobra.Command, args []string) error {
+	pid, err := processIDArg(args)
+	if err != nil {
+		return err
+	}
+	kk, _, err := openKeyKeeper(cmd)
+	if err != nil {
+		return err
+	}
+	defer kk.Close()
+	txBytes, err := kk.RevealKeysTx(pid)
+	if err != nil {
+		return err
+	}
+	return submitTx(cmd, txBytes)
+}
+
+// submitTx sends a signed transaction to the gateway if provided, otherwise it is written
+// to the output file
+func submitTx(cmd *cobra.Command, txBytes []byte) error {
+	gateway, _ := cmd.Flags().GetString("gateway")
+	outFile, _ := cmd.Flags().GetString("out")
+	if gateway == "" {
+		if outFile == "" {
+			fmt.Println(string(txBytes))
+			return nil
+		}
+		return ioutil.WriteFile(outFile, txBytes, 0600)
+	}
+	c, err := client.New(gateway)
+	if err != nil {
+		return fmt.Errorf("cannot connect to gateway: (%s)", err)
+	}
+	defer c.Close()
+	req := types.MetaRequest{Method: "submitRawTx", RawTx: base64.StdEncoding.EncodeToString(txBytes)}
+	resp, err := c.Request(req, nil)
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("%s failed: %s", req.Method, resp.Message)
+	}
+	fmt.Println("transaction sent")
+	return nil
+}
@@ -0,0 +1,233 @@
+package commands
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gitlab.com/vocdoni/go-dvote/crypto"
+	"gitlab.com/vocdoni/go-dvote/crypto/nacl"
+	"gitlab.com/vocdoni/go-dvote/crypto/threshold"
+	"gitlab.com/vocdoni/go-dvote/log"
+	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/vochain"
+)
+
+const (
+	MaxQuestions = types.MaxQuestions
+	MaxOptions   = types.MaxOptions
+)
+
+type ProcessVotes [][]uint64
+
+var resultsCmd = &cobra.Command{
+	Use:   "results <processId>",
+	Short: "Compute the results of an encrypted process without waiting for the key reveals",
+	Long: `Compute the results of an encrypted process without waiting for the key reveals.
+
+The encryption keys revealed on the vochain are used, together with the key of this
+keykeeper and the keys of other keykeepers provided with --keys. On threshold encrypted
+processes the keys are key shares, and the encryption key is recovered once there are
+enough of them.`,
+	RunE: results,
+}
+
+func init() {
+	rootCmd.AddCommand(resultsCmd)
+	resultsCmd.Flags().StringSlice("keys", nil, "encryption private keys (or key shares) of other keykeepers, as index:hexKey")
+}
+
+func results(cmd *cobra.Command, args []string) error {
+	pid, err := processIDArg(args)
+	if err != nil {
+		return err
+	}
+	extraKeys, _ := cmd.Flags().GetStringSlice("keys")
+	kk, app, err := openKeyKeeper(cmd)
+	if err != nil {
+		return err
+	}
+	defer kk.Close()
+	process, err := app.State.Process(pid, true)
+	if err != nil {
+		return err
+	}
+	if !process.IsEncrypted() {
+		return fmt.Errorf("process is not encrypted, its results are live")
+	}
+
+	index, _ := cmd.Flags().GetInt8("index")
+	if process.EncryptionPrivateKeys[index] == "" {
+		if process.EncryptionPrivateKeys[index], err = kk.EncryptionPrivateKey(pid); err != nil {
+			return err
+		}
+	}
+	for _, k := range extraKeys {
+		ksp := strings.Split(k, ":")
+		if len(ksp) != 2 {
+			return fmt.Errorf("key malformed (%s)", k)
+		}
+		i, err := strconv.Atoi(ksp[0])
+		if err != nil || i < 1 || i >= types.MaxKeyIndex {
+			return fmt.Errorf("invalid key index (%s)", ksp[0])
+		}
+		process.EncryptionPrivateKeys[i] = ksp[1]
+	}
+	if process.IsThresholdEncrypted() && process.EncryptionPrivateKeys[types.ThresholdKeyIndex] == "" {
+		if process.EncryptionPrivateKeys[types.ThresholdKeyIndex], err = combineKeyShares(process); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("computing results for %x", pid)
+	votes, err := computeNonLiveResults(pid, process, app.State)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Results: %v\n", votes)
+	return nil
+}
+
+// combineKeyShares recovers the threshold encryption private key from the key shares
+func combineKeyShares(process *types.Process) (string, error) {
+	shares := make(map[int]*threshold.Scalar)
+	for i, k := range process.EncryptionPrivateKeys {
+		if i == types.ThresholdKeyIndex || k == "" {
+			continue
+		}
+		share, err := threshold.DecodeScalar(k)
+		if err != nil {
+			return "", fmt.Errorf("cannot decode key share %d: (%s)", i, err)
+		}
+		shares[i] = share
+	}
+	if len(shares) < int(process.KeyThreshold) {
+		return "", fmt.Errorf("%d key shares available, %d required", len(shares), process.KeyThreshold)
+	}
+	secret, err := threshold.Combine(shares)
+	if err != nil {
+		return "", err
+	}
+	return threshold.EncodeScalar(secret), nil
+}
+
+func emptyProcess() ProcessVotes {
+	pv := make(ProcessVotes, MaxQuestions)
+	for i := range pv {
+		pv[i] = make([]uint64, MaxOptions)
+	}
+	return pv
+}
+
+func computeNonLiveResults(pid []byte, p *types.Process, s *vochain.State) (pv ProcessVotes, err error) {
+	pv = emptyProcess()
+	var nvotes int
+	for _, e := range s.EnvelopeList(pid, 0, 32<<18, true) { // 8.3M seems enough for now
+		v, err := s.Envelope(pid, e, true)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		var vp *types.VotePackage
+		err = nil
+		if len(p.EncryptionPrivateKeys) < len(v.EncryptionKeyIndexes) {
+			err = fmt.Errorf("encryptionKeyIndexes has too many fields")
+		} else {
+			keys := []crypto.Cipher{}
+			for _, k := range v.EncryptionKeyIndexes {
+				if k >= types.MaxKeyIndex {
+					err = fmt.Errorf("key index overflow")
+					break
+				}
+				priv, err := decodeEncryptionKey(p, k)
+				if err != nil {
+					log.Warnf("cannot create private key cipher: (%s)", err)
+					continue
+				}
+				keys = append(keys, priv)
+			}
+			if len(keys) == 0 || err != nil {
+				err = fmt.Errorf("no keys provided or wrong index")
+			} else {
+				vp, err = unmarshalVote(v.VotePackage, keys)
+			}
+		}
+		if err == nil {
+			err = p.Ballot().CheckVotes(vp.Votes)
+		}
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		for question, opt := range vp.Votes {
+			pv[question][opt] += v.TallyWeight()
+		}
+		nvotes++
+	}
+	pruneVoteResult(&pv)
+	log.Infof("computed results for process %x with %d votes", pid, nvotes)
+	return
+}
+
+// decodeEncryptionKey decodes an encryption private key of a process, the threshold encryption
+// key cannot be used as a regular nacl key (see crypto/threshold)
+func decodeEncryptionKey(p *types.Process, index int) (crypto.Cipher, error) {
+	if p.IsThresholdEncrypted() && index == types.ThresholdKeyIndex {
+		return threshold.DecodePrivate(p.EncryptionPrivateKeys[index])
+	}
+	return nacl.DecodePrivate(p.EncryptionPrivateKeys[index])
+}
+
+func unmarshalVote(votePackage string, keys []crypto.Cipher) (*types.VotePackage, error) {
+	rawVote, err := base64.StdEncoding.DecodeString(votePackage)
+	if err != nil {
+		return nil, err
+	}
+	var vote types.VotePackage
+	// if encryption keys, decrypt the vote
+	for i := len(keys) - 1; i >= 0; i-- {
+		if rawVote, err = keys[i].Decrypt(rawVote); err != nil {
+			log.Warnf("cannot decrypt vote with index key %d", i)
+		}
+	}
+	if err := json.Unmarshal(rawVote, &vote); err != nil {
+		return nil, err
+	}
+	return &vote, nil
+}
+
+func pruneVoteResult(pv *ProcessVotes) {
+	pvv := *pv
+	var pvc ProcessVotes
+	min := MaxQuestions - 1
+	for ; min >= 0; min-- { // find the real size of first dimension (questions with some answer)
+		j := 0
+		for ; j < MaxOptions; j++ {
+			if pvv[min][j] != 0 {
+				break
+			}
+		}
+		if j < MaxOptions {
+			break
+		} // we found a non-empty question, this is the min. Stop iteration.
+	}
+
+	for i := 0; i <= min; i++ { // copy the options for each question but pruning options too
+		pvc = make([][]uint64, i+1)
+		for i2 := 0; i2 <= i; i2++ { // copy only the first non-zero values
+			j2 := MaxOptions - 1
+			for ; j2 >= 0; j2-- {
+				if pvv[i2][j2] != 0 {
+					break
+				}
+			}
+			pvc[i2] = make([]uint64, j2+1)
+			copy(pvc[i2], pvv[i2])
+		}
+	}
+	*pv = pvc
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
+	"gitlab.com/vocdoni/go-dvote/log"
+	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/util"
+	"gitlab.com/vocdoni/go-dvote/vochain"
+	"gitlab.com/vocdoni/go-dvote/vochain/keykeeper"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "keykeepercli",
+	Short: "keykeeper administration and recovery tool.",
+	Long: `keykeeper administration and recovery tool.
+
+It works on the keykeeper database and the vochain state of a stopped dvotenode,
+so the node must be stopped before running any command. The transactions created
+by the publish and reveal commands are signed with the keykeeper key, and can be
+sent to a gateway using the submitRawTx method.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		log.Init("warn", "stderr")
+	},
+}
+
+func init() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	rootCmd.PersistentFlags().String("dataDir", home+"/.dvote", "dvotenode data directory")
+	rootCmd.PersistentFlags().Bool("dev", false, "use the development network data directory")
+	rootCmd.PersistentFlags().String("stateBackend", vochain.StateBackendIavl, "vochain state backend (iavl or graviton)")
+	rootCmd.PersistentFlags().String("key", "", "hex encoded keykeeper (oracle) private key (required)")
+	rootCmd.PersistentFlags().Int8("index", 0, "keykeeper index (required)")
+}
+
+// Execute ...
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// openKeyKeeper opens the vochain state and the keykeeper database of the data directory
+func openKeyKeeper(cmd *cobra.Command) (*keykeeper.KeyKeeper, *vochain.BaseApplication, error) {
+	dataDir, _ := cmd.Flags().GetString("dataDir")
+	dev, _ := cmd.Flags().GetBool("dev")
+	stateBackend, _ := cmd.Flags().GetString("stateBackend")
+	key, _ := cmd.Flags().GetString("key")
+	index, _ := cmd.Flags().GetInt8("index")
+	if key == "" || index < 1 || index >= types.MaxKeyIndex {
+		return nil, nil, fmt.Errorf("the keykeeper key and index are required")
+	}
+	if dev {
+		dataDir += "/dev"
+	}
+
+	signer := ethereum.NewSignKeys()
+	if err := signer.AddHexKey(util.TrimHex(key)); err != nil {
+		return nil, nil, fmt.Errorf("cannot import private key: (%s)", err)
+	}
+	app, err := vochain.NewBaseApplication(dataDir+"/vochain/data", stateBackend)
+	if err != nil {
+		return nil, nil, err
+	}
+	if app.State.Header(true) == nil {
+		return nil, nil, fmt.Errorf("vochain state on %s is empty", dataDir)
+	}
+	kk, err := keykeeper.OpenKeyKeeper(dataDir+"/vochain/keykeeper", app, signer, index)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open keykeeper database, is the node stopped? (%s)", err)
+	}
+	return kk, app, nil
+}
+
+// processIDArg decodes the process ID passed as the only argument of a command
+func processIDArg(args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("a process ID is required")
+	}
+	pid, err := hex.DecodeString(util.TrimHex(args[0]))
+	if err != nil || len(pid) != types.ProcessIDsize {
+		return nil, fmt.Errorf("malformed process ID %s", args[0])
+	}
+	return pid, nil
+}
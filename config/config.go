@@ -1,5 +1,7 @@
 package config
 
+import "fmt"
+
 // DvoteCfg stores global configs for dvote
 type DvoteCfg struct {
 	// W3Config ethereum config options
@@ -49,6 +51,20 @@ func (c *DvoteCfg) ValidMode() bool {
 	return true
 }
 
+// ValidSnapshots checks the vochain snapshot options, snapshots are only supported by the graviton state backend
+func (c *VochainCfg) ValidSnapshots() error {
+	if c.SnapshotInterval <= 0 && c.SnapshotBootstrap == "" {
+		return nil
+	}
+	if c.StateBackend != "graviton" {
+		return fmt.Errorf("vochain snapshots require the graviton state backend, %s configured", c.StateBackend)
+	}
+	if c.SnapshotBootstrap != "" && (c.SnapshotTrustedHeight <= 0 || c.SnapshotTrustedHash == "") {
+		return fmt.Errorf("vochain snapshot bootstrap requires a trusted height and app hash")
+	}
+	return nil
+}
+
 // NewGatewayConfig initializes the fields in the gateway config stuct
 func NewConfig() *DvoteCfg {
 	return &DvoteCfg{
@@ -70,6 +86,8 @@ type API struct {
 	Tendermint bool
 	Vote       bool
 	Results    bool
+	// Indexer enables the transaction indexer API (getTxByHash, getBlockTxs...)
+	Indexer bool
 	// AllowPrivate allow to use private methods
 	AllowPrivate bool
 	// AllowedAddrs allowed addresses to interact with
@@ -88,6 +106,15 @@ type API struct {
 	WebsocketsReadLimit int64
 	// Enable HTTP API
 	HTTP bool
+	// EnvelopeLimitIP is the maximum number of envelopes per minute accepted from the same client IP (0 disables the limit)
+	EnvelopeLimitIP int
+	// EnvelopeLimitAddress is the maximum number of envelopes per minute accepted from the same voter (0 disables the limit)
+	EnvelopeLimitAddress int
+	// EnvelopeLimitProcess is the maximum number of envelopes per minute accepted for the same process (0 disables the limit)
+	EnvelopeLimitProcess int
+	// TrustedProxies are the IPs or CIDR networks of the reverse proxies in front of the API, the client IP
+	// of their requests is taken from the X-Forwarded-For header
+	TrustedProxies []string
 }
 
 // IPFSCfg includes all possible config params needed by IPFS
@@ -190,6 +217,25 @@ type VochainCfg struct {
 	ImportPreviousCensus bool
 	// Enable Prometheus metrics from tendermint
 	TendermintMetrics bool
+	// StateBackend is the database used for the vochain state (iavl or graviton)
+	StateBackend string
+	// PruneKeepRecent is the number of recent state versions kept by the iavl backend, zero disables the pruning
+	PruneKeepRecent int64
+	// PruneKeepEvery keeps every PruneKeepEvery state version as a checkpoint when pruning, zero means no checkpoints
+	PruneKeepEvery int64
+	// SnapshotInterval is the number of blocks between state snapshots, zero disables them (requires graviton)
+	SnapshotInterval int64
+	// SnapshotBootstrap is the local file or remote storage URI of the snapshot used to bootstrap an empty state.
+	// Snapshots do not include the blocks, the block store must already be at the snapshot height
+	// or SnapshotTrustedRPC must be set.
+	SnapshotBootstrap string
+	// SnapshotTrustedHeight is the height of the bootstrap snapshot
+	SnapshotTrustedHeight int64
+	// SnapshotTrustedHash is the hex encoded app hash of the bootstrap snapshot
+	SnapshotTrustedHash string
+	// SnapshotTrustedRPC is the Tendermint RPC endpoint of a trusted vochain node, used to fetch the
+	// block and validators at the snapshot height when the block store is empty
+	SnapshotTrustedRPC string
 }
 
 // OracleCfg includes all possible config params needed by the Oracle
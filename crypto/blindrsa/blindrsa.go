@@ -0,0 +1,105 @@
+// Package blindrsa implements Chaum RSA blind signatures with a full domain hash.
+// The signer cannot link the signatures it produces with the messages that have been
+// blinded by the requester, while anyone can verify them as regular RSA-FDH signatures.
+package blindrsa
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// MinKeyBits is the minimum size of the RSA modulus
+const MinKeyBits = 2048
+
+// hashToInt returns the full domain hash of message: sha256(counter ++ message) blocks are
+// concatenated until the size of the modulus, and the result is reduced modulo N.
+func hashToInt(pub *rsa.PublicKey, message []byte) *big.Int {
+	size := (pub.N.BitLen() + 7) / 8
+	digest := make([]byte, 0, size+sha256.Size)
+	var counter [4]byte
+	for i := uint32(0); len(digest) < size; i++ {
+		binary.BigEndian.PutUint32(counter[:], i)
+		h := sha256.New()
+		h.Write(counter[:])
+		h.Write(message)
+		digest = h.Sum(digest)
+	}
+	return new(big.Int).Mod(new(big.Int).SetBytes(digest[:size]), pub.N)
+}
+
+// Blind blinds message for being signed by the owner of pub. Returns the blinded message, to be
+// sent to the signer, and the unblinder, which must be kept secret for unblinding the signature.
+func Blind(pub *rsa.PublicKey, message []byte) (blinded []byte, unblinder *big.Int, err error) {
+	one := big.NewInt(1)
+	for {
+		r, err := cryptorand.Int(cryptorand.Reader, pub.N)
+		if err != nil {
+			return nil, nil, err
+		}
+		if r.Cmp(one) <= 0 || new(big.Int).GCD(nil, nil, r, pub.N).Cmp(one) != 0 {
+			continue
+		}
+		// blinded = H(m) * r^e mod N
+		m := new(big.Int).Exp(r, big.NewInt(int64(pub.E)), pub.N)
+		m.Mul(m, hashToInt(pub, message)).Mod(m, pub.N)
+		return m.Bytes(), r, nil
+	}
+}
+
+// BlindSign signs a blinded message. The signer learns nothing about the original message.
+func BlindSign(priv *rsa.PrivateKey, blinded []byte) ([]byte, error) {
+	m := new(big.Int).SetBytes(blinded)
+	if m.Sign() == 0 || m.Cmp(priv.N) >= 0 {
+		return nil, fmt.Errorf("blinded message out of range")
+	}
+	return new(big.Int).Exp(m, priv.D, priv.N).Bytes(), nil
+}
+
+// Unblind returns the signature of the original message from the signature of the blinded one
+func Unblind(pub *rsa.PublicKey, blindSignature []byte, unblinder *big.Int) ([]byte, error) {
+	rInv := new(big.Int).ModInverse(unblinder, pub.N)
+	if rInv == nil {
+		return nil, fmt.Errorf("invalid unblinder")
+	}
+	s := new(big.Int).SetBytes(blindSignature)
+	return s.Mul(s, rInv).Mod(s, pub.N).Bytes(), nil
+}
+
+// Verify checks the (unblinded) signature of message
+func Verify(pub *rsa.PublicKey, message, signature []byte) error {
+	s := new(big.Int).SetBytes(signature)
+	if s.Sign() == 0 || s.Cmp(pub.N) >= 0 {
+		return fmt.Errorf("signature out of range")
+	}
+	if new(big.Int).Exp(s, big.NewInt(int64(pub.E)), pub.N).Cmp(hashToInt(pub, message)) != 0 {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// EncodePublicKey returns the hexadecimal PKCS #1 encoding of pub
+func EncodePublicKey(pub *rsa.PublicKey) string {
+	return hex.EncodeToString(x509.MarshalPKCS1PublicKey(pub))
+}
+
+// DecodePublicKey parses an hexadecimal PKCS #1 encoded public key
+func DecodePublicKey(pubHex string) (*rsa.PublicKey, error) {
+	der, err := hex.DecodeString(pubHex)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.ParsePKCS1PublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	if pub.N.BitLen() < MinKeyBits {
+		return nil, fmt.Errorf("key too small, at least %d bits are required", MinKeyBits)
+	}
+	return pub, nil
+}
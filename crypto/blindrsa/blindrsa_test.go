@@ -0,0 +1,61 @@
+package blindrsa
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestBlindSignature(t *testing.T) {
+	t.Parallel()
+
+	priv, err := rsa.GenerateKey(cryptorand.Reader, MinKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := DecodePublicKey(EncodePublicKey(&priv.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := []byte("process and voter")
+
+	blinded, unblinder, err := Blind(pub, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blindSignature, err := BlindSign(priv, blinded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the blind signature is not a valid signature of the message
+	if err := Verify(pub, message, blindSignature); err == nil {
+		t.Fatal("blind signature accepted before unblinding")
+	}
+	signature, err := Unblind(pub, blindSignature, unblinder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(pub, message, signature); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(pub, []byte("another message"), signature); err == nil {
+		t.Fatal("signature accepted for another message")
+	}
+
+	// blinding the same message twice must produce unlinkable blinded messages
+	blinded2, _, err := Blind(pub, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blinded) == string(blinded2) {
+		t.Fatal("blinded messages are linkable")
+	}
+
+	small, err := rsa.GenerateKey(cryptorand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodePublicKey(EncodePublicKey(&small.PublicKey)); err == nil {
+		t.Fatal("small keys must be rejected")
+	}
+}
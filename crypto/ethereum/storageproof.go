@@ -0,0 +1,80 @@
+package ethereum
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// emptyCodeHash is the code hash of the Ethereum accounts without code
+var emptyCodeHash = ethcrypto.Keccak256(nil)
+
+// stateAccount is the RLP encoded Ethereum account stored on the state trie
+type stateAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     ethcommon.Hash
+	CodeHash []byte
+}
+
+// TokenBalanceSlot returns the storage key of the holder balance on a token contract whose balances
+// mapping is declared at the storage index slot, following the solidity storage layout:
+// keccak256(leftPad32(holder) ++ leftPad32(slot))
+func TokenBalanceSlot(holder ethcommon.Address, slot uint64) ethcommon.Hash {
+	return ethcrypto.Keccak256Hash(
+		ethcommon.LeftPadBytes(holder.Bytes(), 32),
+		ethcommon.LeftPadBytes(new(big.Int).SetUint64(slot).Bytes(), 32),
+	)
+}
+
+// VerifyTokenBalance verifies the storage proof of the holder balance on a token contract, as returned
+// by eth_getProof (accountProof and storageProof nodes), against an Ethereum state root.
+// Returns the proven balance, which is zero if the proof shows the holder has no balance.
+func VerifyTokenBalance(stateRoot ethcommon.Hash, token, holder ethcommon.Address, slot uint64,
+	accountProof, storageProof [][]byte) (*big.Int, error) {
+	accountRLP, err := verifyProof(stateRoot, ethcrypto.Keccak256(token.Bytes()), accountProof)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account proof: (%s)", err)
+	}
+	if len(accountRLP) == 0 {
+		return nil, fmt.Errorf("token account does not exist")
+	}
+	var account stateAccount
+	if err := rlp.DecodeBytes(accountRLP, &account); err != nil {
+		return nil, fmt.Errorf("cannot decode token account: (%s)", err)
+	}
+	if bytes.Equal(account.CodeHash, emptyCodeHash) {
+		return nil, fmt.Errorf("token address is not a contract")
+	}
+	key := TokenBalanceSlot(holder, slot)
+	valueRLP, err := verifyProof(account.Root, ethcrypto.Keccak256(key.Bytes()), storageProof)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage proof: (%s)", err)
+	}
+	if len(valueRLP) == 0 {
+		return new(big.Int), nil
+	}
+	var value []byte
+	if err := rlp.DecodeBytes(valueRLP, &value); err != nil {
+		return nil, fmt.Errorf("cannot decode storage value: (%s)", err)
+	}
+	return new(big.Int).SetBytes(value), nil
+}
+
+// verifyProof checks a merkle patricia trie proof of key against root and returns the proven value
+func verifyProof(root ethcommon.Hash, key []byte, proof [][]byte) ([]byte, error) {
+	db := memorydb.New()
+	for _, node := range proof {
+		if err := db.Put(ethcrypto.Keccak256(node), node); err != nil {
+			return nil, err
+		}
+	}
+	value, _, err := trie.VerifyProof(root, key, db)
+	return value, err
+}
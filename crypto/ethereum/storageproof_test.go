@@ -0,0 +1,78 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestVerifyTokenBalance(t *testing.T) {
+	t.Parallel()
+
+	token := ethcommon.HexToAddress("0x2b7b1d1e0ae2d09e0a19a4c0fd6c2c5b68b1e3a1")
+	holder := ethcommon.HexToAddress("0x06d0d2c41f4560f8ffea1285f44ce0ffa2e19ef0")
+	other := ethcommon.HexToAddress("0x0fa7a3fdb5c7c611646a535bdde669db64dc03d2")
+	balance, _ := new(big.Int).SetString("1500000000000000000000", 10)
+	const slot = 3
+
+	// an Ethereum block whose state contains the token balances mapping at slot
+	db := rawdb.NewMemoryDatabase()
+	genesis := core.Genesis{
+		Config: params.AllEthashProtocolChanges,
+		Alloc: core.GenesisAlloc{
+			token: {
+				Balance: new(big.Int),
+				Code:    []byte{0x60, 0x00},
+				Storage: map[ethcommon.Hash]ethcommon.Hash{
+					TokenBalanceSlot(holder, slot): ethcommon.BigToHash(balance),
+				},
+			},
+		},
+	}
+	root := genesis.MustCommit(db).Root()
+	statedb, err := state.New(root, state.NewDatabase(db), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs := func(addr ethcommon.Address) ([][]byte, [][]byte) {
+		accountProof, err := statedb.GetProof(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		storageProof, err := statedb.GetStorageProof(token, TokenBalanceSlot(addr, slot))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return accountProof, storageProof
+	}
+
+	accountProof, storageProof := proofs(holder)
+	got, err := VerifyTokenBalance(root, token, holder, slot, accountProof, storageProof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(balance) != 0 {
+		t.Fatalf("expected balance %s, got %s", balance, got)
+	}
+	// the proof of a holder cannot be used by another address or for another slot
+	if got, err := VerifyTokenBalance(root, token, other, slot, accountProof, storageProof); err == nil && got.Sign() != 0 {
+		t.Fatalf("proof of %s accepted for %s", holder.Hex(), other.Hex())
+	}
+	if got, err := VerifyTokenBalance(root, token, holder, slot+1, accountProof, storageProof); err == nil && got.Sign() != 0 {
+		t.Fatal("proof accepted for another storage slot")
+	}
+	// non holders prove a zero balance
+	accountProof, storageProof = proofs(other)
+	if got, err := VerifyTokenBalance(root, token, other, slot, accountProof, storageProof); err != nil || got.Sign() != 0 {
+		t.Fatalf("expected zero balance, got %v (%v)", got, err)
+	}
+	// the proof must match the state root
+	if _, err := VerifyTokenBalance(ethcommon.Hash{1}, token, holder, slot, accountProof, storageProof); err == nil {
+		t.Fatal("proof accepted for another state root")
+	}
+}
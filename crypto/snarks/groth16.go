@@ -0,0 +1,163 @@
+package snarks
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto/bn256"
+
+	"gitlab.com/vocdoni/go-dvote/types"
+)
+
+// FieldSize is the order of the BN254 scalar field. All the public inputs of
+// a circuit must be lower than this value.
+var FieldSize, _ = new(big.Int).SetString(
+	"21888242871839275222246405745257275088548364400416034343698204186575808495617", 10)
+
+// verificationKey is the parsed form of a types.ZkVerificationKey
+type verificationKey struct {
+	alpha *bn256.G1
+	beta  *bn256.G2
+	gamma *bn256.G2
+	delta *bn256.G2
+	ic    []*bn256.G1
+}
+
+// ValidateVerificationKey checks that vk is a well formed Groth16 verification
+// key for a circuit with nPublic public inputs.
+func ValidateVerificationKey(vk *types.ZkVerificationKey, nPublic int) error {
+	pvk, err := parseVerificationKey(vk)
+	if err != nil {
+		return err
+	}
+	if len(pvk.ic) != nPublic+1 {
+		return fmt.Errorf("verification key expects %d public inputs, not %d", len(pvk.ic)-1, nPublic)
+	}
+	return nil
+}
+
+// VerifyGroth16 verifies a Groth16 zk-SNARK proof over the BN254 curve for
+// the given verification key and public inputs. Keys and proofs follow the
+// snarkjs JSON format.
+func VerifyGroth16(vk *types.ZkVerificationKey, proof *types.ZkProof, inputs []*big.Int) (bool, error) {
+	pvk, err := parseVerificationKey(vk)
+	if err != nil {
+		return false, err
+	}
+	if len(inputs)+1 != len(pvk.ic) {
+		return false, fmt.Errorf("wrong number of public inputs, got %d expected %d", len(inputs), len(pvk.ic)-1)
+	}
+	if proof == nil {
+		return false, fmt.Errorf("proof is nil")
+	}
+	a, err := parseG1(proof.A)
+	if err != nil {
+		return false, fmt.Errorf("cannot parse proof A: (%s)", err)
+	}
+	b, err := parseG2(proof.B)
+	if err != nil {
+		return false, fmt.Errorf("cannot parse proof B: (%s)", err)
+	}
+	c, err := parseG1(proof.C)
+	if err != nil {
+		return false, fmt.Errorf("cannot parse proof C: (%s)", err)
+	}
+
+	// vkX = IC[0] + sum(inputs[i] * IC[i+1])
+	vkX := pvk.ic[0]
+	for i, in := range inputs {
+		if in == nil || in.Sign() < 0 || in.Cmp(FieldSize) >= 0 {
+			return false, fmt.Errorf("public input %d is not a valid field element", i)
+		}
+		vkX = new(bn256.G1).Add(vkX, new(bn256.G1).ScalarMult(pvk.ic[i+1], in))
+	}
+
+	// e(A, B) == e(alpha, beta) * e(vkX, gamma) * e(C, delta)
+	return bn256.PairingCheck(
+		[]*bn256.G1{new(bn256.G1).Neg(a), pvk.alpha, vkX, c},
+		[]*bn256.G2{b, pvk.beta, pvk.gamma, pvk.delta},
+	), nil
+}
+
+func parseVerificationKey(vk *types.ZkVerificationKey) (*verificationKey, error) {
+	if vk == nil {
+		return nil, fmt.Errorf("verification key is nil")
+	}
+	var err error
+	pvk := new(verificationKey)
+	if pvk.alpha, err = parseG1(vk.Alpha); err != nil {
+		return nil, fmt.Errorf("cannot parse alpha: (%s)", err)
+	}
+	if pvk.beta, err = parseG2(vk.Beta); err != nil {
+		return nil, fmt.Errorf("cannot parse beta: (%s)", err)
+	}
+	if pvk.gamma, err = parseG2(vk.Gamma); err != nil {
+		return nil, fmt.Errorf("cannot parse gamma: (%s)", err)
+	}
+	if pvk.delta, err = parseG2(vk.Delta); err != nil {
+		return nil, fmt.Errorf("cannot parse delta: (%s)", err)
+	}
+	if len(vk.IC) < 1 {
+		return nil, fmt.Errorf("verification key without IC points")
+	}
+	pvk.ic = make([]*bn256.G1, len(vk.IC))
+	for i, p := range vk.IC {
+		if pvk.ic[i], err = parseG1(p); err != nil {
+			return nil, fmt.Errorf("cannot parse IC %d: (%s)", i, err)
+		}
+	}
+	return pvk, nil
+}
+
+// parseG1 parses a G1 point given as decimal affine coordinates [x, y, (1)]
+func parseG1(p []string) (*bn256.G1, error) {
+	if len(p) < 2 {
+		return nil, fmt.Errorf("invalid G1 point length %d", len(p))
+	}
+	buf := make([]byte, 64)
+	if err := putCoordinate(buf[:32], p[0]); err != nil {
+		return nil, err
+	}
+	if err := putCoordinate(buf[32:], p[1]); err != nil {
+		return nil, err
+	}
+	g := new(bn256.G1)
+	if _, err := g.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// parseG2 parses a G2 point given as decimal affine coordinates
+// [[x0, x1], [y0, y1], ([1, 0])] where x = x0 + x1*i and y = y0 + y1*i.
+// The bn256 encoding expects the imaginary part first.
+func parseG2(p [][]string) (*bn256.G2, error) {
+	if len(p) < 2 || len(p[0]) != 2 || len(p[1]) != 2 {
+		return nil, fmt.Errorf("invalid G2 point")
+	}
+	buf := make([]byte, 128)
+	for i, c := range []string{p[0][1], p[0][0], p[1][1], p[1][0]} {
+		if err := putCoordinate(buf[i*32:(i+1)*32], c); err != nil {
+			return nil, err
+		}
+	}
+	g := new(bn256.G2)
+	if _, err := g.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// putCoordinate writes the decimal number c as a 32 bytes big-endian integer
+func putCoordinate(dst []byte, c string) error {
+	n, ok := new(big.Int).SetString(c, 10)
+	if !ok || n.Sign() < 0 {
+		return fmt.Errorf("invalid coordinate %q", c)
+	}
+	b := n.Bytes()
+	if len(b) > len(dst) {
+		return fmt.Errorf("coordinate %q overflows", c)
+	}
+	copy(dst[len(dst)-len(b):], b)
+	return nil
+}
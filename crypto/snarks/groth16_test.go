@@ -0,0 +1,118 @@
+package snarks
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto/bn256"
+
+	"gitlab.com/vocdoni/go-dvote/types"
+)
+
+func randScalar(t *testing.T) *big.Int {
+	t.Helper()
+	k, err := rand.Int(rand.Reader, FieldSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func g1ToStrings(g *bn256.G1) []string {
+	b := g.Marshal()
+	return []string{
+		new(big.Int).SetBytes(b[:32]).String(),
+		new(big.Int).SetBytes(b[32:64]).String(),
+		"1",
+	}
+}
+
+func g2ToStrings(g *bn256.G2) [][]string {
+	b := g.Marshal()
+	n := func(i int) string { return new(big.Int).SetBytes(b[i*32 : (i+1)*32]).String() }
+	return [][]string{{n(1), n(0)}, {n(3), n(2)}, {"1", "0"}}
+}
+
+// fakeSetup builds a verification key and a valid proof for the given public
+// inputs by choosing all the exponents, so the pairing equation holds without
+// an actual circuit.
+func fakeSetup(t *testing.T, inputs []*big.Int) (*types.ZkVerificationKey, *types.ZkProof) {
+	t.Helper()
+	mul := func(a, b *big.Int) *big.Int { return new(big.Int).Mod(new(big.Int).Mul(a, b), FieldSize) }
+	alpha, beta, gamma, delta := randScalar(t), randScalar(t), randScalar(t), randScalar(t)
+	ic := make([]*big.Int, len(inputs)+1)
+	for i := range ic {
+		ic[i] = randScalar(t)
+	}
+	x := new(big.Int).Set(ic[0])
+	for i, in := range inputs {
+		x.Add(x, mul(in, ic[i+1]))
+	}
+	x.Mod(x, FieldSize)
+
+	// a*b = alpha*beta + x*gamma + c*delta
+	a, b := randScalar(t), randScalar(t)
+	c := new(big.Int).Sub(mul(a, b), mul(alpha, beta))
+	c.Sub(c, mul(x, gamma))
+	c.Mul(c, new(big.Int).ModInverse(delta, FieldSize))
+	c.Mod(c, FieldSize)
+
+	vk := &types.ZkVerificationKey{
+		Alpha: g1ToStrings(new(bn256.G1).ScalarBaseMult(alpha)),
+		Beta:  g2ToStrings(new(bn256.G2).ScalarBaseMult(beta)),
+		Gamma: g2ToStrings(new(bn256.G2).ScalarBaseMult(gamma)),
+		Delta: g2ToStrings(new(bn256.G2).ScalarBaseMult(delta)),
+	}
+	for _, k := range ic {
+		vk.IC = append(vk.IC, g1ToStrings(new(bn256.G1).ScalarBaseMult(k)))
+	}
+	proof := &types.ZkProof{
+		A: g1ToStrings(new(bn256.G1).ScalarBaseMult(a)),
+		B: g2ToStrings(new(bn256.G2).ScalarBaseMult(b)),
+		C: g1ToStrings(new(bn256.G1).ScalarBaseMult(c)),
+	}
+	return vk, proof
+}
+
+func TestVerifyGroth16(t *testing.T) {
+	t.Parallel()
+
+	inputs := []*big.Int{randScalar(t), randScalar(t), big.NewInt(3)}
+	vk, proof := fakeSetup(t, inputs)
+	if err := ValidateVerificationKey(vk, len(inputs)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateVerificationKey(vk, len(inputs)+1); err == nil {
+		t.Fatal("verification key with wrong number of inputs should fail")
+	}
+
+	valid, err := VerifyGroth16(vk, proof, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Fatal("valid proof not verified")
+	}
+
+	// modified public input
+	inputs[2] = big.NewInt(4)
+	valid, err = VerifyGroth16(vk, proof, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Fatal("proof verified with a wrong public input")
+	}
+
+	// public input out of the field
+	inputs[2] = new(big.Int).Set(FieldSize)
+	if _, err := VerifyGroth16(vk, proof, inputs); err == nil {
+		t.Fatal("public input out of the field should fail")
+	}
+
+	// wrong number of inputs
+	if _, err := VerifyGroth16(vk, proof, inputs[:2]); err == nil {
+		t.Fatal("wrong number of inputs should fail")
+	}
+}
@@ -0,0 +1,104 @@
+package threshold
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/salsa20/salsa"
+
+	"gitlab.com/vocdoni/go-dvote/crypto"
+	"gitlab.com/vocdoni/go-dvote/crypto/nacl"
+)
+
+// x25519 returns the u coordinate of k*P, being u the coordinate of P. Unlike curve25519.X25519,
+// the scalar is not clamped, since the threshold keys are arbitrary scalars of the prime order group.
+// P must belong to the prime order subgroup (such as the nacl ephemeral keys), the scalar is reduced.
+func x25519(k *Scalar, u []byte) ([]byte, error) {
+	// the edwards y coordinate of P is (u - 1) / (u + 1), the sign of x does not change the
+	// resulting u coordinate
+	ue, err := new(field.Element).SetBytes(u)
+	if err != nil {
+		return nil, err
+	}
+	one := new(field.Element).One()
+	y := new(field.Element).Subtract(ue, one)
+	y.Multiply(y, new(field.Element).Invert(new(field.Element).Add(ue, one)))
+	p, err := new(edwards25519.Point).SetBytes(y.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("invalid curve25519 point: (%s)", err)
+	}
+	return new(edwards25519.Point).ScalarMult(k, p).BytesMontgomery(), nil
+}
+
+// Decrypt opens a nacl anonymous box encrypted for the public key of secret (see PublicKey)
+func Decrypt(secret *Scalar, cipher []byte) ([]byte, error) {
+	if len(cipher) < 32+box.Overhead {
+		return nil, fmt.Errorf("cipher too small")
+	}
+	var ephemeralPub, recipientPub, sharedKey [32]byte
+	copy(ephemeralPub[:], cipher[:32])
+	copy(recipientPub[:], ScalarBaseMult(secret).p.BytesMontgomery())
+
+	// same nonce and key derivation than box.OpenAnonymous
+	var nonce [24]byte
+	h, err := blake2b.New(24, nil)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(ephemeralPub[:])
+	h.Write(recipientPub[:])
+	h.Sum(nonce[:0])
+	sharedPoint, err := x25519(secret, ephemeralPub[:])
+	if err != nil {
+		return nil, err
+	}
+	var shared [32]byte
+	copy(shared[:], sharedPoint)
+	var zeros [16]byte
+	salsa.HSalsa20(&sharedKey, &zeros, &shared, &salsa.Sigma)
+
+	message, ok := box.OpenAfterPrecomputation(nil, cipher[32:], &nonce, &sharedKey)
+	if !ok {
+		return nil, fmt.Errorf("could not open box")
+	}
+	return message, nil
+}
+
+// privateKey implements crypto.Cipher for the threshold keys
+type privateKey struct {
+	secret *Scalar
+	pub    crypto.PublicKey
+}
+
+// DecodePrivate decodes a threshold private key (see Combine) from a hexadecimal scalar.
+// Unlike nacl.DecodePrivate, the key is not clamped.
+func DecodePrivate(hexkey string) (crypto.Cipher, error) {
+	secret, err := DecodeScalar(hexkey)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := nacl.DecodePublic(hex.EncodeToString(ScalarBaseMult(secret).p.BytesMontgomery()))
+	if err != nil {
+		return nil, err
+	}
+	return &privateKey{secret: secret, pub: pub}, nil
+}
+
+func (k *privateKey) Bytes() []byte { return k.secret.Bytes() }
+
+func (k *privateKey) Public() crypto.PublicKey { return k.pub }
+
+func (k *privateKey) Encrypt(message []byte, recipient crypto.PublicKey) ([]byte, error) {
+	if recipient == nil {
+		recipient = k.pub
+	}
+	return nacl.Anonymous.Encrypt(message, recipient)
+}
+
+func (k *privateKey) Decrypt(cipher []byte) ([]byte, error) {
+	return Decrypt(k.secret, cipher)
+}
@@ -0,0 +1,156 @@
+package threshold
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+
+	"filippo.io/edwards25519"
+)
+
+// order is the order of the edwards25519 prime subgroup, 2^252 + 27742317777372353535851937790883648493
+var order, _ = new(big.Int).SetString("1000000000000000000000000000000014def9dea2f79cd65812631a5cf5d3ed", 16)
+
+const (
+	// PointSize is the size of an encoded edwards25519 point
+	PointSize = 32
+	// ScalarSize is the size of an encoded scalar
+	ScalarSize = 32
+)
+
+// Scalar is an integer modulo the order of the edwards25519 prime subgroup. The operations
+// over secret scalars (key shares and polynomial coefficients) run in constant time.
+type Scalar = edwards25519.Scalar
+
+// Point is a point of the edwards25519 curve, the birationally equivalent twisted Edwards
+// form of curve25519 used for adding the public keys of the dealers.
+type Point struct {
+	p *edwards25519.Point
+}
+
+// identity returns the neutral element of the edwards25519 group
+func identity() *Point {
+	return &Point{p: edwards25519.NewIdentityPoint()}
+}
+
+// Add returns p + q
+func (p *Point) Add(q *Point) *Point {
+	return &Point{p: new(edwards25519.Point).Add(p.p, q.p)}
+}
+
+// ScalarMult returns k*p
+func (p *Point) ScalarMult(k *Scalar) *Point {
+	return &Point{p: new(edwards25519.Point).ScalarMult(k, p.p)}
+}
+
+// ScalarBaseMult returns k*G, being G the curve25519 base point
+func ScalarBaseMult(k *Scalar) *Point {
+	return &Point{p: new(edwards25519.Point).ScalarBaseMult(k)}
+}
+
+// Equal returns true if p and q are the same point
+func (p *Point) Equal(q *Point) bool {
+	return p.p.Equal(q.p) == 1
+}
+
+// Bytes returns the standard 32 bytes encoding of p: the little endian y coordinate with the
+// parity of x stored on the most significant bit
+func (p *Point) Bytes() []byte {
+	return p.p.Bytes()
+}
+
+// Hex returns the hexadecimal encoding of p
+func (p *Point) Hex() string {
+	return hex.EncodeToString(p.Bytes())
+}
+
+// BoxPublicKey returns the curve25519 (montgomery u coordinate) public key equivalent to p,
+// which can be used for encrypting nacl boxes
+func (p *Point) BoxPublicKey() ([]byte, error) {
+	if p.Equal(identity()) {
+		return nil, fmt.Errorf("the identity point has no public key")
+	}
+	return p.p.BytesMontgomery(), nil
+}
+
+// InPrimeSubgroup returns true if p belongs to the prime order subgroup generated by the base point.
+// Untrusted points (such as the dealer commitments) must be checked, since a small order component
+// would make the key shares unverifiable.
+func (p *Point) InPrimeSubgroup() bool {
+	// (order - 1)*p + p is the identity only for the points of the prime order subgroup
+	minusOne := edwards25519.NewScalar().Negate(newScalar(1))
+	return p.ScalarMult(minusOne).Add(p).Equal(identity())
+}
+
+// DecodePoint decodes an hexadecimal edwards25519 point (see Bytes)
+func DecodePoint(pointHex string) (*Point, error) {
+	b, err := hex.DecodeString(pointHex)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != PointSize {
+		return nil, fmt.Errorf("point size must be %d, not %d", PointSize, len(b))
+	}
+	p, err := new(edwards25519.Point).SetBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return &Point{p: p}, nil
+}
+
+// newScalar returns the scalar of a small integer, such as a participant index
+func newScalar(n uint64) *Scalar {
+	b := make([]byte, ScalarSize)
+	binary.LittleEndian.PutUint64(b, n)
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(b)
+	if err != nil {
+		panic(err) // cannot happen, n is lower than the group order
+	}
+	return s
+}
+
+// scalarFromBig returns the scalar of a public integer, reduced modulo the group order
+func scalarFromBig(n *big.Int) *Scalar {
+	be := new(big.Int).Mod(n, order).Bytes()
+	b := make([]byte, ScalarSize)
+	for i := range be {
+		b[i] = be[len(be)-1-i]
+	}
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(b)
+	if err != nil {
+		panic(err) // cannot happen, the integer is reduced
+	}
+	return s
+}
+
+// randomScalar returns a uniformly distributed scalar read from randReader
+func randomScalar(randReader io.Reader) (*Scalar, error) {
+	b := make([]byte, 64)
+	if _, err := io.ReadFull(randReader, b); err != nil {
+		return nil, err
+	}
+	return edwards25519.NewScalar().SetUniformBytes(b)
+}
+
+// EncodeScalar returns the hexadecimal 32 bytes little endian encoding of k
+func EncodeScalar(k *Scalar) string {
+	return hex.EncodeToString(k.Bytes())
+}
+
+// DecodeScalar decodes an hexadecimal little endian scalar, it must be lower than the group order
+func DecodeScalar(scalarHex string) (*Scalar, error) {
+	b, err := hex.DecodeString(scalarHex)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ScalarSize {
+		return nil, fmt.Errorf("scalar size must be %d, not %d", ScalarSize, len(b))
+	}
+	k, err := edwards25519.NewScalar().SetCanonicalBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("scalar out of range")
+	}
+	return k, nil
+}
@@ -0,0 +1,140 @@
+// Package threshold implements the t-of-n distributed generation of curve25519 encryption keys
+// (joint-Feldman DKG), whose public key can be used for encrypting nacl anonymous boxes.
+//
+// Each dealer shares a random secret with a polynomial of degree t-1: it publishes the commitments
+// of the polynomial coefficients and sends the evaluation of the polynomial at each participant
+// index. The encryption private key is the sum of all the dealer secrets, and its public key the
+// sum of the first commitment of each dealer. The key share of a participant is the sum of the
+// evaluations received from all the dealers, and the private key can be recovered from any t of them.
+//
+// The curve arithmetic is implemented by filippo.io/edwards25519, whose operations over secret
+// scalars run in constant time.
+package threshold
+
+import (
+	cryptorand "crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+
+	"filippo.io/edwards25519"
+)
+
+// Polynomial is the secret polynomial of a dealer, whose constant term is the dealer secret
+type Polynomial struct {
+	coeffs []*Scalar
+}
+
+// NewPolynomial returns a random polynomial for a threshold of t participants.
+// If randReader is nil, crypto/rand.Reader is used.
+func NewPolynomial(t int, randReader io.Reader) (*Polynomial, error) {
+	if t < 1 {
+		return nil, fmt.Errorf("threshold must be positive")
+	}
+	if randReader == nil {
+		randReader = cryptorand.Reader
+	}
+	p := &Polynomial{coeffs: make([]*Scalar, t)}
+	for i := range p.coeffs {
+		c, err := randomScalar(randReader)
+		if err != nil {
+			return nil, err
+		}
+		p.coeffs[i] = c
+	}
+	return p, nil
+}
+
+// Commitments returns the public commitments of the polynomial coefficients
+func (p *Polynomial) Commitments() []*Point {
+	commitments := make([]*Point, len(p.coeffs))
+	for i, c := range p.coeffs {
+		commitments[i] = ScalarBaseMult(c)
+	}
+	return commitments
+}
+
+// Share returns the evaluation of the polynomial at the participant index
+func (p *Polynomial) Share(index int) *Scalar {
+	x := newScalar(uint64(index))
+	share := edwards25519.NewScalar()
+	for i := len(p.coeffs) - 1; i >= 0; i-- {
+		share.MultiplyAdd(share, x, p.coeffs[i])
+	}
+	return share
+}
+
+// evalCommitments returns the commitment of the evaluation at index of the polynomial whose
+// coefficient commitments are given
+func evalCommitments(commitments []*Point, index int) *Point {
+	x := newScalar(uint64(index))
+	r := identity()
+	for i := len(commitments) - 1; i >= 0; i-- {
+		r = r.ScalarMult(x).Add(commitments[i])
+	}
+	return r
+}
+
+// VerifyShare checks the share received by the participant index from a dealer, against the
+// dealer commitments
+func VerifyShare(commitments []*Point, index int, share *Scalar) bool {
+	return ScalarBaseMult(share).Equal(evalCommitments(commitments, index))
+}
+
+// SumShares returns the key share of a participant, which is the sum of the shares received from
+// all the dealers
+func SumShares(shares []*Scalar) *Scalar {
+	sum := edwards25519.NewScalar()
+	for _, s := range shares {
+		sum.Add(sum, s)
+	}
+	return sum
+}
+
+// PublicShare returns the public key of the key share of the participant index, computed from the
+// commitments of all the dealers. It is used for verifying the revealed key shares.
+func PublicShare(deals [][]*Point, index int) *Point {
+	r := identity()
+	for _, commitments := range deals {
+		r = r.Add(evalCommitments(commitments, index))
+	}
+	return r
+}
+
+// PublicKey returns the encryption public key generated by the dealers
+func PublicKey(deals [][]*Point) *Point {
+	r := identity()
+	for _, commitments := range deals {
+		if len(commitments) > 0 {
+			r = r.Add(commitments[0])
+		}
+	}
+	return r
+}
+
+// Combine recovers the encryption private key from the key shares of at least t participants,
+// indexed by participant index
+func Combine(shares map[int]*Scalar) (*Scalar, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no key shares")
+	}
+	secret := edwards25519.NewScalar()
+	for i, share := range shares {
+		if i < 1 {
+			return nil, fmt.Errorf("invalid participant index %d", i)
+		}
+		// lagrange coefficient at zero: prod(j / (j - i)) for j != i. The participant
+		// indexes are public, so the coefficient can be computed in variable time.
+		num, den := big.NewInt(1), big.NewInt(1)
+		for j := range shares {
+			if j == i {
+				continue
+			}
+			num.Mul(num, big.NewInt(int64(j))).Mod(num, order)
+			den.Mul(den, big.NewInt(int64(j-i))).Mod(den, order)
+		}
+		lambda := num.Mul(num, new(big.Int).ModInverse(den, order))
+		secret.MultiplyAdd(scalarFromBig(lambda), share, secret)
+	}
+	return secret, nil
+}
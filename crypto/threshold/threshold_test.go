@@ -0,0 +1,163 @@
+package threshold
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/curve25519"
+
+	"gitlab.com/vocdoni/go-dvote/crypto/nacl"
+	"gitlab.com/vocdoni/go-dvote/util"
+)
+
+func TestX25519(t *testing.T) {
+	t.Parallel()
+
+	// with a clamped scalar and a point of the prime order subgroup, the unclamped
+	// ladder of the reduced scalar must match curve25519
+	for i := 0; i < 4; i++ {
+		k := util.RandomBytes(32)
+		k[0] &= 248
+		k[31] &= 127
+		k[31] |= 64
+		u, err := curve25519.X25519(util.RandomBytes(32), curve25519.Basepoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := curve25519.X25519(k, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reduced, err := edwards25519.NewScalar().SetUniformBytes(append(k, make([]byte, 32)...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := x25519(reduced, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("x25519 mismatch: %x != %x", got, want)
+		}
+	}
+	// the edwards and montgomery representations of a public key must match
+	k := newScalar(1234567890123456789)
+	pub, err := ScalarBaseMult(k).BoxPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := x25519(k, curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pub, want) {
+		t.Fatalf("public key mismatch: %x != %x", pub, want)
+	}
+}
+
+func TestPointEncoding(t *testing.T) {
+	t.Parallel()
+
+	p := ScalarBaseMult(newScalar(987654321))
+	q, err := DecodePoint(p.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Equal(p) || !q.InPrimeSubgroup() {
+		t.Fatal("decoded point does not match")
+	}
+	// the standard encoding of the base point
+	if got := ScalarBaseMult(newScalar(1)).Hex(); got != "5866666666666666666666666666666666666666666666666666666666666666" {
+		t.Fatalf("unexpected base point encoding %s", got)
+	}
+	// a point of order 8 (plus the base point) is not in the prime order subgroup
+	small, err := DecodePoint("26e8958fc2b227b045c3f489f2ef98f0d5dfac05d3c63339b13802886d53fc05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small.InPrimeSubgroup() || small.Add(p).InPrimeSubgroup() {
+		t.Fatal("small order point accepted as a prime subgroup point")
+	}
+	if _, err := DecodePoint(hex.EncodeToString(make([]byte, PointSize+1))); err == nil {
+		t.Fatal("malformed point accepted")
+	}
+}
+
+func TestDistributedKeyGeneration(t *testing.T) {
+	t.Parallel()
+
+	const threshold, participants = 3, 5
+	polynomials := make([]*Polynomial, participants)
+	deals := make([][]*Point, participants)
+	for i := range polynomials {
+		var err error
+		if polynomials[i], err = NewPolynomial(threshold, nil); err != nil {
+			t.Fatal(err)
+		}
+		deals[i] = polynomials[i].Commitments()
+	}
+	// each participant sums the shares received from all the dealers (indexes start at 1)
+	shares := make(map[int]*Scalar)
+	for j := 1; j <= participants; j++ {
+		var received []*Scalar
+		for i, p := range polynomials {
+			s := p.Share(j)
+			if !VerifyShare(deals[i], j, s) {
+				t.Fatalf("share of dealer %d for participant %d not valid", i, j)
+			}
+			received = append(received, s)
+		}
+		share := SumShares(received)
+		if !ScalarBaseMult(share).Equal(PublicShare(deals, j)) {
+			t.Fatalf("key share of participant %d does not match its public share", j)
+		}
+		shares[j] = share
+	}
+	if VerifyShare(deals[0], 1, polynomials[1].Share(1)) {
+		t.Fatal("share of another dealer accepted")
+	}
+
+	pub, err := PublicKey(deals).BoxPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	naclPub, err := nacl.DecodePublic(hex.EncodeToString(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := []byte("encrypted vote")
+	cipher, err := nacl.Anonymous.Encrypt(message, naclPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// any threshold participants can decrypt, two missing participants are tolerated
+	secret, err := Combine(map[int]*Scalar{2: shares[2], 4: shares[4], 5: shares[5]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := DecodePrivate(EncodeScalar(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(priv.Public().Bytes(), pub) {
+		t.Fatal("combined private key does not match the public key")
+	}
+	decrypted, err := priv.Decrypt(cipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, message) {
+		t.Fatalf("decrypted message mismatch: %q", decrypted)
+	}
+	// less than threshold participants cannot
+	secret, err = Combine(map[int]*Scalar{1: shares[1], 3: shares[3]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(secret, cipher); err == nil {
+		t.Fatal("message decrypted with less than threshold key shares")
+	}
+}
@@ -99,7 +99,8 @@ func (db *BadgerDB) Close() error {
 type BadgerIterator struct {
 	txn      *badger.Txn
 	Iter     *badger.Iterator
-	first    bool // so that the first Next does a Rewind
+	first    bool   // so that the first Next does a Rewind
+	prefix   []byte // if set, only the keys with this prefix are iterated
 	released bool
 }
 
@@ -113,6 +114,13 @@ func (db *BadgerDB) NewIterator() Iterator {
 	}
 }
 
+// NewPrefixIterator returns an iterator over the keys starting with prefix
+func (db *BadgerDB) NewPrefixIterator(prefix []byte) Iterator {
+	iter := db.NewIterator().(*BadgerIterator)
+	iter.prefix = prefix
+	return iter
+}
+
 func (i *BadgerIterator) Release() {
 	i.Iter.Close()
 	i.txn.Discard()
@@ -125,15 +133,19 @@ func (i *BadgerIterator) Released() bool {
 
 func (i *BadgerIterator) Next() bool {
 	if i.first {
-		// For the first element, we only rewind.
+		// For the first element, we only rewind (or seek the prefix).
 		// Don't call iter.Next, as that would skip the first element
 		// entirely.
-		i.Iter.Rewind()
+		if i.prefix != nil {
+			i.Iter.Seek(i.prefix)
+		} else {
+			i.Iter.Rewind()
+		}
 		i.first = false
 	} else {
 		i.Iter.Next()
 	}
-	return i.Iter.Valid()
+	return i.Iter.ValidForPrefix(i.prefix)
 }
 
 func (i *BadgerIterator) Seek(key []byte) {
@@ -54,6 +54,27 @@ func TestIterEmpty(t *testing.T) {
 	}
 }
 
+func TestIterPrefix(t *testing.T) {
+	d := NewTestDB(t)
+	for i := 0; i < 10; i++ {
+		d.Put([]byte("a"+strconv.Itoa(i)), []byte(strconv.Itoa(i)))
+		d.Put([]byte("b"+strconv.Itoa(i)), []byte(strconv.Itoa(i)))
+		d.Put([]byte("c"+strconv.Itoa(i)), []byte(strconv.Itoa(i)))
+	}
+	iter := d.NewPrefixIterator([]byte("b"))
+	defer iter.Release()
+	keysFound := 0
+	for iter.Next() {
+		if key := string(iter.Key()); key[0] != 'b' {
+			t.Errorf("unexpected key %q", key)
+		}
+		keysFound++
+	}
+	if keysFound != 10 {
+		t.Errorf("expected 10 keys, found %d", keysFound)
+	}
+}
+
 func NewTestDB(tb testing.TB) *BadgerDB {
 	db, err := NewBadgerDB(tb.TempDir())
 	if err != nil {
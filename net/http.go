@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
 	"gitlab.com/vocdoni/go-dvote/log"
@@ -68,6 +69,14 @@ func (h *HttpContext) ConnectionType() string {
 	return "HTTP"
 }
 
+func (h *HttpContext) RemoteAddr() string {
+	return h.Request.RemoteAddr
+}
+
+func (h *HttpContext) ForwardedFor() string {
+	return strings.Join(h.Request.Header.Values("X-Forwarded-For"), ",")
+}
+
 func (h *HttpContext) Send(msg types.Message) {
 	defer close(h.sent)
 
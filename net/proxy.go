@@ -32,8 +32,9 @@ const (
 	VochainWsReadLimit = 20 << 20 // tendermint requires 20 MiB minimum
 )
 
-// ProxyWsHandler function signature required to add a handler in the net/http Server
-type ProxyWsHandler func(c *websocket.Conn)
+// ProxyWsHandler function signature required to add a handler in the net/http Server.
+// r is the HTTP request of the client which opened the connection.
+type ProxyWsHandler func(c *websocket.Conn, r *http.Request)
 
 // Proxy represents a proxy
 type Proxy struct {
@@ -273,7 +274,7 @@ func (p *Proxy) ProxyIPC(path string) http.HandlerFunc {
 
 // AddWsHTTPBridge adds a WS endpoint to interact with the underlying web3
 func (p *Proxy) AddWsHTTPBridge(url string) ProxyWsHandler {
-	return func(c *websocket.Conn) {
+	return func(c *websocket.Conn, r *http.Request) {
 		for {
 			msgType, msg, err := c.Reader(context.TODO())
 			if err != nil {
@@ -307,7 +308,7 @@ func (p *Proxy) AddWsHTTPBridge(url string) ProxyWsHandler {
 
 // AddWsWsBridge adds a WS endpoint to interact with the underlying web3
 func (p *Proxy) AddWsWsBridge(url string, readLimit int64) ProxyWsHandler {
-	return func(wsServer *websocket.Conn) {
+	return func(wsServer *websocket.Conn, r *http.Request) {
 		// connection to web3 or vochain
 		wsClient := recws.RecConn{
 			KeepAliveTimeout: 10 * time.Second,
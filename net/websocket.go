@@ -25,13 +25,23 @@ type WebsocketHandle struct {
 }
 
 type WebsocketContext struct {
-	Conn *websocket.Conn
+	Conn      *websocket.Conn
+	Addr      string
+	Forwarded string
 }
 
 func (c WebsocketContext) ConnectionType() string {
 	return "Websocket"
 }
 
+func (c WebsocketContext) RemoteAddr() string {
+	return c.Addr
+}
+
+func (c WebsocketContext) ForwardedFor() string {
+	return c.Forwarded
+}
+
 func (c *WebsocketContext) Send(msg types.Message) {
 	tctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
@@ -62,8 +72,8 @@ func (w *WebsocketHandle) Init(c *types.Connection) error {
 	return nil
 }
 
-func getWsHandler(path string, receiver chan types.Message) func(conn *websocket.Conn) {
-	return func(conn *websocket.Conn) {
+func getWsHandler(path string, receiver chan types.Message) ProxyWsHandler {
+	return func(conn *websocket.Conn, r *http.Request) {
 		// Read websocket messages until the connection is closed. HTTP
 		// handlers are run in new goroutines, so we don't need to spawn
 		// another goroutine.
@@ -76,7 +86,11 @@ func getWsHandler(path string, receiver chan types.Message) func(conn *websocket
 			msg := types.Message{
 				Data:      payload,
 				TimeStamp: int32(time.Now().Unix()),
-				Context:   &WebsocketContext{Conn: conn},
+				Context: &WebsocketContext{
+					Conn:      conn,
+					Addr:      r.RemoteAddr,
+					Forwarded: strings.Join(r.Header.Values("X-Forwarded-For"), ","),
+				},
 				Namespace: path,
 			}
 
@@ -143,7 +157,7 @@ func wshandler(w http.ResponseWriter, r *http.Request, ph ProxyWsHandler, readLi
 		return
 	}
 	conn.SetReadLimit(readLimit)
-	ph(conn)
+	ph(conn, r)
 }
 
 func somaxconn() int {
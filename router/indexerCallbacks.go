@@ -0,0 +1,93 @@
+package router
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/util"
+)
+
+func (r *Router) getTxByHash(request routerRequest) {
+	request.Hash = util.TrimHex(request.Hash)
+	if !util.IsHexEncodedStringWithLength(request.Hash, types.TxHashSize) {
+		r.sendError(request, "cannot get transaction: (malformed hash)")
+		return
+	}
+	hash, err := hex.DecodeString(request.Hash)
+	if err != nil {
+		r.sendError(request, "cannot decode hash")
+		return
+	}
+	var response types.MetaResponse
+	if response.Tx, err = r.Indexer.TxByHash(hash); err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get transaction: (%s)", err))
+		return
+	}
+	request.Send(r.buildReply(request, &response))
+}
+
+func (r *Router) getTxListForBlock(request routerRequest) {
+	if request.Height <= 0 {
+		r.sendError(request, "cannot get transaction list: (invalid height)")
+		return
+	}
+	var response types.MetaResponse
+	response.TxList = r.Indexer.BlockTxList(request.Height)
+	request.Send(r.buildReply(request, &response))
+}
+
+func (r *Router) getBlockTxs(request routerRequest) {
+	if request.Height <= 0 {
+		r.sendError(request, "cannot get block transactions: (invalid height)")
+		return
+	}
+	var response types.MetaResponse
+	var err error
+	if response.Txs, err = r.Indexer.BlockTxs(request.Height); err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get block transactions: (%s)", err))
+		return
+	}
+	request.Send(r.buildReply(request, &response))
+}
+
+func (r *Router) getTxListForProcess(request routerRequest) {
+	request.ProcessID = util.TrimHex(request.ProcessID)
+	if !util.IsHexEncodedStringWithLength(request.ProcessID, types.ProcessIDsize) {
+		r.sendError(request, "cannot get transaction list: (malformed processId)")
+		return
+	}
+	if request.ListSize > MaxListSize {
+		r.sendError(request, fmt.Sprintf("listSize overflow, maximum is %d", MaxListSize))
+		return
+	}
+	if request.ListSize == 0 {
+		request.ListSize = 64
+	}
+	pid, err := hex.DecodeString(request.ProcessID)
+	if err != nil {
+		r.sendError(request, "cannot decode processID")
+		return
+	}
+	var response types.MetaResponse
+	response.TxList = r.Indexer.ProcessTxList(pid, request.From, request.ListSize)
+	request.Send(r.buildReply(request, &response))
+}
+
+func (r *Router) getTxListBySigner(request routerRequest) {
+	if !ethcommon.IsHexAddress(request.Address) {
+		r.sendError(request, "cannot get transaction list: (malformed address)")
+		return
+	}
+	if request.ListSize > MaxListSize {
+		r.sendError(request, fmt.Sprintf("listSize overflow, maximum is %d", MaxListSize))
+		return
+	}
+	if request.ListSize == 0 {
+		request.ListSize = 64
+	}
+	var response types.MetaResponse
+	response.TxList = r.Indexer.SignerTxList(ethcommon.HexToAddress(request.Address), request.From, request.ListSize)
+	request.Send(r.buildReply(request, &response))
+}
@@ -20,9 +20,16 @@ var (
 		Name:      "public_reqs",
 		Help:      "The number of public requests processed",
 	}, []string{"method"})
+	// RouterRejectedReqs ...
+	RouterRejectedReqs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "router",
+		Name:      "rejected_reqs",
+		Help:      "The number of requests rejected by the rate limits or the pre-checks",
+	}, []string{"method", "reason"})
 )
 
 func (r *Router) registerMetrics(ma *metrics.Agent) {
 	ma.Register(RouterPrivateReqs)
 	ma.Register(RouterPublicReqs)
+	ma.Register(RouterRejectedReqs)
 }
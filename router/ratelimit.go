@@ -0,0 +1,172 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiterMaxKeys is the maximum number of keys tracked by a rate limiter. Once reached,
+// the idle keys (those with a full bucket) are forgotten.
+const rateLimiterMaxKeys = 100000
+
+// rateLimiter is a token bucket rate limiter keyed by an arbitrary string (IP, address, processId...).
+// Each key can spend up to limit tokens per minute, and the bucket is refilled continuously.
+type rateLimiter struct {
+	lock    sync.Mutex
+	limit   float64
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a rate limiter of limit requests per minute per key.
+// If limit is zero or negative, nil is returned (a nil rateLimiter allows everything).
+func newRateLimiter(limit int) *rateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &rateLimiter{limit: float64(limit), buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+// allow spends a token of key, returns false if the key has no tokens left
+func (l *rateLimiter) allow(key string) bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	b := l.refill(key)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// available returns true if key has tokens left, without spending any. It is used for the limits
+// charged once the request succeeds (see charge).
+func (l *rateLimiter) available(key string) bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.refill(key).tokens >= 1
+}
+
+// charge spends a token of key, even if the key has no tokens left
+func (l *rateLimiter) charge(key string) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.refill(key).tokens--
+}
+
+// refill returns the bucket of key with the tokens refilled since its last use, the lock must be held
+func (l *rateLimiter) refill(key string) *tokenBucket {
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimiterMaxKeys {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: l.limit, last: now}
+		l.buckets[key] = b
+		return b
+	}
+	b.tokens += now.Sub(b.last).Minutes() * l.limit
+	if b.tokens > l.limit {
+		b.tokens = l.limit
+	}
+	b.last = now
+	return b
+}
+
+// prune removes the keys whose bucket is already refilled, the lock must be held
+func (l *rateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Minutes()*l.limit >= l.limit {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// remoteIP returns the host part of a network address
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// parseTrustedProxies parses a list of IPs or CIDR networks
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy IP %q", p)
+			}
+			bits := 8 * len(ip)
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy network %q: (%s)", p, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// isTrusted returns true if ip belongs to any of the trusted networks
+func isTrusted(trusted []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client which sent a request. If the request comes from a trusted
+// proxy, the X-Forwarded-For addresses are walked from the right (the ones appended by the nearest
+// proxies) and the first untrusted one is returned, since the leftmost entries can be forged.
+func clientIP(trusted []*net.IPNet, remoteAddr, forwardedFor string) string {
+	ip := remoteIP(remoteAddr)
+	if !isTrusted(trusted, ip) || forwardedFor == "" {
+		return ip
+	}
+	forwarded := strings.Split(forwardedFor, ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			// a malformed entry cannot be trusted, nor the ones at its left
+			return ip
+		}
+		ip = hop
+		if !isTrusted(trusted, ip) {
+			break
+		}
+	}
+	return ip
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(6)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 6; i++ {
+		if !l.allow("a") {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if l.allow("a") {
+		t.Fatal("burst exceeded but request allowed")
+	}
+	if !l.allow("b") {
+		t.Fatal("keys must have independent buckets")
+	}
+
+	// 6 requests per minute refill a token every 10 seconds
+	now = now.Add(9 * time.Second)
+	if l.allow("a") {
+		t.Fatal("token refilled too early")
+	}
+	now = now.Add(2 * time.Second)
+	if !l.allow("a") {
+		t.Fatal("token not refilled")
+	}
+
+	// idle keys are forgotten once the limiter is full
+	now = now.Add(time.Hour)
+	l.prune(now)
+	if len(l.buckets) != 0 {
+		t.Fatalf("expected idle keys to be pruned, got %d", len(l.buckets))
+	}
+
+	// the tokens are only spent when charged
+	for i := 0; i < 6; i++ {
+		if !l.available("c") {
+			t.Fatalf("request %d should be available", i)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		l.charge("c")
+	}
+	if l.available("c") {
+		t.Fatal("burst exceeded but request available")
+	}
+
+	disabled := newRateLimiter(0)
+	if !disabled.allow("a") || !disabled.available("a") {
+		t.Fatal("a disabled limiter must allow everything")
+	}
+	disabled.charge("a")
+}
+
+func TestRemoteIP(t *testing.T) {
+	for addr, ip := range map[string]string{
+		"10.0.0.1:5432":    "10.0.0.1",
+		"[2001:db8::1]:80": "2001:db8::1",
+		"10.0.0.1":         "10.0.0.1",
+	} {
+		if got := remoteIP(addr); got != ip {
+			t.Errorf("remoteIP(%q) = %q, want %q", addr, got, ip)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.1", "192.168.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		remoteAddr, forwardedFor, ip string
+	}{
+		{"10.0.0.2:80", "1.2.3.4", "10.0.0.2"},
+		{"10.0.0.1:80", "", "10.0.0.1"},
+		{"10.0.0.1:80", "1.2.3.4", "1.2.3.4"},
+		{"10.0.0.1:80", "6.6.6.6, 1.2.3.4, 192.168.1.1", "1.2.3.4"},
+		{"10.0.0.1:80", "192.168.1.2,192.168.1.1", "192.168.1.2"},
+		{"10.0.0.1:80", "1.2.3.4, forged", "10.0.0.1"},
+	} {
+		if got := clientIP(trusted, tc.remoteAddr, tc.forwardedFor); got != tc.ip {
+			t.Errorf("clientIP(%q, %q) = %q, want %q", tc.remoteAddr, tc.forwardedFor, got, tc.ip)
+		}
+	}
+	if _, err := parseTrustedProxies([]string{"10.0.0"}); err == nil {
+		t.Fatal("invalid trusted proxy accepted")
+	}
+}
@@ -4,6 +4,7 @@ package router
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
@@ -22,6 +23,7 @@ import (
 	"gitlab.com/vocdoni/go-dvote/metrics"
 	"gitlab.com/vocdoni/go-dvote/types"
 	"gitlab.com/vocdoni/go-dvote/vochain"
+	"gitlab.com/vocdoni/go-dvote/vochain/indexer"
 	"gitlab.com/vocdoni/go-dvote/vochain/scrutinizer"
 	"gitlab.com/vocdoni/go-dvote/vochain/vochaininfo"
 )
@@ -111,10 +113,18 @@ type Router struct {
 	vocinfo      *vochaininfo.VochainInfo
 	allowPrivate bool
 	Scrutinizer  *scrutinizer.Scrutinizer
+	Indexer      *indexer.Indexer
 	PrivateCalls uint64
 	PublicCalls  uint64
 	codec        *amino.Codec
 	APIs         []string
+
+	// envelope submission rate limits (nil means disabled)
+	envelopeLimitIP      *rateLimiter
+	envelopeLimitAddress *rateLimiter
+	envelopeLimitProcess *rateLimiter
+	// reverse proxies whose X-Forwarded-For header is used for the per-IP limit
+	trustedProxies []*net.IPNet
 }
 
 func NewRouter(inbound <-chan types.Message, storage data.Storage,
@@ -243,6 +253,25 @@ func (r *Router) EnableCensusAPI(cm *census.Manager) {
 	r.registerPrivate("getCensusList", r.censusLocal)
 }
 
+// SetEnvelopeLimits sets the maximum number of envelopes per minute accepted from the same
+// client IP, from the same voter and for the same process. Zero disables the limit.
+func (r *Router) SetEnvelopeLimits(ip, address, process int) {
+	r.envelopeLimitIP = newRateLimiter(ip)
+	r.envelopeLimitAddress = newRateLimiter(address)
+	r.envelopeLimitProcess = newRateLimiter(process)
+}
+
+// SetTrustedProxies sets the IPs or CIDR networks of the reverse proxies in front of the API. The
+// per-IP envelope limit uses the client IP from the X-Forwarded-For header of their requests.
+func (r *Router) SetTrustedProxies(proxies []string) error {
+	nets, err := parseTrustedProxies(proxies)
+	if err != nil {
+		return err
+	}
+	r.trustedProxies = nets
+	return nil
+}
+
 // EnableVoteAPI enabled the Vote API in the Router
 func (r *Router) EnableVoteAPI(vocapp *vochain.BaseApplication, vocInfo *vochaininfo.VochainInfo) {
 	r.APIs = append(r.APIs, "vote")
@@ -252,6 +281,7 @@ func (r *Router) EnableVoteAPI(vocapp *vochain.BaseApplication, vocInfo *vochain
 	r.registerPublic("submitEnvelope", r.submitEnvelope)
 	r.registerPublic("getEnvelopeStatus", r.getEnvelopeStatus)
 	r.registerPublic("getEnvelope", r.getEnvelope)
+	r.registerPublic("getEnvelopeProof", r.getEnvelopeProof)
 	r.registerPublic("getEnvelopeHeight", r.getEnvelopeHeight)
 	r.registerPublic("getProcessList", r.getProcessList)
 	r.registerPublic("getEnvelopeList", r.getEnvelopeList)
@@ -267,6 +297,14 @@ func (r *Router) EnableVoteAPI(vocapp *vochain.BaseApplication, vocInfo *vochain
 		r.registerPublic("getScrutinizerEntities", r.getScrutinizerEntities)
 		r.registerPublic("getScrutinizerEntityCount", r.getScrutinizerEntityCount)
 	}
+	if r.Indexer != nil {
+		r.APIs = append(r.APIs, "indexer")
+		r.registerPublic("getTxByHash", r.getTxByHash)
+		r.registerPublic("getTxListForBlock", r.getTxListForBlock)
+		r.registerPublic("getBlockTxs", r.getBlockTxs)
+		r.registerPublic("getTxListForProcess", r.getTxListForProcess)
+		r.registerPublic("getTxListBySigner", r.getTxListBySigner)
+	}
 }
 
 // Route routes requests through the Router object
@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/types"
 	"gitlab.com/vocdoni/go-dvote/util"
+	"gitlab.com/vocdoni/go-dvote/vochain"
 	"gitlab.com/vocdoni/go-dvote/vochain/scrutinizer"
 )
 
@@ -38,11 +41,16 @@ func (r *Router) submitRawTx(request routerRequest) {
 	}
 	log.Infof("broadcasting vochain tx hash:%s code:%d", res.Hash, res.Code)
 	var response types.MetaResponse
+	response.Hash = fmt.Sprintf("%x", res.Hash)
 	response.Payload = fmt.Sprintf("%x", res.Data) // return nullifier or other info
 	request.Send(r.buildReply(request, &response))
 }
 
 func (r *Router) submitEnvelope(request routerRequest) {
+	if !r.envelopeLimitIP.allow(clientIP(r.trustedProxies, request.RemoteAddr(), request.ForwardedFor())) {
+		r.rejectEnvelope(request, "ip_limit", "too many envelopes from the same IP, try again later")
+		return
+	}
 	voteTxArgs := new(types.VoteTx)
 	if request.Payload == nil {
 		r.sendError(request, "payload is empty")
@@ -55,7 +63,28 @@ func (r *Router) submitEnvelope(request routerRequest) {
 	voteTxArgs.Proof = request.Payload.Proof
 	voteTxArgs.Type = "vote"
 	voteTxArgs.EncryptionKeyIndexes = request.Payload.EncryptionKeyIndexes
+	voteTxArgs.EthProof = request.Payload.EthProof
+	voteTxArgs.CAProof = request.Payload.CAProof
 	voteTxArgs.Signature = request.Payload.Signature
+	voteTxArgs.Weight = request.Payload.Weight
+	voteTxArgs.ZkProof = request.Payload.ZkProof
+
+	voter, err := r.envelopePreCheck(voteTxArgs)
+	if err != nil {
+		r.rejectEnvelope(request, "precheck", fmt.Sprintf("invalid envelope: (%s)", err))
+		return
+	}
+	// the process limit is only charged once the vochain accepts the envelope, so invalid envelopes
+	// cannot exhaust it and block the legit voters of the process
+	pidKey := util.TrimHex(voteTxArgs.ProcessID)
+	if !r.envelopeLimitProcess.available(pidKey) {
+		r.rejectEnvelope(request, "process_limit", "too many envelopes for this process, try again later")
+		return
+	}
+	if !r.envelopeLimitAddress.allow(voter) {
+		r.rejectEnvelope(request, "address_limit", "too many envelopes from the same voter, try again later")
+		return
+	}
 
 	voteTxBytes, err := json.Marshal(voteTxArgs)
 	if err != nil {
@@ -75,12 +104,89 @@ func (r *Router) submitEnvelope(request routerRequest) {
 		r.sendError(request, string(res.Data))
 		return
 	}
+	r.envelopeLimitProcess.charge(pidKey)
 	log.Infof("broadcasting vochain tx hash:%s code:%d", res.Hash, res.Code)
 	var response types.MetaResponse
+	response.Hash = fmt.Sprintf("%x", res.Hash)
 	response.Nullifier = fmt.Sprintf("%x", res.Data)
 	request.Send(r.buildReply(request, &response))
 }
 
+// envelopePreCheck performs the cheap validations of a vote envelope, so the invalid ones are
+// discarded before the vochain verifies the census proof. It returns the voter identifier used
+// for rate limiting: the signer address or the nullifier for anonymous (snark) votes.
+func (r *Router) envelopePreCheck(tx *types.VoteTx) (string, error) {
+	pidHex := util.TrimHex(tx.ProcessID)
+	if !util.IsHexEncodedStringWithLength(pidHex, types.ProcessIDsize) {
+		return "", fmt.Errorf("malformed processId")
+	}
+	pid, err := hex.DecodeString(pidHex)
+	if err != nil {
+		return "", fmt.Errorf("cannot decode processId")
+	}
+	process, err := r.vocapp.State.Process(pid, true)
+	if err != nil {
+		return "", err
+	}
+	header := r.vocapp.State.Header(true)
+	if header == nil {
+		return "", fmt.Errorf("cannot obtain state header")
+	}
+	// the envelope can be included on the next block at the earliest (its time is not known yet)
+	if err := vochain.CheckVoteWindow(process, header.Height+1, header.Time); err != nil {
+		return "", err
+	}
+	if tx.VotePackage == "" {
+		return "", fmt.Errorf("empty vote package")
+	}
+	if process.Type == types.SnarkVote {
+		if !util.IsHexEncodedStringWithLength(tx.Nullifier, types.VoteNullifierSize) {
+			return "", fmt.Errorf("malformed nullifier")
+		}
+		if tx.ZkProof == nil {
+			return "", fmt.Errorf("missing zk-SNARK census proof")
+		}
+		return util.TrimHex(tx.Nullifier), nil
+	}
+	// open-poll processes have no census, token census processes use ethereum storage proofs
+	// and census CA processes use a token signed by the CA
+	if process.TokenCensus != nil {
+		if tx.EthProof == nil {
+			return "", fmt.Errorf("missing ethereum storage proof")
+		}
+	} else if process.CensusCA != nil {
+		if tx.CAProof == "" {
+			return "", fmt.Errorf("missing census CA proof")
+		}
+	} else if tx.Proof == "" && process.Type != types.OpenPoll {
+		return "", fmt.Errorf("missing census proof")
+	}
+	// the signed bytes are built as the vochain does, without signature and type
+	signedTx := *tx
+	signedTx.Signature, signedTx.Type = "", ""
+	signedBytes, err := json.Marshal(signedTx)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal vote: (%s)", err)
+	}
+	addr, err := ethereum.AddrFromSignature(signedBytes, tx.Signature)
+	if err != nil {
+		return "", fmt.Errorf("cannot extract address from signature: (%s)", err)
+	}
+	if process.Type == types.OpenPoll && !r.vocapp.State.IsAddressAllowed(pid, process, addr, true) {
+		return "", fmt.Errorf("address %s is not allowed to vote", addr.Hex())
+	}
+	return addr.Hex(), nil
+}
+
+// rejectEnvelope sends an error reply for a discarded envelope and accounts it on the metrics
+func (r *Router) rejectEnvelope(request routerRequest, reason, msg string) {
+	if r.metricsagent != nil {
+		RouterRejectedReqs.With(prometheus.Labels{"method": request.method, "reason": reason}).Inc()
+	}
+	log.Debugf("envelope rejected (%s): %s", reason, msg)
+	r.sendError(request, msg)
+}
+
 func (r *Router) getEnvelopeStatus(request routerRequest) {
 	// check pid
 	request.ProcessID = util.TrimHex(request.ProcessID)
@@ -159,6 +265,56 @@ func (r *Router) getEnvelope(request routerRequest) {
 	request.Send(r.buildReply(request, &response))
 }
 
+func (r *Router) getEnvelopeProof(request routerRequest) {
+	// check pid
+	request.ProcessID = util.TrimHex(request.ProcessID)
+	if !util.IsHexEncodedStringWithLength(request.ProcessID, types.ProcessIDsize) {
+		r.sendError(request, "cannot get envelope proof: (malformed processId)")
+		return
+	}
+	// check nullifier
+	request.Nullifier = util.TrimHex(request.Nullifier)
+	if !util.IsHexEncodedStringWithLength(request.Nullifier, types.VoteNullifierSize) {
+		r.sendError(request, "cannot get envelope proof: (malformed nullifier)")
+		return
+	}
+	pid, err := hex.DecodeString(request.ProcessID)
+	if err != nil {
+		r.sendError(request, "cannot decode processID")
+		return
+	}
+	nullifier, err := hex.DecodeString(request.Nullifier)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot decode nullifier: (%s)", err))
+		return
+	}
+	envelope, ops, height, err := r.vocapp.State.QueryProof(vochain.VoteTree, append(pid, nullifier...), true)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get envelope proof: (%s)", err))
+		return
+	}
+	var roots map[string]string
+	if err := json.Unmarshal(ops[1].Data, &roots); err != nil {
+		r.sendError(request, fmt.Sprintf("cannot decode state roots: (%s)", err))
+		return
+	}
+
+	var response types.MetaResponse
+	response.Envelope = hex.EncodeToString(envelope)
+	response.Proof = hex.EncodeToString(ops[0].Data)
+	response.ProofType = ops[0].Type
+	response.StateRoots = roots
+	// The AppHash of the state produced by block height is included in the header of the
+	// next block. If the next block is not yet available, the AppHash is left empty and
+	// the client must obtain it from a trusted header at that height.
+	response.Height = new(int64)
+	*response.Height = height + 1
+	if meta := r.vocapp.Node.BlockStore().LoadBlockMeta(height + 1); meta != nil {
+		response.AppHash = hex.EncodeToString(meta.Header.AppHash)
+	}
+	request.Send(r.buildReply(request, &response))
+}
+
 func (r *Router) getEnvelopeHeight(request routerRequest) {
 	// check pid
 	request.ProcessID = util.TrimHex(request.ProcessID)
@@ -273,6 +429,11 @@ func (r *Router) getProcessKeys(request routerRequest) {
 	var response types.MetaResponse
 	var pubs, privs, coms, revs []types.Key
 	for idx, pubk := range process.EncryptionPublicKeys {
+		// the votes of threshold encrypted processes are only encrypted with the threshold key,
+		// the other keys are used by the keykeepers for exchanging the key shares
+		if process.IsThresholdEncrypted() && idx != types.ThresholdKeyIndex {
+			continue
+		}
 		if len(pubk) > 0 {
 			pubs = append(pubs, types.Key{Idx: idx, Key: pubk})
 		}
@@ -350,11 +511,15 @@ func (r *Router) getResults(request routerRequest) {
 		return
 	}
 	response.Type = procInfo.Type
-	if procInfo.Canceled {
+	switch {
+	case procInfo.Canceled:
 		response.State = "canceled"
-	} else {
+	case procInfo.Paused:
+		response.State = "paused"
+	default:
 		response.State = "active"
 	}
+	response.Paused = types.Bool(procInfo.Paused)
 
 	// Get results info
 	vr, err := r.Scrutinizer.VoteResult(pid)
@@ -367,6 +532,16 @@ func (r *Router) getResults(request routerRequest) {
 		response.Message = scrutinizer.ErrNoResultsYet.Error()
 	}
 	response.Results = vr
+	response.TallyMode = procInfo.Ballot().Mode()
+	if response.TallyMode == types.TallyRankedChoice {
+		// the instant runoff is only computed once the process is finished
+		response.RankedResults, err = r.Scrutinizer.RankedChoiceResult(pid)
+		if err != nil && err != scrutinizer.ErrNoResultsYet {
+			log.Warn(err)
+			r.sendError(request, err.Error())
+			return
+		}
+	}
 
 	// Get number of votes
 	votes := r.vocapp.State.CountVotes(pid, true)
@@ -14,13 +14,14 @@ import (
 	"gitlab.com/vocdoni/go-dvote/router"
 	"gitlab.com/vocdoni/go-dvote/types"
 	"gitlab.com/vocdoni/go-dvote/vochain"
+	"gitlab.com/vocdoni/go-dvote/vochain/indexer"
 	"gitlab.com/vocdoni/go-dvote/vochain/scrutinizer"
 	"gitlab.com/vocdoni/go-dvote/vochain/vochaininfo"
 )
 
 // TBD: user the net.Transport interface
 func API(apiconfig *config.API, pxy *net.Proxy, storage data.Storage, cm *census.Manager, vapp *vochain.BaseApplication,
-	sc *scrutinizer.Scrutinizer, vi *vochaininfo.VochainInfo, vochainRPCaddr string, signer *ethereum.SignKeys, ma *metrics.Agent,
+	sc *scrutinizer.Scrutinizer, idx *indexer.Indexer, vi *vochaininfo.VochainInfo, vochainRPCaddr string, signer *ethereum.SignKeys, ma *metrics.Agent,
 ) error {
 	log.Infof("creating API service")
 	// API Endpoint initialization
@@ -63,6 +64,11 @@ func API(apiconfig *config.API, pxy *net.Proxy, storage data.Storage, cm *census
 		// todo: client params as cli flags
 		log.Info("enabling vote API")
 		routerAPI.Scrutinizer = sc
+		routerAPI.Indexer = idx
+		routerAPI.SetEnvelopeLimits(apiconfig.EnvelopeLimitIP, apiconfig.EnvelopeLimitAddress, apiconfig.EnvelopeLimitProcess)
+		if err := routerAPI.SetTrustedProxies(apiconfig.TrustedProxies); err != nil {
+			return err
+		}
 		routerAPI.EnableVoteAPI(vapp, vi)
 	}
 
@@ -10,16 +10,18 @@ import (
 
 	"gitlab.com/vocdoni/go-dvote/census"
 	"gitlab.com/vocdoni/go-dvote/config"
+	"gitlab.com/vocdoni/go-dvote/data"
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/metrics"
 	"gitlab.com/vocdoni/go-dvote/util"
 	"gitlab.com/vocdoni/go-dvote/vochain"
 	"gitlab.com/vocdoni/go-dvote/vochain/censusdownloader"
+	"gitlab.com/vocdoni/go-dvote/vochain/indexer"
 	"gitlab.com/vocdoni/go-dvote/vochain/scrutinizer"
 	"gitlab.com/vocdoni/go-dvote/vochain/vochaininfo"
 )
 
-func Vochain(vconfig *config.VochainCfg, results, waitForSync bool, ma *metrics.Agent, cm *census.Manager) (vnode *vochain.BaseApplication, sc *scrutinizer.Scrutinizer, vi *vochaininfo.VochainInfo, err error) {
+func Vochain(vconfig *config.VochainCfg, results, indexTxs, waitForSync bool, ma *metrics.Agent, cm *census.Manager, storage data.Storage) (vnode *vochain.BaseApplication, sc *scrutinizer.Scrutinizer, idx *indexer.Indexer, vi *vochaininfo.VochainInfo, err error) {
 	log.Infof("creating vochain service for network %s", vconfig.Chain)
 	var host, port string
 	var ip net.IP
@@ -102,6 +104,11 @@ func Vochain(vconfig *config.VochainCfg, results, waitForSync bool, ma *metrics.
 		vconfig.TendermintMetrics = true
 	}
 
+	// Restore the state from a snapshot (only if the state is empty)
+	if err = vochain.BootstrapFromSnapshot(vconfig, storage); err != nil {
+		return
+	}
+
 	vnode = vochain.NewVochain(vconfig, genesisBytes)
 	// Scrutinizer
 	if results {
@@ -111,6 +118,14 @@ func Vochain(vconfig *config.VochainCfg, results, waitForSync bool, ma *metrics.
 			return
 		}
 	}
+	// Transaction indexer
+	if indexTxs {
+		log.Info("creating vochain transaction indexer service")
+		idx, err = indexer.NewIndexer(vconfig.DataDir+"/indexer", vnode.State)
+		if err != nil {
+			return
+		}
+	}
 	if cm != nil {
 		log.Infof("starting census downloader service")
 		censusdownloader.NewCensusDownloader(vnode, cm, !vconfig.ImportPreviousCensus)
@@ -10,11 +10,15 @@ import (
 	"sync/atomic"
 
 	"github.com/deroproject/graviton"
+	"github.com/tendermint/tendermint/crypto/merkle"
 	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/statedb"
 )
 
+// ProofType identifies the proof operations of GravitonTree proofs (see ValueOp)
+const ProofType = "graviton:v"
+
 type GravitonState struct {
 	store             *graviton.Store
 	hash              []byte
@@ -297,12 +301,14 @@ func (t *GravitonTree) Get(key []byte) []byte {
 
 func (t *GravitonTree) Add(key, value []byte) error {
 	// if already exist, just return
-	if v, err := t.tree.Get(key); err == nil && string(v) == string(value) {
+	v, err := t.tree.Get(key)
+	exists := err == nil
+	if exists && string(v) == string(value) {
 		return nil
 	}
-	// if it does not exist, add, increase size counter and return
-	err := t.tree.Put(key, value)
-	if err == nil {
+	// add or update, increase size counter only if it is a new key and return
+	err = t.tree.Put(key, value)
+	if err == nil && !exists {
 		atomic.AddUint64(&t.size, 1)
 	}
 	return err
@@ -399,3 +405,73 @@ func Verify(key, proof, root []byte) (bool, error) {
 	copy(r[:], root[:32])
 	return p.VerifyMembership(r, key), nil
 }
+
+// VerifyValue checks that key is part of the tree identified by root and its value is value
+func VerifyValue(key, value, proof, root []byte) (bool, error) {
+	var p graviton.Proof
+	var r [32]byte
+	if err := p.Unmarshal(proof); err != nil {
+		return false, err
+	}
+	if len(root) != 32 {
+		return false, fmt.Errorf("root hash size is not correct")
+	}
+	copy(r[:], root[:32])
+	if !p.VerifyMembership(r, key) {
+		return false, nil
+	}
+	return bytes.Equal(p.Value(), value), nil
+}
+
+// ValueOp proves a key and value against the root of a GravitonTree, it implements the
+// Tendermint merkle.ProofOperator interface. Graviton proofs cannot compute the tree root,
+// so the root is part of the operation and Run fails if the proof is not valid for it.
+type ValueOp struct {
+	key   []byte
+	Root  []byte
+	Proof []byte
+}
+
+// NewValueOp returns the operation proving key against root with a GravitonTree proof
+func NewValueOp(key, root, proof []byte) ValueOp {
+	return ValueOp{key: key, Root: root, Proof: proof}
+}
+
+// ValueOpDecoder decodes a ValueOp, to be registered on a merkle.ProofRuntime for ProofType
+func ValueOpDecoder(pop merkle.ProofOp) (merkle.ProofOperator, error) {
+	if pop.Type != ProofType {
+		return nil, fmt.Errorf("unexpected proof op type %s, expected %s", pop.Type, ProofType)
+	}
+	if len(pop.Data) <= 32 {
+		return nil, fmt.Errorf("proof op data too short")
+	}
+	return NewValueOp(pop.Key, pop.Data[:32], pop.Data[32:]), nil
+}
+
+// ProofOp encodes the operation, its data is the root followed by the marshaled graviton.Proof
+func (op ValueOp) ProofOp() merkle.ProofOp {
+	data := make([]byte, 0, len(op.Root)+len(op.Proof))
+	data = append(data, op.Root...)
+	data = append(data, op.Proof...)
+	return merkle.ProofOp{Type: ProofType, Key: op.key, Data: data}
+}
+
+// GetKey returns the key proved by the operation
+func (op ValueOp) GetKey() []byte {
+	return op.key
+}
+
+// Run checks the value args[0] against the proof and returns the tree root
+func (op ValueOp) Run(args [][]byte) ([][]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 value, got %d", len(args))
+	}
+	valid, err := VerifyValue(op.key, args[0], op.Proof, op.Root)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid proof for key %x", op.key)
+	}
+	return [][]byte{op.Root}, nil
+}
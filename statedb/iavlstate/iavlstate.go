@@ -1,19 +1,25 @@
 package iavlstate
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
 	"strconv"
 	"sync"
 
 	"github.com/tendermint/iavl"
+	"github.com/tendermint/tendermint/crypto/merkle"
 	tmdb "github.com/tendermint/tm-db"
 	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
+	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/statedb"
 )
 
 const PrefixDBCacheSize = 1024
 
+// ProofType identifies the proofs generated by IavlTree (an encoded iavl value operation)
+const ProofType = iavl.ProofOpIAVLValue
+
 type IavlState struct {
 	dataDir     string
 	dataType    string
@@ -22,6 +28,14 @@ type IavlState struct {
 	versionTree *iavl.MutableTree // For each tree, saves its last commited version
 	storageType string            // mem or disk
 	db          tmdb.DB
+	treeDBs     []tmdb.DB
+
+	// pruning policy, see SetPruning
+	keepRecent    int64
+	keepEvery     int64
+	prunedVersion int64 // versions below or equal have already been pruned
+	pruning       bool  // the versions are being deleted in the background (see prune)
+	closed        bool
 }
 
 type IavlTree struct {
@@ -148,6 +162,7 @@ func (i *IavlState) AddTree(name string) error {
 	if t, err = iavl.NewMutableTree(st, PrefixDBCacheSize); err != nil {
 		return err
 	}
+	i.treeDBs = append(i.treeDBs, st)
 
 	i.trees[name] = &IavlTree{
 		tree:                t,
@@ -191,9 +206,91 @@ func (i *IavlState) Commit() ([]byte, error) { // Returns New Hash
 	if err := i.updateImmutables(); err != nil {
 		return nil, err
 	}
+	if i.keepRecent > 0 && !i.pruning {
+		i.pruning = true
+		go i.prune()
+	}
 	return i.getHash(), nil
 }
 
+// SetPruning enables the deletion of old versions after each Commit. The last keepRecent
+// versions are kept, plus every keepEvery version as a checkpoint (zero means no checkpoints).
+// At least two recent versions are required, since the previous version is loaded on startup
+// (see LoadVersion). The pruned versions cannot be loaded anymore.
+func (i *IavlState) SetPruning(keepRecent, keepEvery int64) error {
+	if keepRecent < 2 {
+		return fmt.Errorf("at least 2 recent versions must be kept")
+	}
+	if keepEvery < 0 {
+		return fmt.Errorf("invalid checkpoint interval %d", keepEvery)
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.keepRecent = keepRecent
+	i.keepEvery = keepEvery
+	return nil
+}
+
+// prune deletes the versions out of the pruning policy in the background. The lock is held
+// for deleting each version, so the next blocks are only delayed by a single deletion. The
+// trees can be written meanwhile: a deleted version only removes the nodes orphaned by the
+// later versions, and the node database has its own lock.
+func (i *IavlState) prune() {
+	for i.pruneNextVersion() {
+	}
+}
+
+// pruneNextVersion deletes the version after the last pruned one, if it is out of the pruning
+// policy. It returns false once no more versions can be deleted, finishing the pruning.
+func (i *IavlState) pruneNextVersion() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	v := i.prunedVersion + 1
+	if i.closed || v > i.versionTree.Version()-i.keepRecent {
+		i.pruning = false
+		return false
+	}
+	if i.keepEvery == 0 || v%i.keepEvery != 0 {
+		deleted, err := i.deleteVersion(v)
+		if err != nil {
+			log.Warnf("cannot delete state version %d: (%s)", v, err)
+		}
+		if err != nil || !deleted {
+			// the version is pruned again after the next Commit
+			i.pruning = false
+			return false
+		}
+	}
+	i.prunedVersion = v
+	return true
+}
+
+// deleteVersion deletes the version v of all the trees (if it exists), the lock must be held.
+// The trees rolled back to a previous version keep v, and false is returned so it is deleted later.
+func (i *IavlState) deleteVersion(v int64) (bool, error) {
+	deleted := true
+	for name, t := range i.trees {
+		if v >= t.tree.Version() {
+			deleted = false
+			continue
+		}
+		if t.tree.VersionExists(v) {
+			if err := t.tree.DeleteVersion(v); err != nil {
+				return false, fmt.Errorf("tree %s: %w", name, err)
+			}
+		}
+	}
+	if v >= i.versionTree.Version() {
+		return false, nil
+	}
+	if i.versionTree.VersionExists(v) {
+		if err := i.versionTree.DeleteVersion(v); err != nil {
+			return false, err
+		}
+	}
+	return deleted, nil
+}
+
 func (i *IavlState) Rollback() error {
 	i.lock.Lock()
 	defer i.lock.Unlock()
@@ -224,6 +321,14 @@ func (i *IavlState) Hash() []byte {
 }
 
 func (t *IavlState) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.closed = true
+	for _, db := range t.treeDBs {
+		if err := db.Close(); err != nil {
+			return err
+		}
+	}
 	return t.db.Close()
 }
 
@@ -248,16 +353,14 @@ func (t *IavlTree) Add(key, value []byte) error {
 }
 
 func (t *IavlTree) Iterate(prefix []byte, callback func(key, value []byte) bool) {
-	until := make([]byte, len(prefix))
-	copy(until, prefix[:])
-	// Set until to the next prefix: 0xABCDEF => 0xABCDFF
-	for i := len(until) - 1; i >= 0; i-- {
-		if until[i] != byte(0xFF) {
-			until[i] = until[i] + byte(0x01)
-			break
-		}
-		if i == -1 {
-			until = nil
+	// Set until to the next prefix: 0xABCDEF => 0xABCDF0, 0xABFF => 0xAC
+	// If the prefix is empty or all 0xFF, until is nil (iterate until the end)
+	var until []byte
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != byte(0xFF) {
+			until = make([]byte, i+1)
+			copy(until, prefix[:i+1])
+			until[i]++
 			break
 		}
 	}
@@ -301,11 +404,60 @@ func (t *IavlTree) Proof(key []byte) ([]byte, error) {
 	} else {
 		_, p, err = t.tree.GetWithProof(key)
 	}
-
-	return []byte(p.String()), err
+	if err != nil {
+		return nil, err
+	}
+	return iavl.NewValueOp(key, p).ProofOp().Data, nil
 }
 
+// Verify checks the key is part of the tree. If root is nil the tree hash is used.
 func (t *IavlTree) Verify(key, proof, root []byte) bool {
-	// TO-DO
-	return false
+	if root == nil {
+		root = t.Hash()
+	}
+	valid, err := Verify(key, proof, root)
+	return err == nil && valid
+}
+
+// Verify checks that key is part of the tree identified by root
+func Verify(key, proof, root []byte) (bool, error) {
+	op, err := decodeProof(key, proof)
+	if err != nil {
+		return false, err
+	}
+	if err := op.Proof.Verify(root); err != nil {
+		return false, nil
+	}
+	for _, k := range op.Proof.Keys() {
+		if bytes.Equal(k, key) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// VerifyValue checks that key is part of the tree identified by root and its value is value
+func VerifyValue(key, value, proof, root []byte) (bool, error) {
+	op, err := decodeProof(key, proof)
+	if err != nil {
+		return false, err
+	}
+	// Run verifies the value against the proof and returns the computed root
+	r, err := op.Run([][]byte{value})
+	if err != nil || len(r) != 1 {
+		return false, nil
+	}
+	return bytes.Equal(r[0], root), nil
+}
+
+func decodeProof(key, proof []byte) (*iavl.ValueOp, error) {
+	pop, err := iavl.ValueOpDecoder(merkle.ProofOp{Type: ProofType, Key: key, Data: proof})
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode proof: (%s)", err)
+	}
+	op, ok := pop.(iavl.ValueOp)
+	if !ok || op.Proof == nil {
+		return nil, fmt.Errorf("cannot decode proof: unexpected proof operation")
+	}
+	return &op, nil
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestState(t *testing.T) {
@@ -129,6 +130,15 @@ func TestState(t *testing.T) {
 		return false
 	})
 
+	// An empty prefix iterates over the whole tree
+	count := 0
+	s.Tree("t1").Iterate(nil, func(k, v []byte) bool {
+		count++
+		return false
+	})
+	if count != int(s.Tree("t1").Count()) {
+		t.Errorf("iterate with empty prefix returned %d keys, expected %d", count, s.Tree("t1").Count())
+	}
 }
 
 func TestOrder(t *testing.T) {
@@ -166,17 +176,197 @@ func TestOrder(t *testing.T) {
 	}
 
 	// Check Proof generation and validation
-	_, err := s.Tree("t3").Proof([]byte("5"))
+	proof, err := s.Tree("t3").Proof([]byte("5"))
 	if err != nil {
 		t.Error(err)
 	}
 
-	/*	if ok := s.Tree("t3").Verify([]byte("5"), proof, nil); !ok {
-			t.Errorf("proof is invalid, should be valid")
+	if ok := s.Tree("t3").Verify([]byte("5"), proof, nil); !ok {
+		t.Errorf("proof is invalid, should be valid")
+	}
+
+	if ok := s.Tree("t3").Verify([]byte("_"), proof, nil); ok {
+		t.Errorf("proof is valid, should be invalid")
+	}
+
+	// Check value verification against the root
+	root := s.Tree("t3").Hash()
+	if ok, err := VerifyValue([]byte("5"), []byte("number 5"), proof, root); err != nil || !ok {
+		t.Errorf("value proof is invalid, should be valid: %v", err)
+	}
+	if ok, _ := VerifyValue([]byte("5"), []byte("number 6"), proof, root); ok {
+		t.Errorf("value proof is valid for a wrong value")
+	}
+	if ok, _ := VerifyValue([]byte("5"), []byte("number 5"), proof, s.Tree("t4").Hash()[:31]); ok {
+		t.Errorf("value proof is valid for a wrong root")
+	}
+}
+
+func TestPruning(t *testing.T) {
+	t.Parallel()
+
+	s := &IavlState{}
+	if err := s.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadVersion(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPruning(1, 0); err == nil {
+		t.Errorf("pruning policy keeping a single version accepted")
+	}
+
+	// Commit 10 versions, the pruning is enabled afterwards and run manually
+	for i := 1; i <= 10; i++ {
+		s.Tree("t1").Add([]byte(fmt.Sprintf("%d", i)), []byte(fmt.Sprintf("number %d", i)))
+		if _, err := s.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetPruning(2, 4); err != nil {
+		t.Fatal(err)
+	}
+	s.prune()
+	if s.prunedVersion != 8 {
+		t.Errorf("pruned version is %d, expected 8", s.prunedVersion)
+	}
+
+	// Keep the last 2 versions and every 4th version
+	tree := s.trees["t1"].tree
+	for v := int64(1); v <= 10; v++ {
+		keep := v >= 9 || v%4 == 0
+		if tree.VersionExists(v) != keep {
+			t.Errorf("version %d exists: %t, expected %t", v, tree.VersionExists(v), keep)
+		}
+	}
+
+	// Immutable queries and loading the previous version must keep working
+	if v := string(s.ImmutableTree("t1").Get([]byte("10"))); v != "number 10" {
+		t.Errorf("immutable tree value is %q after pruning", v)
+	}
+	if err := s.LoadVersion(-1); err != nil {
+		t.Fatal(err)
+	}
+	if s.Tree("t1").Get([]byte("10")) != nil || string(s.Tree("t1").Get([]byte("9"))) != "number 9" {
+		t.Errorf("load version -1 does not work after pruning")
+	}
+}
+
+// TestPruningPending checks that the versions which cannot be deleted from all
+// the trees are not skipped by the next pruning
+func TestPruningPending(t *testing.T) {
+	t.Parallel()
+
+	s := &IavlState{}
+	if err := s.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadVersion(0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		s.Tree("t1").Add([]byte(fmt.Sprintf("%d", i)), []byte(fmt.Sprintf("number %d", i)))
+		if _, err := s.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetPruning(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	// a new tree has no versions yet, so the versions of t1 are deleted but kept as pending
+	if err := s.AddTree("t2"); err != nil {
+		t.Fatal(err)
+	}
+	s.prune()
+	if s.prunedVersion != 0 {
+		t.Errorf("pruned version is %d, expected 0", s.prunedVersion)
+	}
+	if s.trees["t1"].tree.VersionExists(1) {
+		t.Errorf("version 1 of t1 not deleted")
+	}
+	for i := 1; i <= 4; i++ {
+		s.Tree("t2").Add([]byte(fmt.Sprintf("%d", i)), []byte(fmt.Sprintf("number %d", i)))
+		if _, err := s.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		waitPruning(s)
+	}
+	// t2 is at version 4, so its versions up to 3 are deleted
+	if s.prunedVersion != 3 {
+		t.Errorf("pruned version is %d, expected 3", s.prunedVersion)
+	}
+	for v := int64(1); v <= 4; v++ {
+		if keep := v == 4; s.trees["t2"].tree.VersionExists(v) != keep {
+			t.Errorf("version %d of t2 exists: %t, expected %t", v, !keep, keep)
+		}
+	}
+}
+
+// waitPruning waits until the background pruning started by Commit finishes
+func waitPruning(s *IavlState) {
+	for {
+		s.lock.RLock()
+		pruning := s.pruning
+		s.lock.RUnlock()
+		if !pruning {
+			return
 		}
+		time.Sleep(time.Millisecond)
+	}
+}
 
-		if ok := s.Tree("t3").Verify([]byte("_"), proof, nil); ok {
-			t.Errorf("proof is valid, should be invalid")
+// TestPruningWhileWriting must be run with -race, the background pruning must
+// not race with the writes of the next blocks
+func TestPruningWhileWriting(t *testing.T) {
+	t.Parallel()
+
+	s := &IavlState{}
+	if err := s.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadVersion(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPruning(2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 1; i <= 50; i++ {
+			for j := 0; j < 10; j++ {
+				s.Tree("t1").Add([]byte(fmt.Sprintf("%d-%d", i, j)), []byte("value"))
+			}
+			if _, err := s.Commit(); err != nil {
+				t.Error(err)
+				return
+			}
 		}
-	*/
+	}()
+	for {
+		select {
+		case <-done:
+			waitPruning(s)
+			tree := s.trees["t1"].tree
+			for v := int64(1); v <= 50; v++ {
+				if keep := v >= 49; tree.VersionExists(v) != keep {
+					t.Errorf("version %d exists: %t, expected %t", v, tree.VersionExists(v), keep)
+				}
+			}
+			return
+		default:
+			s.Hash()
+			s.Version()
+		}
+	}
 }
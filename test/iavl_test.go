@@ -1,6 +1,7 @@
 package test
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -8,7 +9,9 @@ import (
 	"github.com/tendermint/go-amino"
 	"github.com/tendermint/tendermint/privval"
 
+	"gitlab.com/vocdoni/go-dvote/client"
 	"gitlab.com/vocdoni/go-dvote/test/testcommon"
+	"gitlab.com/vocdoni/go-dvote/types"
 	"gitlab.com/vocdoni/go-dvote/util"
 	"gitlab.com/vocdoni/go-dvote/vochain"
 )
@@ -164,6 +167,61 @@ func TestGetEnvelope(t *testing.T) {
 	}
 }
 
+func TestEnvelopeProof(t *testing.T) {
+	t.Parallel()
+
+	s := testcommon.NewVochainStateWithProcess(t)
+	vote := testcommon.VoteHardcoded()
+	if err := s.AddVote(vote); err != nil {
+		t.Fatal(err)
+	}
+	s.Save()
+
+	envelope, ops, height, err := s.QueryProof(vochain.VoteTree, append(vote.ProcessID, vote.Nullifier...), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots map[string]string
+	if err := json.Unmarshal(ops[1].Data, &roots); err != nil {
+		t.Fatal(err)
+	}
+	p := &client.EnvelopeProof{
+		Envelope:   envelope,
+		Proof:      ops[0].Data,
+		ProofType:  ops[0].Type,
+		StateRoots: roots,
+		Height:     height + 1,
+	}
+	appHash := s.Store.Hash()
+	v, err := client.VerifyEnvelopeProof(vote.ProcessID, vote.Nullifier, p, appHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.VotePackage != vote.VotePackage {
+		t.Errorf("wrong vote package %s", v.VotePackage)
+	}
+
+	// no trusted app hash, even if the gateway reported one
+	p.AppHash = appHash
+	if _, err := client.VerifyEnvelopeProof(vote.ProcessID, vote.Nullifier, p, nil); err == nil {
+		t.Errorf("proof verified without a trusted app hash")
+	}
+	// wrong nullifier
+	if _, err := client.VerifyEnvelopeProof(vote.ProcessID, util.RandomBytes(types.VoteNullifierSize), p, appHash); err == nil {
+		t.Errorf("proof verified for a wrong nullifier")
+	}
+	// wrong app hash
+	if _, err := client.VerifyEnvelopeProof(vote.ProcessID, vote.Nullifier, p, util.RandomBytes(32)); err == nil {
+		t.Errorf("proof verified for a wrong app hash")
+	}
+	// modified envelope
+	p.Envelope = append([]byte{}, envelope...)
+	p.Envelope[len(p.Envelope)-1]++
+	if _, err := client.VerifyEnvelopeProof(vote.ProcessID, vote.Nullifier, p, appHash); err == nil {
+		t.Errorf("proof verified for a modified envelope")
+	}
+}
+
 func TestCountVotes(t *testing.T) {
 	t.Parallel()
 
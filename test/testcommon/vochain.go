@@ -45,14 +45,14 @@ var (
 		Type:                 types.PetitionSign,
 	}
 
-	// privKey e0aa6db5a833531da4d259fb5df210bae481b276dc4c2ab6ab9771569375aed5 for address 06d0d2c41f4560f8ffea1285f44ce0ffa2e19ef0
+	// HardcodedNewProcessTx is not signed, see SignedNewProcessTx
 	HardcodedNewProcessTx = &types.NewProcessTx{
 		EntityID:       "180dd5765d9f7ecef810b565a2e5bd14a3ccd536c442b3de74867df552855e85",
 		MkRoot:         "0x0a975f5cf517899e6116000fd366dc0feb34a2ea1b64e9b213278442dd9852fe",
+		Nonce:          "5592f1c18e2a15953f355c34b247d751da307338c994000b9a65db1dc14cc6c0",
 		NumberOfBlocks: 1000,
 		ProcessID:      "e9d5e8d791f51179e218c606f83f5967ab272292a6dbda887853d81f7a1d5105",
 		ProcessType:    types.PetitionSign,
-		Signature:      "845289a58a4d5292f8353cc29055f5f5ca92579ec4afc5c589107023f8c0d87055f75e2b91a47a15e7d4cc8294de422cf229a5b5731d989a7b439fffa2ee141b01",
 		Type:           "newProcess",
 	}
 
@@ -102,6 +102,23 @@ var (
 	}
 )
 
+// HardcodedOracleKey is the private key of the oracle 06d0d2c41f4560f8ffea1285f44ce0ffa2e19ef0
+const HardcodedOracleKey = "e0aa6db5a833531da4d259fb5df210bae481b276dc4c2ab6ab9771569375aed5"
+
+// SignedNewProcessTx returns a copy of HardcodedNewProcessTx signed by the hardcoded oracle
+func SignedNewProcessTx(tb testing.TB) *types.NewProcessTx {
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.AddHexKey(HardcodedOracleKey); err != nil {
+		tb.Fatal(err)
+	}
+	tx := *HardcodedNewProcessTx
+	var err error
+	if tx.Signature, err = oracle.SignJSON(tx); err != nil {
+		tb.Fatal(err)
+	}
+	return &tx
+}
+
 func NewVochainStateWithOracles(tb testing.TB) *vochain.State {
 	c := amino.NewCodec()
 	vochain.RegisterAmino(c)
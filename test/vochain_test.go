@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"testing"
 
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
 	"gitlab.com/vocdoni/go-dvote/test/testcommon"
 	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/util"
 	"gitlab.com/vocdoni/go-dvote/vochain"
 )
 
@@ -15,7 +19,7 @@ func TestNewProcessTxCheck(t *testing.T) {
 	// t.Parallel()
 
 	s := testcommon.NewVochainStateWithOracles(t)
-	txb, err := json.Marshal(testcommon.HardcodedNewProcessTx)
+	txb, err := json.Marshal(testcommon.SignedNewProcessTx(t))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -24,8 +28,43 @@ func TestNewProcessTxCheck(t *testing.T) {
 		t.Fatal(err)
 	}
 	tx := gtx.(*types.NewProcessTx)
-	if _, err := vochain.NewProcessTxCheck(tx, s); err != nil {
-		t.Errorf("cannot validate new process tx: %s", err)
+	p, err := vochain.NewProcessTxCheck(tx, s)
+	if err != nil {
+		t.Fatalf("cannot validate new process tx: %s", err)
+	}
+	if p.BallotRules != nil {
+		t.Errorf("process created without a ballot rules height has ballot rules")
+	}
+
+	// from the genesis ballot rules height, the processes get the default ballot rules
+	if err := s.SetBallotRulesHeight(1); err != nil {
+		t.Fatal(err)
+	}
+	headerBytes, err := s.Codec.MarshalBinaryBare(tmtypes.Header{Height: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Store.Tree(vochain.AppTree).Add([]byte("header"), headerBytes)
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.AddHexKey(testcommon.HardcodedOracleKey); err != nil {
+		t.Fatal(err)
+	}
+	newTx := *testcommon.HardcodedNewProcessTx
+	newTx.StartBlock = 1
+	if newTx.Signature, err = oracle.SignJSON(newTx); err != nil {
+		t.Fatal(err)
+	}
+	if txb, err = json.Marshal(newTx); err != nil {
+		t.Fatal(err)
+	}
+	if gtx, err = vochain.UnmarshalTx(txb); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = vochain.NewProcessTxCheck(gtx.(*types.NewProcessTx), s); err != nil {
+		t.Fatalf("cannot validate new process tx: %s", err)
+	}
+	if p.BallotRules == nil || *p.BallotRules != *types.DefaultBallotRules() {
+		t.Errorf("process created from the ballot rules height has no default ballot rules")
 	}
 }
 
@@ -82,14 +121,37 @@ func TestCreateProcess(t *testing.T) {
 	// t.Parallel()
 
 	s := testcommon.NewVochainStateWithOracles(t)
-	bytes, err := json.Marshal(*testcommon.HardcodedNewProcessTx)
+	// the nonce is required
+	noNonce := *testcommon.HardcodedNewProcessTx
+	noNonce.Nonce = ""
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.AddHexKey(testcommon.HardcodedOracleKey); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if noNonce.Signature, err = oracle.SignJSON(noNonce); err != nil {
+		t.Fatal(err)
+	}
+	bytes, err := json.Marshal(noNonce)
 	if err != nil {
-		t.Errorf("cannot mashal process: %+v", *testcommon.HardcodedNewProcessTx)
+		t.Fatal(err)
 	}
 	var gtx vochain.GenericTX
 	if gtx, err = vochain.UnmarshalTx(bytes); err != nil {
 		t.Errorf("cannot unmarshal tx")
 	}
+	if _, err = vochain.AddTx(gtx, s, true); err == nil {
+		t.Errorf("process created without nonce")
+	}
+
+	process := testcommon.SignedNewProcessTx(t)
+	bytes, err = json.Marshal(process)
+	if err != nil {
+		t.Errorf("cannot mashal process: %+v", *process)
+	}
+	if gtx, err = vochain.UnmarshalTx(bytes); err != nil {
+		t.Errorf("cannot unmarshal tx")
+	}
 	_, err = vochain.AddTx(gtx, s, true)
 	if err != nil {
 		t.Errorf("cannot create process: %s", err)
@@ -99,7 +161,7 @@ func TestCreateProcess(t *testing.T) {
 		t.Errorf("same process added: %s", err)
 	}
 	// cannot add process if not oracle
-	badoracle := testcommon.HardcodedNewProcessTx
+	badoracle := *process
 	badoracle.Signature = "a25259cff9ce3a709e517c6a01e445f216212f58f553fa26d25566b7c731339242ef9a0df0235b53a819a64ebf2c3394fb6b56138c5113cc1905c68ffcebb1971c"
 	bytes, err = json.Marshal(badoracle)
 	if err != nil {
@@ -113,6 +175,479 @@ func TestCreateProcess(t *testing.T) {
 	}
 }
 
+func TestPauseProcess(t *testing.T) {
+	// TODO(mvdan): re-enable once
+	// https://gitlab.com/vocdoni/go-dvote/-/issues/172 is fixed.
+	// t.Parallel()
+
+	s := testcommon.NewVochainStateWithOracles(t)
+	pid := "e9d5e8d791f51179e218c606f83f5967ab272292a6dbda887853d81f7a1d5105"
+	if err := s.AddProcess(*testcommon.ProcessHardcoded, util.Hex2byte(t, pid), ""); err != nil {
+		t.Fatalf("cannot create process: %s", err)
+	}
+
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.AddHexKey("e0aa6db5a833531da4d259fb5df210bae481b276dc4c2ab6ab9771569375aed5"); err != nil {
+		t.Fatal(err)
+	}
+	notOracle := ethereum.NewSignKeys()
+	if err := notOracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	signTx := func(signer *ethereum.SignKeys, txType string) []byte {
+		tx := types.PauseProcessTx{Nonce: util.RandomHex(32), ProcessID: pid, Type: txType}
+		var err error
+		if tx.Signature, err = signer.SignJSON(tx); err != nil {
+			t.Fatal(err)
+		}
+		bytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes
+	}
+	deliverTx := func(bytes []byte) error {
+		gtx, err := vochain.UnmarshalTx(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = vochain.AddTx(gtx, s, true)
+		return err
+	}
+	setPaused := func(signer *ethereum.SignKeys, txType string) error {
+		return deliverTx(signTx(signer, txType))
+	}
+	isPaused := func() bool {
+		p, err := s.Process(util.Hex2byte(t, pid), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.Paused
+	}
+
+	if err := setPaused(oracle, types.TxResumeProcess); err == nil {
+		t.Errorf("non paused process resumed")
+	}
+	if err := setPaused(notOracle, types.TxPauseProcess); err == nil {
+		t.Errorf("process paused by non oracle")
+	}
+	pauseTx := signTx(oracle, types.TxPauseProcess)
+	if err := deliverTx(pauseTx); err != nil {
+		t.Fatalf("cannot pause process: %s", err)
+	}
+	if !isPaused() {
+		t.Fatalf("process not paused")
+	}
+	if err := setPaused(oracle, types.TxPauseProcess); err == nil {
+		t.Errorf("process paused twice")
+	}
+	if err := setPaused(oracle, types.TxResumeProcess); err != nil {
+		t.Fatalf("cannot resume process: %s", err)
+	}
+	if isPaused() {
+		t.Fatalf("process not resumed")
+	}
+	// the same pause transaction cannot be replayed
+	if err := deliverTx(pauseTx); err == nil {
+		t.Errorf("pause process transaction replayed")
+	}
+}
+
+func TestUpdateCensus(t *testing.T) {
+	// TODO(mvdan): re-enable once
+	// https://gitlab.com/vocdoni/go-dvote/-/issues/172 is fixed.
+	// t.Parallel()
+
+	s := testcommon.NewVochainStateWithOracles(t)
+	pid := "e9d5e8d791f51179e218c606f83f5967ab272292a6dbda887853d81f7a1d5105"
+	if err := s.AddProcess(*testcommon.ProcessHardcoded, util.Hex2byte(t, pid), ""); err != nil {
+		t.Fatalf("cannot create process: %s", err)
+	}
+
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.AddHexKey("e0aa6db5a833531da4d259fb5df210bae481b276dc4c2ab6ab9771569375aed5"); err != nil {
+		t.Fatal(err)
+	}
+	notOracle := ethereum.NewSignKeys()
+	if err := notOracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	signTx := func(signer *ethereum.SignKeys, mkroot string, acceptPrevious bool) []byte {
+		tx := types.UpdateCensusTx{
+			AcceptPreviousMkRoots: acceptPrevious,
+			MkRoot:                mkroot,
+			MkURI:                 "ipfs://123456789",
+			Nonce:                 util.RandomHex(32),
+			ProcessID:             pid,
+			Type:                  types.TxUpdateCensus,
+		}
+		var err error
+		if tx.Signature, err = signer.SignJSON(tx); err != nil {
+			t.Fatal(err)
+		}
+		bytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes
+	}
+	deliverTx := func(bytes []byte) error {
+		gtx, err := vochain.UnmarshalTx(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = vochain.AddTx(gtx, s, true)
+		return err
+	}
+	process := func() *types.Process {
+		p, err := s.Process(util.Hex2byte(t, pid), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	firstRoot := testcommon.ProcessHardcoded.MkRoot
+	secondRoot, thirdRoot := util.RandomHex(32), util.RandomHex(32)
+	if err := deliverTx(signTx(notOracle, secondRoot, false)); err == nil {
+		t.Errorf("census updated by non oracle")
+	}
+	if err := deliverTx(signTx(oracle, firstRoot, false)); err == nil {
+		t.Errorf("census updated with the current root")
+	}
+	if err := deliverTx(signTx(oracle, "not hex", false)); err == nil {
+		t.Errorf("census updated with a malformed root")
+	}
+	updateTx := signTx(oracle, secondRoot, false)
+	if err := deliverTx(updateTx); err != nil {
+		t.Fatalf("cannot update census: %s", err)
+	}
+	if p := process(); p.MkRoot != secondRoot || p.IsValidCensusRoot(firstRoot) {
+		t.Fatalf("census not updated: %+v", p)
+	}
+	// the same update transaction cannot be replayed
+	if err := deliverTx(updateTx); err == nil {
+		t.Errorf("update census transaction replayed")
+	}
+	if err := deliverTx(signTx(oracle, thirdRoot, true)); err != nil {
+		t.Fatalf("cannot update census: %s", err)
+	}
+	p := process()
+	if len(p.PreviousMkRoots) != 2 || p.PreviousMkRoots[0] != firstRoot || p.PreviousMkRoots[1] != secondRoot {
+		t.Fatalf("unexpected census root history %v", p.PreviousMkRoots)
+	}
+	// the URI of each previous root is kept on the same position
+	if len(p.PreviousMkURIs) != 2 || p.PreviousMkURIs[0] != "" ||
+		p.PreviousMkURIs[1] != "ipfs://123456789" || p.MkURI != "ipfs://123456789" {
+		t.Fatalf("unexpected census URI history %v (current %s)", p.PreviousMkURIs, p.MkURI)
+	}
+	for _, root := range []string{firstRoot, secondRoot, thirdRoot} {
+		if !p.IsValidCensusRoot(root) {
+			t.Errorf("census root %s should be accepted", root)
+		}
+	}
+
+	if err := s.CancelProcess(util.Hex2byte(t, pid)); err != nil {
+		t.Fatal(err)
+	}
+	if err := deliverTx(signTx(oracle, util.RandomHex(32), false)); err == nil {
+		t.Errorf("census of a canceled process updated")
+	}
+}
+
+func TestAdminTxReplay(t *testing.T) {
+	// TODO(mvdan): re-enable once
+	// https://gitlab.com/vocdoni/go-dvote/-/issues/172 is fixed.
+	// t.Parallel()
+
+	s := testcommon.NewVochainStateWithOracles(t)
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.AddHexKey("e0aa6db5a833531da4d259fb5df210bae481b276dc4c2ab6ab9771569375aed5"); err != nil {
+		t.Fatal(err)
+	}
+	newOracle := ethereum.NewSignKeys()
+	if err := newOracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	signTx := func(txType, nonce string) []byte {
+		tx := types.AdminTx{Address: newOracle.AddressString(), Nonce: nonce, Type: txType}
+		var err error
+		if tx.Signature, err = oracle.SignJSON(tx); err != nil {
+			t.Fatal(err)
+		}
+		bytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes
+	}
+	deliverTx := func(bytes []byte) error {
+		gtx, err := vochain.UnmarshalTx(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = vochain.AddTx(gtx, s, true)
+		return err
+	}
+
+	if err := deliverTx(signTx(types.TxAddOracle, "")); err == nil {
+		t.Errorf("admin transaction without nonce accepted")
+	}
+	addTx := signTx(types.TxAddOracle, util.RandomHex(32))
+	if err := deliverTx(addTx); err != nil {
+		t.Fatalf("cannot add oracle: %s", err)
+	}
+	if err := deliverTx(signTx(types.TxRemoveOracle, util.RandomHex(32))); err != nil {
+		t.Fatalf("cannot remove oracle: %s", err)
+	}
+	// replaying the addOracle transaction must fail
+	if err := deliverTx(addTx); err == nil {
+		t.Errorf("add oracle transaction replayed")
+	}
+	oracles, err := s.Oracles(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range oracles {
+		if o == util.TrimHex(newOracle.AddressString()) {
+			t.Errorf("removed oracle added again by a replayed transaction")
+		}
+	}
+}
+
+func TestOracleThreshold(t *testing.T) {
+	// TODO(mvdan): re-enable once
+	// https://gitlab.com/vocdoni/go-dvote/-/issues/172 is fixed.
+	// t.Parallel()
+
+	s := testcommon.NewVochainStateWithOracles(t)
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.AddHexKey("e0aa6db5a833531da4d259fb5df210bae481b276dc4c2ab6ab9771569375aed5"); err != nil {
+		t.Fatal(err)
+	}
+	coOracle := ethereum.NewSignKeys()
+	if err := coOracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddOracle(coOracle.AddressString()); err != nil {
+		t.Fatal(err)
+	}
+	notOracle := ethereum.NewSignKeys()
+	if err := notOracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	// six oracles are required by the cancelProcess threshold
+	if err := s.SetOracleThresholds(types.OracleThresholds{Admin: 2, CancelProcess: 6}); err != nil {
+		t.Fatal(err)
+	}
+
+	signTx := func(txType string, signers ...*ethereum.SignKeys) []byte {
+		tx := types.AdminTx{Address: notOracle.AddressString(), Nonce: util.RandomHex(32), Type: txType}
+		// all the signers sign the transaction without signatures
+		var signatures []string
+		for _, signer := range signers {
+			signature, err := signer.SignJSON(tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signatures = append(signatures, signature)
+		}
+		tx.Signature, tx.Signatures = signatures[0], signatures[1:]
+		bytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes
+	}
+	deliverTx := func(bytes []byte) error {
+		gtx, err := vochain.UnmarshalTx(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = vochain.AddTx(gtx, s, true)
+		return err
+	}
+
+	if err := deliverTx(signTx(types.TxAddOracle, oracle)); err == nil {
+		t.Errorf("admin transaction accepted with a single oracle signature")
+	}
+	if err := deliverTx(signTx(types.TxAddOracle, oracle, oracle)); err == nil {
+		t.Errorf("admin transaction accepted with a duplicated oracle signature")
+	}
+	if err := deliverTx(signTx(types.TxAddOracle, oracle, notOracle)); err == nil {
+		t.Errorf("admin transaction accepted with a non oracle signature")
+	}
+	if err := deliverTx(signTx(types.TxRemoveOracle, oracle, coOracle)); err == nil {
+		t.Errorf("oracle removed below the oracle signature thresholds")
+	}
+	if err := deliverTx(signTx(types.TxAddOracle, oracle, coOracle)); err != nil {
+		t.Fatalf("cannot add oracle with two oracle signatures: %s", err)
+	}
+	oracles, err := s.Oracles(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(oracles) != 7 {
+		t.Errorf("expected 7 oracles, got %d", len(oracles))
+	}
+}
+
+func TestOracleThresholdProcess(t *testing.T) {
+	// TODO(mvdan): re-enable once
+	// https://gitlab.com/vocdoni/go-dvote/-/issues/172 is fixed.
+	// t.Parallel()
+
+	s := testcommon.NewVochainStateWithOracles(t)
+	oracles := testcommon.CreateEthRandomKeysBatch(t, 3)
+	var addrs []string
+	for _, oracle := range oracles {
+		addrs = append(addrs, oracle.AddressString())
+	}
+	oraclesBytes, err := s.Codec.MarshalBinaryBare(addrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store.Tree(vochain.AppTree).Add([]byte("oracle"), oraclesBytes); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetOracleThresholds(types.OracleThresholds{CancelProcess: 2, NewProcess: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	newProcessTx := func(pid string) types.NewProcessTx {
+		return types.NewProcessTx{
+			EntityID:       "180dd5765d9f7ecef810b565a2e5bd14a3ccd536c442b3de74867df552855e85",
+			MkRoot:         "0a975f5cf517899e6116000fd366dc0feb34a2ea1b64e9b213278442dd9852fe",
+			Nonce:          util.RandomHex(32),
+			NumberOfBlocks: 1000,
+			ProcessID:      pid,
+			ProcessType:    types.PetitionSign,
+			Type:           types.TxNewProcess,
+		}
+	}
+	// sign returns the signatures of tx, which must have no signatures
+	sign := func(tx interface{}, signers ...*ethereum.SignKeys) []string {
+		var signatures []string
+		for _, signer := range signers {
+			signature, err := signer.SignJSON(tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signatures = append(signatures, signature)
+		}
+		return signatures
+	}
+	deliverTx := func(tx interface{}) error {
+		bytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gtx, err := vochain.UnmarshalTx(bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = vochain.AddTx(gtx, s, true)
+		return err
+	}
+	coSignTx := func(tx interface{}) types.CoSignTx {
+		bytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return types.CoSignTx{Tx: bytes, Type: types.TxCoSign}
+	}
+	processExists := func(pid string) bool {
+		_, err := s.Process(util.Hex2byte(t, pid), false)
+		return err == nil
+	}
+
+	// 1-of-3 is rejected and 2-of-3 is accepted
+	pid := "e9d5e8d791f51179e218c606f83f5967ab272292a6dbda887853d81f7a1d5105"
+	tx := newProcessTx(pid)
+	signatures := sign(tx, oracles[0], oracles[1])
+	tx.Signature = signatures[0]
+	if err := deliverTx(tx); err == nil {
+		t.Fatal("process created with one of three oracle signatures")
+	}
+	tx.Signatures = signatures[1:]
+	if err := deliverTx(tx); err != nil {
+		t.Fatalf("cannot create process with two of three oracle signatures: %s", err)
+	}
+	if !processExists(pid) {
+		t.Fatal("process not created")
+	}
+
+	cancelTx := types.CancelProcessTx{Nonce: util.RandomHex(32), ProcessID: pid, Type: types.TxCancelProcess}
+	signatures = sign(cancelTx, oracles[1], oracles[2])
+	cancelTx.Signature = signatures[0]
+	if err := deliverTx(cancelTx); err == nil {
+		t.Fatal("process canceled with one of three oracle signatures")
+	}
+	cancelTx.Signatures = signatures[1:]
+	if err := deliverTx(cancelTx); err != nil {
+		t.Fatalf("cannot cancel process with two of three oracle signatures: %s", err)
+	}
+	if p, err := s.Process(util.Hex2byte(t, pid), false); err != nil || !p.Canceled {
+		t.Fatalf("process not canceled (%v)", err)
+	}
+
+	// each oracle sends the transaction with its own signature, and it is executed
+	// once two of them co-signed it
+	pid = "f9d5e8d791f51179e218c606f83f5967ab272292a6dbda887853d81f7a1d5105"
+	tx = newProcessTx(pid)
+	cosignTxs := make([]types.CoSignTx, len(oracles))
+	for i, oracle := range oracles {
+		signed := tx
+		signed.Signature = sign(tx, oracle)[0]
+		cosignTxs[i] = coSignTx(signed)
+	}
+	if err := deliverTx(cosignTxs[0]); err != nil {
+		t.Fatalf("cannot co-sign transaction: %s", err)
+	}
+	if processExists(pid) {
+		t.Fatal("process created with one of three oracle signatures")
+	}
+	if pending := s.PendingCoSignedTxs(false); len(pending) != 1 || len(pending[0].Signatures) != 1 {
+		t.Fatalf("expected a pending transaction with one signature, got %+v", pending)
+	}
+	if err := deliverTx(cosignTxs[0]); err == nil {
+		t.Error("transaction co-signed twice by the same oracle")
+	}
+	if err := deliverTx(cosignTxs[1]); err != nil {
+		t.Fatalf("cannot co-sign transaction: %s", err)
+	}
+	if !processExists(pid) {
+		t.Fatal("process not created with two of three oracle signatures")
+	}
+	if pending := s.PendingCoSignedTxs(false); len(pending) != 0 {
+		t.Errorf("executed transaction still pending: %+v", pending)
+	}
+	if err := deliverTx(cosignTxs[2]); err == nil {
+		t.Error("executed transaction co-signed again")
+	}
+
+	notOracle := ethereum.NewSignKeys()
+	if err := notOracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	cancelTx = types.CancelProcessTx{Nonce: util.RandomHex(32), ProcessID: pid, Type: types.TxCancelProcess}
+	signed := cancelTx
+	signed.Signature = sign(cancelTx, notOracle)[0]
+	if err := deliverTx(coSignTx(signed)); err == nil {
+		t.Error("transaction co-signed by a non oracle")
+	}
+	for _, oracle := range oracles[:2] {
+		signed := cancelTx
+		signed.Signature = sign(cancelTx, oracle)[0]
+		if err := deliverTx(coSignTx(signed)); err != nil {
+			t.Fatalf("cannot co-sign transaction: %s", err)
+		}
+	}
+	if p, err := s.Process(util.Hex2byte(t, pid), false); err != nil || !p.Canceled {
+		t.Fatalf("process not canceled by the co-signed transaction (%v)", err)
+	}
+}
+
 /*
 func TestSubmitEnvelope(t *testing.T) {
 	t.Parallel()
@@ -120,7 +120,8 @@ func (t *Tree) GenProof(index, value []byte) (string, error) {
 	return fmt.Sprintf("%x", proof), nil
 }
 
-// CheckProof standalone function for checking a merkle proof
+// CheckProof standalone function for checking a merkle proof.
+// If value is not empty, the claim value must match too.
 func CheckProof(root, mpHex string, index, value []byte) (bool, error) {
 	p, err := hex.DecodeString(mpHex)
 	if err != nil {
@@ -130,6 +131,9 @@ func CheckProof(root, mpHex string, index, value []byte) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	if len(value) > 0 {
+		return gravitonstate.VerifyValue(index, value, p, r)
+	}
 	return gravitonstate.Verify(index, p, r)
 }
 
@@ -144,6 +148,9 @@ func (t *Tree) CheckProof(index, value []byte, mpHex string) (bool, error) {
 	if tr == nil {
 		return false, fmt.Errorf("tree %s does not exist", t.name)
 	}
+	if len(value) > 0 {
+		return gravitonstate.VerifyValue(index, value, proof, tr.Hash())
+	}
 	return tr.Verify(index, proof, nil), nil
 }
 
@@ -168,17 +175,25 @@ func (t *Tree) treeWithRoot(root string) statedb.StateTree {
 	return t.store.TreeWithRoot(r)
 }
 
-// Dump returns the whole merkle tree serialized in a format that can be used on Import
-func (t *Tree) Dump(root string) (claims []string, err error) {
+// Dump returns the whole merkle tree serialized in a format that can be used on Import.
+// The claim values are returned hex encoded on the same order as the claims, or nil if
+// none of the claims has a value.
+func (t *Tree) Dump(root string) (claims, values []string, err error) {
 	t.updateAccessTime()
 	tree := t.treeWithRoot(root)
 	if tree == nil {
-		return nil, fmt.Errorf("dump: root not found %s", root)
+		return nil, nil, fmt.Errorf("dump: root not found %s", root)
 	}
+	hasValues := false
 	tree.Iterate(nil, func(k, v []byte) bool {
 		claims = append(claims, fmt.Sprintf("%x", k))
+		values = append(values, fmt.Sprintf("%x", v))
+		hasValues = hasValues || len(v) > 0
 		return false
 	})
+	if !hasValues {
+		values = nil
+	}
 	return
 }
 
@@ -219,17 +234,30 @@ func (t *Tree) DumpPlain(root string, responseBase64 bool) ([]string, []string,
 	return indexes, values, err
 }
 
-// ImportDump imports a partial or whole tree previously exported with Dump()
-func (t *Tree) ImportDump(claims []string) error {
+// ImportDump imports a partial or whole tree previously exported with Dump().
+// values might be nil if the claims have no value.
+func (t *Tree) ImportDump(claims, values []string) error {
 	t.updateAccessTime()
+	if values != nil && len(values) != len(claims) {
+		return fmt.Errorf("claims and values size mismatch (%d != %d)", len(claims), len(values))
+	}
 	var cb []byte
 	var err error
-	for _, c := range claims {
+	for i, c := range claims {
 		cb, err = hex.DecodeString(util.TrimHex(c))
 		if err != nil {
 			return err
 		}
-		if err = t.Tree.Add(cb, []byte{}); err != nil {
+		vb := []byte{}
+		if values != nil {
+			if vb, err = hex.DecodeString(util.TrimHex(values[i])); err != nil {
+				return err
+			}
+		}
+		if len(cb) > MaxIndexSize || len(vb) > MaxValueSize {
+			return fmt.Errorf("index or value claim data too big")
+		}
+		if err = t.Tree.Add(cb, vb); err != nil {
 			return err
 		}
 	}
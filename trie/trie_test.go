@@ -3,6 +3,8 @@ package trie
 import (
 	"fmt"
 	"testing"
+
+	"gitlab.com/vocdoni/go-dvote/types"
 )
 
 func TestTree(t *testing.T) {
@@ -17,16 +19,19 @@ func TestTree(t *testing.T) {
 		}
 	}
 	root1 := tr1.Root()
-	claims, err := tr1.Dump(root1)
+	claims, values, err := tr1.Dump(root1)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if values != nil {
+		t.Errorf("dump returned values for claims without value")
+	}
 
 	tr2, err := NewTree("test2", storage)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err = tr2.ImportDump(claims); err != nil {
+	if err = tr2.ImportDump(claims, values); err != nil {
 		t.Fatal(err)
 	}
 	root2 := tr2.Root()
@@ -81,3 +86,52 @@ func TestTree(t *testing.T) {
 	}
 
 }
+
+func TestTreeValues(t *testing.T) {
+	storage := t.TempDir()
+	tr1, err := NewTree("test1", storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err = tr1.AddClaim([]byte(fmt.Sprintf("number %d", i)), types.EncodeCensusWeight(uint64(i+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	claims, values, err := tr1.Dump("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != len(claims) {
+		t.Fatalf("expected %d values, got %d", len(claims), len(values))
+	}
+	tr2, err := NewTree("test2", storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr2.ImportDump(claims, values); err != nil {
+		t.Fatal(err)
+	}
+	if tr1.Root() != tr2.Root() {
+		t.Fatalf("roots are diferent but they should be equals (%s != %s)", tr1.Root(), tr2.Root())
+	}
+
+	proof, err := tr2.GenProof([]byte("number 5"), []byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := CheckProof(tr2.Root(), proof, []byte("number 5"), types.EncodeCensusWeight(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Errorf("proof with the right value is invalid")
+	}
+	valid, err = CheckProof(tr2.Root(), proof, []byte("number 5"), types.EncodeCensusWeight(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Errorf("proof with a wrong value is valid")
+	}
+}
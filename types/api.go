@@ -16,29 +16,35 @@ type RequestMessage struct {
 // MetaRequest contains all of the possible request fields.
 // Fields must be in alphabetical order
 type MetaRequest struct {
-	CensusID   string   `json:"censusId,omitempty"`
-	CensusURI  string   `json:"censusUri,omitempty"`
-	ClaimData  string   `json:"claimData,omitempty"`
-	ClaimsData []string `json:"claimsData,omitempty"`
-	Content    string   `json:"content,omitempty"`
-	Digested   bool     `json:"digested,omitempty"`
-	EntityId   string   `json:"entityId,omitempty"`
-	From       int64    `json:"from,omitempty"`
-	FromID     string   `json:"fromId,omitempty"`
-	ListSize   int64    `json:"listSize,omitempty"`
-	Method     string   `json:"method"`
-	Name       string   `json:"name,omitempty"`
-	Nullifier  string   `json:"nullifier,omitempty"`
-	Payload    *VoteTx  `json:"payload,omitempty"`
-	ProcessID  string   `json:"processId,omitempty"`
-	ProofData  string   `json:"proofData,omitempty"`
-	PubKeys    []string `json:"pubKeys,omitempty"`
-	RawTx      string   `json:"rawTx,omitempty"`
-	RootHash   string   `json:"rootHash,omitempty"`
-	Signature  string   `json:"signature,omitempty"`
-	Timestamp  int32    `json:"timestamp"`
-	Type       string   `json:"type,omitempty"`
-	URI        string   `json:"uri,omitempty"`
+	Address      string   `json:"address,omitempty"`
+	CensusID     string   `json:"censusId,omitempty"`
+	CensusURI    string   `json:"censusUri,omitempty"`
+	ClaimData    string   `json:"claimData,omitempty"`
+	ClaimsData   []string `json:"claimsData,omitempty"`
+	ClaimsValues []string `json:"claimsValues,omitempty"`
+	Content      string   `json:"content,omitempty"`
+	Digested     bool     `json:"digested,omitempty"`
+	EntityId     string   `json:"entityId,omitempty"`
+	From         int64    `json:"from,omitempty"`
+	FromID       string   `json:"fromId,omitempty"`
+	Hash         string   `json:"hash,omitempty"`
+	Height       int64    `json:"height,omitempty"`
+	ListSize     int64    `json:"listSize,omitempty"`
+	Method       string   `json:"method"`
+	Name         string   `json:"name,omitempty"`
+	Nullifier    string   `json:"nullifier,omitempty"`
+	Payload      *VoteTx  `json:"payload,omitempty"`
+	ProcessID    string   `json:"processId,omitempty"`
+	ProofData    string   `json:"proofData,omitempty"`
+	PubKeys      []string `json:"pubKeys,omitempty"`
+	RawTx        string   `json:"rawTx,omitempty"`
+	RootHash     string   `json:"rootHash,omitempty"`
+	Signature    string   `json:"signature,omitempty"`
+	Timestamp    int32    `json:"timestamp"`
+	Type         string   `json:"type,omitempty"`
+	URI          string   `json:"uri,omitempty"`
+	Weight       string   `json:"weight,omitempty"`
+	Weights      []string `json:"weights,omitempty"`
 }
 
 // ResponseMessage wraps an api response
@@ -53,43 +59,55 @@ type ResponseMessage struct {
 // Fields must be in alphabetical order
 // Those fields with valid zero-values (such as bool) must be pointers
 type MetaResponse struct {
-	APIList              []string   `json:"apiList,omitempty"`
-	BlockTime            *[5]int32  `json:"blockTime,omitempty"`
-	BlockTimestamp       int32      `json:"blockTimestamp,omitempty"`
-	CensusID             string     `json:"censusId,omitempty"`
-	CensusList           []string   `json:"censusList,omitempty"`
-	ClaimsData           []string   `json:"claimsData,omitempty"`
-	CommitmentKeys       []Key      `json:"commitmentKeys,omitempty"`
-	Content              string     `json:"content,omitempty"`
-	EncryptionPrivKeys   []Key      `json:"encryptionPrivKeys,omitempty"`
-	EncryptionPublicKeys []Key      `json:"encryptionPubKeys,omitempty"`
-	EntityID             string     `json:"entityId,omitempty"`
-	EntityIDs            []string   `json:"entityIds,omitempty"`
-	Files                []byte     `json:"files,omitempty"`
-	Finished             *bool      `json:"finished,omitempty"`
-	Health               int32      `json:"health,omitempty"`
-	Height               *int64     `json:"height,omitempty"`
-	InvalidClaims        []int      `json:"invalidClaims,omitempty"`
-	Message              string     `json:"message,omitempty"`
-	Nullifier            string     `json:"nullifier,omitempty"`
-	Nullifiers           *[]string  `json:"nullifiers,omitempty"`
-	Ok                   bool       `json:"ok"`
-	Paused               *bool      `json:"paused,omitempty"`
-	Payload              string     `json:"payload,omitempty"`
-	ProcessIDs           []string   `json:"processIds,omitempty"`
-	ProcessList          []string   `json:"processList,omitempty"`
-	Registered           *bool      `json:"registered,omitempty"`
-	Request              string     `json:"request"`
-	Results              [][]uint32 `json:"results,omitempty"`
-	RevealKeys           []Key      `json:"revealKeys,omitempty"`
-	Root                 string     `json:"root,omitempty"`
-	Siblings             string     `json:"siblings,omitempty"`
-	Size                 *int64     `json:"size,omitempty"`
-	State                string     `json:"state,omitempty"`
-	Timestamp            int32      `json:"timestamp"`
-	Type                 string     `json:"type,omitempty"`
-	URI                  string     `json:"uri,omitempty"`
-	ValidProof           *bool      `json:"validProof,omitempty"`
+	APIList              []string             `json:"apiList,omitempty"`
+	AppHash              string               `json:"appHash,omitempty"`
+	BlockTime            *[5]int32            `json:"blockTime,omitempty"`
+	BlockTimestamp       int32                `json:"blockTimestamp,omitempty"`
+	CensusID             string               `json:"censusId,omitempty"`
+	CensusList           []string             `json:"censusList,omitempty"`
+	ClaimsData           []string             `json:"claimsData,omitempty"`
+	ClaimsValues         []string             `json:"claimsValues,omitempty"`
+	CommitmentKeys       []Key                `json:"commitmentKeys,omitempty"`
+	Content              string               `json:"content,omitempty"`
+	EncryptionPrivKeys   []Key                `json:"encryptionPrivKeys,omitempty"`
+	EncryptionPublicKeys []Key                `json:"encryptionPubKeys,omitempty"`
+	EntityID             string               `json:"entityId,omitempty"`
+	EntityIDs            []string             `json:"entityIds,omitempty"`
+	Envelope             string               `json:"envelope,omitempty"`
+	Files                []byte               `json:"files,omitempty"`
+	Finished             *bool                `json:"finished,omitempty"`
+	Hash                 string               `json:"hash,omitempty"`
+	Health               int32                `json:"health,omitempty"`
+	Height               *int64               `json:"height,omitempty"`
+	InvalidClaims        []int                `json:"invalidClaims,omitempty"`
+	Message              string               `json:"message,omitempty"`
+	Nullifier            string               `json:"nullifier,omitempty"`
+	Nullifiers           *[]string            `json:"nullifiers,omitempty"`
+	Ok                   bool                 `json:"ok"`
+	Paused               *bool                `json:"paused,omitempty"`
+	Payload              string               `json:"payload,omitempty"`
+	ProcessIDs           []string             `json:"processIds,omitempty"`
+	ProcessList          []string             `json:"processList,omitempty"`
+	Proof                string               `json:"proof,omitempty"`
+	ProofType            string               `json:"proofType,omitempty"`
+	RankedResults        *RankedChoiceResults `json:"rankedResults,omitempty"`
+	Registered           *bool                `json:"registered,omitempty"`
+	Request              string               `json:"request"`
+	Results              [][]uint64           `json:"results,omitempty"`
+	RevealKeys           []Key                `json:"revealKeys,omitempty"`
+	Root                 string               `json:"root,omitempty"`
+	Siblings             string               `json:"siblings,omitempty"`
+	Size                 *int64               `json:"size,omitempty"`
+	State                string               `json:"state,omitempty"`
+	StateRoots           map[string]string    `json:"stateRoots,omitempty"`
+	TallyMode            string               `json:"tallyMode,omitempty"`
+	Timestamp            int32                `json:"timestamp"`
+	Tx                   *TxReference         `json:"tx,omitempty"`
+	TxList               []string             `json:"txList,omitempty"`
+	Txs                  []*TxReference       `json:"txs,omitempty"`
+	Type                 string               `json:"type,omitempty"`
+	URI                  string               `json:"uri,omitempty"`
+	ValidProof           *bool                `json:"validProof,omitempty"`
 }
 
 // SetError sets the MetaResponse's Ok field to false, and Message to a string
@@ -100,8 +118,19 @@ func (r *MetaResponse) SetError(v interface{}) {
 }
 
 type CensusDump struct {
-	RootHash   string   `json:"rootHash"`
-	ClaimsData []string `json:"claimsData"`
+	RootHash     string   `json:"rootHash"`
+	ClaimsData   []string `json:"claimsData"`
+	ClaimsValues []string `json:"claimsValues,omitempty"`
+}
+
+// RankedChoiceResults contains the instant runoff rounds of a ranked-choice process
+type RankedChoiceResults struct {
+	// Eliminated contains the option eliminated at the end of each round
+	Eliminated []int `json:"eliminated"`
+	// Rounds contains the votes of each option on each round
+	Rounds [][]uint64 `json:"rounds"`
+	// Winner is the option which reached the majority, -1 if there is no winner (tie or no votes)
+	Winner int `json:"winner"`
 }
 
 // VotePackage represents the payload of a vote (usually base64 encoded)
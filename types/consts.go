@@ -21,6 +21,7 @@ const (
 	// this is a temporal work around to support both
 	EntityIDsizeV2                 = 32
 	VoteNullifierSize              = 32
+	TxHashSize                     = 32
 	KeyIndexSeparator              = ":"
 	EthereumConfirmationsThreshold = 6
 	EntityResolverDomain           = "entity-resolver.vocdoni.eth"
@@ -38,6 +39,21 @@ const (
 	ScrutinizerResultsPrefix = byte(0x24)
 	// ScrutinizerProcessEndingPrefix is the prefix for keep track of the processes ending on a specific block
 	ScrutinizerProcessEndingPrefix = byte(0x25)
+	// ScrutinizerRankedResultsPrefix is the prefix of the storage ranked-choice results keys
+	ScrutinizerRankedResultsPrefix = byte(0x26)
+	// ScrutinizerProcessEndingTimePrefix is the prefix for keep track of the time scheduled processes until they end
+	ScrutinizerProcessEndingTimePrefix = byte(0x27)
+
+	// Indexer
+
+	// IndexerTxPrefix is the prefix of the storage transaction reference keys (by hash)
+	IndexerTxPrefix = byte(0x31)
+	// IndexerBlockPrefix is the prefix of the storage keys listing the transactions of a block
+	IndexerBlockPrefix = byte(0x32)
+	// IndexerSignerPrefix is the prefix of the storage keys listing the transactions of a signer
+	IndexerSignerPrefix = byte(0x33)
+	// IndexerProcessPrefix is the prefix of the storage keys listing the transactions of a process
+	IndexerProcessPrefix = byte(0x34)
 
 	// Vochain
 
@@ -49,6 +65,12 @@ const (
 	EncryptedPoll = "encrypted-poll"
 	// SnarkVote contains the string that needs to match with the received vote type for snark-vote
 	SnarkVote = "snark-vote"
+	// WeightedPoll contains the string that needs to match with the received vote type for weighted-poll.
+	// The census claim value of each voter is its voting weight (see EncodeCensusWeight)
+	WeightedPoll = "weighted-poll"
+	// OpenPoll contains the string that needs to match with the received vote type for open-poll.
+	// There is no census, any ethereum address can vote once (see AllowListSize and DenyListSize of Process)
+	OpenPoll = "open-poll"
 
 	// List of transation names
 	TxVote              = "vote"
@@ -60,7 +82,65 @@ const (
 	TxRemoveOracle      = "removeOracle"
 	TxAddProcessKeys    = "addProcessKeys"
 	TxRevealProcessKeys = "revealProcessKeys"
+	TxPauseProcess      = "pauseProcess"
+	TxResumeProcess     = "resumeProcess"
+	TxUpdateCensus      = "updateCensus"
+	TxAddProcessKeyDeal = "addProcessKeyDeal"
+	// TxAddProcessKeyComplaint is sent by a keykeeper which received an invalid share from a dealer
+	TxAddProcessKeyComplaint = "addProcessKeyComplaint"
+	// TxRevealProcessKeyDealShare is sent by a dealer for revealing a disputed share
+	TxRevealProcessKeyDealShare = "revealProcessKeyDealShare"
+	// TxCoSign is sent by an oracle for adding its signature to a privileged transaction
+	TxCoSign = "coSign"
 
 	// MaxKeyIndex is the maxim number of allowed Encryption or Commitment keys
 	MaxKeyIndex = 16
+	// ThresholdKeyIndex is the key index of the encryption key of the threshold encrypted processes
+	// (see Process.KeyThreshold). The keykeepers use the indexes from 1 to MaxKeyIndex-1.
+	ThresholdKeyIndex = 0
+	// KeyGenerationPhaseBlocks is the number of blocks of each phase of the threshold key generation
+	// of a block scheduled process (see Process.KeyGenerationPhase)
+	KeyGenerationPhaseBlocks = 3
+	// KeyGenerationPhaseSeconds is the duration of each phase of the threshold key generation of a
+	// time scheduled process (see Process.KeyGenerationPhase)
+	KeyGenerationPhaseSeconds = 60
+
+	// MaxAddressListSize is the maximum number of addresses of the allow and deny lists of an open-poll process
+	MaxAddressListSize = 10000
+
+	// List of census certification authority types (see CensusCA)
+	CensusCAECDSA    = "ecdsa"
+	CensusCABlindRSA = "blind-rsa"
+
+	// CensusWeightSize is the size of the voting weight stored as census claim value
+	CensusWeightSize = 8
+	// MaxVoteWeight is the maximum voting weight of a vote (weighted and token census processes).
+	// It keeps the results of millions of votes far from overflowing the uint64 counters.
+	MaxVoteWeight = 1 << 40
+
+	// List of tally modes (see BallotRules)
+	TallyPlurality    = "plurality"
+	TallyApproval     = "approval"
+	TallyRankedChoice = "ranked-choice"
+	TallyQuadratic    = "quadratic"
+
+	// MaxQuestions is the maximum number of questions allowed in a VotePackage
+	MaxQuestions = 64
+	// MaxOptions is the maximum number of options allowed in a VotePackage question
+	MaxOptions = 64
+)
+
+// Phases of the threshold key generation of a process, which take place before the process
+// starts (see Process.KeyGenerationPhase)
+const (
+	// KeyPhaseKeys the keykeepers publish their process keys
+	KeyPhaseKeys = iota
+	// KeyPhaseDeals the keykeepers send their key deals
+	KeyPhaseDeals
+	// KeyPhaseComplaints the keykeepers complain about the invalid shares received
+	KeyPhaseComplaints
+	// KeyPhaseReveals the dealers reveal the disputed shares
+	KeyPhaseReveals
+	// KeyPhaseDone the process has started and the threshold key is final
+	KeyPhaseDone
 )
@@ -2,6 +2,10 @@ package types
 
 type MessageContext interface {
 	ConnectionType() string
+	// RemoteAddr returns the network address of the client which sent the message
+	RemoteAddr() string
+	// ForwardedFor returns the X-Forwarded-For header of the client request, empty if there is none
+	ForwardedFor() string
 	Send(Message)
 }
 
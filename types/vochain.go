@@ -1,7 +1,10 @@
 package types
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"math/bits"
 	"time"
 
 	// Don't import tendermint/types, because that pulls in lots of indirect
@@ -32,49 +35,203 @@ type Vote struct {
 	Height int64 `json:"height,omitempty"`
 	// Nullifier is the unique identifier of the vote
 	Nullifier []byte `json:"nullifier,omitempty"`
+	// OverwriteCount is the number of times the vote has been replaced by its voter
+	OverwriteCount uint32 `json:"overwriteCount,omitempty"`
 	// ProcessID contains the unique voting process identifier
 	ProcessID []byte `json:"processId,omitempty"`
 	// VotePackage base64 encoded vote content
 	VotePackage string `json:"votePackage,omitempty"`
+	// Weight is the voting weight proven by the voter on weighted processes
+	Weight uint64 `json:"weight,omitempty"`
+}
+
+// TallyWeight returns the weight of the vote when counting the results.
+// Votes of unweighted processes count as one.
+func (v *Vote) TallyWeight() uint64 {
+	if v.Weight == 0 {
+		return 1
+	}
+	return v.Weight
+}
+
+// EncodeCensusWeight returns the census claim value which represents a voting weight
+func EncodeCensusWeight(weight uint64) []byte {
+	value := make([]byte, CensusWeightSize)
+	binary.BigEndian.PutUint64(value, weight)
+	return value
+}
+
+// DecodeCensusWeight returns the voting weight represented by a census claim value
+func DecodeCensusWeight(value []byte) (uint64, error) {
+	if len(value) != CensusWeightSize {
+		return 0, fmt.Errorf("wrong census weight size %d", len(value))
+	}
+	return binary.BigEndian.Uint64(value), nil
 }
 
 // VoteProof contains the proof indicating that the user is in the census of the process
 type VoteProof struct {
-	Proof        string    `json:"proof,omitempty"`
-	PubKey       string    `json:"pubKey,omitempty"`
-	PubKeyDigest []byte    `json:"pubKeyDigest,omitempty"`
-	Nullifier    []byte    `json:"nullifier,omitempty"`
-	Created      time.Time `json:"timestamp"`
+	// MkRoot is the census root the proof was verified against
+	MkRoot       string `json:"mkRoot,omitempty"`
+	Proof        string `json:"proof,omitempty"`
+	PubKey       string `json:"pubKey,omitempty"`
+	PubKeyDigest []byte `json:"pubKeyDigest,omitempty"`
+	Nullifier    []byte `json:"nullifier,omitempty"`
+	Weight       uint64 `json:"weight,omitempty"`
+	Height       int64  `json:"height"`
+	// TxDigest is the hash of the vote transaction the proof was verified for
+	TxDigest []byte `json:"txDigest,omitempty"`
 }
 
 // ________________________ PROCESS ________________________
 
 // Process represents a state per process
 type Process struct {
+	// AcceptPreviousMkRoots if true the census proofs against PreviousMkRoots are also valid
+	AcceptPreviousMkRoots bool `json:"acceptPreviousMkRoots,omitempty"`
+	// AllowListSize if not zero, only the addresses of the allow list can vote on an open-poll process.
+	// The lists are stored on the state, outside the process (see vochain.State.IsAddressAllowed).
+	AllowListSize int `json:"allowListSize,omitempty"`
+	// BallotRules defines the valid vote packages, if nil the default rules are used
+	BallotRules *BallotRules `json:"ballotRules,omitempty"`
 	// Canceled if true process is canceled
 	Canceled bool `json:"canceled,omitempty"`
+	// CensusCA if not nil, the voters prove they belong to the census with a token signed by this CA
+	CensusCA *CensusCA `json:"censusCA,omitempty"`
 	// CommitmentKeys are the reveal keys hashed
 	CommitmentKeys []string `json:"commitmentKeys,omitempty"`
+	// DenyListSize is the number of addresses which cannot vote on an open-poll process
+	DenyListSize int `json:"denyListSize,omitempty"`
 	// EncryptionPrivateKeys are the keys required to decrypt the votes
 	EncryptionPrivateKeys []string `json:"encryptionPrivateKeys,omitempty"`
 	// EncryptionPublicKeys are the keys required to encrypt the votes
 	EncryptionPublicKeys []string `json:"encryptionPublicKeys,omitempty"`
+	// EndTime is the unix timestamp (seconds) where the process goes from active to finished (time scheduled processes)
+	EndTime int64 `json:"endTime,omitempty"`
 	// EntityID identifies unequivocally a process
 	EntityID []byte `json:"entityId,omitempty"`
+	// KeyDeals are the contributions of each keykeeper (by key index) to the threshold encryption key
+	KeyDeals []KeyDeal `json:"keyDeals,omitempty"`
 	// KeyIndex
 	KeyIndex int `json:"keyIndex,omitempty"`
+	// KeyThreshold if not zero, the votes are encrypted with a single key generated by the keykeepers,
+	// which can be decrypted once KeyThreshold keykeepers reveal their key share
+	KeyThreshold uint32 `json:"keyThreshold,omitempty"`
+	// MaxVoteOverwrites is the number of times a voter can replace its vote, only the last one is counted
+	MaxVoteOverwrites uint32 `json:"maxVoteOverwrites,omitempty"`
 	// MkRoot merkle root of all the census in the process
 	MkRoot string `json:"mkRoot,omitempty"`
+	// MkURI is the URI of the census merkle tree of MkRoot
+	MkURI string `json:"mkURI,omitempty"`
 	// NumberOfBlocks represents the amount of tendermint blocks that the process will last
 	NumberOfBlocks int64 `json:"numberOfBlocks,omitempty"`
 	// Paused if true process is paused and cannot add or modify any vote
 	Paused bool `json:"paused,omitempty"`
+	// PreviousMkRoots are the census merkle roots replaced by census updates, the oldest first
+	PreviousMkRoots []string `json:"previousMkRoots,omitempty"`
+	// PreviousMkURIs are the census merkle tree URIs of PreviousMkRoots (same index), so the
+	// previous census can still be retrieved
+	PreviousMkURIs []string `json:"previousMkURIs,omitempty"`
 	// RevealKeys are the seed of the CommitmentKeys
 	RevealKeys []string `json:"revealKeys,omitempty"`
 	// StartBlock represents the tendermint block where the process goes from scheduled to active
 	StartBlock int64 `json:"startBlock,omitempty"`
+	// StartTime is the unix timestamp (seconds) where the process goes from scheduled to active (time scheduled processes)
+	StartTime int64 `json:"startTime,omitempty"`
+	// TokenCensus if not nil, the census are the holders of an Ethereum token instead of a merkle tree
+	TokenCensus *TokenCensus `json:"tokenCensus,omitempty"`
 	// Type represents the process type
 	Type string `json:"type,omitempty"`
+	// ZkVerificationKey is the Groth16 verification key used to check the census proofs of a snark-vote process
+	ZkVerificationKey *ZkVerificationKey `json:"zkVerificationKey,omitempty"`
+}
+
+// TokenCensus defines a census of the holders of an ERC20 token on an Ethereum block.
+// Voters prove their balance with an Ethereum storage proof (see EthStorageProof),
+// which is used as vote weight.
+type TokenCensus struct {
+	// BlockNumber is the Ethereum block of the balances snapshot
+	BlockNumber uint64 `json:"blockNumber"`
+	// Decimals are removed from the balance for computing the vote weight (weight = balance / 10^Decimals),
+	// which must not be greater than MaxVoteWeight
+	Decimals uint32 `json:"decimals,omitempty"`
+	// StateRoot is the Ethereum state root of BlockNumber, set by the oracle (hexadecimal)
+	StateRoot string `json:"stateRoot"`
+	// StorageSlot is the index of the balances mapping on the token contract storage
+	StorageSlot uint64 `json:"storageSlot"`
+	// TokenAddress is the address of the ERC20 token contract
+	TokenAddress string `json:"tokenAddress"`
+}
+
+// CensusCA defines a census held by an external certification authority, which authenticates the
+// voters on its own systems (LDAP, SMS codes...) and gives them a census token: the CA signature
+// of the process ID and the voter address (see CensusCAMessage).
+// With the CensusCABlindRSA type the voter address is blinded before being signed, so the CA cannot
+// link the tokens with the votes. The CA must then issue a single token per process to each voter.
+// Since the CA cannot see what it signs, a blind CA key is only valid for a single process: a new
+// process using the key of any existing process is rejected.
+type CensusCA struct {
+	// PublicKey is the CA public key (hexadecimal). A secp256k1 public key for CensusCAECDSA and a
+	// PKCS #1 encoded RSA public key for CensusCABlindRSA.
+	PublicKey string `json:"publicKey"`
+	// Type is the signature scheme used by the CA
+	Type string `json:"type"`
+}
+
+// CensusCAMessage returns the message signed by a census CA for allowing a voter address to vote
+// on a process: processID ++ address
+func CensusCAMessage(processID, address []byte) []byte {
+	return append(append([]byte{}, processID...), address...)
+}
+
+// KeyDeal is the contribution of a keykeeper to the distributed generation of the threshold
+// encryption key of a process. The dealer shares a random secret using a polynomial of degree
+// KeyThreshold-1, the encryption key is the sum of the secrets of all the dealers.
+type KeyDeal struct {
+	// Commitments are the commitments of the dealer polynomial coefficients (hexadecimal edwards25519
+	// points), the first one is the public key of the dealer secret
+	Commitments []string `json:"commitments"`
+	// Complaints are the key indexes of the keykeepers which complained about the share received
+	Complaints []int `json:"complaints,omitempty"`
+	// RevealedShares are the disputed shares revealed by the dealer (hexadecimal scalars), by key index
+	RevealedShares []string `json:"revealedShares,omitempty"`
+	// Shares are the evaluations of the dealer polynomial at each keykeeper index, encrypted with the
+	// encryption public key of the keykeeper. Empty if the keykeeper has not published its keys.
+	Shares []string `json:"shares"`
+}
+
+// Qualified indicates whether the dealer contributes to the threshold encryption key. The dealer
+// must reveal all the disputed shares, and it is disqualified if there are threshold or more
+// complaints, since revealing threshold shares would reveal its secret.
+func (d *KeyDeal) Qualified(threshold uint32) bool {
+	if len(d.Commitments) == 0 || len(d.Complaints) >= int(threshold) {
+		return false
+	}
+	for _, i := range d.Complaints {
+		if i >= len(d.RevealedShares) || d.RevealedShares[i] == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// HasComplaint returns true if the keykeeper of the given index complained about the deal
+func (d *KeyDeal) HasComplaint(index int) bool {
+	for _, i := range d.Complaints {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// EthStorageProof contains the proof of a token balance, as returned by the eth_getProof
+// Ethereum RPC method. The trie nodes are RLP and hexadecimal encoded.
+type EthStorageProof struct {
+	// AccountProof proves the token contract account against the state root
+	AccountProof []string `json:"accountProof"`
+	// StorageProof proves the balance against the storage root of the token contract account
+	StorageProof []string `json:"storageProof"`
 }
 
 // RequireKeys indicates wheter a process require Encryption or Commitment keys
@@ -87,11 +244,235 @@ func (p *Process) IsEncrypted() bool {
 	return ProcessIsEncrypted[p.Type]
 }
 
+// IsThresholdEncrypted indicates whether the votes are encrypted with a threshold key, stored on
+// the ThresholdKeyIndex of the encryption keys
+func (p *Process) IsThresholdEncrypted() bool {
+	return p.KeyThreshold > 0
+}
+
+// KeyDealers returns the number of qualified keykeepers contributing to the threshold encryption key
+func (p *Process) KeyDealers() int {
+	n := 0
+	for _, d := range p.KeyDeals {
+		if d.Qualified(p.KeyThreshold) {
+			n++
+		}
+	}
+	return n
+}
+
+// KeyGenerationPhase returns the phase of the threshold key generation on a block of the given
+// height and time. The key deals, the complaints and the reveals of the disputed shares take
+// place in the last KeyGenerationPhaseBlocks*3 blocks before the process start (or the last
+// KeyGenerationPhaseSeconds*3 seconds if the process is time scheduled), and the keykeepers
+// publish their keys before.
+func (p *Process) KeyGenerationPhase(height int64, blockTime time.Time) int {
+	remaining, phase := p.StartBlock-height, int64(KeyGenerationPhaseBlocks)
+	if p.IsTimeScheduled() {
+		remaining, phase = p.StartTime-blockTime.Unix(), KeyGenerationPhaseSeconds
+	}
+	switch {
+	case remaining <= 0:
+		return KeyPhaseDone
+	case remaining <= phase:
+		return KeyPhaseReveals
+	case remaining <= 2*phase:
+		return KeyPhaseComplaints
+	case remaining <= 3*phase:
+		return KeyPhaseDeals
+	}
+	return KeyPhaseKeys
+}
+
+// RevealedKeyShares returns the number of keykeepers which have revealed their threshold key share
+func (p *Process) RevealedKeyShares() int {
+	n := 0
+	for i, k := range p.EncryptionPrivateKeys {
+		if i != ThresholdKeyIndex && k != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// IsTimeScheduled indicates whether the process voting period is defined by StartTime and EndTime
+// instead of StartBlock and NumberOfBlocks
+func (p *Process) IsTimeScheduled() bool {
+	return p.EndTime > 0
+}
+
+// Started indicates whether the process voting period has started on a block of the given height and time
+func (p *Process) Started(height int64, blockTime time.Time) bool {
+	if p.IsTimeScheduled() {
+		return blockTime.Unix() >= p.StartTime
+	}
+	return height >= p.StartBlock
+}
+
+// Finished indicates whether the process voting period is over on a block of the given height and time.
+// Block scheduled processes accept votes until StartBlock+NumberOfBlocks (included), while time
+// scheduled processes accept votes on the blocks with a time lower than EndTime.
+func (p *Process) Finished(height int64, blockTime time.Time) bool {
+	if p.IsTimeScheduled() {
+		return blockTime.Unix() >= p.EndTime
+	}
+	return height > p.StartBlock+p.NumberOfBlocks
+}
+
+// CensusRoots returns the census merkle roots a census proof can be checked against,
+// the current one first and then the previous ones (newest first) if accepted
+func (p *Process) CensusRoots() []string {
+	roots := []string{p.MkRoot}
+	if p.AcceptPreviousMkRoots {
+		for i := len(p.PreviousMkRoots) - 1; i >= 0; i-- {
+			roots = append(roots, p.PreviousMkRoots[i])
+		}
+	}
+	return roots
+}
+
+// IsValidCensusRoot indicates whether a census proof against mkroot is currently valid for the process
+func (p *Process) IsValidCensusRoot(mkroot string) bool {
+	for _, root := range p.CensusRoots() {
+		if root == mkroot {
+			return true
+		}
+	}
+	return false
+}
+
+// Ballot returns the ballot rules of the process, or the default ones if not defined.
+// The vote packages of processes without rules (created before the genesis BallotRulesHeight)
+// are not checked on chain, the default rules only keep the results tally within its limits.
+func (p *Process) Ballot() *BallotRules {
+	if p.BallotRules == nil {
+		return DefaultBallotRules()
+	}
+	return p.BallotRules
+}
+
+// BallotRules defines the valid vote packages of a process and how they are counted.
+// The meaning of the VotePackage votes depends on the TallyMode.
+// On plurality, votes[question] is the option chosen for each question.
+// On approval, votes[option] is 1 if the option is approved, 0 otherwise.
+// On ranked-choice, votes is the list of options sorted by preference, first the preferred one.
+// On quadratic, votes[option] is the number of votes given to the option, each vote costs
+// its square and the total cost must be within MaxTotalCost credits.
+type BallotRules struct {
+	// ExactCount if true, VotePackage.Votes must have exactly MaxCount values (e.g. all the questions answered)
+	ExactCount bool `json:"exactCount,omitempty"`
+	// MaxCount is the maximum number of values of VotePackage.Votes
+	MaxCount uint32 `json:"maxCount"`
+	// MaxTotalCost is the maximum total cost of a vote, zero means no limit.
+	// The cost is the sum of all the values, or the sum of their squares on quadratic mode.
+	MaxTotalCost uint64 `json:"maxTotalCost,omitempty"`
+	// MaxValue is the maximum value of each option (inclusive)
+	MaxValue uint32 `json:"maxValue"`
+	// TallyMode is the strategy used for counting the votes, empty means plurality
+	TallyMode string `json:"tallyMode,omitempty"`
+	// UniqueValues if true, a value cannot be repeated on the same vote
+	UniqueValues bool `json:"uniqueValues,omitempty"`
+}
+
+// Mode returns the tally mode of the rules
+func (r *BallotRules) Mode() string {
+	if r.TallyMode == "" {
+		return TallyPlurality
+	}
+	return r.TallyMode
+}
+
+// DefaultBallotRules returns the rules of the processes created without ballot rules
+// (from the genesis BallotRulesHeight), which only enforce the limits of the results tally
+func DefaultBallotRules() *BallotRules {
+	return &BallotRules{MaxCount: MaxQuestions, MaxValue: MaxOptions - 1}
+}
+
+// Validate checks the rules are consistent with the tally mode and within the limits
+// of the results tally
+func (r *BallotRules) Validate() error {
+	switch r.Mode() {
+	case TallyPlurality:
+		if r.MaxCount < 1 || r.MaxCount > MaxQuestions {
+			return fmt.Errorf("maxCount must be between 1 and %d", MaxQuestions)
+		}
+		if r.MaxValue >= MaxOptions {
+			return fmt.Errorf("maxValue must be lower than %d", MaxOptions)
+		}
+	case TallyApproval:
+		if r.MaxCount < 1 || r.MaxCount > MaxOptions {
+			return fmt.Errorf("maxCount must be between 1 and %d", MaxOptions)
+		}
+		if r.MaxValue != 1 || r.UniqueValues {
+			return fmt.Errorf("approval votes require maxValue 1 and no unique values")
+		}
+	case TallyRankedChoice:
+		if r.MaxValue >= MaxOptions {
+			return fmt.Errorf("maxValue must be lower than %d", MaxOptions)
+		}
+		if r.MaxCount < 1 || r.MaxCount > r.MaxValue+1 {
+			return fmt.Errorf("maxCount must be between 1 and the number of options (%d)", r.MaxValue+1)
+		}
+		if !r.UniqueValues {
+			return fmt.Errorf("ranked-choice votes require unique values")
+		}
+	case TallyQuadratic:
+		if r.MaxCount < 1 || r.MaxCount > MaxOptions {
+			return fmt.Errorf("maxCount must be between 1 and %d", MaxOptions)
+		}
+		if r.MaxValue < 1 || r.MaxTotalCost < 1 {
+			return fmt.Errorf("quadratic votes require maxValue and maxTotalCost (credits)")
+		}
+	default:
+		return fmt.Errorf("tally mode %q not supported", r.TallyMode)
+	}
+	return nil
+}
+
+// CheckVotes returns an error if the votes of a VotePackage do not follow the rules
+func (r *BallotRules) CheckVotes(votes []int) error {
+	if len(votes) == 0 {
+		return fmt.Errorf("empty vote")
+	}
+	if len(votes) > int(r.MaxCount) {
+		return fmt.Errorf("too many questions (%d > %d)", len(votes), r.MaxCount)
+	}
+	if r.ExactCount && len(votes) != int(r.MaxCount) {
+		return fmt.Errorf("wrong number of questions (%d != %d)", len(votes), r.MaxCount)
+	}
+	var cost uint64
+	used := make(map[int]bool, len(votes))
+	for i, v := range votes {
+		if v < 0 || v > int(r.MaxValue) {
+			return fmt.Errorf("value %d of question %d out of range", v, i)
+		}
+		if r.UniqueValues && used[v] {
+			return fmt.Errorf("value %d is repeated", v)
+		}
+		used[v] = true
+		var overflow uint64
+		if r.Mode() == TallyQuadratic {
+			cost, overflow = bits.Add64(cost, uint64(v)*uint64(v), 0)
+		} else {
+			cost, overflow = bits.Add64(cost, uint64(v), 0)
+		}
+		if overflow != 0 {
+			return fmt.Errorf("total cost overflow")
+		}
+	}
+	if r.MaxTotalCost > 0 && cost > r.MaxTotalCost {
+		return fmt.Errorf("total cost %d exceeds the maximum %d", cost, r.MaxTotalCost)
+	}
+	return nil
+}
+
 var ProcessRequireKeys = map[string]bool{
 	PollVote:      false,
 	PetitionSign:  false,
 	EncryptedPoll: true,
 	SnarkVote:     true,
+	WeightedPoll:  false,
+	OpenPoll:      false,
 }
 
 var ProcessIsEncrypted = map[string]bool{
@@ -99,21 +480,30 @@ var ProcessIsEncrypted = map[string]bool{
 	PetitionSign:  false,
 	EncryptedPoll: true,
 	SnarkVote:     true,
+	WeightedPoll:  false,
+	OpenPoll:      false,
 }
 
 // ________________________ TX ________________________
 
 // ValidTypes represents an allowed specific tx type
 var ValidTypes = map[string]string{
-	TxVote:              "VoteTx",
-	TxNewProcess:        "NewProcessTx",
-	TxCancelProcess:     "CancelProcessTx",
-	TxAddValidator:      "AdminTx",
-	TxRemoveValidator:   "AdminTx",
-	TxAddOracle:         "AdminTx",
-	TxRemoveOracle:      "AdminTx",
-	TxAddProcessKeys:    "AdminTx",
-	TxRevealProcessKeys: "AdminTx",
+	TxVote:                      "VoteTx",
+	TxNewProcess:                "NewProcessTx",
+	TxCancelProcess:             "CancelProcessTx",
+	TxAddValidator:              "AdminTx",
+	TxRemoveValidator:           "AdminTx",
+	TxAddOracle:                 "AdminTx",
+	TxRemoveOracle:              "AdminTx",
+	TxAddProcessKeys:            "AdminTx",
+	TxRevealProcessKeys:         "AdminTx",
+	TxPauseProcess:              "PauseProcessTx",
+	TxResumeProcess:             "PauseProcessTx",
+	TxUpdateCensus:              "UpdateCensusTx",
+	TxAddProcessKeyDeal:         "AdminTx",
+	TxAddProcessKeyComplaint:    "AdminTx",
+	TxRevealProcessKeyDealShare: "AdminTx",
+	TxCoSign:                    "CoSignTx",
 }
 
 // Tx is an abstraction for any specific tx which is primarly defined by its type
@@ -124,15 +514,20 @@ type Tx struct {
 
 // VoteTx represents the info required for submmiting a vote
 type VoteTx struct {
-	EncryptionKeyIndexes []int  `json:"encryptionKeyIndexes,omitempty"`
-	Nonce                string `json:"nonce,omitempty"`
-	Nullifier            string `json:"nullifier,omitempty"`
-	ProcessID            string `json:"processId"`
-	Proof                string `json:"proof,omitempty"`
-	Signature            string `json:"signature,omitempty"`
-	Type                 string `json:"type,omitempty"`
-	VotePackage          string `json:"votePackage,omitempty"`
-	SignedBytes          []byte `json:"-"`
+	CAProof              string           `json:"caProof,omitempty"`
+	EncryptionKeyIndexes []int            `json:"encryptionKeyIndexes,omitempty"`
+	EthProof             *EthStorageProof `json:"ethProof,omitempty"`
+	Nonce                string           `json:"nonce,omitempty"`
+	Nullifier            string           `json:"nullifier,omitempty"`
+	ProcessID            string           `json:"processId"`
+	Proof                string           `json:"proof,omitempty"`
+	Signature            string           `json:"signature,omitempty"`
+	Type                 string           `json:"type,omitempty"`
+	VotePackage          string           `json:"votePackage,omitempty"`
+	Weight               string           `json:"weight,omitempty"`
+	ZkProof              *ZkProof         `json:"zkProof,omitempty"`
+	SignedBytes          []byte           `json:"-"`
+	Signer               string           `json:"-"` // recovered from the signature once the vote is checked
 }
 
 func (tx *VoteTx) TxType() string {
@@ -142,31 +537,59 @@ func (tx *VoteTx) TxType() string {
 // UniqID returns a uniq identifier for the VoteTX. It depends on the Type.
 func (tx *VoteTx) UniqID(processType string) string {
 	switch processType {
-	case PollVote, PetitionSign, EncryptedPoll:
+	case PollVote, PetitionSign, EncryptedPoll, WeightedPoll, OpenPoll:
 		if len(tx.Signature) > 32 {
 			return tx.Signature[:32]
 		}
+	case SnarkVote:
+		return tx.Nullifier
 	}
 	return ""
 }
 
 // NewProcessTx represents the info required for starting a new process
 type NewProcessTx struct {
+	// AllowList if not empty, only these addresses can vote (only for open-poll)
+	AllowList []string `json:"allowList,omitempty"`
+	// BallotRules defines the valid vote packages, if nil the default rules are used
+	BallotRules *BallotRules `json:"ballotRules,omitempty"`
+	// CensusCA if not nil, the census is held by a certification authority (MkRoot must be empty)
+	CensusCA *CensusCA `json:"censusCA,omitempty"`
+	// DenyList are the addresses which cannot vote (only for open-poll)
+	DenyList []string `json:"denyList,omitempty"`
+	// EndTime is the unix timestamp (seconds) where the process goes from active to finished.
+	// If set, StartTime is also required and StartBlock and NumberOfBlocks must be empty.
+	EndTime int64 `json:"endTime,omitempty"`
 	// EntityID the process belongs to
 	EntityID string `json:"entityId"`
+	// KeyThreshold if not zero, the number of keykeepers required for decrypting the votes
+	// of an encrypted process (see Process.KeyThreshold)
+	KeyThreshold uint32 `json:"keyThreshold,omitempty"`
+	// MaxVoteOverwrites is the number of times a voter can replace its vote (0 means votes are final)
+	MaxVoteOverwrites uint32 `json:"maxVoteOverwrites,omitempty"`
 	// MkRoot merkle root of all the census in the process
 	MkRoot string `json:"mkRoot,omitempty"`
 	// MkURI merkle tree URI
 	MkURI string `json:"mkURI,omitempty"`
+	// Nonce is a unique value for the signer, required for replay protection
+	Nonce string `json:"nonce,omitempty"`
 	// NumberOfBlocks represents the tendermint block where the process goes from active to finished
 	NumberOfBlocks int64  `json:"numberOfBlocks"`
 	ProcessID      string `json:"processId"`
 	ProcessType    string `json:"processType"`
 	Signature      string `json:"signature,omitempty"`
+	// Signatures contains the co-signatures of other oracles (see OracleThresholds)
+	Signatures []string `json:"signatures,omitempty"`
 	// StartBlock represents the tendermint block where the process goes from scheduled to active
-	StartBlock  int64  `json:"startBlock"`
-	Type        string `json:"type,omitempty"`
-	SignedBytes []byte `json:"-"`
+	StartBlock int64 `json:"startBlock"`
+	// StartTime is the unix timestamp (seconds) where the process goes from scheduled to active
+	StartTime int64 `json:"startTime,omitempty"`
+	// TokenCensus if not nil, the census are the holders of an Ethereum token (MkRoot must be empty)
+	TokenCensus *TokenCensus `json:"tokenCensus,omitempty"`
+	Type        string       `json:"type,omitempty"`
+	// ZkVerificationKey is the Groth16 verification key of the census circuit (only for snark-vote)
+	ZkVerificationKey *ZkVerificationKey `json:"zkVerificationKey,omitempty"`
+	SignedBytes       []byte             `json:"-"`
 }
 
 func (tx *NewProcessTx) TxType() string {
@@ -175,38 +598,103 @@ func (tx *NewProcessTx) TxType() string {
 
 // CancelProcessTx represents a tx for canceling a valid process
 type CancelProcessTx struct {
+	// Nonce is a unique value for the signer, required for replay protection
+	Nonce string `json:"nonce,omitempty"`
 	// EntityID the process belongs to
-	ProcessID   string `json:"processId"`
-	Signature   string `json:"signature,omitempty"`
-	Type        string `json:"type,omitempty"`
-	SignedBytes []byte `json:"-"`
+	ProcessID   string   `json:"processId"`
+	Signature   string   `json:"signature,omitempty"`
+	Signatures  []string `json:"signatures,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	SignedBytes []byte   `json:"-"`
 }
 
 func (tx *CancelProcessTx) TxType() string {
 	return "CancelProcessTx"
 }
 
+// PauseProcessTx represents a tx for pausing (type pauseProcess) or resuming
+// (type resumeProcess) a valid process
+type PauseProcessTx struct {
+	Nonce       string   `json:"nonce"`
+	ProcessID   string   `json:"processId"`
+	Signature   string   `json:"signature,omitempty"`
+	Signatures  []string `json:"signatures,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	SignedBytes []byte   `json:"-"`
+}
+
+func (tx *PauseProcessTx) TxType() string {
+	return "PauseProcessTx"
+}
+
+// UpdateCensusTx represents a tx for replacing the census of a process which has not finished.
+// The replaced census root is kept on the process history.
+type UpdateCensusTx struct {
+	// AcceptPreviousMkRoots if true the census proofs against the previous roots are still valid
+	AcceptPreviousMkRoots bool `json:"acceptPreviousMkRoots,omitempty"`
+	// MkRoot is the new census merkle root
+	MkRoot string `json:"mkRoot"`
+	// MkURI is the new census merkle tree URI
+	MkURI       string   `json:"mkURI,omitempty"`
+	Nonce       string   `json:"nonce"`
+	ProcessID   string   `json:"processId"`
+	Signature   string   `json:"signature,omitempty"`
+	Signatures  []string `json:"signatures,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	SignedBytes []byte   `json:"-"`
+}
+
+func (tx *UpdateCensusTx) TxType() string {
+	return "UpdateCensusTx"
+}
+
 // AdminTx represents a Tx that can be only executed by some authorized addresses
 type AdminTx struct {
-	Address              string `json:"address"`
-	CommitmentKey        string `json:"commitmentKey,omitempty"`
-	EncryptionPrivateKey string `json:"encryptionPrivateKey,omitempty"`
-	EncryptionPublicKey  string `json:"encryptionPublicKey,omitempty"`
-	KeyIndex             int    `json:"keyIndex,omitempty"`
-	Nonce                string `json:"nonce"`
-	Power                int64  `json:"power,omitempty"`
-	ProcessID            string `json:"processId,omitempty"`
-	PubKey               string `json:"publicKey,omitempty"`
-	RevealKey            string `json:"revealKey,omitempty"`
-	Signature            string `json:"signature,omitempty"`
-	Type                 string `json:"type"` // addValidator, removeValidator, addOracle, removeOracle
-	SignedBytes          []byte `json:"-"`
+	Address              string   `json:"address"`
+	CommitmentKey        string   `json:"commitmentKey,omitempty"`
+	DealerIndex          int      `json:"dealerIndex,omitempty"`
+	EncryptionPrivateKey string   `json:"encryptionPrivateKey,omitempty"`
+	EncryptionPublicKey  string   `json:"encryptionPublicKey,omitempty"`
+	KeyDeal              *KeyDeal `json:"keyDeal,omitempty"`
+	KeyIndex             int      `json:"keyIndex,omitempty"`
+	KeyShare             string   `json:"keyShare,omitempty"`
+	Nonce                string   `json:"nonce"`
+	Power                int64    `json:"power,omitempty"`
+	ProcessID            string   `json:"processId,omitempty"`
+	PubKey               string   `json:"publicKey,omitempty"`
+	RevealKey            string   `json:"revealKey,omitempty"`
+	Signature            string   `json:"signature,omitempty"`
+	Signatures           []string `json:"signatures,omitempty"`
+	Type                 string   `json:"type"` // addValidator, removeValidator, addOracle, removeOracle
+	SignedBytes          []byte   `json:"-"`
 }
 
 func (tx *AdminTx) TxType() string {
 	return "AdminTx"
 }
 
+// CoSignTx adds the signature of an oracle to a privileged transaction whose class requires
+// the signatures of several oracles (see OracleThresholds). The signatures are collected on
+// the state, and the transaction is executed once they reach the threshold.
+type CoSignTx struct {
+	// Tx is the co-signed transaction (JSON), signed only by the sender oracle
+	Tx          json.RawMessage `json:"tx"`
+	Type        string          `json:"type"`
+	SignedBytes []byte          `json:"-"` // signed bytes of the co-signed transaction
+}
+
+func (tx *CoSignTx) TxType() string {
+	return "CoSignTx"
+}
+
+// CoSignedTx is the state entry of a privileged transaction being co-signed (see CoSignTx).
+// Once executed, the transaction and its signatures are removed from the entry.
+type CoSignedTx struct {
+	Executed   bool     `json:"executed,omitempty"`
+	Signatures []string `json:"signatures,omitempty"`
+	Tx         []byte   `json:"tx,omitempty"`
+}
+
 // ValidateType a valid Tx type specified in ValidTypes. Returns empty string if invalid type.
 func ValidateType(t string) string {
 	val, ok := ValidTypes[t]
@@ -216,6 +704,24 @@ func ValidateType(t string) string {
 	return val
 }
 
+// ________________________ ZK-SNARKS ________________________
+
+// ZkProof is a Groth16 proof using the snarkjs JSON format (decimal coordinates)
+type ZkProof struct {
+	A []string   `json:"pi_a"`
+	B [][]string `json:"pi_b"`
+	C []string   `json:"pi_c"`
+}
+
+// ZkVerificationKey is a Groth16 verification key using the snarkjs JSON format
+type ZkVerificationKey struct {
+	Alpha []string   `json:"vk_alpha_1"`
+	Beta  [][]string `json:"vk_beta_2"`
+	Gamma [][]string `json:"vk_gamma_2"`
+	Delta [][]string `json:"vk_delta_2"`
+	IC    [][]string `json:"IC"`
+}
+
 // ________________________ VALIDATORS ________________________
 
 // ________________________ QUERIES ________________________
@@ -235,8 +741,43 @@ type QueryData struct {
 
 // GenesisAppState application state in genesis
 type GenesisAppState struct {
-	Validators []GenesisValidator `json:"validators"`
-	Oracles    []string           `json:"oracles"`
+	Validators       []GenesisValidator `json:"validators"`
+	Oracles          []string           `json:"oracles"`
+	OracleThresholds *OracleThresholds  `json:"oracleThresholds,omitempty"`
+	// BallotRulesHeight is the height from which the processes created without ballot rules get
+	// DefaultBallotRules. Zero means never, so the networks created before the ballot rules can
+	// replay the votes of their processes.
+	BallotRulesHeight int64 `json:"ballotRulesHeight,omitempty"`
+}
+
+// OracleThresholds contains the number of distinct oracle signatures required by
+// each class of privileged transaction. Zero means a single signature is enough.
+// The oracles can either send a transaction with all the signatures or send it
+// signed by themselves inside a CoSignTx, so the signatures are collected on the state.
+type OracleThresholds struct {
+	// Admin is used for adding or removing oracles and validators
+	Admin uint32 `json:"admin,omitempty"`
+	// CancelProcess is used for canceling processes
+	CancelProcess uint32 `json:"cancelProcess,omitempty"`
+	// NewProcess is used for creating processes
+	NewProcess uint32 `json:"newProcess,omitempty"`
+	// PauseProcess is used for pausing and resuming processes
+	PauseProcess uint32 `json:"pauseProcess,omitempty"`
+	// ProcessKeys is used for adding, dealing and revealing process keys
+	ProcessKeys uint32 `json:"processKeys,omitempty"`
+	// UpdateCensus is used for updating the census of processes
+	UpdateCensus uint32 `json:"updateCensus,omitempty"`
+}
+
+// Max returns the highest threshold
+func (t *OracleThresholds) Max() int {
+	max := 1
+	for _, n := range []uint32{t.Admin, t.CancelProcess, t.NewProcess, t.PauseProcess, t.ProcessKeys, t.UpdateCensus} {
+		if int(n) > max {
+			max = int(n)
+		}
+	}
+	return max
 }
 
 // The rest of these genesis app state types are copied from
@@ -302,3 +843,17 @@ type ScrutinizerOnProcessData struct {
 	EntityID  []byte
 	ProcessID []byte
 }
+
+// TxReference holds the indexed information of a transaction delivered on the vochain.
+// Transactions rejected by the application are also indexed, with a non-zero Code.
+type TxReference struct {
+	Code      uint32   `json:"code"`
+	Error     string   `json:"error,omitempty"`
+	Hash      string   `json:"hash"`
+	Height    int64    `json:"height"`
+	Index     int32    `json:"index"`
+	Nullifier string   `json:"nullifier,omitempty"`
+	ProcessID string   `json:"processId,omitempty"`
+	Signers   []string `json:"signers,omitempty"`
+	Type      string   `json:"type"`
+}
@@ -1,11 +1,17 @@
 package vochain
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync/atomic"
 
 	amino "github.com/tendermint/go-amino"
 	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
 	cryptoamino "github.com/tendermint/tendermint/crypto/encoding/amino"
+	"github.com/tendermint/tendermint/crypto/merkle"
 	mempl "github.com/tendermint/tendermint/mempool"
 	nm "github.com/tendermint/tendermint/node"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
@@ -13,6 +19,7 @@ import (
 
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/util"
 )
 
 // BaseApplication reflects the ABCI application implementation.
@@ -20,6 +27,13 @@ type BaseApplication struct {
 	State *State
 	Codec *amino.Codec
 	Node  *nm.Node
+
+	snapshotDir      string
+	snapshotInterval int64
+	snapshotRunning  int32
+	// height and txIndex locate the next delivered transaction (block height and position)
+	height  int64
+	txIndex int32
 }
 
 var _ abcitypes.Application = (*BaseApplication)(nil)
@@ -30,12 +44,13 @@ func RegisterAmino(cdc *amino.Codec) {
 	cdc.RegisterInterface((*types.PubKey)(nil), nil)
 }
 
-// NewBaseApplication creates a new BaseApplication given a name an a DB backend
-func NewBaseApplication(dbpath string) (*BaseApplication, error) {
+// NewBaseApplication creates a new BaseApplication given a name an a DB backend.
+// stateBackend selects the state database (iavl or graviton), empty means iavl.
+func NewBaseApplication(dbpath, stateBackend string) (*BaseApplication, error) {
 	cdc := amino.NewCodec()
 	RegisterAmino(cdc)
 
-	state, err := NewState(dbpath, cdc)
+	state, err := NewStateWithBackend(dbpath, stateBackend, cdc)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create vochain state: (%s)", err)
 	}
@@ -65,6 +80,26 @@ func (app *BaseApplication) SendTX(tx []byte) (*ctypes.ResultBroadcastTx, error)
 	}, nil
 }
 
+// SendOracleTx sends a privileged transaction signed by the oracle. If the transaction class
+// requires the signatures of several oracles, it is sent inside a CoSignTx, so the transaction
+// is executed once enough oracles sent it (see types.OracleThresholds).
+func (app *BaseApplication) SendOracleTx(tx []byte) (*ctypes.ResultBroadcastTx, error) {
+	gtx, err := UnmarshalTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	thresholds, err := app.State.OracleThresholds(true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get oracle thresholds: (%s)", err)
+	}
+	if oracleTxThreshold(gtx, thresholds) > 1 {
+		if tx, err = json.Marshal(types.CoSignTx{Tx: tx, Type: types.TxCoSign}); err != nil {
+			return nil, fmt.Errorf("cannot marshal co-sign transaction: (%s)", err)
+		}
+	}
+	return app.SendTX(tx)
+}
+
 // Info Return information about the application state.
 // Used to sync Tendermint with the application during a handshake that happens on startup.
 // The returned AppVersion will be included in the Header of every block.
@@ -105,6 +140,23 @@ func (app *BaseApplication) InitChain(req abcitypes.RequestInitChain) abcitypes.
 		log.Infof("adding genesis oracle %s", v)
 		app.State.AddOracle(v)
 	}
+	// get oracle signature thresholds
+	if t := genesisAppState.OracleThresholds; t != nil {
+		if t.Max() > len(genesisAppState.Oracles) {
+			log.Fatalf("oracle thresholds require %d oracles but genesis has %d", t.Max(), len(genesisAppState.Oracles))
+		}
+		log.Infof("setting oracle signature thresholds %+v", *t)
+		if err = app.State.SetOracleThresholds(*t); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// get the height from which the processes get the default ballot rules
+	if h := genesisAppState.BallotRulesHeight; h > 0 {
+		log.Infof("setting default ballot rules from height %d", h)
+		if err = app.State.SetBallotRulesHeight(h); err != nil {
+			log.Fatal(err)
+		}
+	}
 	// get validators
 	for i := 0; i < len(genesisAppState.Validators); i++ {
 		log.Infof("adding genesis validator %s", genesisAppState.Validators[i].PubKey.Address())
@@ -126,7 +178,13 @@ func (app *BaseApplication) InitChain(req abcitypes.RequestInitChain) abcitypes.
 	}
 	app.State.Unlock()
 	app.State.Save() // Is this save needed?
-	// TBD: using empty list here, should return validatorsUpdate to use the validators obtained here
+
+	// The genesis app state validators must match the genesis file ones, so the
+	// Tendermint list is used (empty response). Later changes are sent on EndBlock.
+	if len(req.Validators) != len(genesisAppState.Validators) {
+		log.Warnf("genesis validators (%d) and app state validators (%d) do not match",
+			len(req.Validators), len(genesisAppState.Validators))
+	}
 	return abcitypes.ResponseInitChain{}
 }
 
@@ -146,6 +204,8 @@ func (app *BaseApplication) BeginBlock(req abcitypes.RequestBeginBlock) abcitype
 	}
 	app.State.Unlock()
 	app.State.VoteCachePurge(app.State.Header(true).Height)
+	app.height = req.Header.Height
+	app.txIndex = 0
 	return abcitypes.ResponseBeginBlock{}
 }
 
@@ -158,6 +218,14 @@ func (app *BaseApplication) CheckTx(req abcitypes.RequestCheckTx) abcitypes.Resp
 	var err error
 	var tx GenericTX
 	if req.Type == abcitypes.CheckTxType_Recheck {
+		// evict the transactions which are no longer valid after the last committed block
+		if tx, err = UnmarshalTx(req.Tx); err == nil {
+			err = RecheckTx(tx, app.State)
+		}
+		if err != nil {
+			log.Debugf("recheckTx error, evicting transaction: %s", err)
+			return abcitypes.ResponseCheckTx{Code: 1, Data: []byte(err.Error())}
+		}
 		return abcitypes.ResponseCheckTx{Code: 0, Data: data}
 	}
 	if tx, err = UnmarshalTx(req.Tx); err == nil {
@@ -177,25 +245,134 @@ func (app *BaseApplication) DeliverTx(req abcitypes.RequestDeliverTx) abcitypes.
 	var tx GenericTX
 
 	if tx, err = UnmarshalTx(req.Tx); err == nil {
-		if data, err = AddTx(tx, app.State, true); err != nil {
-			return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}
-		}
-	} else {
+		data, err = AddTx(tx, app.State, true)
+	}
+	app.indexTx(req.Tx, tx, data, err)
+	if err != nil {
 		return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}
 	}
 	return abcitypes.ResponseDeliverTx{Code: 0, Data: data}
 }
 
+// indexTx sends the reference of a delivered transaction to the transaction listeners
+func (app *BaseApplication) indexTx(rawTx []byte, tx GenericTX, data []byte, txErr error) {
+	if len(app.State.txListeners) == 0 {
+		app.txIndex++
+		return
+	}
+	ref := newTxReference(rawTx, tx, data, txErr)
+	ref.Height = app.height
+	ref.Index = app.txIndex
+	app.txIndex++
+	app.State.notifyTx(ref)
+}
+
 func (app *BaseApplication) Commit() abcitypes.ResponseCommit {
+	hash := app.State.Save()
+	if app.snapshotInterval > 0 {
+		if header := app.State.Header(false); header != nil && header.Height%app.snapshotInterval == 0 {
+			app.snapshot()
+		}
+	}
 	return abcitypes.ResponseCommit{
-		Data: app.State.Save(),
+		Data: hash,
 	}
 }
 
+// SetSnapshots enables a state snapshot every interval blocks, stored on dir (see SaveSnapshot)
+func (app *BaseApplication) SetSnapshots(dir string, interval int64) {
+	app.snapshotDir = dir
+	app.snapshotInterval = interval
+}
+
+// snapshot takes the committed state trees and copies them to disk in background.
+// A snapshot is skipped if the previous one is still being written.
+func (app *BaseApplication) snapshot() {
+	if !atomic.CompareAndSwapInt32(&app.snapshotRunning, 0, 1) {
+		log.Warnf("previous state snapshot still running, skipping snapshot")
+		return
+	}
+	src, err := app.State.snapshotSource()
+	if err != nil {
+		atomic.StoreInt32(&app.snapshotRunning, 0)
+		log.Errorf("cannot create state snapshot: (%s)", err)
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&app.snapshotRunning, 0)
+		s := src.copy()
+		path, err := SaveSnapshot(app.snapshotDir, s)
+		if err != nil {
+			log.Errorf("cannot save state snapshot: (%s)", err)
+			return
+		}
+		log.Infof("state snapshot at height %d saved to %s (app hash %x)", s.Height, path, s.AppHash)
+	}()
+}
+
+// Query returns the last committed state value for the given path. Supported paths are:
+// /process/<processId>, /vote/<processId>/<nullifier>, /oracles and /validators.
+// The returned value is the raw (amino encoded) state value. If req.Prove is true the response
+// includes the proof operations required to verify it against the AppHash (see State.QueryProof).
 func (app *BaseApplication) Query(req abcitypes.RequestQuery) abcitypes.ResponseQuery {
-	return abcitypes.ResponseQuery{}
+	tree, key, err := queryPathKey(req.Path)
+	if err != nil {
+		return abcitypes.ResponseQuery{Code: 1, Log: err.Error()}
+	}
+	value, ops, height, err := app.State.QueryProof(tree, key, req.Prove)
+	if err != nil {
+		return abcitypes.ResponseQuery{Code: 1, Log: err.Error(), Key: key, Height: height}
+	}
+	if req.Height != 0 && req.Height != height {
+		return abcitypes.ResponseQuery{Code: 1, Log: fmt.Sprintf("only last height (%d) can be queried", height), Height: height}
+	}
+	resp := abcitypes.ResponseQuery{Key: key, Value: value, Height: height}
+	if req.Prove {
+		resp.Proof = &merkle.Proof{Ops: ops}
+	}
+	return resp
 }
 
+// queryPathKey returns the state tree and key for an ABCI query path
+func queryPathKey(path string) (string, []byte, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "process":
+		pid, err := hex.DecodeString(util.TrimHex(parts[1]))
+		if err != nil || len(pid) != types.ProcessIDsize {
+			return "", nil, fmt.Errorf("malformed processId")
+		}
+		return ProcessTree, pid, nil
+	case len(parts) == 3 && parts[0] == "vote":
+		pid, err := hex.DecodeString(util.TrimHex(parts[1]))
+		if err != nil || len(pid) != types.ProcessIDsize {
+			return "", nil, fmt.Errorf("malformed processId")
+		}
+		nullifier, err := hex.DecodeString(util.TrimHex(parts[2]))
+		if err != nil || len(nullifier) != types.VoteNullifierSize {
+			return "", nil, fmt.Errorf("malformed nullifier")
+		}
+		return VoteTree, append(pid, nullifier...), nil
+	case len(parts) == 1 && parts[0] == "oracles":
+		return AppTree, oracleKey, nil
+	case len(parts) == 1 && parts[0] == "validators":
+		return AppTree, validatorKey, nil
+	}
+	return "", nil, fmt.Errorf("invalid query path %s", path)
+}
+
+// EndBlock signals the end of a block.
+// The validators added, modified or removed during the block are returned as validator updates.
 func (app *BaseApplication) EndBlock(req abcitypes.RequestEndBlock) abcitypes.ResponseEndBlock {
-	return abcitypes.ResponseEndBlock{}
+	var updates []abcitypes.ValidatorUpdate
+	for _, v := range app.State.ValidatorUpdates() {
+		pubKey, ok := v.PubKey.(ed25519.PubKeyEd25519)
+		if !ok {
+			log.Errorf("validator %s pubkey type not supported", v.Address)
+			continue
+		}
+		log.Infof("updating validator %s with power %d", v.Address, v.Power)
+		updates = append(updates, abcitypes.Ed25519ValidatorUpdate(pubKey[:], v.Power))
+	}
+	return abcitypes.ResponseEndBlock{ValidatorUpdates: updates}
 }
@@ -20,7 +20,7 @@ import (
 
 func BenchmarkCheckTx(b *testing.B) {
 	b.ReportAllocs()
-	app, err := NewBaseApplication(b.TempDir())
+	app, err := NewBaseApplication(b.TempDir(), "")
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -82,9 +82,10 @@ func prepareBenchCheckTx(b *testing.B, app *BaseApplication, nvoters int) (voter
 			b.Fatal(err)
 		}
 		tx := types.VoteTx{
-			Nonce:     util.RandomHex(16),
-			ProcessID: hex.EncodeToString(pid),
-			Proof:     proof,
+			Nonce:       util.RandomHex(16),
+			ProcessID:   hex.EncodeToString(pid),
+			Proof:       proof,
+			VotePackage: newVotePackage(b, 1, 2, 3),
 		}
 
 		txBytes, err := json.Marshal(tx)
@@ -1,21 +1,37 @@
 package vochain
 
 import (
+	"bytes"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/bn256"
 	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	"github.com/tendermint/tendermint/privval"
+	"gitlab.com/vocdoni/go-dvote/crypto/blindrsa"
 	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
 	"gitlab.com/vocdoni/go-dvote/crypto/snarks"
+	"gitlab.com/vocdoni/go-dvote/statedb/gravitonstate"
+	"gitlab.com/vocdoni/go-dvote/statedb/iavlstate"
 	tree "gitlab.com/vocdoni/go-dvote/trie"
 	"gitlab.com/vocdoni/go-dvote/types"
 	"gitlab.com/vocdoni/go-dvote/util"
 )
 
 func TestCheckTX(t *testing.T) {
-	app, err := NewBaseApplication(t.TempDir())
+	app, err := NewBaseApplication(t.TempDir(), "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -67,9 +83,10 @@ func TestCheckTX(t *testing.T) {
 			t.Fatal(err)
 		}
 		tx = types.VoteTx{
-			Nonce:     util.RandomHex(16),
-			ProcessID: pid,
-			Proof:     proof,
+			Nonce:       util.RandomHex(16),
+			ProcessID:   pid,
+			Proof:       proof,
+			VotePackage: newVotePackage(t, 1, 2, 3),
 		}
 
 		txBytes, err := json.Marshal(tx)
@@ -98,6 +115,1126 @@ func TestCheckTX(t *testing.T) {
 
 }
 
+func TestWeightedVote(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := tree.NewTree("testweightedvote", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := createEthRandomKeysBatch(3)
+	claims := [][]byte{}
+	for i, k := range keys {
+		weight := uint64(i+1) * 100
+		if i == 2 {
+			weight = types.MaxVoteWeight + 1
+		}
+		pub, _ := k.HexString()
+		pub, err = ethereum.DecompressPubKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubb, err := hex.DecodeString(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := snarks.Poseidon.Hash(pubb)
+		if err := tr.AddClaim(c, types.EncodeCensusWeight(weight)); err != nil {
+			t.Fatal(err)
+		}
+		claims = append(claims, c)
+	}
+	process := &types.Process{
+		Type:           types.WeightedPoll,
+		EntityID:       util.RandomBytes(types.EntityIDsize),
+		MkRoot:         tr.Root(),
+		NumberOfBlocks: 1024,
+	}
+	pid := util.RandomHex(types.ProcessIDsize)
+	app.State.AddProcess(*process, util.Hex2byte(t, pid), "ipfs://123456789")
+
+	voteTx := func(signer *ethereum.SignKeys, claim []byte, weight string) []byte {
+		proof, err := tr.GenProof(claim, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := types.VoteTx{
+			Nonce:       util.RandomHex(16),
+			ProcessID:   pid,
+			Proof:       proof,
+			VotePackage: newVotePackage(t, 1),
+			Weight:      weight,
+		}
+		txBytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Signature, err = signer.Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		tx.Type = "vote"
+		if txBytes, err = json.Marshal(tx); err != nil {
+			t.Fatal(err)
+		}
+		return txBytes
+	}
+
+	// the weight must match the census claim value
+	for _, weight := range []string{"", "0", "150", "200"} {
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: voteTx(keys[0], claims[0], weight)}); resp.Code == 0 {
+			t.Fatalf("vote with weight %q accepted", weight)
+		}
+	}
+	// the census weight is valid but above the maximum vote weight
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: voteTx(keys[2], claims[2], fmt.Sprint(types.MaxVoteWeight+1))}); resp.Code == 0 {
+		t.Fatalf("vote with weight above %d accepted", types.MaxVoteWeight)
+	}
+	detxresp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: voteTx(keys[0], claims[0], "100")})
+	if detxresp.Code != 0 {
+		t.Fatalf("deliverTX failed: %s", detxresp.Data)
+	}
+	app.Commit()
+
+	vote, err := app.State.Envelope(util.Hex2byte(t, pid), GenerateNullifier(keys[0].Address(), util.Hex2byte(t, pid)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vote.Weight != 100 {
+		t.Errorf("expected vote weight 100, got %d", vote.Weight)
+	}
+
+	// a transaction reusing the signature of a cached vote must be fully verified
+	valid := voteTx(keys[1], claims[1], "200")
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: valid}); resp.Code != 0 {
+		t.Fatalf("checkTX failed: %s", resp.Data)
+	}
+	var tx types.VoteTx
+	if err := json.Unmarshal(valid, &tx); err != nil {
+		t.Fatal(err)
+	}
+	tx.VotePackage = newVotePackage(t, 2)
+	tampered, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tampered}); resp.Code == 0 {
+		t.Fatalf("deliverTX accepted a tampered vote package using the cached proof")
+	}
+	if app.State.EnvelopeExists(util.Hex2byte(t, pid), GenerateNullifier(keys[1].Address(), util.Hex2byte(t, pid))) {
+		t.Fatalf("tampered vote stored")
+	}
+}
+
+func TestVoteOverwrite(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := tree.NewTree("testvoteoverwrite", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := createEthRandomKeysBatch(1)[0]
+	pub, _ := key.HexString()
+	pub, err = ethereum.DecompressPubKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim := snarks.Poseidon.Hash(util.Hex2byte(t, pub))
+	if err := tr.AddClaim(claim, nil); err != nil {
+		t.Fatal(err)
+	}
+	process := &types.Process{
+		Type:              types.PollVote,
+		EntityID:          util.RandomBytes(types.EntityIDsize),
+		MaxVoteOverwrites: 1,
+		MkRoot:            tr.Root(),
+		NumberOfBlocks:    1024,
+	}
+	pid := util.RandomHex(types.ProcessIDsize)
+	app.State.AddProcess(*process, util.Hex2byte(t, pid), "ipfs://123456789")
+
+	voteTx := func(votePackage string) []byte {
+		proof, err := tr.GenProof(claim, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := types.VoteTx{
+			Nonce:       util.RandomHex(16),
+			ProcessID:   pid,
+			Proof:       proof,
+			VotePackage: votePackage,
+		}
+		txBytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Signature, err = key.Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		tx.Type = "vote"
+		if txBytes, err = json.Marshal(tx); err != nil {
+			t.Fatal(err)
+		}
+		return txBytes
+	}
+
+	if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: voteTx(newVotePackage(t, 0))}); resp.Code != 0 {
+		t.Fatalf("deliverTX failed: %s", resp.Data)
+	}
+	app.Commit()
+
+	// the vote can be overwritten once
+	lastVotePackage := newVotePackage(t, 1)
+	tx := voteTx(lastVotePackage)
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: tx}); resp.Code != 0 {
+		t.Fatalf("checkTX failed: %s", resp.Data)
+	}
+	if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx}); resp.Code != 0 {
+		t.Fatalf("deliverTX failed: %s", resp.Data)
+	}
+	app.Commit()
+	if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: voteTx(newVotePackage(t, 2))}); resp.Code == 0 {
+		t.Fatalf("vote overwritten more times than allowed")
+	}
+
+	vote, err := app.State.Envelope(util.Hex2byte(t, pid), GenerateNullifier(key.Address(), util.Hex2byte(t, pid)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vote.OverwriteCount != 1 {
+		t.Errorf("expected overwrite count 1, got %d", vote.OverwriteCount)
+	}
+	if vote.VotePackage != lastVotePackage {
+		t.Errorf("the last vote should replace the previous one")
+	}
+	if n := app.State.CountVotes(util.Hex2byte(t, pid), false); n != 1 {
+		t.Errorf("expected 1 vote, got %d", n)
+	}
+}
+
+func TestVoteRecheck(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := tree.NewTree("testvoterecheck", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := createEthRandomKeysBatch(2)
+	claims := [][]byte{}
+	for _, key := range keys {
+		pub, _ := key.HexString()
+		pub, err = ethereum.DecompressPubKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claim := snarks.Poseidon.Hash(util.Hex2byte(t, pub))
+		if err := tr.AddClaim(claim, nil); err != nil {
+			t.Fatal(err)
+		}
+		claims = append(claims, claim)
+	}
+	process := &types.Process{
+		Type:           types.PollVote,
+		EntityID:       util.RandomBytes(types.EntityIDsize),
+		MkRoot:         tr.Root(),
+		NumberOfBlocks: 1024,
+	}
+	pid := util.RandomHex(types.ProcessIDsize)
+	app.State.AddProcess(*process, util.Hex2byte(t, pid), "ipfs://123456789")
+	app.Commit()
+
+	voteTx := func(voter int) []byte {
+		proof, err := tr.GenProof(claims[voter], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := types.VoteTx{
+			Nonce:       util.RandomHex(16),
+			ProcessID:   pid,
+			Proof:       proof,
+			VotePackage: newVotePackage(t, 1),
+		}
+		txBytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Signature, err = keys[voter].Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		tx.Type = "vote"
+		if txBytes, err = json.Marshal(tx); err != nil {
+			t.Fatal(err)
+		}
+		return txBytes
+	}
+	recheck := func(tx []byte) abcitypes.ResponseCheckTx {
+		return app.CheckTx(abcitypes.RequestCheckTx{Tx: tx, Type: abcitypes.CheckTxType_Recheck})
+	}
+
+	pending := voteTx(0)
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: pending}); resp.Code != 0 {
+		t.Fatalf("checkTX failed: %s", resp.Data)
+	}
+	if resp := recheck(pending); resp.Code != 0 {
+		t.Fatalf("recheckTX failed: %s", resp.Data)
+	}
+	// the vote cache survives a restart but the mempool does not, so a vote
+	// already in the cache must be accepted again
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: pending}); resp.Code != 0 {
+		t.Fatalf("checkTX of a cached vote failed: %s", resp.Data)
+	}
+
+	// another vote of the same voter is included on a block, so the pending one must be evicted
+	if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: voteTx(0)}); resp.Code != 0 {
+		t.Fatalf("deliverTX failed: %s", resp.Data)
+	}
+	app.Commit()
+	cacheSize := app.State.VoteCacheSize()
+	if resp := recheck(pending); resp.Code == 0 {
+		t.Fatalf("recheckTX should evict an already existing vote")
+	}
+	if size := app.State.VoteCacheSize(); size != cacheSize-1 {
+		t.Fatalf("evicted vote should be removed from the vote cache, cache size is %d", size)
+	}
+
+	// votes of a canceled process must be evicted
+	pending = voteTx(1)
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: pending}); resp.Code != 0 {
+		t.Fatalf("checkTX failed: %s", resp.Data)
+	}
+	if err := app.State.CancelProcess(util.Hex2byte(t, pid)); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+	if resp := recheck(pending); resp.Code == 0 {
+		t.Fatalf("recheckTX should evict a vote of a canceled process")
+	}
+}
+
+func TestTimeScheduledVote(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := tree.NewTree("testtimescheduledvote", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := createEthRandomKeysBatch(1)[0]
+	pub, _ := key.HexString()
+	if pub, err = ethereum.DecompressPubKey(pub); err != nil {
+		t.Fatal(err)
+	}
+	claim := snarks.Poseidon.Hash(util.Hex2byte(t, pub))
+	if err := tr.AddClaim(claim, nil); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(time.Hour)
+	process := &types.Process{
+		Type:      types.PollVote,
+		EntityID:  util.RandomBytes(types.EntityIDsize),
+		MkRoot:    tr.Root(),
+		StartTime: start.Unix(),
+		EndTime:   start.Add(time.Hour).Unix(),
+	}
+	pid := util.RandomHex(types.ProcessIDsize)
+	app.State.AddProcess(*process, util.Hex2byte(t, pid), "ipfs://123456789")
+	app.Commit()
+
+	proof, err := tr.GenProof(claim, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := types.VoteTx{
+		Nonce:       util.RandomHex(16),
+		ProcessID:   pid,
+		Proof:       proof,
+		VotePackage: newVotePackage(t, 1),
+	}
+	if tx.SignedBytes, err = json.Marshal(tx); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Signature, err = key.Sign(tx.SignedBytes); err != nil {
+		t.Fatal(err)
+	}
+
+	// the block height is irrelevant, only the block time is used
+	for i, tc := range []struct {
+		blockTime time.Time
+		valid     bool
+	}{
+		{start.Add(-time.Second), false},
+		{start, true},
+		{start.Add(time.Hour - time.Second), true},
+		{start.Add(time.Hour), false},
+	} {
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: int64(i + 1), Time: tc.blockTime}})
+		_, err := VoteTxCheck(&tx, app.State, true)
+		if tc.valid && err != nil {
+			t.Fatalf("vote should be accepted on block time %d: (%s)", tc.blockTime.Unix(), err)
+		}
+		if !tc.valid && err == nil {
+			t.Fatalf("vote should be rejected on block time %d", tc.blockTime.Unix())
+		}
+		app.Commit()
+	}
+}
+
+func TestOpenPollVote(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := createEthRandomKeysBatch(3)
+	process := &types.Process{
+		Type:           types.OpenPoll,
+		EntityID:       util.RandomBytes(types.EntityIDsize),
+		NumberOfBlocks: 1024,
+		DenyListSize:   1,
+	}
+	pid := util.RandomHex(types.ProcessIDsize)
+	app.State.AddProcess(*process, util.Hex2byte(t, pid), "")
+	if err := app.State.AddProcessAddressLists(util.Hex2byte(t, pid), nil, []ethcommon.Address{keys[2].Address()}); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+	txs := &txRecorder{}
+	app.State.AddTxListener(txs)
+
+	voteTx := func(voter int) []byte {
+		// no census proof is required
+		tx := types.VoteTx{
+			Nonce:       util.RandomHex(16),
+			ProcessID:   pid,
+			VotePackage: newVotePackage(t, 1),
+		}
+		txBytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Signature, err = keys[voter].Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		tx.Type = "vote"
+		if txBytes, err = json.Marshal(tx); err != nil {
+			t.Fatal(err)
+		}
+		return txBytes
+	}
+
+	for voter := 0; voter < 2; voter++ {
+		tx := voteTx(voter)
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: tx}); resp.Code != 0 {
+			t.Fatalf("checkTX failed: %s", resp.Data)
+		}
+		if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx}); resp.Code != 0 {
+			t.Fatalf("deliverTX failed: %s", resp.Data)
+		}
+		app.Commit()
+		// the signer recovered on the vote check is indexed
+		if ref := txs.refs[voter]; len(ref.Signers) != 1 || ref.Signers[0] != keys[voter].AddressString() {
+			t.Fatalf("unexpected signers of the vote transaction: %v", ref.Signers)
+		}
+	}
+	// each address can only vote once
+	if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: voteTx(0)}); resp.Code == 0 {
+		t.Fatalf("the same address voted twice")
+	}
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: voteTx(2)}); resp.Code == 0 {
+		t.Fatalf("an address of the deny list voted")
+	}
+	if n := app.State.CountVotes(util.Hex2byte(t, pid), false); n != 2 {
+		t.Fatalf("expected 2 votes, got %d", n)
+	}
+}
+
+func TestCensusCAVote(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := createEthRandomKeysBatch(3)
+	ca := keys[2]
+	caPub, _ := ca.HexString()
+	rsaKey, err := rsa.GenerateKey(cryptorand.Reader, blindrsa.MinKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newProcess := func(ca *types.CensusCA) []byte {
+		pid := util.RandomBytes(types.ProcessIDsize)
+		app.State.AddProcess(types.Process{
+			Type:           types.PollVote,
+			EntityID:       util.RandomBytes(types.EntityIDsize),
+			NumberOfBlocks: 1024,
+			CensusCA:       ca,
+		}, pid, "")
+		if ca.Type == types.CensusCABlindRSA {
+			if err := app.State.AddCensusCAKey(pid, ca); err != nil {
+				t.Fatal(err)
+			}
+		}
+		app.Commit()
+		return pid
+	}
+	voteTx := func(pid []byte, voter int, caProof string) []byte {
+		tx := types.VoteTx{
+			CAProof:     caProof,
+			Nonce:       util.RandomHex(16),
+			ProcessID:   hex.EncodeToString(pid),
+			VotePackage: newVotePackage(t, 1),
+		}
+		txBytes, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.Signature, err = keys[voter].Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		tx.Type = "vote"
+		if txBytes, err = json.Marshal(tx); err != nil {
+			t.Fatal(err)
+		}
+		return txBytes
+	}
+	vote := func(tx []byte) error {
+		if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: tx}); resp.Code != 0 {
+			return fmt.Errorf("checkTX failed: %s", resp.Data)
+		}
+		if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx}); resp.Code != 0 {
+			return fmt.Errorf("deliverTX failed: %s", resp.Data)
+		}
+		app.Commit()
+		return nil
+	}
+
+	// ecdsa CA, the census token is a regular signature
+	pid := newProcess(&types.CensusCA{PublicKey: caPub, Type: types.CensusCAECDSA})
+	token, err := ca.Sign(types.CensusCAMessage(pid, keys[0].Address().Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vote(voteTx(pid, 0, token)); err != nil {
+		t.Fatal(err)
+	}
+	// the token of a voter cannot be used by another one
+	if err := vote(voteTx(pid, 1, token)); err == nil {
+		t.Fatal("census token of another voter accepted")
+	}
+	// tokens signed by other keys are rejected
+	token, err = keys[1].Sign(types.CensusCAMessage(pid, keys[1].Address().Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vote(voteTx(pid, 1, token)); err == nil {
+		t.Fatal("census token not signed by the CA accepted")
+	}
+
+	// blind CA, the CA signs the blinded voter address
+	pid = newProcess(&types.CensusCA{
+		PublicKey: blindrsa.EncodePublicKey(&rsaKey.PublicKey),
+		Type:      types.CensusCABlindRSA,
+	})
+	blinded, unblinder, err := blindrsa.Blind(&rsaKey.PublicKey, types.CensusCAMessage(pid, keys[1].Address().Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blindSignature, err := blindrsa.BlindSign(rsaKey, blinded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := blindrsa.Unblind(&rsaKey.PublicKey, blindSignature, unblinder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vote(voteTx(pid, 0, hex.EncodeToString(signature))); err == nil {
+		t.Fatal("blind census token of another voter accepted")
+	}
+	if err := vote(voteTx(pid, 1, hex.EncodeToString(signature))); err != nil {
+		t.Fatal(err)
+	}
+	if n := app.State.CountVotes(pid, false); n != 1 {
+		t.Fatalf("expected 1 vote, got %d", n)
+	}
+
+	// a blind CA key cannot be used by another process
+	tx := &types.NewProcessTx{
+		ProcessType: types.PollVote,
+		CensusCA:    &types.CensusCA{PublicKey: blindrsa.EncodePublicKey(&rsaKey.PublicKey), Type: types.CensusCABlindRSA},
+	}
+	if err := checkCensusCA(tx, app.State); err == nil {
+		t.Fatal("blind census CA key of another process accepted")
+	}
+	// ecdsa tokens sign the process ID, the CA keys can be shared
+	tx.CensusCA = &types.CensusCA{PublicKey: caPub, Type: types.CensusCAECDSA}
+	if err := checkCensusCA(tx, app.State); err != nil {
+		t.Fatalf("ecdsa census CA key of another process rejected: %v", err)
+	}
+}
+
+func TestCheckVotePackage(t *testing.T) {
+	process := &types.Process{
+		Type:        types.PollVote,
+		BallotRules: &types.BallotRules{MaxCount: 3, MaxValue: 4, MaxTotalCost: 6, UniqueValues: true},
+	}
+	for _, tc := range []struct {
+		votes []int
+		valid bool
+	}{
+		{[]int{0, 2, 4}, true},
+		{[]int{3}, true},
+		{[]int{}, false},
+		{[]int{0, 1, 2, 3}, false}, // too many questions
+		{[]int{5}, false},          // value out of range
+		{[]int{-1}, false},         // negative value
+		{[]int{2, 2}, false},       // repeated value
+		{[]int{1, 2, 4}, false},    // total cost exceeded
+	} {
+		err := checkVotePackage(process, newVotePackage(t, tc.votes...))
+		if tc.valid && err != nil {
+			t.Errorf("votes %v should be valid: %s", tc.votes, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("votes %v should be invalid", tc.votes)
+		}
+	}
+	if err := checkVotePackage(process, "bm90IGEgdm90ZQ=="); err == nil {
+		t.Errorf("malformed vote package accepted")
+	}
+	// the votes of processes without ballot rules (created before the genesis ballot
+	// rules height) are not checked, the default rules only enforce the tally limits
+	process.BallotRules = nil
+	if err := checkVotePackage(process, newVotePackage(t, types.MaxOptions)); err != nil {
+		t.Errorf("votes of a process without ballot rules should not be checked: %s", err)
+	}
+	process.BallotRules = types.DefaultBallotRules()
+	if err := checkVotePackage(process, newVotePackage(t, 5, 5, 5, 5)); err != nil {
+		t.Errorf("votes should be valid with the default rules: %s", err)
+	}
+	if err := checkVotePackage(process, newVotePackage(t, types.MaxOptions)); err == nil {
+		t.Errorf("option out of the tally limits accepted")
+	}
+
+	// an exact count requires answering all the questions
+	process.BallotRules = &types.BallotRules{ExactCount: true, MaxCount: 3, MaxValue: 4}
+	if err := checkVotePackage(process, newVotePackage(t, 1, 2, 3)); err != nil {
+		t.Errorf("votes answering all the questions should be valid: %s", err)
+	}
+	if err := checkVotePackage(process, newVotePackage(t, 1, 2)); err == nil {
+		t.Errorf("votes not answering all the questions accepted")
+	}
+
+	// quadratic votes cost the square of the values
+	process.BallotRules = &types.BallotRules{MaxCount: 3, MaxValue: 4, MaxTotalCost: 10, TallyMode: types.TallyQuadratic}
+	if err := process.BallotRules.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkVotePackage(process, newVotePackage(t, 3, 1, 0)); err != nil {
+		t.Errorf("quadratic vote within the credits should be valid: %s", err)
+	}
+	if err := checkVotePackage(process, newVotePackage(t, 3, 1, 1)); err == nil {
+		t.Errorf("quadratic vote exceeding the credits accepted")
+	}
+	// the total cost must not wrap around
+	process.BallotRules = &types.BallotRules{MaxCount: 3, MaxValue: math.MaxUint32, MaxTotalCost: math.MaxUint64,
+		TallyMode: types.TallyQuadratic}
+	if err := checkVotePackage(process, newVotePackage(t, math.MaxUint32, math.MaxUint32, math.MaxUint32)); err == nil {
+		t.Errorf("quadratic vote overflowing the total cost accepted")
+	}
+	// ranked-choice requires unique preferences
+	process.BallotRules = &types.BallotRules{MaxCount: 3, MaxValue: 3, TallyMode: types.TallyRankedChoice}
+	if err := process.BallotRules.Validate(); err == nil {
+		t.Errorf("ranked-choice rules without unique values accepted")
+	}
+}
+
+func TestEndBlockValidatorUpdates(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk1 := ed25519.GenPrivKey().PubKey().(ed25519.PubKeyEd25519)
+	pk2 := ed25519.GenPrivKey().PubKey().(ed25519.PubKeyEd25519)
+
+	if err := app.State.AddValidator(pk1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State.AddValidator(pk2, 10); err != nil {
+		t.Fatal(err)
+	}
+	resp := app.EndBlock(abcitypes.RequestEndBlock{})
+	if len(resp.ValidatorUpdates) != 2 {
+		t.Fatalf("expected 2 validator updates, got %d", len(resp.ValidatorUpdates))
+	}
+	app.Commit()
+	if resp = app.EndBlock(abcitypes.RequestEndBlock{}); len(resp.ValidatorUpdates) != 0 {
+		t.Fatalf("validator updates not cleared after commit")
+	}
+
+	// power change and removal
+	if err := app.State.AddValidator(pk1, 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State.RemoveValidator(pk2.Address().String()); err != nil {
+		t.Fatal(err)
+	}
+	resp = app.EndBlock(abcitypes.RequestEndBlock{})
+	if len(resp.ValidatorUpdates) != 2 {
+		t.Fatalf("expected 2 validator updates, got %d", len(resp.ValidatorUpdates))
+	}
+	for _, u := range resp.ValidatorUpdates {
+		switch string(u.PubKey.Data) {
+		case string(pk1[:]):
+			if u.Power != 20 {
+				t.Errorf("wrong power for updated validator: %d", u.Power)
+			}
+		case string(pk2[:]):
+			if u.Power != 0 {
+				t.Errorf("removed validator must have zero power: %d", u.Power)
+			}
+		default:
+			t.Errorf("unexpected validator update %x", u.PubKey.Data)
+		}
+	}
+	app.Commit()
+
+	// the last validator cannot be removed
+	if err := app.State.RemoveValidator(pk1.Address().String()); err == nil {
+		t.Errorf("last validator removed")
+	}
+}
+
+func TestQueryProof(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.InitChain(abcitypes.RequestInitChain{})
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: 1}})
+	pid := util.RandomBytes(types.ProcessIDsize)
+	process := types.Process{EntityID: util.RandomBytes(types.EntityIDsize), Type: types.PollVote}
+	if err := app.State.AddProcess(process, pid, ""); err != nil {
+		t.Fatal(err)
+	}
+	appHash := app.Commit().Data
+
+	resp := app.Query(abcitypes.RequestQuery{Path: fmt.Sprintf("/process/%x", pid), Prove: true})
+	if resp.Code != 0 {
+		t.Fatalf("query failed: %s", resp.Log)
+	}
+	if resp.Height != 1 {
+		t.Errorf("wrong query height %d", resp.Height)
+	}
+	if resp.Proof == nil || len(resp.Proof.Ops) != 2 {
+		t.Fatalf("missing proof operations")
+	}
+	var roots map[string]string
+	if err := json.Unmarshal(resp.Proof.Ops[1].Data, &roots); err != nil {
+		t.Fatal(err)
+	}
+	processRoot, err := hex.DecodeString(roots[ProcessTree])
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := iavlstate.VerifyValue(resp.Key, resp.Value, resp.Proof.Ops[0].Data, processRoot)
+	if err != nil || !valid {
+		t.Fatalf("process proof is not valid: %v", err)
+	}
+	var concat []byte
+	for _, name := range []string{AppTree, ProcessTree, VoteTree} {
+		r, err := hex.DecodeString(roots[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		concat = append(concat, r...)
+	}
+	if !bytes.Equal(ethereum.HashRaw(concat), appHash) {
+		t.Errorf("state roots do not match the app hash")
+	}
+	verifyQueryProof(t, resp, ProcessTree, appHash)
+
+	// non existing keys and bad paths
+	if resp = app.Query(abcitypes.RequestQuery{Path: fmt.Sprintf("/process/%x", util.RandomBytes(32))}); resp.Code == 0 {
+		t.Errorf("non existing process query must fail")
+	}
+	if resp = app.Query(abcitypes.RequestQuery{Path: "/foo/bar"}); resp.Code == 0 {
+		t.Errorf("invalid path query must fail")
+	}
+}
+
+func TestQueryProofGraviton(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), StateBackendGraviton)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.InitChain(abcitypes.RequestInitChain{})
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: 1}})
+	pid := util.RandomBytes(types.ProcessIDsize)
+	process := types.Process{EntityID: util.RandomBytes(types.EntityIDsize), Type: types.PollVote}
+	if err := app.State.AddProcess(process, pid, ""); err != nil {
+		t.Fatal(err)
+	}
+	appHash := app.Commit().Data
+
+	resp := app.Query(abcitypes.RequestQuery{Path: fmt.Sprintf("/process/%x", pid), Prove: true})
+	if resp.Code != 0 {
+		t.Fatalf("query failed: %s", resp.Log)
+	}
+	if resp.Proof == nil || len(resp.Proof.Ops) != 2 || resp.Proof.Ops[0].Type != gravitonstate.ProofType {
+		t.Fatalf("missing graviton proof operations")
+	}
+	verifyQueryProof(t, resp, ProcessTree, appHash)
+}
+
+// verifyQueryProof checks the proof of a query response with a Tendermint proof runtime
+func verifyQueryProof(t *testing.T, resp abcitypes.ResponseQuery, treeName string, appHash []byte) {
+	t.Helper()
+	prt := ProofRuntime()
+	if err := prt.VerifyValue(resp.Proof, appHash, ProofKeyPath(treeName, resp.Key), resp.Value); err != nil {
+		t.Errorf("cannot verify proof: %v", err)
+	}
+	if err := prt.VerifyValue(resp.Proof, appHash, ProofKeyPath(treeName, resp.Key), util.RandomBytes(32)); err == nil {
+		t.Errorf("proof verified for a wrong value")
+	}
+	if err := prt.VerifyValue(resp.Proof, util.RandomBytes(32), ProofKeyPath(treeName, resp.Key), resp.Value); err == nil {
+		t.Errorf("proof verified for a wrong app hash")
+	}
+	if err := prt.VerifyValue(resp.Proof, appHash, ProofKeyPath(VoteTree, resp.Key), resp.Value); err == nil {
+		t.Errorf("proof verified for a wrong tree")
+	}
+}
+
+func TestMigrateState(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	app, err := NewBaseApplication(srcDir, StateBackendIavl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.InitChain(abcitypes.RequestInitChain{})
+	var pids [][]byte
+	for height := int64(1); height <= 2; height++ {
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: height}})
+		for i := 0; i < 5; i++ {
+			pid := util.RandomBytes(types.ProcessIDsize)
+			process := types.Process{EntityID: util.RandomBytes(types.EntityIDsize), Type: types.PollVote}
+			if err := app.State.AddProcess(process, pid, ""); err != nil {
+				t.Fatal(err)
+			}
+			pids = append(pids, pid)
+		}
+		app.Commit()
+	}
+	if err := app.State.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateState(srcDir, StateBackendIavl, dstDir, StateBackendGraviton, 1); err == nil {
+		t.Fatalf("migrating a height which is not the last one must fail")
+	}
+	if _, err := MigrateState(srcDir, StateBackendIavl, dstDir, StateBackendGraviton, -1); err == nil {
+		t.Fatalf("migrating a negative height must fail")
+	}
+	missingDir := filepath.Join(t.TempDir(), "missing")
+	if _, err := MigrateState(missingDir, StateBackendIavl, dstDir, StateBackendGraviton, 0); err == nil {
+		t.Fatalf("migrating a missing source state must fail")
+	}
+	if _, err := os.Stat(missingDir); err == nil {
+		t.Fatalf("missing source state directory created")
+	}
+	m, err := MigrateState(srcDir, StateBackendIavl, dstDir, StateBackendGraviton, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Height != 2 {
+		t.Errorf("wrong migrated height %d", m.Height)
+	}
+	if m.Keys[ProcessTree] != uint64(len(pids)) {
+		t.Errorf("wrong number of migrated processes %d", m.Keys[ProcessTree])
+	}
+	if _, err := MigrateState(srcDir, StateBackendIavl, dstDir, StateBackendGraviton, 0); err == nil {
+		t.Errorf("migrating to a non empty destination must fail")
+	}
+
+	// the node must load the migrated state on startup
+	s, err := NewStateWithBackend(dstDir, StateBackendGraviton, app.Codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := s.Header(false); h == nil || h.Height != 2 {
+		t.Errorf("migrated state header is not at height 2")
+	}
+	for _, pid := range pids {
+		if _, err := s.Process(pid, false); err != nil {
+			t.Errorf("process %x not migrated: %v", pid, err)
+		}
+	}
+}
+
+func TestMigrateValidator(t *testing.T) {
+	nodeDir := t.TempDir()
+	srcDir := filepath.Join(nodeDir, "data")
+	if err := os.MkdirAll(filepath.Join(nodeDir, "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(nodeDir, "config", "priv_validator_key.json")
+	pv := privval.GenFilePV(keyFile, filepath.Join(nodeDir, "priv_validator_state.json"))
+	pv.Save()
+	app, err := NewBaseApplication(srcDir, StateBackendIavl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.InitChain(abcitypes.RequestInitChain{})
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: 1}})
+	if err := app.State.AddValidator(pv.Key.PubKey.(ed25519.PubKeyEd25519), 10); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+	if err := app.State.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateState(srcDir, StateBackendIavl, t.TempDir(), StateBackendGraviton, 0); err == nil {
+		t.Fatalf("migrating the state of a validator must fail")
+	}
+	// the same state can be migrated by a node which is not a validator
+	if err := os.Remove(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateState(srcDir, StateBackendIavl, t.TempDir(), StateBackendGraviton, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), StateBackendGraviton)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.InitChain(abcitypes.RequestInitChain{})
+	var pids [][]byte
+	var hash []byte
+	for height := int64(1); height <= 4; height++ {
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: height}})
+		pid := util.RandomBytes(types.ProcessIDsize)
+		process := types.Process{EntityID: util.RandomBytes(types.EntityIDsize), Type: types.PollVote}
+		if err := app.State.AddProcess(process, pid, ""); err != nil {
+			t.Fatal(err)
+		}
+		pids = append(pids, pid)
+		hash = app.Commit().Data
+	}
+
+	// the snapshot is copied from the committed trees, the next blocks must not change it
+	src, err := app.State.snapshotSource()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: 5}})
+	if err := app.State.AddProcess(types.Process{EntityID: util.RandomBytes(types.EntityIDsize), Type: types.PollVote},
+		util.RandomBytes(types.ProcessIDsize), ""); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+	s := src.copy()
+	if s.Height != 4 || !bytes.Equal(s.AppHash, hash) {
+		t.Fatalf("wrong snapshot height %d or app hash %x", s.Height, s.AppHash)
+	}
+	snapshotDir := t.TempDir()
+	path, err := SaveSnapshot(snapshotDir, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heights, err := ListSnapshots(snapshotDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(heights) != "[4]" {
+		t.Errorf("expected a snapshot at height 4, got %v", heights)
+	}
+	if s, err = LoadSnapshot(path, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the snapshot must match the trusted height and app hash
+	if err := RestoreSnapshot(t.TempDir(), s, 3, hash); err == nil {
+		t.Errorf("snapshot restored with a wrong trusted height")
+	}
+	if err := RestoreSnapshot(t.TempDir(), s, 4, util.RandomBytes(32)); err == nil {
+		t.Errorf("snapshot restored with a wrong trusted app hash")
+	}
+	s.Trees[1].Values[0] = []byte("tampered")
+	if err := RestoreSnapshot(t.TempDir(), s, 4, hash); err == nil {
+		t.Errorf("tampered snapshot restored")
+	}
+	if s, err = LoadSnapshot(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	dataDir := t.TempDir()
+	if err := RestoreSnapshot(dataDir, s, 4, hash); err != nil {
+		t.Fatal(err)
+	}
+
+	// the node must load the restored state on startup
+	state, err := NewStateWithBackend(dataDir, StateBackendGraviton, app.Codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := state.Header(false); h == nil || h.Height != 4 {
+		t.Errorf("restored state header is not at height 4")
+	}
+	if !bytes.Equal(state.WorkingHash(), hash) {
+		t.Errorf("restored state app hash %x does not match %x", state.WorkingHash(), hash)
+	}
+	for _, pid := range pids {
+		if _, err := state.Process(pid, false); err != nil {
+			t.Errorf("process %x not restored: %v", pid, err)
+		}
+	}
+}
+
+func TestSnarkVoteCache(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := util.RandomBytes(types.ProcessIDsize)
+	// the census root and nullifier must be valid field elements
+	root := append([]byte{0}, util.RandomBytes(31)...)
+	nullifier := append([]byte{0}, util.RandomBytes(types.VoteNullifierSize-1)...)
+	votePackage := newVotePackage(t, 1)
+	inputs, err := snarkVotePublicInputs(hex.EncodeToString(root), pid, nullifier, votePackage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vk, proof := groth16Setup(t, inputs)
+	process := &types.Process{
+		Type:              types.SnarkVote,
+		EntityID:          util.RandomBytes(types.EntityIDsize),
+		MkRoot:            hex.EncodeToString(root),
+		NumberOfBlocks:    1024,
+		ZkVerificationKey: vk,
+	}
+	if err := app.State.AddProcess(*process, pid, "ipfs://123456789"); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+
+	voteTx := func(votePackage string) []byte {
+		txBytes, err := json.Marshal(types.VoteTx{
+			Nullifier:   hex.EncodeToString(nullifier),
+			ProcessID:   hex.EncodeToString(pid),
+			Type:        "vote",
+			VotePackage: votePackage,
+			ZkProof:     proof,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return txBytes
+	}
+	valid := voteTx(votePackage)
+	tampered := voteTx(newVotePackage(t, 2))
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: tampered}); resp.Code == 0 {
+		t.Fatalf("checkTX accepted a proof for another vote package")
+	}
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: valid}); resp.Code != 0 {
+		t.Fatalf("checkTX failed: %s", resp.Data)
+	}
+
+	// a proposer replays the cached nullifier with another vote package
+	if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tampered}); resp.Code == 0 {
+		t.Fatalf("deliverTX accepted a tampered vote package using the cached proof")
+	}
+	if app.State.EnvelopeExists(pid, nullifier) {
+		t.Fatalf("tampered vote stored")
+	}
+
+	if resp := app.CheckTx(abcitypes.RequestCheckTx{Tx: valid}); resp.Code != 0 {
+		t.Fatalf("checkTX failed: %s", resp.Data)
+	}
+	if resp := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: valid}); resp.Code != 0 {
+		t.Fatalf("deliverTX failed: %s", resp.Data)
+	}
+	app.Commit()
+	vote, err := app.State.Envelope(pid, nullifier, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vote.VotePackage != votePackage {
+		t.Errorf("stored vote package %s does not match %s", vote.VotePackage, votePackage)
+	}
+}
+
+// newVotePackage returns a plaintext vote package with the given votes
+// txRecorder is a TxListener which keeps the delivered transaction references
+type txRecorder struct {
+	refs []*types.TxReference
+}
+
+func (r *txRecorder) OnTx(ref *types.TxReference) {
+	r.refs = append(r.refs, ref)
+}
+
+func newVotePackage(tb testing.TB, votes ...int) string {
+	vp, err := json.Marshal(types.VotePackage{Nonce: util.RandomHex(16), Votes: votes})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(vp)
+}
+
+// groth16Setup builds a Groth16 verification key and a valid proof for the given public
+// inputs by choosing the setup exponents, so the pairing equation holds without an
+// actual circuit
+func groth16Setup(tb testing.TB, inputs []*big.Int) (*types.ZkVerificationKey, *types.ZkProof) {
+	scalar := func() *big.Int {
+		k, err := cryptorand.Int(cryptorand.Reader, snarks.FieldSize)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return k
+	}
+	mul := func(a, b *big.Int) *big.Int {
+		return new(big.Int).Mod(new(big.Int).Mul(a, b), snarks.FieldSize)
+	}
+	g1 := func(k *big.Int) []string {
+		b := new(bn256.G1).ScalarBaseMult(k).Marshal()
+		return []string{new(big.Int).SetBytes(b[:32]).String(), new(big.Int).SetBytes(b[32:]).String(), "1"}
+	}
+	g2 := func(k *big.Int) [][]string {
+		b := new(bn256.G2).ScalarBaseMult(k).Marshal()
+		n := func(i int) string { return new(big.Int).SetBytes(b[i*32 : (i+1)*32]).String() }
+		return [][]string{{n(1), n(0)}, {n(3), n(2)}, {"1", "0"}}
+	}
+
+	alpha, beta, gamma, delta := scalar(), scalar(), scalar(), scalar()
+	vk := &types.ZkVerificationKey{Alpha: g1(alpha), Beta: g2(beta), Gamma: g2(gamma), Delta: g2(delta)}
+	x := scalar()
+	vk.IC = append(vk.IC, g1(x))
+	for _, in := range inputs {
+		k := scalar()
+		vk.IC = append(vk.IC, g1(k))
+		x.Add(x, mul(in, k))
+	}
+	x.Mod(x, snarks.FieldSize)
+
+	// a*b = alpha*beta + x*gamma + c*delta
+	a, b := scalar(), scalar()
+	c := new(big.Int).Sub(mul(a, b), mul(alpha, beta))
+	c.Sub(c, mul(x, gamma))
+	c.Mul(c, new(big.Int).ModInverse(delta, snarks.FieldSize))
+	c.Mod(c, snarks.FieldSize)
+	return vk, &types.ZkProof{A: g1(a), B: g2(b), C: g1(c)}
+}
+
 // CreateEthRandomKeysBatch creates a set of eth random signing keys
 func createEthRandomKeysBatch(n int) []*ethereum.SignKeys {
 	s := make([]*ethereum.SignKeys, n)
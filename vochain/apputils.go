@@ -1,19 +1,26 @@
 package vochain
 
 import (
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"math/rand"
 	"strconv"
 
 	"gitlab.com/vocdoni/go-dvote/config"
+	"gitlab.com/vocdoni/go-dvote/crypto/blindrsa"
 	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
+	"gitlab.com/vocdoni/go-dvote/crypto/snarks"
+	"gitlab.com/vocdoni/go-dvote/crypto/threshold"
 	tree "gitlab.com/vocdoni/go-dvote/trie"
 	"gitlab.com/vocdoni/go-dvote/types"
 	"gitlab.com/vocdoni/go-dvote/util"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-crypto/poseidon"
 	amino "github.com/tendermint/go-amino"
 	cfg "github.com/tendermint/tendermint/config"
 	crypto25519 "github.com/tendermint/tendermint/crypto/ed25519"
@@ -23,17 +30,405 @@ import (
 )
 
 // hexproof is the hexadecimal a string. leafData is the claim data in byte format
-func checkMerkleProof(rootHash, hexproof string, leafData []byte) (bool, error) {
-	return tree.CheckProof(rootHash, hexproof, leafData, []byte{})
+func checkMerkleProof(rootHash, hexproof string, leafData, leafValue []byte) (bool, error) {
+	return tree.CheckProof(rootHash, hexproof, leafData, leafValue)
 }
 
-// VerifySignatureAgainstOracles verifies that a signature match with one of the oracles
-func verifySignatureAgainstOracles(oracles []string, message []byte, signHex string) (bool, ethcommon.Address, error) {
+// checkVotePackage decodes a plaintext (base64 encoded JSON) vote package and checks
+// its votes follow the ballot rules of the process. The processes without ballot rules
+// were created before the genesis BallotRulesHeight and their votes are not checked.
+func checkVotePackage(process *types.Process, votePackage string) error {
+	if process.BallotRules == nil {
+		return nil
+	}
+	rawVote, err := base64.StdEncoding.DecodeString(votePackage)
+	if err != nil {
+		return fmt.Errorf("cannot decode base64: (%s)", err)
+	}
+	var vp types.VotePackage
+	if err := json.Unmarshal(rawVote, &vp); err != nil {
+		return fmt.Errorf("cannot decode JSON: (%s)", err)
+	}
+	return process.BallotRules.CheckVotes(vp.Votes)
+}
+
+// snarkVoteInputs is the number of public inputs of the snark-vote census circuit
+const snarkVoteInputs = 5
+
+// snarkVotePublicInputs returns the public inputs of the snark-vote census circuit.
+// In order: census root, nullifier, first and second half of the process ID and
+// the Poseidon hash of the vote package. Binding the vote package prevents a
+// relayer from replacing the vote of a valid proof.
+func snarkVotePublicInputs(mkRoot string, processID, nullifier []byte, votePackage string) ([]*big.Int, error) {
+	root, err := hex.DecodeString(util.TrimHex(mkRoot))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode census root: (%s)", err)
+	}
+	if len(processID) != types.ProcessIDsize {
+		return nil, fmt.Errorf("wrong process ID size %d", len(processID))
+	}
+	voteHash, err := poseidon.HashBytes([]byte(votePackage))
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute vote package hash: (%s)", err)
+	}
+	return []*big.Int{
+		new(big.Int).SetBytes(root),
+		new(big.Int).SetBytes(nullifier),
+		new(big.Int).SetBytes(processID[:types.ProcessIDsize/2]),
+		new(big.Int).SetBytes(processID[types.ProcessIDsize/2:]),
+		voteHash,
+	}, nil
+}
+
+// checkSnarkProof verifies the zk-SNARK census proof of an anonymous vote against the
+// process verification key and its valid census roots. Returns the census root of the
+// valid proof, or an empty string if the proof is not valid.
+func checkSnarkProof(process *types.Process, processID, nullifier []byte, votePackage string, proof *types.ZkProof) (string, error) {
+	for _, root := range process.CensusRoots() {
+		inputs, err := snarkVotePublicInputs(root, processID, nullifier, votePackage)
+		if err != nil {
+			return "", err
+		}
+		valid, err := snarks.VerifyGroth16(process.ZkVerificationKey, proof, inputs)
+		if err != nil {
+			return "", err
+		}
+		if valid {
+			return root, nil
+		}
+	}
+	return "", nil
+}
+
+// checkCensusProof checks a merkle census proof against the valid census roots of a process.
+// Returns the census root of the valid proof, or an empty string if the proof is not valid.
+func checkCensusProof(process *types.Process, hexproof string, leafData, leafValue []byte) (string, error) {
+	for _, root := range process.CensusRoots() {
+		valid, err := checkMerkleProof(root, hexproof, leafData, leafValue)
+		if err != nil {
+			return "", err
+		}
+		if valid {
+			return root, nil
+		}
+	}
+	return "", nil
+}
+
+// checkCAProof checks the census token of a voter address, which is the signature of the census
+// CA of the process over CensusCAMessage(processID, address)
+func checkCAProof(ca *types.CensusCA, processID []byte, address ethcommon.Address, proofHex string) error {
+	if proofHex == "" {
+		return fmt.Errorf("missing census CA proof")
+	}
+	message := types.CensusCAMessage(processID, address.Bytes())
+	switch ca.Type {
+	case types.CensusCAECDSA:
+		caAddr, err := ethereum.AddrFromPublicKey(ca.PublicKey)
+		if err != nil {
+			return fmt.Errorf("cannot decode census CA public key: (%s)", err)
+		}
+		signer, err := ethereum.AddrFromSignature(message, proofHex)
+		if err != nil {
+			return fmt.Errorf("cannot extract address from census CA proof: (%s)", err)
+		}
+		if signer != caAddr {
+			return fmt.Errorf("census CA proof not signed by the process CA")
+		}
+	case types.CensusCABlindRSA:
+		pub, err := blindrsa.DecodePublicKey(ca.PublicKey)
+		if err != nil {
+			return fmt.Errorf("cannot decode census CA public key: (%s)", err)
+		}
+		signature, err := hex.DecodeString(util.TrimHex(proofHex))
+		if err != nil {
+			return fmt.Errorf("cannot decode census CA proof: (%s)", err)
+		}
+		if err := blindrsa.Verify(pub, message, signature); err != nil {
+			return fmt.Errorf("invalid census CA proof: (%s)", err)
+		}
+	default:
+		return fmt.Errorf("census CA type %s not supported", ca.Type)
+	}
+	return nil
+}
+
+// checkCensusCA returns an error if the census CA of a new process is not valid.
+// A blind CA signs any message, so a voter could get a valid token for any other process using
+// the same key: each process must have its own blind CA key, which cannot be used again.
+func checkCensusCA(tx *types.NewProcessTx, state *State) error {
+	switch tx.ProcessType {
+	case types.PollVote, types.PetitionSign, types.EncryptedPoll:
+		// ok
+	default:
+		return fmt.Errorf("not allowed on %s processes", tx.ProcessType)
+	}
+	if tx.MkRoot != "" || tx.TokenCensus != nil {
+		return fmt.Errorf("the process cannot have another census")
+	}
+	switch tx.CensusCA.Type {
+	case types.CensusCAECDSA:
+		if _, err := ethereum.AddrFromPublicKey(util.TrimHex(tx.CensusCA.PublicKey)); err != nil {
+			return fmt.Errorf("invalid public key: (%s)", err)
+		}
+	case types.CensusCABlindRSA:
+		if _, err := blindrsa.DecodePublicKey(util.TrimHex(tx.CensusCA.PublicKey)); err != nil {
+			return fmt.Errorf("invalid public key: (%s)", err)
+		}
+		if pid := state.CensusCAProcess(tx.CensusCA, false); pid != nil {
+			return fmt.Errorf("public key already used by process %x", pid)
+		}
+	default:
+		return fmt.Errorf("type %s not supported", tx.CensusCA.Type)
+	}
+	return nil
+}
+
+// checkAddressList returns an error if the list is too big or contains a malformed ethereum address
+func checkAddressList(list []string) error {
+	if len(list) > types.MaxAddressListSize {
+		return fmt.Errorf("too many addresses, maximum is %d", types.MaxAddressListSize)
+	}
+	for _, addr := range list {
+		if !ethcommon.IsHexAddress(addr) {
+			return fmt.Errorf("malformed address %s", addr)
+		}
+	}
+	return nil
+}
+
+// addressList returns the unique ethereum addresses of a list of hexadecimal addresses
+func addressList(list []string) []ethcommon.Address {
+	var addresses []ethcommon.Address
+	known := make(map[ethcommon.Address]bool, len(list))
+	for _, hexAddr := range list {
+		addr := ethcommon.HexToAddress(hexAddr)
+		if !known[addr] {
+			known[addr] = true
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses
+}
+
+// checkTokenCensus returns an error if the token census of a new process is not valid
+func checkTokenCensus(tx *types.NewProcessTx) error {
+	switch tx.ProcessType {
+	case types.PollVote, types.PetitionSign, types.EncryptedPoll, types.WeightedPoll:
+		// ok
+	default:
+		return fmt.Errorf("not allowed on %s processes", tx.ProcessType)
+	}
+	if tx.MkRoot != "" {
+		return fmt.Errorf("census root is not allowed")
+	}
+	tc := tx.TokenCensus
+	if !ethcommon.IsHexAddress(tc.TokenAddress) {
+		return fmt.Errorf("malformed token address")
+	}
+	if !util.IsHexEncodedStringWithLength(util.TrimHex(tc.StateRoot), ethcommon.HashLength) {
+		return fmt.Errorf("malformed state root")
+	}
+	// the balance is an uint256, so it has at most 78 decimal digits
+	if tc.Decimals > 77 {
+		return fmt.Errorf("too many decimals")
+	}
+	return nil
+}
+
+// processKeyDeals decodes the commitments of the qualified threshold encryption key deals of a process
+func processKeyDeals(process *types.Process) ([][]*threshold.Point, error) {
+	var deals [][]*threshold.Point
+	for i := range process.KeyDeals {
+		if !process.KeyDeals[i].Qualified(process.KeyThreshold) {
+			continue
+		}
+		commitments, err := decodeKeyDeal(&process.KeyDeals[i])
+		if err != nil {
+			return nil, err
+		}
+		deals = append(deals, commitments)
+	}
+	return deals, nil
+}
+
+// decodeKeyDeal decodes the commitments of a threshold encryption key deal
+func decodeKeyDeal(deal *types.KeyDeal) ([]*threshold.Point, error) {
+	commitments := make([]*threshold.Point, len(deal.Commitments))
+	for i, c := range deal.Commitments {
+		var err error
+		if commitments[i], err = threshold.DecodePoint(c); err != nil {
+			return nil, fmt.Errorf("cannot decode key deal commitment: (%s)", err)
+		}
+	}
+	return commitments, nil
+}
+
+// updateThresholdPublicKey sets the threshold encryption public key of a process, which is the sum
+// of the secrets of the qualified dealers. It is empty while there are no qualified dealers.
+func updateThresholdPublicKey(process *types.Process) error {
+	deals, err := processKeyDeals(process)
+	if err != nil {
+		return err
+	}
+	process.EncryptionPublicKeys[types.ThresholdKeyIndex] = ""
+	if len(deals) == 0 {
+		return nil
+	}
+	pub, err := threshold.PublicKey(deals).BoxPublicKey()
+	if err != nil {
+		return err
+	}
+	process.EncryptionPublicKeys[types.ThresholdKeyIndex] = fmt.Sprintf("%x", pub)
+	return nil
+}
+
+// combineKeyShares recovers the threshold encryption private key of a process from the revealed
+// key shares, which have been already verified against the key deals
+func combineKeyShares(process *types.Process) error {
+	shares := make(map[int]*threshold.Scalar)
+	for i, k := range process.EncryptionPrivateKeys {
+		if i == types.ThresholdKeyIndex || k == "" {
+			continue
+		}
+		share, err := threshold.DecodeScalar(k)
+		if err != nil {
+			return err
+		}
+		shares[i] = share
+	}
+	secret, err := threshold.Combine(shares)
+	if err != nil {
+		return err
+	}
+	process.EncryptionPrivateKeys[types.ThresholdKeyIndex] = threshold.EncodeScalar(secret)
+	return nil
+}
+
+// decodeHexList decodes a list of hexadecimal strings
+func decodeHexList(list []string) ([][]byte, error) {
+	decoded := make([][]byte, len(list))
+	for i, h := range list {
+		var err error
+		if decoded[i], err = hex.DecodeString(util.TrimHex(h)); err != nil {
+			return nil, err
+		}
+	}
+	return decoded, nil
+}
+
+// isSnarkCensusRoot returns true if the hexadecimal census root can be used as input of the snark-vote circuit
+func isSnarkCensusRoot(mkroot string) bool {
+	root, err := hex.DecodeString(mkroot)
+	return err == nil && len(root) > 0 && new(big.Int).SetBytes(root).Cmp(snarks.FieldSize) < 0
+}
+
+// verifyOracleSignatures checks that message is signed by at least threshold distinct oracles.
+// Every signature must belong to an oracle. Returns the addresses of the signers.
+func verifyOracleSignatures(oracles []string, message []byte, signatures []string, threshold int) ([]ethcommon.Address, error) {
+	if len(oracles) == 0 {
+		return nil, fmt.Errorf("cannot check authorization against a nil or empty oracle list")
+	}
 	signKeys := ethereum.NewSignKeys()
 	for _, oracle := range oracles {
 		signKeys.AddAuthKey(ethcommon.HexToAddress(oracle))
 	}
-	return signKeys.VerifySender(message, signHex)
+	var signers []ethcommon.Address
+	signed := make(map[ethcommon.Address]bool, len(signatures))
+	for _, signature := range signatures {
+		authorized, addr, err := signKeys.VerifySender(message, signature)
+		if err != nil {
+			return nil, err
+		}
+		if !authorized {
+			return nil, fmt.Errorf("recovered address %s is not an oracle", addr.Hex())
+		}
+		if signed[addr] {
+			return nil, fmt.Errorf("oracle %s signed more than once", addr.Hex())
+		}
+		signed[addr] = true
+		signers = append(signers, addr)
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	if len(signers) < threshold {
+		return nil, fmt.Errorf("not enough oracle signatures (got %d, need %d)", len(signers), threshold)
+	}
+	return signers, nil
+}
+
+// txSignatures returns the non empty signatures of a transaction
+func txSignatures(signature string, signatures []string) []string {
+	all := make([]string, 0, len(signatures)+1)
+	for _, s := range append([]string{signature}, signatures...) {
+		if s != "" {
+			all = append(all, s)
+		}
+	}
+	return all
+}
+
+// oracleTxFields returns the signed bytes, the signatures and the nonce of a privileged
+// transaction, or an error if the transaction cannot be co-signed by the oracles
+func oracleTxFields(gtx GenericTX) ([]byte, []string, string, error) {
+	switch tx := gtx.(type) {
+	case *types.AdminTx:
+		return tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce, nil
+	case *types.NewProcessTx:
+		return tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce, nil
+	case *types.CancelProcessTx:
+		return tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce, nil
+	case *types.PauseProcessTx:
+		return tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce, nil
+	case *types.UpdateCensusTx:
+		return tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce, nil
+	}
+	return nil, nil, "", fmt.Errorf("transaction type %s cannot be co-signed", gtx.TxType())
+}
+
+// setTxSignatures replaces the signatures of a privileged transaction
+func setTxSignatures(gtx GenericTX, signatures []string) {
+	if len(signatures) == 0 {
+		return
+	}
+	signature, cosignatures := signatures[0], signatures[1:]
+	switch tx := gtx.(type) {
+	case *types.AdminTx:
+		tx.Signature, tx.Signatures = signature, cosignatures
+	case *types.NewProcessTx:
+		tx.Signature, tx.Signatures = signature, cosignatures
+	case *types.CancelProcessTx:
+		tx.Signature, tx.Signatures = signature, cosignatures
+	case *types.PauseProcessTx:
+		tx.Signature, tx.Signatures = signature, cosignatures
+	case *types.UpdateCensusTx:
+		tx.Signature, tx.Signatures = signature, cosignatures
+	}
+}
+
+// oracleTxThreshold returns the number of oracle signatures required by a privileged transaction
+func oracleTxThreshold(gtx GenericTX, t types.OracleThresholds) int {
+	var threshold uint32
+	switch tx := gtx.(type) {
+	case *types.AdminTx:
+		threshold = t.Admin
+		if processKeysTxTypes[tx.Type] {
+			threshold = t.ProcessKeys
+		}
+	case *types.NewProcessTx:
+		threshold = t.NewProcess
+	case *types.CancelProcessTx:
+		threshold = t.CancelProcess
+	case *types.PauseProcessTx:
+		threshold = t.PauseProcess
+	case *types.UpdateCensusTx:
+		threshold = t.UpdateCensus
+	}
+	if threshold < 1 {
+		return 1
+	}
+	return int(threshold)
 }
 
 // GenerateNullifier generates the nullifier of a vote (hash(address+processId))
@@ -108,6 +503,8 @@ func NewGenesis(cfg *config.VochainCfg, chainID string, consensusParams *types.C
 	}
 
 	appState.Oracles = oracles
+	// new networks check the vote packages of every process
+	appState.BallotRulesHeight = 1
 	cdc := amino.NewCodec()
 	RegisterAmino(cdc)
 
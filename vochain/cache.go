@@ -1,19 +1,189 @@
 package vochain
 
 import (
-	"time"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
 
+	tmdb "github.com/tendermint/tm-db"
+	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/types"
 )
 
-// VoteCacheAdd adds a new vote proof to the local cache
-func (v *State) VoteCacheAdd(id string, vc *types.VoteProof) {
+const (
+	// voteCacheTTL is the number of blocks a vote proof is kept on the vote cache
+	// if its vote is not included in the blockchain
+	voteCacheTTL = 60
+	// voteCacheMaxSize is the maximum number of vote proofs kept on the vote cache.
+	// Once reached, the oldest vote proofs are evicted.
+	voteCacheMaxSize = 100000
+)
+
+// voteCache keeps the vote proofs verified on checkTx, so the expensive checks (signature
+// and census proof) are not repeated on deliverTx. Vote proofs are only added on checkTx and
+// consumed on deliverTx. They are evicted once expired (voteCacheTTL blocks after the height
+// they were added) or when the cache is full. All the operations are persisted on a local
+// database, so the cache survives node restarts.
+type voteCache struct {
+	lock    sync.Mutex
+	db      tmdb.DB
+	entries map[string]*list.Element
+	// order keeps the entries sorted by insertion, so also by height
+	order   *list.List
+	maxSize int
+	height  int64
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type voteCacheEntry struct {
+	id    string
+	proof *types.VoteProof
+}
+
+// VoteCacheStats contains the counters of the vote cache usage
+type VoteCacheStats struct {
+	// Hits is the number of votes delivered whose vote proof was found on the cache
+	Hits uint64
+	// Misses is the number of votes delivered whose vote proof was not found on the cache
+	Misses uint64
+	// Evictions is the number of vote proofs removed because expired or because the cache was full
+	Evictions uint64
+}
+
+// newVoteCache opens (or creates) the vote cache database on dataDir and loads its vote proofs
+func newVoteCache(dataDir string) (*voteCache, error) {
+	db, err := tmdb.NewGoLevelDB("votecache", dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open vote cache database: (%s)", err)
+	}
+	c := &voteCache{
+		db:      db,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		maxSize: voteCacheMaxSize,
+	}
+	iter, err := db.Iterator(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	loaded := []*voteCacheEntry{}
+	for ; iter.Valid(); iter.Next() {
+		vp := new(types.VoteProof)
+		if err := json.Unmarshal(iter.Value(), vp); err != nil {
+			log.Warnf("cannot unmarshal vote cache entry %s: (%s)", iter.Key(), err)
+			continue
+		}
+		loaded = append(loaded, &voteCacheEntry{id: string(iter.Key()), proof: vp})
+	}
+	sort.SliceStable(loaded, func(i, j int) bool { return loaded[i].proof.Height < loaded[j].proof.Height })
+	for _, e := range loaded {
+		c.entries[e.id] = c.order.PushBack(e)
+		// the current height is unknown until the next block, use the highest known one
+		c.height = e.proof.Height
+	}
+	if len(loaded) > 0 {
+		log.Infof("loaded %d vote proofs from the vote cache", len(loaded))
+	}
+	return c, nil
+}
+
+func (c *voteCache) add(id string, vp *types.VoteProof) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	vp.Height = c.height
+	if e, ok := c.entries[id]; ok {
+		c.order.Remove(e)
+	}
+	for c.order.Len() >= c.maxSize {
+		c.remove(c.order.Front())
+		c.evictions++
+	}
+	c.entries[id] = c.order.PushBack(&voteCacheEntry{id: id, proof: vp})
+	vpBytes, err := json.Marshal(vp)
+	if err != nil {
+		log.Errorf("cannot marshal vote proof: (%s)", err)
+		return
+	}
+	if err := c.db.Set([]byte(id), vpBytes); err != nil {
+		log.Errorf("cannot store vote proof: (%s)", err)
+	}
+}
+
+func (c *voteCache) get(id string) *types.VoteProof {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.entries[id]; ok {
+		return e.Value.(*voteCacheEntry).proof
+	}
+	return nil
+}
+
+func (c *voteCache) pop(id string) *types.VoteProof {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[id]
+	if !ok {
+		c.misses++
+		return nil
+	}
+	c.hits++
+	c.remove(e)
+	return e.Value.(*voteCacheEntry).proof
+}
+
+func (c *voteCache) del(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.entries[id]; ok {
+		c.remove(e)
+	}
+}
+
+// purge sets the current height and evicts the expired vote proofs
+func (c *voteCache) purge(height int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.height = height
+	for e := c.order.Front(); e != nil && e.Value.(*voteCacheEntry).proof.Height+voteCacheTTL < height; e = c.order.Front() {
+		c.remove(e)
+		c.evictions++
+	}
+}
+
+// remove deletes an entry from the cache and its database, the lock must be held
+func (c *voteCache) remove(e *list.Element) {
+	entry := c.order.Remove(e).(*voteCacheEntry)
+	delete(c.entries, entry.id)
+	if err := c.db.Delete([]byte(entry.id)); err != nil {
+		log.Errorf("cannot delete vote proof: (%s)", err)
+	}
+}
+
+func (c *voteCache) size() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
+
+func (c *voteCache) stats() VoteCacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return VoteCacheStats{Hits: c.hits, Misses: c.misses, Evictions: c.evictions}
+}
+
+// VoteCacheAdd adds a new vote proof to the local cache, stamped with the current height.
+// It must only be used on checkTx.
+func (v *State) VoteCacheAdd(id string, vp *types.VoteProof) {
 	if len(id) == 0 {
 		return
 	}
-	v.voteCacheLock.Lock()
-	defer v.voteCacheLock.Unlock()
-	v.voteCache[id] = vc
+	v.voteCache.add(id, vp)
 }
 
 // VoteCacheDel deletes an existing vote proof from the local cache
@@ -21,9 +191,7 @@ func (v *State) VoteCacheDel(id string) {
 	if id == "" {
 		return
 	}
-	v.voteCacheLock.Lock()
-	defer v.voteCacheLock.Unlock()
-	delete(v.voteCache, id)
+	v.voteCache.del(id)
 }
 
 // VoteCacheGet fetch an existing vote proof from the local cache
@@ -31,28 +199,29 @@ func (v *State) VoteCacheGet(id string) *types.VoteProof {
 	if len(id) == 0 {
 		return nil
 	}
-	v.voteCacheLock.RLock()
-	defer v.voteCacheLock.RUnlock()
-	return v.voteCache[id]
+	return v.voteCache.get(id)
 }
 
-// VoteCachePurge removes the old cache saved votes
-func (v *State) VoteCachePurge(height int64) {
-	if height%6 != 0 {
-		return
-	}
-	v.voteCacheLock.Lock()
-	defer v.voteCacheLock.Unlock()
-	for id, vp := range v.voteCache {
-		if time.Since(vp.Created) > voteCachePurgeThreshold {
-			delete(v.voteCache, id)
-		}
+// VoteCachePop fetches and removes an existing vote proof from the local cache.
+// It must be used on deliverTx, and it is accounted as a hit or miss of the cache.
+func (v *State) VoteCachePop(id string) *types.VoteProof {
+	if len(id) == 0 {
+		return nil
 	}
+	return v.voteCache.pop(id)
+}
+
+// VoteCachePurge removes the vote proofs added more than voteCacheTTL blocks before height
+func (v *State) VoteCachePurge(height int64) {
+	v.voteCache.purge(height)
 }
 
 // VoteCacheSize returns the current size of the vote cache
 func (v *State) VoteCacheSize() int {
-	v.voteCacheLock.RLock()
-	defer v.voteCacheLock.RUnlock()
-	return len(v.voteCache)
+	return v.voteCache.size()
+}
+
+// VoteCacheStats returns the hit, miss and eviction counters of the vote cache
+func (v *State) VoteCacheStats() VoteCacheStats {
+	return v.voteCache.stats()
 }
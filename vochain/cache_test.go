@@ -0,0 +1,64 @@
+package vochain
+
+import (
+	"testing"
+
+	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/util"
+)
+
+func TestVoteCache(t *testing.T) {
+	dataDir := t.TempDir()
+	c, err := newVoteCache(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.purge(10)
+	c.add("a", &types.VoteProof{Nullifier: util.RandomBytes(32)})
+	c.purge(20)
+	c.add("b", &types.VoteProof{Nullifier: util.RandomBytes(32)})
+	c.add("c", &types.VoteProof{Nullifier: util.RandomBytes(32)})
+
+	if vp := c.get("a"); vp == nil || vp.Height != 10 {
+		t.Fatalf("vote proof a should be stamped with height 10, got %+v", vp)
+	}
+	if vp := c.pop("c"); vp == nil {
+		t.Fatal("vote proof c not found")
+	}
+	if vp := c.pop("c"); vp != nil {
+		t.Fatal("vote proof c should be consumed")
+	}
+	if s := c.stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	// the cache must survive a restart
+	c.db.Close()
+	if c, err = newVoteCache(dataDir); err != nil {
+		t.Fatal(err)
+	}
+	if c.size() != 2 || c.get("a") == nil || c.get("b") == nil {
+		t.Fatalf("vote cache not restored, size %d", c.size())
+	}
+
+	// vote proofs expire after voteCacheTTL blocks
+	c.purge(10 + voteCacheTTL)
+	if c.get("a") == nil {
+		t.Fatal("vote proof a expired too early")
+	}
+	c.purge(11 + voteCacheTTL)
+	if c.get("a") != nil || c.get("b") == nil {
+		t.Fatal("only vote proof a should be expired")
+	}
+
+	// the oldest vote proofs are evicted when the cache is full
+	c.maxSize = 2
+	c.add("d", &types.VoteProof{})
+	c.add("e", &types.VoteProof{})
+	if c.size() != 2 || c.get("b") != nil || c.get("d") == nil || c.get("e") == nil {
+		t.Fatalf("the oldest vote proof should be evicted, size %d", c.size())
+	}
+	if s := c.stats(); s.Evictions != 2 {
+		t.Fatalf("expected 2 evictions, got %d", s.Evictions)
+	}
+}
@@ -65,7 +65,29 @@ func (c *CensusDownloader) OnProcess(pid, eid []byte, mkroot, mkuri string) {
 	}
 }
 
+// OnCensusUpdate imports the new census of a process, as done by OnProcess. If the previous
+// census are still accepted, they are imported too (the already imported ones are skipped).
+func (c *CensusDownloader) OnCensusUpdate(pid []byte, mkroot, mkuri string) {
+	c.OnProcess(pid, nil, mkroot, mkuri)
+	process, err := c.vochain.State.Process(pid, false)
+	if err != nil {
+		log.Warnf("cannot get process %x: (%s)", pid, err)
+		return
+	}
+	if !process.AcceptPreviousMkRoots {
+		return
+	}
+	for i, uri := range process.PreviousMkURIs {
+		if uri != "" && i < len(process.PreviousMkRoots) {
+			c.OnProcess(pid, nil, process.PreviousMkRoots[i], uri)
+		}
+	}
+}
+
 func (c *CensusDownloader) OnCancel(pid []byte)                       {}
+func (c *CensusDownloader) OnPause(pid []byte)                        {}
+func (c *CensusDownloader) OnResume(pid []byte)                       {}
 func (c *CensusDownloader) OnVote(v *types.Vote)                      {}
+func (c *CensusDownloader) OnVoteOverwrite(previous, v *types.Vote)   {}
 func (c *CensusDownloader) OnProcessKeys(pid []byte, pub, com string) {}
 func (c *CensusDownloader) OnRevealKeys(pid []byte, priv, rev string) {}
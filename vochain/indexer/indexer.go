@@ -0,0 +1,212 @@
+package indexer
+
+/*
+	Indexer keeps 4 diferent database entries (splited by key prefix)
+
+	+ Tx: key is the transaction hash. Value is the transaction reference
+	+ Block: key is height+index. Value is the transaction hash
+	+ Signer: key is signerAddress+height+index. Value is the transaction hash
+	+ Process: key is processId+height+index. Value is the transaction hash (votes are not included)
+*/
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"gitlab.com/vocdoni/go-dvote/db"
+	"gitlab.com/vocdoni/go-dvote/log"
+	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/vochain"
+)
+
+// Indexer is the component which keeps a local index of the vochain transactions,
+// so they can be queried by hash, block, signer or process
+type Indexer struct {
+	VochainState *vochain.State
+	Storage      *db.BadgerDB
+	txPool       []*types.TxReference
+}
+
+// NewIndexer returns an instance of the Indexer
+// using the local storage database of dbPath and integrated into the state vochain instance
+func NewIndexer(dbPath string, state *vochain.State) (*Indexer, error) {
+	i := &Indexer{VochainState: state}
+	var err error
+	i.Storage, err = db.NewBadgerDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	i.VochainState.AddEventListener(i)
+	i.VochainState.AddTxListener(i)
+	return i, nil
+}
+
+// Commit is called by the APP when a block is confirmed and included into the chain
+func (i *Indexer) Commit(height int64) {
+	if len(i.txPool) == 0 {
+		return
+	}
+	batch := i.Storage.NewBatch()
+	for _, ref := range i.txPool {
+		if err := i.addTx(batch, ref); err != nil {
+			log.Errorf("cannot index transaction %s: (%s)", ref.Hash, err)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Errorf("cannot write indexed transactions of block %d: (%s)", height, err)
+		return
+	}
+	log.Debugf("indexed %d transactions from block %d", len(i.txPool), height)
+}
+
+// Rollback removes the non commited pending operations
+func (i *Indexer) Rollback() {
+	i.txPool = []*types.TxReference{}
+}
+
+// OnTx stores the transaction reference until the block is committed
+func (i *Indexer) OnTx(ref *types.TxReference) {
+	i.txPool = append(i.txPool, ref)
+}
+
+// OnProcess does nothing, the transaction is indexed by OnTx
+func (i *Indexer) OnProcess(pid, eid []byte, mkroot, mkuri string) {}
+
+// OnVote does nothing, the transaction is indexed by OnTx
+func (i *Indexer) OnVote(v *types.Vote) {}
+
+// OnVoteOverwrite does nothing, the transaction is indexed by OnTx
+func (i *Indexer) OnVoteOverwrite(previous, v *types.Vote) {}
+
+// OnCancel does nothing, the transaction is indexed by OnTx
+func (i *Indexer) OnCancel(pid []byte) {}
+
+// OnPause does nothing, the transaction is indexed by OnTx
+func (i *Indexer) OnPause(pid []byte) {}
+
+// OnResume does nothing, the transaction is indexed by OnTx
+func (i *Indexer) OnResume(pid []byte) {}
+
+// OnCensusUpdate does nothing, the transaction is indexed by OnTx
+func (i *Indexer) OnCensusUpdate(pid []byte, mkroot, mkuri string) {}
+
+// OnProcessKeys does nothing, the transaction is indexed by OnTx
+func (i *Indexer) OnProcessKeys(pid []byte, pub, com string) {}
+
+// OnRevealKeys does nothing, the transaction is indexed by OnTx
+func (i *Indexer) OnRevealKeys(pid []byte, priv, rev string) {}
+
+// addTx adds the index entries of a transaction reference to the batch
+func (i *Indexer) addTx(batch db.Batch, ref *types.TxReference) error {
+	hash, err := hex.DecodeString(ref.Hash)
+	if err != nil {
+		return fmt.Errorf("cannot decode hash: (%s)", err)
+	}
+	refBytes, err := i.VochainState.Codec.MarshalBinaryBare(ref)
+	if err != nil {
+		return fmt.Errorf("cannot marshal reference: (%s)", err)
+	}
+	position := txPosition(ref.Height, ref.Index)
+	if err := batch.Put(append([]byte{types.IndexerTxPrefix}, hash...), refBytes); err != nil {
+		return err
+	}
+	if err := batch.Put(append([]byte{types.IndexerBlockPrefix}, position...), hash); err != nil {
+		return err
+	}
+	for _, signer := range ref.Signers {
+		key := append([]byte{types.IndexerSignerPrefix}, ethcommon.HexToAddress(signer).Bytes()...)
+		if err := batch.Put(append(key, position...), hash); err != nil {
+			return err
+		}
+	}
+	// votes are already listed by the envelope API methods
+	if ref.ProcessID != "" && ref.Type != types.TxVote {
+		pid, err := hex.DecodeString(ref.ProcessID)
+		if err != nil {
+			return fmt.Errorf("cannot decode processId: (%s)", err)
+		}
+		key := append([]byte{types.IndexerProcessPrefix}, pid...)
+		if err := batch.Put(append(key, position...), hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TxByHash returns the reference of the transaction identified by hash
+func (i *Indexer) TxByHash(hash []byte) (*types.TxReference, error) {
+	refBytes, err := i.Storage.Get(append([]byte{types.IndexerTxPrefix}, hash...))
+	if err != nil {
+		return nil, fmt.Errorf("transaction %x not found", hash)
+	}
+	var ref types.TxReference
+	if err := i.VochainState.Codec.UnmarshalBinaryBare(refBytes, &ref); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal reference: (%s)", err)
+	}
+	return &ref, nil
+}
+
+// BlockTxList returns the hashes of the transactions included in a block, ordered by index
+func (i *Indexer) BlockTxList(height int64) []string {
+	return i.hashList(append([]byte{types.IndexerBlockPrefix}, heightBytes(height)...), 0, -1)
+}
+
+// BlockTxs returns the references of the transactions included in a block, ordered by index
+func (i *Indexer) BlockTxs(height int64) ([]*types.TxReference, error) {
+	refs := []*types.TxReference{}
+	for _, h := range i.BlockTxList(height) {
+		hash, err := hex.DecodeString(h)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := i.TxByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// SignerTxList returns the hashes of the transactions signed by address, ordered by height.
+// The first from transactions are skipped and at most max hashes are returned.
+func (i *Indexer) SignerTxList(address ethcommon.Address, from, max int64) []string {
+	return i.hashList(append([]byte{types.IndexerSignerPrefix}, address.Bytes()...), from, max)
+}
+
+// ProcessTxList returns the hashes of the transactions (but votes) related to a process, ordered by height.
+// The first from transactions are skipped and at most max hashes are returned.
+func (i *Indexer) ProcessTxList(pid []byte, from, max int64) []string {
+	return i.hashList(append([]byte{types.IndexerProcessPrefix}, pid...), from, max)
+}
+
+// hashList returns the hex encoded values of the keys matching prefix, skipping the first from.
+// If max is negative all the values are returned.
+func (i *Indexer) hashList(prefix []byte, from, max int64) []string {
+	iter := i.Storage.NewPrefixIterator(prefix)
+	list := []string{}
+	for max != 0 && iter.Next() {
+		if from > 0 {
+			from--
+			continue
+		}
+		list = append(list, fmt.Sprintf("%x", iter.Value()))
+		max--
+	}
+	iter.Release()
+	return list
+}
+
+// txPosition returns the key suffix of a transaction, sorted by height and index
+func txPosition(height int64, index int32) []byte {
+	position := make([]byte, 12)
+	binary.BigEndian.PutUint64(position, uint64(height))
+	binary.BigEndian.PutUint32(position[8:], uint32(index))
+	return position
+}
+
+func heightBytes(height int64) []byte {
+	return txPosition(height, 0)[:8]
+}
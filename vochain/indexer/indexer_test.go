@@ -0,0 +1,90 @@
+package indexer
+
+import (
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/tendermint/go-amino"
+	"gitlab.com/vocdoni/go-dvote/log"
+	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/util"
+	"gitlab.com/vocdoni/go-dvote/vochain"
+)
+
+func TestTxIndex(t *testing.T) {
+	log.Init("info", "stdout")
+	state, err := vochain.NewState(t.TempDir(), amino.NewCodec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := NewIndexer(t.TempDir(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oracle := ethcommon.HexToAddress(util.RandomHex(20))
+	pid := util.RandomHex(32)
+	newProcess := &types.TxReference{
+		Hash: util.RandomHex(32), Height: 1, Index: 0, Type: types.TxNewProcess,
+		ProcessID: pid, Signers: []string{oracle.Hex()},
+	}
+	vote := &types.TxReference{
+		Hash: util.RandomHex(32), Height: 1, Index: 1, Type: types.TxVote,
+		ProcessID: pid, Nullifier: util.RandomHex(32),
+	}
+	cancel := &types.TxReference{
+		Hash: util.RandomHex(32), Height: 2, Index: 0, Type: types.TxCancelProcess,
+		ProcessID: pid, Signers: []string{oracle.Hex()},
+	}
+	failed := &types.TxReference{Hash: util.RandomHex(32), Height: 2, Index: 1, Code: 1, Error: "invalid"}
+
+	idx.Rollback()
+	idx.OnTx(newProcess)
+	idx.OnTx(vote)
+	idx.Commit(1)
+	// a rolled back block must not be indexed
+	idx.OnTx(&types.TxReference{Hash: util.RandomHex(32), Height: 2, Index: 0})
+	idx.Rollback()
+	idx.OnTx(cancel)
+	idx.OnTx(failed)
+	idx.Commit(2)
+
+	ref, err := idx.TxByHash(util.Hex2byte(t, vote.Hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Nullifier != vote.Nullifier || ref.Height != 1 || ref.Index != 1 {
+		t.Fatalf("unexpected vote reference: %+v", ref)
+	}
+	if _, err := idx.TxByHash(util.Hex2byte(t, util.RandomHex(32))); err == nil {
+		t.Fatal("unknown transaction found")
+	}
+
+	if list := idx.BlockTxList(1); len(list) != 2 || list[0] != newProcess.Hash || list[1] != vote.Hash {
+		t.Fatalf("unexpected block 1 transactions: %v", list)
+	}
+	refs, err := idx.BlockTxs(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Hash != cancel.Hash || refs[1].Code != 1 {
+		t.Fatalf("unexpected block 2 transactions: %+v", refs)
+	}
+	if list := idx.BlockTxList(3); len(list) != 0 {
+		t.Fatalf("expected no transactions on block 3, got %v", list)
+	}
+
+	if list := idx.SignerTxList(oracle, 0, 10); len(list) != 2 || list[0] != newProcess.Hash || list[1] != cancel.Hash {
+		t.Fatalf("unexpected signer transactions: %v", list)
+	}
+	if list := idx.SignerTxList(oracle, 1, 10); len(list) != 1 || list[0] != cancel.Hash {
+		t.Fatalf("unexpected signer transactions from 1: %v", list)
+	}
+	// votes are not listed on the process transactions
+	if list := idx.ProcessTxList(util.Hex2byte(t, pid), 0, 10); len(list) != 2 || list[1] != cancel.Hash {
+		t.Fatalf("unexpected process transactions: %v", list)
+	}
+	if list := idx.ProcessTxList(util.Hex2byte(t, pid), 0, 1); len(list) != 1 || list[0] != newProcess.Hash {
+		t.Fatalf("unexpected process transactions with max 1: %v", list)
+	}
+}
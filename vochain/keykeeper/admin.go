@@ -0,0 +1,379 @@
+package keykeeper
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gitlab.com/vocdoni/go-dvote/crypto/nacl"
+)
+
+// This file contains the keykeeper administration and recovery operations, used by the
+// keykeepercli tool. They read the last committed vochain state, so the node should be stopped
+// (the keykeeper database cannot be opened twice anyway).
+
+// ScheduledReveal is a reveal of the process keys scheduled on the keykeeper database.
+// Height is set on the block scheduled reveals and EndTime on the time scheduled ones.
+type ScheduledReveal struct {
+	EndTime   int64
+	Height    int64
+	ProcessID []byte
+}
+
+// KeyIssue is an inconsistency between the keykeeper database and the vochain state
+type KeyIssue struct {
+	Issue     string
+	ProcessID []byte
+}
+
+// backup is the content of an encrypted keykeeper database backup
+type backup struct {
+	Address string        `json:"address"`
+	Entries []backupEntry `json:"entries"`
+	Index   int8          `json:"index"`
+}
+
+type backupEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Close closes the keykeeper database
+func (k *KeyKeeper) Close() error {
+	return k.storage.Close()
+}
+
+// ScheduledReveals returns the reveals scheduled on the keykeeper database, the block scheduled
+// ones first, sorted by height and time
+func (k *KeyKeeper) ScheduledReveals() ([]ScheduledReveal, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	iter := k.storage.NewIterator()
+	defer iter.Release()
+	var reveals []ScheduledReveal
+	for iter.Next() {
+		key := string(iter.Key())
+		switch {
+		case strings.HasPrefix(key, dbPrefixBlock):
+			height, err := strconv.ParseInt(key[len(dbPrefixBlock):], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot fetch block number from keykeeper database: (%s)", err)
+			}
+			var pids []string
+			if err := k.vochain.Codec.UnmarshalBinaryBare(iter.Value(), &pids); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal process pids for block %d: (%s)", height, err)
+			}
+			for _, pid := range pids {
+				reveals = append(reveals, ScheduledReveal{Height: height, ProcessID: []byte(pid)})
+			}
+		case strings.HasPrefix(key, dbPrefixTime):
+			endTime, err := strconv.ParseInt(string(iter.Value()), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot fetch end time from keykeeper database: (%s)", err)
+			}
+			reveals = append(reveals, ScheduledReveal{EndTime: endTime, ProcessID: []byte(key[len(dbPrefixTime):])})
+		}
+	}
+	sort.SliceStable(reveals, func(i, j int) bool {
+		if reveals[i].Height != reveals[j].Height {
+			// time scheduled reveals (height 0) go last
+			return reveals[j].Height == 0 || (reveals[i].Height != 0 && reveals[i].Height < reveals[j].Height)
+		}
+		return reveals[i].EndTime < reveals[j].EndTime
+	})
+	return reveals, nil
+}
+
+// UnpublishedKeys returns the processes requiring keys, neither canceled nor finished, whose keys
+// have not been published by the keykeeper
+func (k *KeyKeeper) UnpublishedKeys() ([][]byte, error) {
+	header := k.vochain.State.Header(true)
+	if header == nil {
+		return nil, fmt.Errorf("cannot get blockchain header")
+	}
+	var pids [][]byte
+	for _, pid := range k.vochain.State.ProcessList(true) {
+		process, err := k.vochain.State.Process(pid, true)
+		if err != nil {
+			return nil, err
+		}
+		if !process.RequireKeys() || process.Canceled || process.Finished(header.Height, header.Time) {
+			continue
+		}
+		if len(process.EncryptionPublicKeys[k.myIndex])+len(process.CommitmentKeys[k.myIndex]) == 0 {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// PublishKeysTx returns the signed transaction publishing the keys of the keykeeper for a process,
+// and stores the keys on the database if missing. The keys are deterministic, so they match with
+// the ones the keykeeper would have published.
+func (k *KeyKeeper) PublishKeysTx(pid []byte) ([]byte, error) {
+	process, err := k.vochain.State.Process(pid, true)
+	if err != nil {
+		return nil, err
+	}
+	if !process.RequireKeys() {
+		return nil, fmt.Errorf("process does not require keys")
+	}
+	if len(process.EncryptionPublicKeys[k.myIndex])+len(process.CommitmentKeys[k.myIndex]) > 0 {
+		return nil, fmt.Errorf("keys for process %x already published", pid)
+	}
+	pk, err := k.generateKeys(pid)
+	if err != nil {
+		return nil, err
+	}
+	txBytes, err := k.signTx(publishKeysTx(pk, string(pid)))
+	if err != nil {
+		return nil, err
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	dbKey := []byte(dbPrefixProcess + string(pid))
+	// TODO(mvdan): replace Has with just Get
+	if exists, err := k.storage.Has(dbKey); err != nil {
+		return nil, err
+	} else if !exists {
+		if err := k.storage.Put(dbKey, pk.Encode()); err != nil {
+			return nil, err
+		}
+	}
+	return txBytes, nil
+}
+
+// RevealKeysTx returns the signed transaction revealing the keys of the keykeeper for a process,
+// even if the reveal is not scheduled yet. The transaction is only accepted once the process is
+// finished or canceled.
+func (k *KeyKeeper) RevealKeysTx(pid []byte) ([]byte, error) {
+	process, err := k.vochain.State.Process(pid, true)
+	if err != nil {
+		return nil, err
+	}
+	if !process.RequireKeys() {
+		return nil, fmt.Errorf("process does not require keys")
+	}
+	if len(process.EncryptionPublicKeys[k.myIndex])+len(process.CommitmentKeys[k.myIndex]) == 0 {
+		return nil, fmt.Errorf("keys for process %x not published", pid)
+	}
+	if len(process.EncryptionPrivateKeys[k.myIndex])+len(process.RevealKeys[k.myIndex]) > 0 {
+		return nil, fmt.Errorf("keys for process %x already revealed", pid)
+	}
+	tx, err := k.revealKeysTx(string(pid), true)
+	if err != nil {
+		return nil, err
+	}
+	return k.signTx(tx)
+}
+
+// EncryptionPrivateKey returns the encryption private key the keykeeper reveals for a process,
+// which is a key share on threshold encrypted processes
+func (k *KeyKeeper) EncryptionPrivateKey(pid []byte) (string, error) {
+	tx, err := k.revealKeysTx(string(pid), true)
+	if err != nil {
+		return "", err
+	}
+	return tx.EncryptionPrivateKey, nil
+}
+
+// CheckKeys compares the keys stored on the keykeeper database with the vochain state, and
+// returns the inconsistencies found
+func (k *KeyKeeper) CheckKeys() ([]KeyIssue, error) {
+	header := k.vochain.State.Header(true)
+	if header == nil {
+		return nil, fmt.Errorf("cannot get blockchain header")
+	}
+	reveals, err := k.ScheduledReveals()
+	if err != nil {
+		return nil, err
+	}
+	scheduled := make(map[string]bool, len(reveals))
+	for _, r := range reveals {
+		scheduled[string(r.ProcessID)] = true
+	}
+
+	var issues []KeyIssue
+	stored := make(map[string]bool)
+	k.lock.Lock()
+	iter := k.storage.NewIterator()
+	for iter.Next() {
+		if !strings.HasPrefix(string(iter.Key()), dbPrefixProcess) {
+			continue
+		}
+		// keys might be reused by the iterator
+		pid := append([]byte{}, iter.Key()[len(dbPrefixProcess):]...)
+		stored[string(pid)] = true
+		var pk processKeys
+		if err := pk.Decode(iter.Value()); err != nil {
+			issues = append(issues, KeyIssue{Issue: fmt.Sprintf("cannot decode stored keys: (%s)", err), ProcessID: pid})
+			continue
+		}
+		if issue := k.checkStoredKeys(pid, &pk); issue != "" {
+			issues = append(issues, KeyIssue{Issue: issue, ProcessID: pid})
+		}
+	}
+	iter.Release()
+	k.lock.Unlock()
+
+	for _, pid := range k.vochain.State.ProcessList(true) {
+		process, err := k.vochain.State.Process(pid, true)
+		if err != nil {
+			return nil, err
+		}
+		if !process.RequireKeys() ||
+			len(process.EncryptionPublicKeys[k.myIndex])+len(process.CommitmentKeys[k.myIndex]) == 0 ||
+			len(process.EncryptionPrivateKeys[k.myIndex])+len(process.RevealKeys[k.myIndex]) > 0 {
+			continue
+		}
+		switch {
+		case process.Canceled || process.Finished(header.Height, header.Time):
+			issues = append(issues, KeyIssue{Issue: "keys published but not revealed on a finished process", ProcessID: pid})
+		case !stored[string(pid)]:
+			issues = append(issues, KeyIssue{Issue: "published keys not stored on the keykeeper database", ProcessID: pid})
+		case !scheduled[string(pid)]:
+			issues = append(issues, KeyIssue{Issue: "reveal of the published keys not scheduled", ProcessID: pid})
+		}
+	}
+	return issues, nil
+}
+
+// checkStoredKeys returns the issue found on the keys stored for a process, if any
+func (k *KeyKeeper) checkStoredKeys(pid []byte, pk *processKeys) string {
+	if pk.index != k.myIndex {
+		return fmt.Sprintf("stored keys use the index %d instead of %d", pk.index, k.myIndex)
+	}
+	expected, err := k.generateKeys(pid)
+	if err != nil {
+		return fmt.Sprintf("cannot generate keys: (%s)", err)
+	}
+	if !bytes.Equal(expected.privKey, pk.privKey) || !bytes.Equal(expected.commitmentKey, pk.commitmentKey) {
+		return "stored keys do not match with the keykeeper signer key"
+	}
+	process, err := k.vochain.State.Process(pid, true)
+	if err != nil {
+		return fmt.Sprintf("cannot get process from state: (%s)", err)
+	}
+	switch {
+	case process.EncryptionPublicKeys[pk.index] == "" && process.CommitmentKeys[pk.index] == "":
+		return "stored keys not published"
+	case process.EncryptionPublicKeys[pk.index] != hex.EncodeToString(pk.pubKey):
+		return "stored encryption public key does not match with the published one"
+	case process.CommitmentKeys[pk.index] != hex.EncodeToString(pk.commitmentKey):
+		return "stored commitment key does not match with the published one"
+	}
+	return ""
+}
+
+// ExportBackup returns all the entries of the keykeeper database, encrypted with the hexadecimal
+// nacl public key pubKey
+func (k *KeyKeeper) ExportBackup(pubKey string) ([]byte, error) {
+	pub, err := nacl.DecodePublic(pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode backup public key: (%s)", err)
+	}
+	b := backup{Address: k.signer.AddressString(), Index: k.myIndex}
+	k.lock.Lock()
+	iter := k.storage.NewIterator()
+	for iter.Next() {
+		// keys and values might be reused by the iterator
+		b.Entries = append(b.Entries, backupEntry{
+			Key:   append([]byte{}, iter.Key()...),
+			Value: append([]byte{}, iter.Value()...),
+		})
+	}
+	iter.Release()
+	k.lock.Unlock()
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return nacl.Anonymous.Encrypt(data, pub)
+}
+
+// ImportBackup decrypts a keykeeper database backup (see ExportBackup) with the hexadecimal nacl
+// private key privKey, and restores its entries. The backup must belong to the same keykeeper.
+// The existing entries of the database are kept: the processes scheduled for the same block are
+// merged, and the backup entries which conflict with the stored ones are skipped and returned as
+// issues. It returns the number of restored entries.
+func (k *KeyKeeper) ImportBackup(data []byte, privKey string) (int, []KeyIssue, error) {
+	priv, err := nacl.DecodePrivate(privKey)
+	if err != nil {
+		return 0, nil, fmt.Errorf("cannot decode backup private key: (%s)", err)
+	}
+	if data, err = priv.Decrypt(data); err != nil {
+		return 0, nil, fmt.Errorf("cannot decrypt backup: (%s)", err)
+	}
+	var b backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return 0, nil, fmt.Errorf("cannot unmarshal backup: (%s)", err)
+	}
+	if b.Address != k.signer.AddressString() || b.Index != k.myIndex {
+		return 0, nil, fmt.Errorf("backup belongs to keykeeper %s with index %d", b.Address, b.Index)
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	restored := 0
+	var conflicts []KeyIssue
+	for _, e := range b.Entries {
+		value := e.Value
+		stored, err := k.storage.Get(e.Key)
+		if err == nil {
+			if bytes.Equal(stored, e.Value) {
+				continue
+			}
+			key := string(e.Key)
+			if !strings.HasPrefix(key, dbPrefixBlock) {
+				conflicts = append(conflicts, backupConflict(key))
+				continue
+			}
+			if value, err = k.mergeScheduledPids(stored, e.Value); err != nil {
+				return restored, conflicts, fmt.Errorf("cannot merge the processes scheduled on %s: (%s)", key, err)
+			}
+		}
+		if err := k.storage.Put(e.Key, value); err != nil {
+			return restored, conflicts, err
+		}
+		restored++
+	}
+	return restored, conflicts, nil
+}
+
+// mergeScheduledPids merges two amino encoded lists of the processes scheduled for a block
+func (k *KeyKeeper) mergeScheduledPids(stored, imported []byte) ([]byte, error) {
+	var pids, importedPids []string
+	if err := k.vochain.Codec.UnmarshalBinaryBare(stored, &pids); err != nil {
+		return nil, err
+	}
+	if err := k.vochain.Codec.UnmarshalBinaryBare(imported, &importedPids); err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(pids))
+	for _, pid := range pids {
+		known[pid] = true
+	}
+	for _, pid := range importedPids {
+		if !known[pid] {
+			known[pid] = true
+			pids = append(pids, pid)
+		}
+	}
+	return k.vochain.Codec.MarshalBinaryBare(pids)
+}
+
+// backupConflict returns the issue of a backup entry which differs from the stored one
+func backupConflict(key string) KeyIssue {
+	switch {
+	case strings.HasPrefix(key, dbPrefixProcess):
+		return KeyIssue{ProcessID: []byte(key[len(dbPrefixProcess):]), Issue: "stored keys differ from the backup ones, kept the stored keys"}
+	case strings.HasPrefix(key, dbPrefixTime):
+		return KeyIssue{ProcessID: []byte(key[len(dbPrefixTime):]), Issue: "stored reveal time differs from the backup one, kept the stored time"}
+	case strings.HasPrefix(key, dbPrefixDeal):
+		return KeyIssue{ProcessID: []byte(key[len(dbPrefixDeal):]), Issue: "stored key generation phase differs from the backup one, kept the stored phase"}
+	}
+	return KeyIssue{Issue: fmt.Sprintf("stored entry %q differs from the backup one, kept the stored entry", key)}
+}
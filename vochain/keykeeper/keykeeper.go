@@ -1,6 +1,8 @@
 package keykeeper
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -8,9 +10,13 @@ import (
 	"sync"
 	"time"
 
+	"golang.org/x/crypto/hkdf"
+
+	"gitlab.com/vocdoni/go-dvote/crypto"
 	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
 	"gitlab.com/vocdoni/go-dvote/crypto/nacl"
 	"gitlab.com/vocdoni/go-dvote/crypto/snarks"
+	"gitlab.com/vocdoni/go-dvote/crypto/threshold"
 	"gitlab.com/vocdoni/go-dvote/db"
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/types"
@@ -22,6 +28,8 @@ import (
  KV database shceme:
    p_{processId} = {[]processKeys} // index and stores the process keys by process ID
    b_{#block} = {[]processId} // index by block in order to reveal keys of the finished processes
+   t_{processId} = {endTime} // index the time scheduled processes in order to reveal keys once finished
+   d_{processId} = {phase} // index the threshold encrypted processes in order to take part on the key generation
 */
 
 // TBD: Remove the ProcessKeys storage, we do not need it since the keys are deterministic and can be re-created at any time.
@@ -31,6 +39,8 @@ const (
 	encryptionKeySize = nacl.KeyLength
 	dbPrefixProcess   = "p_"
 	dbPrefixBlock     = "b_"
+	dbPrefixTime      = "t_"
+	dbPrefixDeal      = "d_"
 )
 
 type KeyKeeper struct {
@@ -38,6 +48,8 @@ type KeyKeeper struct {
 	storage   db.Database
 	keyPool   map[string]*processKeys
 	blockPool map[string]int64
+	timePool  map[string]int64
+	dealPool  map[string]bool
 	signer    *ethereum.SignKeys
 	lock      sync.Mutex
 	myIndex   int8
@@ -94,6 +106,17 @@ func (pk *processKeys) Decode(data []byte) error {
 }
 
 func NewKeyKeeper(dbPath string, v *vochain.BaseApplication, signer *ethereum.SignKeys, index int8) (*KeyKeeper, error) {
+	k, err := OpenKeyKeeper(dbPath, v, signer, index)
+	if err != nil {
+		return nil, err
+	}
+	k.vochain.State.AddEventListener(k)
+	return k, nil
+}
+
+// OpenKeyKeeper opens the keykeeper database for its administration (see admin.go).
+// Unlike NewKeyKeeper, the keykeeper does not listen to the vochain events.
+func OpenKeyKeeper(dbPath string, v *vochain.BaseApplication, signer *ethereum.SignKeys, index int8) (*KeyKeeper, error) {
 	if v == nil || signer == nil || len(dbPath) < 1 {
 		return nil, fmt.Errorf("missing values for creating a key keeper")
 	}
@@ -112,7 +135,6 @@ func NewKeyKeeper(dbPath string, v *vochain.BaseApplication, signer *ethereum.Si
 	k.myIndex = index
 	// k.vochain.Codec.RegisterConcrete(&processKeys{}, "vocdoni/keykeeper.processKeys", nil)
 	// k.vochain.Codec.RegisterConcrete(processKeys{}, "processKeys", nil)
-	k.vochain.State.AddEventListener(k)
 	return k, nil
 }
 
@@ -120,15 +142,19 @@ func NewKeyKeeper(dbPath string, v *vochain.BaseApplication, signer *ethereum.Si
 func (k *KeyKeeper) PrintInfo(wait time.Duration) {
 	for {
 		time.Sleep(wait)
-		iter := k.storage.NewIterator()
-		nprocs := 0
-		for iter.Next() {
-			if strings.HasPrefix(string(iter.Key()), dbPrefixBlock) {
-				nprocs++
-			}
+		reveals, err := k.ScheduledReveals()
+		if err != nil {
+			log.Warnf("[keykeeper] cannot get scheduled reveals: (%s)", err)
+			continue
 		}
-		iter.Release()
-		log.Infof("[keykeeper] scheduled keys %d", nprocs)
+		if len(reveals) == 0 {
+			log.Infof("[keykeeper] scheduled keys 0")
+			continue
+		}
+		// the block scheduled reveals go first
+		next := reveals[0]
+		log.Infof("[keykeeper] scheduled keys %d, next reveal for process %x (block %d, time %d)",
+			len(reveals), next.ProcessID, next.Height, next.EndTime)
 	}
 }
 
@@ -141,6 +167,7 @@ func (k *KeyKeeper) RevealUnpublished() {
 		log.Errorf("cannot get blockchain header, skipping reveal unpublished operation")
 		return
 	}
+	k.checkRevealTimedProcesses(header.Time)
 	k.lock.Lock()
 	defer k.lock.Unlock()
 	iter := k.storage.NewIterator()
@@ -206,6 +233,8 @@ func (k *KeyKeeper) RevealUnpublished() {
 func (k *KeyKeeper) Rollback() {
 	k.keyPool = make(map[string]*processKeys)
 	k.blockPool = make(map[string]int64)
+	k.timePool = make(map[string]int64)
+	k.dealPool = make(map[string]bool)
 }
 
 // OnProcess creates the keys and add them to the pool queue, if the process requires it
@@ -235,7 +264,16 @@ func (k *KeyKeeper) OnProcess(pid, eid []byte, mkroot, mkuri string) {
 		return
 	}
 
+	// The threshold encryption key is generated once all the keykeepers have published their keys
+	if p.IsThresholdEncrypted() {
+		k.dealPool[string(pid)] = true
+	}
+
 	// Add keys to the pool queue
+	if p.IsTimeScheduled() {
+		k.timePool[string(pid)] = p.EndTime
+		return
+	}
 	k.blockPool[string(pid)] = p.StartBlock + p.NumberOfBlocks
 }
 
@@ -253,14 +291,21 @@ func (k *KeyKeeper) OnCancel(pid []byte) {
 	if p.EncryptionPublicKeys[k.myIndex] != "" {
 		log.Infof("process canceled, scheduling reveal keys for next block")
 		k.blockPool[string(pid)] = k.vochain.State.Header(false).Height + 1
+		delete(k.timePool, string(pid))
 	}
 }
 
 // Commit saves the pending operation
 func (k *KeyKeeper) Commit(height int64) {
 	k.scheduleRevealKeys()
+	k.scheduleKeyDeals()
 	go k.checkRevealProcess(height)
+	if header := k.vochain.State.Header(false); header != nil {
+		go k.checkKeyGeneration(height, header.Time)
+		go k.checkRevealTimedProcesses(header.Time)
+	}
 	go k.publishPendingKeys()
+	go k.coSignPendingKeys()
 }
 
 // OnVote is not used by the KeyKeeper
@@ -268,6 +313,23 @@ func (k *KeyKeeper) OnVote(v *types.Vote) {
 	// do nothing
 }
 
+// OnVoteOverwrite is not used by the KeyKeeper
+func (k *KeyKeeper) OnVoteOverwrite(previous, v *types.Vote) {
+	// do nothing
+}
+
+func (k *KeyKeeper) OnPause(pid []byte) {
+	// do nothing
+}
+
+func (k *KeyKeeper) OnResume(pid []byte) {
+	// do nothing
+}
+
+func (k *KeyKeeper) OnCensusUpdate(pid []byte, mkroot, mkuri string) {
+	// do nothing
+}
+
 func (k *KeyKeeper) OnProcessKeys(pid []byte, pub, com string) {
 	// do nothing
 }
@@ -304,10 +366,270 @@ func (k *KeyKeeper) generateKeys(pid []byte) (*processKeys, error) {
 	return pk, nil
 }
 
-// scheduleRevealKeys takes the pids from the blockPool and add them to the schedule storage
+// dealPolynomial returns the secret polynomial of the key deal of the keykeeper for a process. It is
+// derived from the signer key, so the disputed shares can be revealed at any time.
+func (k *KeyKeeper) dealPolynomial(pid []byte, process *types.Process) (*threshold.Polynomial, error) {
+	seed := append(append(k.signer.Private.D.Bytes(), pid...), byte(k.myIndex))
+	return threshold.NewPolynomial(int(process.KeyThreshold), hkdf.New(sha256.New, seed, nil, []byte("keydeal")))
+}
+
+// newKeyDeal returns the commitments of the deal polynomial of the keykeeper for the threshold encryption
+// key of a process, and its evaluation at the index of each keykeeper encrypted with its public key
+func (k *KeyKeeper) newKeyDeal(pid []byte, process *types.Process) (*types.KeyDeal, error) {
+	poly, err := k.dealPolynomial(pid, process)
+	if err != nil {
+		return nil, err
+	}
+	deal := &types.KeyDeal{Shares: make([]string, types.MaxKeyIndex)}
+	for _, c := range poly.Commitments() {
+		deal.Commitments = append(deal.Commitments, c.Hex())
+	}
+	for i, pubKey := range process.EncryptionPublicKeys {
+		if i == types.ThresholdKeyIndex || pubKey == "" {
+			continue
+		}
+		pub, err := nacl.DecodePublic(pubKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode public key of index %d: (%s)", i, err)
+		}
+		share, err := nacl.Anonymous.Encrypt([]byte(threshold.EncodeScalar(poly.Share(i))), pub)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encrypt key share for index %d: (%s)", i, err)
+		}
+		deal.Shares[i] = fmt.Sprintf("%x", share)
+	}
+	return deal, nil
+}
+
+// dealShare returns the share received by the keykeeper index from a dealer, verified against the
+// dealer commitments. A share revealed by the dealer after a complaint is used instead of the encrypted one.
+func dealShare(deal *types.KeyDeal, priv crypto.Cipher, index int) (*threshold.Scalar, error) {
+	encoded := ""
+	if index < len(deal.RevealedShares) && deal.RevealedShares[index] != "" {
+		encoded = deal.RevealedShares[index]
+	} else {
+		if deal.Shares[index] == "" {
+			return nil, fmt.Errorf("no key share received")
+		}
+		cipher, err := hex.DecodeString(deal.Shares[index])
+		if err != nil {
+			return nil, err
+		}
+		data, err := priv.Decrypt(cipher)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt key share: (%s)", err)
+		}
+		encoded = string(data)
+	}
+	share, err := threshold.DecodeScalar(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode key share: (%s)", err)
+	}
+	commitments := make([]*threshold.Point, len(deal.Commitments))
+	for j, c := range deal.Commitments {
+		if commitments[j], err = threshold.DecodePoint(c); err != nil {
+			return nil, err
+		}
+	}
+	if !threshold.VerifyShare(commitments, index, share) {
+		return nil, fmt.Errorf("invalid key share")
+	}
+	return share, nil
+}
+
+// keyShare returns the threshold key share of the keykeeper, which is the sum of the shares received
+// from all the qualified dealers. All the received shares are verified against the dealer commitments.
+func (k *KeyKeeper) keyShare(process *types.Process, pk *processKeys) (*threshold.Scalar, error) {
+	priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", pk.privKey))
+	if err != nil {
+		return nil, err
+	}
+	var shares []*threshold.Scalar
+	for i := range process.KeyDeals {
+		if !process.KeyDeals[i].Qualified(process.KeyThreshold) {
+			continue
+		}
+		share, err := dealShare(&process.KeyDeals[i], priv, int(pk.index))
+		if err != nil {
+			return nil, fmt.Errorf("key share of dealer %d: (%s)", i, err)
+		}
+		shares = append(shares, share)
+	}
+	return threshold.SumShares(shares), nil
+}
+
+// scheduleKeyDeals takes the pids from the dealPool and add them to the schedule storage
+func (k *KeyKeeper) scheduleKeyDeals() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	for pid := range k.dealPool {
+		if err := k.storage.Put([]byte(dbPrefixDeal+pid), []byte(fmt.Sprintf("%d", types.KeyPhaseKeys))); err != nil {
+			log.Errorf("cannot save scheduled key deal of process %x: (%s)", pid, err)
+			continue
+		}
+		log.Infof("scheduled key generation of process %x", pid)
+	}
+}
+
+// checkKeyGeneration takes part on the threshold key generation phases of the scheduled processes:
+// it sends the key deal, the complaints about the invalid shares received and the disputed shares
+// of its own deal. Each phase is handled once, and the entries are deleted once the process starts.
+func (k *KeyKeeper) checkKeyGeneration(height int64, blockTime time.Time) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	iter := k.storage.NewIterator()
+	defer iter.Release()
+	for iter.Next() {
+		// TODO(mvdan): use a prefixed iterator
+		if !strings.HasPrefix(string(iter.Key()), dbPrefixDeal) {
+			continue
+		}
+		handled, err := strconv.Atoi(string(iter.Value()))
+		if err != nil {
+			log.Errorf("cannot fetch key generation phase from keykeeper database: (%s)", err)
+			continue
+		}
+		pid := append([]byte(nil), iter.Key()[len(dbPrefixDeal):]...)
+		process, err := k.vochain.State.Process(pid, false)
+		if err != nil {
+			log.Errorf("cannot get process from state: (%s)", err)
+			continue
+		}
+		// the transactions are checked against the last committed block
+		phase := process.KeyGenerationPhase(height, blockTime)
+		if process.Canceled || phase == types.KeyPhaseDone {
+			if err := k.storage.Del(iter.Key()); err != nil {
+				log.Errorf("cannot delete key deal for process %x: (%s)", pid, err)
+			}
+			continue
+		}
+		if phase <= handled {
+			continue
+		}
+		switch phase {
+		case types.KeyPhaseDeals:
+			err = k.publishKeyDeal(pid, process)
+		case types.KeyPhaseComplaints:
+			err = k.publishKeyComplaints(pid, process)
+		case types.KeyPhaseReveals:
+			err = k.revealDisputedShares(pid, process)
+		}
+		if err != nil {
+			log.Errorf("cannot take part on the key generation phase %d of process %x: (%s)", phase, pid, err)
+		}
+		if err := k.storage.Put(iter.Key(), []byte(fmt.Sprintf("%d", phase))); err != nil {
+			log.Errorf("cannot save key generation phase of process %x: (%s)", pid, err)
+		}
+	}
+}
+
+// publishKeyDeal sends the key deal of the keykeeper for a threshold encrypted process
+func (k *KeyKeeper) publishKeyDeal(pid []byte, process *types.Process) error {
+	if process.EncryptionPublicKeys[k.myIndex] == "" {
+		return fmt.Errorf("keys not published")
+	}
+	deal, err := k.newKeyDeal(pid, process)
+	if err != nil {
+		return err
+	}
+	log.Infof("publishing key deal for process %x", pid)
+	return k.signAndSendTx(&types.AdminTx{
+		Type:      types.TxAddProcessKeyDeal,
+		KeyDeal:   deal,
+		KeyIndex:  int(k.myIndex),
+		Nonce:     util.RandomHex(32),
+		ProcessID: fmt.Sprintf("%x", pid),
+	})
+}
+
+// publishKeyComplaints sends a complaint for each dealer whose share received by the keykeeper
+// is missing or invalid
+func (k *KeyKeeper) publishKeyComplaints(pid []byte, process *types.Process) error {
+	if process.EncryptionPublicKeys[k.myIndex] == "" {
+		return fmt.Errorf("keys not published")
+	}
+	pk, err := k.generateKeys(pid)
+	if err != nil {
+		return err
+	}
+	priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", pk.privKey))
+	if err != nil {
+		return err
+	}
+	for i := range process.KeyDeals {
+		deal := &process.KeyDeals[i]
+		if i == int(k.myIndex) || len(deal.Commitments) == 0 || deal.HasComplaint(int(k.myIndex)) {
+			continue
+		}
+		_, err := dealShare(deal, priv, int(k.myIndex))
+		if err == nil {
+			continue
+		}
+		log.Warnf("complaining about key deal %d of process %x: (%s)", i, pid, err)
+		if err := k.signAndSendTx(&types.AdminTx{
+			Type:        types.TxAddProcessKeyComplaint,
+			DealerIndex: i,
+			KeyIndex:    int(k.myIndex),
+			Nonce:       util.RandomHex(32),
+			ProcessID:   fmt.Sprintf("%x", pid),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revealDisputedShares reveals the shares of the keykeeper deal the other keykeepers complained about
+func (k *KeyKeeper) revealDisputedShares(pid []byte, process *types.Process) error {
+	deal := &process.KeyDeals[k.myIndex]
+	if len(deal.Complaints) == 0 {
+		return nil
+	}
+	if len(deal.Complaints) >= int(process.KeyThreshold) {
+		return fmt.Errorf("key deal disqualified, %d complaints received", len(deal.Complaints))
+	}
+	poly, err := k.dealPolynomial(pid, process)
+	if err != nil {
+		return err
+	}
+	for _, i := range deal.Complaints {
+		if i < len(deal.RevealedShares) && deal.RevealedShares[i] != "" {
+			continue
+		}
+		log.Infof("revealing disputed key share %d of process %x", i, pid)
+		if err := k.signAndSendTx(&types.AdminTx{
+			Type:        types.TxRevealProcessKeyDealShare,
+			DealerIndex: int(k.myIndex),
+			KeyIndex:    i,
+			KeyShare:    threshold.EncodeScalar(poly.Share(i)),
+			Nonce:       util.RandomHex(32),
+			ProcessID:   fmt.Sprintf("%x", pid),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduleRevealKeys takes the pids from the blockPool and timePool and add them to the schedule storage
 func (k *KeyKeeper) scheduleRevealKeys() {
 	k.lock.Lock()
 	defer k.lock.Unlock()
+	for pid, endTime := range k.timePool {
+		if err := k.storage.Put([]byte(dbPrefixTime+pid), []byte(fmt.Sprintf("%d", endTime))); err != nil {
+			log.Errorf("cannot save scheduled reveal of process %x: (%s)", pid, err)
+			continue
+		}
+		log.Infof("scheduled reveal keys of process %x for time %d", pid, endTime)
+	}
+	// a canceled time scheduled process is revealed on the next block
+	for pid := range k.blockPool {
+		if has, _ := k.storage.Has([]byte(dbPrefixTime + pid)); has {
+			if err := k.storage.Del([]byte(dbPrefixTime + pid)); err != nil {
+				log.Errorf("cannot delete scheduled reveal of process %x: (%s)", pid, err)
+			}
+		}
+	}
 	for pid, height := range k.blockPool {
 		pids := []string{}
 		pkey := []byte(dbPrefixBlock + fmt.Sprintf("%d", height))
@@ -382,6 +704,37 @@ func (k *KeyKeeper) checkRevealProcess(height int64) {
 	}
 }
 
+// checkRevealTimedProcesses reveals the keys of the time scheduled processes finished at blockTime
+// and deletes their entries from the storage
+func (k *KeyKeeper) checkRevealTimedProcesses(blockTime time.Time) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	iter := k.storage.NewIterator()
+	defer iter.Release()
+	for iter.Next() {
+		// TODO(mvdan): use a prefixed iterator
+		if !strings.HasPrefix(string(iter.Key()), dbPrefixTime) {
+			continue
+		}
+		endTime, err := strconv.ParseInt(string(iter.Value()), 10, 64)
+		if err != nil {
+			log.Errorf("cannot fetch end time from keykeeper database: (%s)", err)
+			continue
+		}
+		if blockTime.Unix() < endTime {
+			continue
+		}
+		pid := string(iter.Key()[len(dbPrefixTime):])
+		log.Infof("revealing keys for process %x on time %d", pid, blockTime.Unix())
+		if err := k.revealKeys(pid); err != nil {
+			log.Errorf("cannot reveal proces keys for %x: (%s)", pid, err)
+		}
+		if err := k.storage.Del(iter.Key()); err != nil {
+			log.Errorf("cannot delete revealed keys for process %x: (%s)", pid, err)
+		}
+	}
+}
+
 func (k *KeyKeeper) publishPendingKeys() {
 	for pid, pk := range k.keyPool {
 		if err := k.publishKeys(pk, pid); err != nil {
@@ -393,15 +746,7 @@ func (k *KeyKeeper) publishPendingKeys() {
 // This functions must be async in order to avoid a deadlock on the block creation
 func (k *KeyKeeper) publishKeys(pk *processKeys, pid string) error {
 	log.Infof("publishing keys for process %x", []byte(pid))
-	tx := &types.AdminTx{
-		Type:                types.TxAddProcessKeys,
-		KeyIndex:            int(pk.index),
-		Nonce:               util.RandomHex(32),
-		ProcessID:           fmt.Sprintf("%x", []byte(pid)),
-		EncryptionPublicKey: fmt.Sprintf("%x", pk.pubKey),
-		CommitmentKey:       fmt.Sprintf("%x", pk.commitmentKey),
-	}
-	if err := k.signAndSendTx(tx); err != nil {
+	if err := k.signAndSendTx(publishKeysTx(pk, pid)); err != nil {
 		return err
 	}
 	k.lock.Lock()
@@ -415,6 +760,18 @@ func (k *KeyKeeper) publishKeys(pk *processKeys, pid string) error {
 	return k.storage.Put(dbKey, data)
 }
 
+// publishKeysTx returns the unsigned transaction publishing the keys of a process
+func publishKeysTx(pk *processKeys, pid string) *types.AdminTx {
+	return &types.AdminTx{
+		Type:                types.TxAddProcessKeys,
+		KeyIndex:            int(pk.index),
+		Nonce:               util.RandomHex(32),
+		ProcessID:           fmt.Sprintf("%x", []byte(pid)),
+		EncryptionPublicKey: fmt.Sprintf("%x", pk.pubKey),
+		CommitmentKey:       fmt.Sprintf("%x", pk.commitmentKey),
+	}
+}
+
 // Insecure
 func (k *KeyKeeper) revealKeys(pid string) error {
 	/*	dbKey := []byte(dbPrefixProcess + pid)
@@ -433,26 +790,17 @@ func (k *KeyKeeper) revealKeys(pid string) error {
 			return fmt.Errorf("empty process keys")
 		}
 	*/
-	pk, err := k.generateKeys([]byte(pid))
+	tx, err := k.revealKeysTx(pid, false)
 	if err != nil {
 		return err
 	}
-
-	tx := &types.AdminTx{
-		Type:                 types.TxRevealProcessKeys,
-		KeyIndex:             int(pk.index),
-		Nonce:                util.RandomHex(32),
-		ProcessID:            fmt.Sprintf("%x", []byte(pid)),
-		EncryptionPrivateKey: fmt.Sprintf("%x", pk.privKey),
-		RevealKey:            fmt.Sprintf("%x", pk.revealKey),
-	}
 	if err := k.signAndSendTx(tx); err != nil {
 		return err
 	}
-	if len(pk.privKey) > 0 {
+	if len(tx.EncryptionPrivateKey) > 0 {
 		log.Infof("revealing encryption key for process %x", pid)
 	}
-	if len(pk.revealKey) > 0 {
+	if len(tx.RevealKey) > 0 {
 		log.Infof("revealing commitment key for process %x", pid)
 	}
 	if err = k.storage.Del([]byte(dbPrefixProcess + pid)); err != nil {
@@ -461,20 +809,58 @@ func (k *KeyKeeper) revealKeys(pid string) error {
 	return nil
 }
 
-func (k *KeyKeeper) signAndSendTx(tx *types.AdminTx) error {
-	// sign the transaction
+// revealKeysTx returns the unsigned transaction revealing the keys of the keykeeper for a process.
+// On threshold encrypted processes the key share is revealed instead of the private key.
+func (k *KeyKeeper) revealKeysTx(pid string, isQuery bool) (*types.AdminTx, error) {
+	pk, err := k.generateKeys([]byte(pid))
+	if err != nil {
+		return nil, err
+	}
+	privKey := fmt.Sprintf("%x", pk.privKey)
+	process, err := k.vochain.State.Process([]byte(pid), isQuery)
+	if err != nil {
+		return nil, err
+	}
+	if process.IsThresholdEncrypted() {
+		share, err := k.keyShare(process, pk)
+		if err != nil {
+			// the key can still be recovered from the shares of the other keykeepers
+			log.Warnf("cannot compute threshold key share for process %x: (%s)", pid, err)
+			privKey = ""
+		} else {
+			privKey = threshold.EncodeScalar(share)
+		}
+	}
+	return &types.AdminTx{
+		Type:                 types.TxRevealProcessKeys,
+		KeyIndex:             int(pk.index),
+		Nonce:                util.RandomHex(32),
+		ProcessID:            fmt.Sprintf("%x", []byte(pid)),
+		EncryptionPrivateKey: privKey,
+		RevealKey:            fmt.Sprintf("%x", pk.revealKey),
+	}, nil
+}
+
+// signTx signs an admin transaction with the keykeeper signer key, and returns its JSON encoding
+func (k *KeyKeeper) signTx(tx *types.AdminTx) ([]byte, error) {
 	txBytes, err := json.Marshal(tx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if tx.Signature, err = k.signer.Sign(txBytes); err != nil {
-		return err
+		return nil, err
 	}
-	if txBytes, err = json.Marshal(tx); err != nil {
+	return json.Marshal(tx)
+}
+
+func (k *KeyKeeper) signAndSendTx(tx *types.AdminTx) error {
+	// sign the transaction
+	txBytes, err := k.signTx(tx)
+	if err != nil {
 		return err
 	}
-	// Send the transaction to the mempool
-	result, err := k.vochain.SendTX(txBytes)
+	// Send the transaction to the mempool, co-signed if the process keys threshold requires it
+	result, err := k.vochain.SendOracleTx(txBytes)
 	if err != nil {
 		return err
 	}
@@ -483,3 +869,68 @@ func (k *KeyKeeper) signAndSendTx(tx *types.AdminTx) error {
 	}
 	return nil
 }
+
+// coSignPendingKeys co-signs the process keys transactions of the other keykeepers which are
+// waiting for more signatures (see OracleThresholds.ProcessKeys). The vochain fully checks
+// each transaction once it reaches the threshold.
+func (k *KeyKeeper) coSignPendingKeys() {
+	for _, cosigned := range k.vochain.State.PendingCoSignedTxs(true) {
+		gtx, err := vochain.UnmarshalTx(cosigned.Tx)
+		if err != nil {
+			log.Warnf("cannot unmarshal co-signed transaction: (%s)", err)
+			continue
+		}
+		tx, ok := gtx.(*types.AdminTx)
+		if !ok || !keyTxTypes[tx.Type] {
+			continue
+		}
+		if k.coSigned(tx.SignedBytes, cosigned.Signatures) {
+			continue
+		}
+		// sign the original transaction, so the signed bytes are the same
+		var cosignTx types.AdminTx
+		if err := json.Unmarshal(cosigned.Tx, &cosignTx); err != nil {
+			log.Warnf("cannot unmarshal co-signed transaction: (%s)", err)
+			continue
+		}
+		cosignTx.Signature, cosignTx.Signatures = "", nil
+		txBytes, err := k.signTx(&cosignTx)
+		if err != nil {
+			log.Warnf("cannot sign transaction: (%s)", err)
+			continue
+		}
+		if txBytes, err = json.Marshal(types.CoSignTx{Tx: txBytes, Type: types.TxCoSign}); err != nil {
+			log.Warnf("cannot marshal co-sign transaction: (%s)", err)
+			continue
+		}
+		result, err := k.vochain.SendTX(txBytes)
+		if err != nil {
+			log.Warnf("cannot send co-sign transaction: (%s)", err)
+			continue
+		}
+		if result.Code != 0 {
+			log.Debugf("co-sign transaction rejected: (%s)", result.Data.Bytes())
+			continue
+		}
+		log.Infof("co-signed %s transaction of keykeeper %d for process %s", tx.Type, tx.KeyIndex, tx.ProcessID)
+	}
+}
+
+// coSigned returns true if any of the signatures belongs to the keykeeper signer
+func (k *KeyKeeper) coSigned(signedBytes []byte, signatures []string) bool {
+	for _, signature := range signatures {
+		if addr, err := ethereum.AddrFromSignature(signedBytes, signature); err == nil && addr == k.signer.Address() {
+			return true
+		}
+	}
+	return false
+}
+
+// keyTxTypes are the transaction types sent by the keykeepers
+var keyTxTypes = map[string]bool{
+	types.TxAddProcessKeys:            true,
+	types.TxRevealProcessKeys:         true,
+	types.TxAddProcessKeyDeal:         true,
+	types.TxAddProcessKeyComplaint:    true,
+	types.TxRevealProcessKeyDealShare: true,
+}
@@ -2,12 +2,21 @@ package keykeeper
 
 import (
 	"crypto/rand"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
 
+	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
 	"gitlab.com/vocdoni/go-dvote/crypto/nacl"
+	"gitlab.com/vocdoni/go-dvote/crypto/threshold"
+	"gitlab.com/vocdoni/go-dvote/types"
+	"gitlab.com/vocdoni/go-dvote/util"
+	"gitlab.com/vocdoni/go-dvote/vochain"
 )
 
 func TestEncodeDecode(t *testing.T) {
@@ -38,3 +47,410 @@ func TestEncodeDecode(t *testing.T) {
 		t.Fatalf("processKeys mismatch: %s", diff)
 	}
 }
+
+func TestThresholdKeys(t *testing.T) {
+	app, err := vochain.NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: 1}})
+	// keykeepers use the indexes from 1
+	keykeepers := make([]*KeyKeeper, 6)
+	for i := 1; i < len(keykeepers); i++ {
+		signer := ethereum.NewSignKeys()
+		if err := signer.Generate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.State.AddOracle(signer.AddressString()); err != nil {
+			t.Fatal(err)
+		}
+		keykeepers[i] = &KeyKeeper{vochain: app, signer: signer, myIndex: int8(i)}
+	}
+	// nextBlock commits the current block and begins a new one, the state is rolled back on BeginBlock
+	nextBlock := func(height int64) {
+		app.Commit()
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: height}})
+	}
+	pid := util.RandomBytes(types.ProcessIDsize)
+	process := types.Process{
+		Type:                  types.EncryptedPoll,
+		EntityID:              util.RandomBytes(types.EntityIDsize),
+		StartBlock:            20,
+		NumberOfBlocks:        5,
+		KeyThreshold:          3,
+		EncryptionPublicKeys:  make([]string, types.MaxKeyIndex),
+		EncryptionPrivateKeys: make([]string, types.MaxKeyIndex),
+		CommitmentKeys:        make([]string, types.MaxKeyIndex),
+		RevealKeys:            make([]string, types.MaxKeyIndex),
+		KeyDeals:              make([]types.KeyDeal, types.MaxKeyIndex),
+	}
+	if err := app.State.AddProcess(process, pid, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	sendTx := func(k *KeyKeeper, tx *types.AdminTx) error {
+		tx.Nonce = util.RandomHex(32)
+		if tx.KeyIndex == 0 {
+			tx.KeyIndex = int(k.myIndex)
+		}
+		tx.ProcessID = fmt.Sprintf("%x", pid)
+		var err error
+		if tx.SignedBytes, err = json.Marshal(tx); err != nil {
+			t.Fatal(err)
+		}
+		if tx.Signature, err = k.signer.Sign(tx.SignedBytes); err != nil {
+			t.Fatal(err)
+		}
+		_, err = vochain.AddTx(tx, app.State, true)
+		return err
+	}
+	getProcess := func() *types.Process {
+		p, err := app.State.Process(pid, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	// keykeeper 5 is absent and never publishes its keys
+	keys := make([]*processKeys, len(keykeepers))
+	for i := 1; i <= 4; i++ {
+		if keys[i], err = keykeepers[i].generateKeys(pid); err != nil {
+			t.Fatal(err)
+		}
+		if err := sendTx(keykeepers[i], &types.AdminTx{
+			Type:                types.TxAddProcessKeys,
+			EncryptionPublicKey: fmt.Sprintf("%x", keys[i].pubKey),
+			CommitmentKey:       fmt.Sprintf("%x", keys[i].commitmentKey),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the key generation phases take place in the 9 blocks before the process start
+	if err := sendTx(keykeepers[1], &types.AdminTx{Type: types.TxAddProcessKeyDeal, KeyDeal: &types.KeyDeal{}}); err == nil {
+		t.Fatal("key deal accepted before the deals phase")
+	}
+	nextBlock(process.StartBlock - 9)
+	for i := 1; i <= 4; i++ {
+		if err := vochain.CheckVoteWindow(getProcess(), process.StartBlock, time.Time{}); err == nil {
+			t.Fatalf("vote accepted with %d key deals", i-1)
+		}
+		deal, err := keykeepers[i].newKeyDeal(pid, getProcess())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// keykeeper 3 sends an invalid share to keykeeper 2, and keykeeper 4 to keykeeper 1
+		switch i {
+		case 3:
+			deal.Shares[2] = deal.Shares[1]
+		case 4:
+			deal.Shares[1] = deal.Shares[2]
+		}
+		if err := sendTx(keykeepers[i], &types.AdminTx{Type: types.TxAddProcessKeyDeal, KeyDeal: deal}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the keys of the absent keykeeper are not accepted once the key generation started
+	keys[5], err = keykeepers[5].generateKeys(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sendTx(keykeepers[5], &types.AdminTx{
+		Type:                types.TxAddProcessKeys,
+		EncryptionPublicKey: fmt.Sprintf("%x", keys[5].pubKey),
+		CommitmentKey:       fmt.Sprintf("%x", keys[5].commitmentKey),
+	}); err == nil {
+		t.Fatal("keys added after the key generation started")
+	}
+	complaint := func(index, dealer int) *types.AdminTx {
+		return &types.AdminTx{Type: types.TxAddProcessKeyComplaint, KeyIndex: index, DealerIndex: dealer}
+	}
+	if err := sendTx(keykeepers[1], complaint(1, 4)); err == nil {
+		t.Fatal("complaint accepted before the complaints phase")
+	}
+
+	nextBlock(process.StartBlock - 6)
+	for i := 1; i <= 4; i++ {
+		priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", keys[i].privKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for dealer := 1; dealer <= 4; dealer++ {
+			_, err := dealShare(&getProcess().KeyDeals[dealer], priv, i)
+			invalid := (dealer == 3 && i == 2) || (dealer == 4 && i == 1)
+			if invalid != (err != nil) {
+				t.Fatalf("unexpected share %d of dealer %d verification: %v", i, dealer, err)
+			}
+		}
+	}
+	for _, c := range []*types.AdminTx{complaint(2, 3), complaint(1, 4)} {
+		if err := sendTx(keykeepers[c.KeyIndex], c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sendTx(keykeepers[1], complaint(1, 4)); err == nil {
+		t.Fatal("duplicated complaint accepted")
+	}
+	// the dealers with complaints do not contribute to the key until the disputed shares are revealed
+	if n := getProcess().KeyDealers(); n != 2 {
+		t.Fatalf("expected 2 qualified dealers, got %d", n)
+	}
+	if err := vochain.CheckVoteWindow(getProcess(), process.StartBlock, time.Time{}); err == nil {
+		t.Fatal("vote accepted with 2 qualified key deals")
+	}
+
+	nextBlock(process.StartBlock - 3)
+	poly, err := keykeepers[4].dealPolynomial(pid, getProcess())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reveal := func(index int) *types.AdminTx {
+		return &types.AdminTx{
+			Type:        types.TxRevealProcessKeyDealShare,
+			KeyIndex:    1,
+			DealerIndex: 4,
+			KeyShare:    threshold.EncodeScalar(poly.Share(index)),
+		}
+	}
+	if err := sendTx(keykeepers[4], reveal(2)); err == nil {
+		t.Fatal("invalid disputed share accepted")
+	}
+	// keykeeper 3 never reveals the disputed share and it is disqualified
+	if err := sendTx(keykeepers[4], reveal(1)); err != nil {
+		t.Fatal(err)
+	}
+	if n := getProcess().KeyDealers(); n != 3 {
+		t.Fatalf("expected 3 qualified dealers, got %d", n)
+	}
+	if err := vochain.CheckVoteWindow(getProcess(), process.StartBlock, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := nacl.DecodePublic(getProcess().EncryptionPublicKeys[types.ThresholdKeyIndex])
+	if err != nil {
+		t.Fatal(err)
+	}
+	vote := []byte(`{"type":"encrypted-poll","votes":[1,2]}`)
+	cipher, err := nacl.Anonymous.Encrypt(vote, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nextBlock(process.StartBlock + process.NumberOfBlocks)
+	shares := make([]string, len(keykeepers))
+	for i := 1; i <= 4; i++ {
+		share, err := keykeepers[i].keyShare(getProcess(), keys[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares[i] = threshold.EncodeScalar(share)
+	}
+	// a key share of another keykeeper is rejected
+	if err := sendTx(keykeepers[1], &types.AdminTx{Type: types.TxRevealProcessKeys, EncryptionPrivateKey: shares[2]}); err == nil {
+		t.Fatal("key share of another keykeeper accepted")
+	}
+	// keykeeper 4 dealt but never reveals its key share
+	for i := 1; i <= 3; i++ {
+		if getProcess().EncryptionPrivateKeys[types.ThresholdKeyIndex] != "" {
+			t.Fatalf("encryption key recovered with %d key shares", i-1)
+		}
+		if err := sendTx(keykeepers[i], &types.AdminTx{
+			Type:                 types.TxRevealProcessKeys,
+			EncryptionPrivateKey: shares[i],
+			RevealKey:            fmt.Sprintf("%x", keys[i].revealKey),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	priv, err := threshold.DecodePrivate(getProcess().EncryptionPrivateKeys[types.ThresholdKeyIndex])
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := priv.Decrypt(cipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decrypted) != string(vote) {
+		t.Fatalf("decrypted vote mismatch: %s", decrypted)
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	app, err := vochain.NewBaseApplication(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := ethereum.NewSignKeys()
+	if err := signer.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	k, err := OpenKeyKeeper(t.TempDir(), app, signer, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+	k.Rollback()
+
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: abcitypes.Header{Height: 1}})
+	pids := [][]byte{util.RandomBytes(types.ProcessIDsize), util.RandomBytes(types.ProcessIDsize)}
+	for _, pid := range pids {
+		if err := app.State.AddProcess(types.Process{
+			Type:                  types.EncryptedPoll,
+			EntityID:              util.RandomBytes(types.EntityIDsize),
+			StartBlock:            10,
+			NumberOfBlocks:        10,
+			EncryptionPublicKeys:  make([]string, types.MaxKeyIndex),
+			EncryptionPrivateKeys: make([]string, types.MaxKeyIndex),
+			CommitmentKeys:        make([]string, types.MaxKeyIndex),
+			RevealKeys:            make([]string, types.MaxKeyIndex),
+		}, pid, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// processes without keys are ignored
+	if err := app.State.AddProcess(types.Process{
+		Type:     types.PollVote,
+		EntityID: util.RandomBytes(types.EntityIDsize),
+	}, util.RandomBytes(types.ProcessIDsize), ""); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+
+	unpublished, err := k.UnpublishedKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unpublished) != 2 {
+		t.Fatalf("expected 2 processes with unpublished keys, got %d", len(unpublished))
+	}
+	if _, err := k.RevealKeysTx(pids[0]); err == nil {
+		t.Fatal("reveal of unpublished keys accepted")
+	}
+
+	// publish the keys of the first process
+	txBytes, err := k.PublishKeysTx(pids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tx types.AdminTx
+	if err := json.Unmarshal(txBytes, &tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State.AddProcessKeys(&tx); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+	if unpublished, err = k.UnpublishedKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if len(unpublished) != 1 || string(unpublished[0]) != string(pids[1]) {
+		t.Fatalf("unexpected processes with unpublished keys: %x", unpublished)
+	}
+	if _, err := k.PublishKeysTx(pids[0]); err == nil {
+		t.Fatal("keys published twice")
+	}
+
+	issues, err := k.CheckKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || string(issues[0].ProcessID) != string(pids[0]) {
+		t.Fatalf("expected an issue for the not scheduled reveal, got %v", issues)
+	}
+	k.blockPool[string(pids[0])] = 20
+	k.timePool[string(pids[1])] = 1000
+	k.scheduleRevealKeys()
+	if issues, err = k.CheckKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+	reveals, err := k.ScheduledReveals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScheduledReveal{{Height: 20, ProcessID: pids[0]}, {EndTime: 1000, ProcessID: pids[1]}}
+	if diff := cmp.Diff(expected, reveals); diff != "" {
+		t.Fatalf("scheduled reveals mismatch: %s", diff)
+	}
+	if txBytes, err = k.RevealKeysTx(pids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(txBytes, &tx); err != nil {
+		t.Fatal(err)
+	}
+	if priv, err := k.EncryptionPrivateKey(pids[0]); err != nil || priv != tx.EncryptionPrivateKey {
+		t.Fatalf("encryption private key mismatch: %s != %s (%v)", priv, tx.EncryptionPrivateKey, err)
+	}
+
+	// restore a backup on an empty database
+	backupKey, err := nacl.Generate(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := k.ExportBackup(fmt.Sprintf("%x", backupKey.Public().Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := OpenKeyKeeper(t.TempDir(), app, signer, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, _, err := other.ImportBackup(data, fmt.Sprintf("%x", backupKey.Bytes())); err == nil {
+		t.Fatal("backup of another keykeeper restored")
+	}
+	restored, err := OpenKeyKeeper(t.TempDir(), app, signer, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if _, _, err := restored.ImportBackup(data, fmt.Sprintf("%x", util.RandomBytes(32))); err == nil {
+		t.Fatal("backup decrypted with a wrong key")
+	}
+	n, conflicts, err := restored.ImportBackup(data, fmt.Sprintf("%x", backupKey.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(conflicts) != 0 {
+		t.Fatalf("expected 3 restored entries without conflicts, got %d (%v)", n, conflicts)
+	}
+	if restoredReveals, err := restored.ScheduledReveals(); err != nil || !cmp.Equal(reveals, restoredReveals) {
+		t.Fatalf("restored scheduled reveals mismatch: %v (%v)", restoredReveals, err)
+	}
+
+	// restore a backup on a database with other entries
+	merged, err := OpenKeyKeeper(t.TempDir(), app, signer, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer merged.Close()
+	merged.Rollback()
+	otherPid := util.RandomBytes(types.ProcessIDsize)
+	merged.blockPool[string(otherPid)] = 20
+	merged.scheduleRevealKeys()
+	if err := merged.storage.Put([]byte(dbPrefixProcess+string(pids[0])), []byte("other keys")); err != nil {
+		t.Fatal(err)
+	}
+	if n, conflicts, err = merged.ImportBackup(data, fmt.Sprintf("%x", backupKey.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 restored entries, got %d", n)
+	}
+	if len(conflicts) != 1 || string(conflicts[0].ProcessID) != string(pids[0]) {
+		t.Fatalf("expected a conflict for the stored keys, got %v", conflicts)
+	}
+	if stored, err := merged.storage.Get([]byte(dbPrefixProcess + string(pids[0]))); err != nil || string(stored) != "other keys" {
+		t.Fatalf("stored keys overwritten by the backup: %q (%v)", stored, err)
+	}
+	mergedReveals, err := merged.ScheduledReveals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []ScheduledReveal{{Height: 20, ProcessID: otherPid}, {Height: 20, ProcessID: pids[0]}, {EndTime: 1000, ProcessID: pids[1]}}
+	if diff := cmp.Diff(expected, mergedReveals); diff != "" {
+		t.Fatalf("merged scheduled reveals mismatch: %s", diff)
+	}
+}
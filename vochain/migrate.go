@@ -0,0 +1,212 @@
+package vochain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	amino "github.com/tendermint/go-amino"
+	tmbytes "github.com/tendermint/tendermint/libs/bytes"
+	tmstore "github.com/tendermint/tendermint/store"
+	tmtypes "github.com/tendermint/tendermint/types"
+	tmdb "github.com/tendermint/tm-db"
+
+	"gitlab.com/vocdoni/go-dvote/types"
+)
+
+// StateMigration contains the result of a state migration between backends
+type StateMigration struct {
+	// Height is the block height of the migrated state
+	Height int64
+	// Keys is the number of keys migrated for each tree
+	Keys map[string]uint64
+	// SourceHash and DestinationHash are the app hashes of the state on each backend
+	SourceHash      []byte
+	DestinationHash []byte
+}
+
+// MigrateState copies the last committed vochain state stored on srcDir by the srcBackend
+// database into a new state database on dstDir using dstBackend.
+// If height is not zero, it must be the height of the last committed state. Older versions
+// are not supported since loading them would delete the newer ones from srcDir, so a different
+// height is refused before opening any state database (using the srcDir block store height).
+// Once copied, the destination is reopened to check its app hash persisted and all the
+// keys and values are compared with the source.
+// Note that each backend computes different tree roots, so the source and destination app
+// hashes are not expected to be equal: the migration is verified by comparing the keys and
+// values, not the app hashes. The migrated state is not consensus compatible with the nodes
+// using srcBackend, the next block header would include an app hash the migrated node does not
+// compute. Thus the migration is refused if the node owning srcDir is one of the state validators
+// (see checkNotValidator), a node using the migrated state can only follow a network where every
+// node switched to dstBackend at the same height.
+func MigrateState(srcDir, srcBackend, dstDir, dstBackend string, height int64) (*StateMigration, error) {
+	if srcDir == dstDir {
+		return nil, fmt.Errorf("source and destination directories must be different")
+	}
+	if height < 0 {
+		return nil, fmt.Errorf("invalid height %d", height)
+	}
+	if _, err := os.Stat(srcDir); err != nil {
+		return nil, fmt.Errorf("cannot find source state: (%s)", err)
+	}
+	if height != 0 {
+		if err := checkBlockStoreHeight(srcDir, height); err != nil {
+			return nil, err
+		}
+	}
+	trees := []string{AppTree, ProcessTree, VoteTree}
+
+	src, err := OpenStateDB(srcDir, srcBackend)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open source state: (%s)", err)
+	}
+	defer src.Close()
+	if err := src.LoadVersion(0); err != nil {
+		return nil, fmt.Errorf("cannot load source state: (%s)", err)
+	}
+	cdc := amino.NewCodec()
+	RegisterAmino(cdc)
+	var header tmtypes.Header
+	if err := cdc.UnmarshalBinaryBare(src.Tree(AppTree).Get(headerKey), &header); err != nil {
+		return nil, fmt.Errorf("cannot get source state height: (%s)", err)
+	}
+	if height != 0 && height != header.Height {
+		return nil, fmt.Errorf("source state is at height %d, stop the node at height %d before migrating", header.Height, height)
+	}
+	var validators []types.GenesisValidator
+	if vb := src.Tree(AppTree).Get(validatorKey); vb != nil {
+		if err := cdc.UnmarshalBinaryBare(vb, &validators); err != nil {
+			return nil, fmt.Errorf("cannot get source state validators: (%s)", err)
+		}
+	}
+	if err := checkNotValidator(srcDir, validators); err != nil {
+		return nil, err
+	}
+
+	dst, err := OpenStateDB(dstDir, dstBackend)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open destination state: (%s)", err)
+	}
+	if err := dst.LoadVersion(0); err != nil {
+		dst.Close()
+		return nil, fmt.Errorf("cannot load destination state: (%s)", err)
+	}
+	for _, name := range trees {
+		if dst.Tree(name).Count() > 0 {
+			dst.Close()
+			return nil, fmt.Errorf("destination state is not empty")
+		}
+	}
+
+	m := &StateMigration{
+		Height:     header.Height,
+		Keys:       make(map[string]uint64, len(trees)),
+		SourceHash: src.Hash(),
+	}
+	for _, name := range trees {
+		dt := dst.Tree(name)
+		src.Tree(name).Iterate(nil, func(k, v []byte) bool {
+			// keys and values might be reused by the source iterator
+			if err = dt.Add(append([]byte{}, k...), append([]byte{}, v...)); err != nil {
+				return true
+			}
+			m.Keys[name]++
+			return false
+		})
+		if err != nil {
+			dst.Close()
+			return nil, fmt.Errorf("cannot migrate tree %s: (%s)", name, err)
+		}
+	}
+	// The state is committed twice, so loading the previous version on startup
+	// (see NewStateWithBackend) also returns the migrated state.
+	for i := 0; i < 2; i++ {
+		if m.DestinationHash, err = dst.Commit(); err != nil {
+			dst.Close()
+			return nil, fmt.Errorf("cannot commit destination state: (%s)", err)
+		}
+	}
+	if err := dst.Close(); err != nil {
+		return nil, fmt.Errorf("cannot close destination state: (%s)", err)
+	}
+
+	// Reopen the destination and check it against the source
+	if dst, err = OpenStateDB(dstDir, dstBackend); err != nil {
+		return nil, fmt.Errorf("cannot reopen destination state: (%s)", err)
+	}
+	defer dst.Close()
+	if err := dst.LoadVersion(0); err != nil {
+		return nil, fmt.Errorf("cannot load destination state: (%s)", err)
+	}
+	if !bytes.Equal(dst.Hash(), m.DestinationHash) {
+		return nil, fmt.Errorf("destination app hash changed after reopening: %x != %x", dst.Hash(), m.DestinationHash)
+	}
+	for _, name := range trees {
+		dt := dst.ImmutableTree(name)
+		src.Tree(name).Iterate(nil, func(k, v []byte) bool {
+			if !bytes.Equal(dt.Get(k), v) {
+				err = fmt.Errorf("value of key %x does not match", k)
+				return true
+			}
+			return false
+		})
+		if err != nil {
+			return nil, fmt.Errorf("tree %s mismatch: (%s)", name, err)
+		}
+		var count uint64
+		dt.Iterate(nil, func(k, v []byte) bool {
+			count++
+			return false
+		})
+		if count != m.Keys[name] {
+			return nil, fmt.Errorf("tree %s mismatch: %d keys migrated but %d found", name, m.Keys[name], count)
+		}
+	}
+	return m, nil
+}
+
+// checkBlockStoreHeight returns an error if the block store of dataDir, if any, is not at height.
+// The state height is checked again once opened, since it might be behind the block store.
+func checkBlockStoreHeight(dataDir string, height int64) error {
+	if _, err := os.Stat(filepath.Join(dataDir, "blockstore.db")); err != nil {
+		return nil
+	}
+	bsdb, err := tmdb.NewGoLevelDB("blockstore", dataDir)
+	if err != nil {
+		return fmt.Errorf("cannot open block store: (%s)", err)
+	}
+	defer bsdb.Close()
+	if storeHeight := tmstore.NewBlockStore(bsdb).Height(); storeHeight != height {
+		return fmt.Errorf("block store is at height %d, stop the node at height %d before migrating", storeHeight, height)
+	}
+	return nil
+}
+
+// checkNotValidator returns an error if the private validator key of the node owning dataDir
+// (the config/priv_validator_key.json file next to it, see newTendermint) belongs to one of the
+// validators, since a migrated validator would sign blocks with a different app hash.
+func checkNotValidator(dataDir string, validators []types.GenesisValidator) error {
+	keyFile := filepath.Join(filepath.Dir(filepath.Clean(dataDir)), "config", "priv_validator_key.json")
+	b, err := ioutil.ReadFile(keyFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read private validator key: (%s)", err)
+	}
+	var key struct {
+		Address tmbytes.HexBytes `json:"address"`
+	}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return fmt.Errorf("cannot decode private validator key %s: (%s)", keyFile, err)
+	}
+	for _, v := range validators {
+		if bytes.Equal(v.Address, key.Address) {
+			return fmt.Errorf("node %s is a validator, the migrated state would not be consensus compatible", key.Address)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package vochain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/tendermint/iavl"
+	"github.com/tendermint/tendermint/crypto/merkle"
+
+	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
+	"gitlab.com/vocdoni/go-dvote/statedb/gravitonstate"
+	"gitlab.com/vocdoni/go-dvote/statedb/iavlstate"
+)
+
+// StateRootsOp proves a tree root against the AppHash, it implements the Tendermint
+// merkle.ProofOperator interface for ProofOpStateRoots. Its key is the tree name.
+type StateRootsOp struct {
+	key []byte
+	// Roots contains the hex encoded root of each state tree
+	Roots map[string]string
+}
+
+// NewStateRootsOp returns the operation proving the root of treeName with the state roots
+func NewStateRootsOp(treeName string, roots map[string]string) StateRootsOp {
+	return StateRootsOp{key: []byte(treeName), Roots: roots}
+}
+
+// StateRootsOpDecoder decodes a StateRootsOp, to be registered on a merkle.ProofRuntime
+func StateRootsOpDecoder(pop merkle.ProofOp) (merkle.ProofOperator, error) {
+	if pop.Type != ProofOpStateRoots {
+		return nil, fmt.Errorf("unexpected proof op type %s, expected %s", pop.Type, ProofOpStateRoots)
+	}
+	var roots map[string]string
+	if err := json.Unmarshal(pop.Data, &roots); err != nil {
+		return nil, fmt.Errorf("cannot decode state roots: (%s)", err)
+	}
+	return StateRootsOp{key: pop.Key, Roots: roots}, nil
+}
+
+// ProofOp encodes the operation, its data is the JSON map of tree name to hex root
+func (op StateRootsOp) ProofOp() merkle.ProofOp {
+	data, err := json.Marshal(op.Roots)
+	if err != nil {
+		panic(err)
+	}
+	return merkle.ProofOp{Type: ProofOpStateRoots, Key: op.key, Data: data}
+}
+
+// GetKey returns the name of the tree whose root is proved
+func (op StateRootsOp) GetKey() []byte {
+	return op.key
+}
+
+// Run checks the tree root args[0] is the root of the tree named by the operation key and
+// returns the AppHash: the hash of the concatenated roots of all the trees, sorted by name
+func (op StateRootsOp) Run(args [][]byte) ([][]byte, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 root, got %d", len(args))
+	}
+	if len(op.Roots) != 3 {
+		return nil, fmt.Errorf("expected 3 state roots, got %d", len(op.Roots))
+	}
+	if _, ok := op.Roots[string(op.key)]; !ok {
+		return nil, fmt.Errorf("unknown tree %s", op.key)
+	}
+	names := make([]string, 0, len(op.Roots))
+	for name := range op.Roots {
+		switch name {
+		case AppTree, ProcessTree, VoteTree:
+		default:
+			return nil, fmt.Errorf("unknown tree %s", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var roots []byte
+	for _, name := range names {
+		r, err := hex.DecodeString(op.Roots[name])
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s tree root: (%s)", name, err)
+		}
+		if name == string(op.key) && !bytes.Equal(r, args[0]) {
+			return nil, fmt.Errorf("%s tree root does not match", name)
+		}
+		roots = append(roots, r...)
+	}
+	return [][]byte{ethereum.HashRaw(roots)}, nil
+}
+
+// ProofRuntime returns a merkle.ProofRuntime able to verify the proofs returned by
+// State.QueryProof (and the ABCI queries) against the AppHash, see ProofKeyPath
+func ProofRuntime() *merkle.ProofRuntime {
+	prt := merkle.DefaultProofRuntime()
+	prt.RegisterOpDecoder(iavlstate.ProofType, iavl.ValueOpDecoder)
+	prt.RegisterOpDecoder(gravitonstate.ProofType, gravitonstate.ValueOpDecoder)
+	prt.RegisterOpDecoder(ProofOpStateRoots, StateRootsOpDecoder)
+	return prt
+}
+
+// ProofKeyPath returns the key path of key on the tree treeName, as expected by
+// merkle.ProofRuntime to verify the proofs of State.QueryProof
+func ProofKeyPath(treeName string, key []byte) string {
+	return new(merkle.KeyPath).AppendKey([]byte(treeName), merkle.KeyEncodingURL).
+		AppendKey(key, merkle.KeyEncodingHex).String()
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"strconv"
 	"sync/atomic"
+	"time"
 
 	"github.com/dgraph-io/badger/v2"
 
@@ -120,6 +122,49 @@ func (s *Scrutinizer) checkFinishedProcesses(height int64) {
 	}
 }
 
+// registerTimeScheduledProcess keeps track of a time scheduled process until its end time is reached.
+// On the database we are storing: processId=>endTime
+func (s *Scrutinizer) registerTimeScheduledProcess(pid []byte) {
+	p, err := s.ProcessInfo(pid)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if !p.IsTimeScheduled() {
+		return
+	}
+	if err := s.Storage.Put(s.encode("processEndingTime", pid), []byte(fmt.Sprintf("%d", p.EndTime))); err != nil {
+		log.Error(err)
+		return
+	}
+	log.Infof("process %x results computation scheduled after time %d", pid, p.EndTime)
+}
+
+// checkTimeFinishedProcesses schedules the results computing of the time scheduled processes
+// finished on the block of the given height and time, so they are computed on the next block
+func (s *Scrutinizer) checkTimeFinishedProcesses(height int64, blockTime time.Time) {
+	prefix := []byte{types.ScrutinizerProcessEndingTimePrefix}
+	for _, pid := range s.List(int64(^uint(0)>>1), []byte{}, prefix) {
+		endTimeBytes, err := s.Storage.Get(s.encode("processEndingTime", pid))
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		endTime, err := strconv.ParseInt(string(endTimeBytes), 10, 64)
+		if err != nil {
+			log.Errorf("cannot parse end time of process %x: (%s)", pid, err)
+			continue
+		}
+		if blockTime.Unix() < endTime {
+			continue
+		}
+		s.registerPendingProcess(pid, height+1)
+		if err := s.Storage.Del(s.encode("processEndingTime", pid)); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
 // creates a new empty process and stores it into the database
 func (s *Scrutinizer) newEmptyLiveProcess(pid []byte) (ProcessVotes, error) {
 	pv := emptyProcess()
@@ -191,6 +236,10 @@ func (s *Scrutinizer) encode(t string, data []byte) []byte {
 		return append([]byte{types.ScrutinizerResultsPrefix}, data...)
 	case "processEnding":
 		return append([]byte{types.ScrutinizerProcessEndingPrefix}, data...)
+	case "processEndingTime":
+		return append([]byte{types.ScrutinizerProcessEndingTimePrefix}, data...)
+	case "rankedResults":
+		return append([]byte{types.ScrutinizerRankedResultsPrefix}, data...)
 	}
 	panic("scrutinizer encode type not known")
 }
@@ -222,7 +271,7 @@ func (s *Scrutinizer) addEntity(eid, pid []byte) {
 func emptyProcess() ProcessVotes {
 	pv := make(ProcessVotes, MaxQuestions)
 	for i := range pv {
-		pv[i] = make([]uint32, MaxOptions)
+		pv[i] = make([]uint64, MaxOptions)
 	}
 	return pv
 }
@@ -4,6 +4,7 @@ package scrutinizer
 	Scrutinizer keeps 4 diferent database entries (splited by key prefix)
 
 	+ ProcessEnding: key is block number. Used for schedule results computing
+	+ ProcessEndingTime: key is processId. Time scheduled processes waiting for its end time
 	+ LiveProcess: key is processId. Temporary storage for live results (poll-vote)
 	+ Entity: key is entityId: List of known entities
 	+ Results: key is processId: Final results for a process
@@ -21,23 +22,25 @@ import (
 
 const (
 	// MaxQuestions is the maximum number of questions allowed in a VotePackage
-	MaxQuestions = 64
+	MaxQuestions = types.MaxQuestions
 	// MaxOptions is the maximum number of options allowed in a VotePackage question
-	MaxOptions = 64
+	MaxOptions = types.MaxOptions
 )
 
 // Scrutinizer is the component which makes the accounting of the voting processes and keeps it indexed in a local database
 type Scrutinizer struct {
-	VochainState *vochain.State
-	Storage      db.Database
-	votePool     []*types.Vote
-	processPool  []*types.ScrutinizerOnProcessData
-	resultsPool  []*types.ScrutinizerOnProcessData
-	entityCount  int64
+	VochainState  *vochain.State
+	Storage       db.Database
+	votePool      []*types.Vote
+	overwritePool []*types.Vote
+	processPool   []*types.ScrutinizerOnProcessData
+	resultsPool   []*types.ScrutinizerOnProcessData
+	entityCount   int64
 }
 
 // ProcessVotes represents the results of a voting process using a two dimensions slice [ question1:[option1,option2], question2:[option1,option2], ...]
-type ProcessVotes [][]uint32
+// Each option accumulates the weight of its votes (one per vote on unweighted processes)
+type ProcessVotes [][]uint64
 
 // ProcessEndingList represents a list of ending voting processes
 type ProcessEndingList [][]byte
@@ -62,6 +65,11 @@ func (s *Scrutinizer) Commit(height int64) {
 	// this can be run async
 	go s.checkFinishedProcesses(height)
 
+	// Schedule the results computing of the time scheduled processes finished on this block
+	if header := s.VochainState.Header(false); header != nil {
+		s.checkTimeFinishedProcesses(height, header.Time)
+	}
+
 	// Add Entity and register new active process
 	var isLive bool
 	var err error
@@ -74,6 +82,8 @@ func (s *Scrutinizer) Commit(height int64) {
 		}
 		if isLive {
 			s.addLiveResultsProcess(p.ProcessID)
+			// encrypted processes are computed once all keys are revealed (see OnRevealKeys)
+			s.registerTimeScheduledProcess(p.ProcessID)
 		}
 	}
 
@@ -83,7 +93,7 @@ func (s *Scrutinizer) Commit(height int64) {
 
 	// Add votes collected by onVote (live results)
 	for _, v := range s.votePool {
-		if err = s.addLiveResultsVote(v); err != nil {
+		if err = s.addLiveResultsVote(v, false); err != nil {
 			log.Errorf("cannot add live vote: (%s)", err)
 			continue
 		}
@@ -92,11 +102,19 @@ func (s *Scrutinizer) Commit(height int64) {
 	if nvotes > 0 {
 		log.Infof("added %d live votes from block %d", nvotes, height)
 	}
+
+	// Subtract the votes replaced by onVoteOverwrite (live results)
+	for _, v := range s.overwritePool {
+		if err = s.addLiveResultsVote(v, true); err != nil {
+			log.Errorf("cannot subtract overwritten live vote: (%s)", err)
+		}
+	}
 }
 
 //Rollback removes the non commited pending operations
 func (s *Scrutinizer) Rollback() {
 	s.votePool = []*types.Vote{}
+	s.overwritePool = []*types.Vote{}
 	s.processPool = []*types.ScrutinizerOnProcessData{}
 	s.resultsPool = []*types.ScrutinizerOnProcessData{}
 }
@@ -119,23 +137,60 @@ func (s *Scrutinizer) OnVote(v *types.Vote) {
 	}
 }
 
+// OnVoteOverwrite scrutinizer stores the new vote and the replaced one if liveResults enabled,
+// so the previous vote is subtracted from the results
+func (s *Scrutinizer) OnVoteOverwrite(previous, v *types.Vote) {
+	isLive, err := s.isLiveResultsProcess(v.ProcessID)
+	if err != nil {
+		log.Errorf("cannot check if process is live results: (%s)", err)
+		return
+	}
+	if isLive {
+		s.votePool = append(s.votePool, v)
+		s.overwritePool = append(s.overwritePool, previous)
+	}
+}
+
 // OnCancel scrutinizer stores the processID and entityID
 func (s *Scrutinizer) OnCancel(pid []byte) {
 	// TBD: compute final live results?
 }
 
+// OnPause does nothing, votes are not accepted while the process is paused
+func (s *Scrutinizer) OnPause(pid []byte) {
+	// do nothing
+}
+
+// OnResume does nothing
+func (s *Scrutinizer) OnResume(pid []byte) {
+	// do nothing
+}
+
+// OnCensusUpdate does nothing, the census is not used for computing the results
+func (s *Scrutinizer) OnCensusUpdate(pid []byte, mkroot, mkuri string) {
+	// do nothing
+}
+
 // OnProcessKeys does nothing
 func (s *Scrutinizer) OnProcessKeys(pid []byte, pub, com string) {
 	// do nothing
 }
 
 // OnRevealKeys checks if all keys have been revealed and in such case add the process to the results queue
-func (s *Scrutinizer) OnRevealKeys(pid []byte, pub, com string) {
+func (s *Scrutinizer) OnRevealKeys(pid []byte, priv, rev string) {
 	p, err := s.VochainState.Process(pid, false)
 	if err != nil {
 		log.Errorf("cannot fetch process %s from state: (%s)", pid, err)
 		return
 	}
+	// threshold encrypted processes are computed once the encryption key is recovered
+	if p.IsThresholdEncrypted() {
+		if priv != "" && p.RevealedKeyShares() == int(p.KeyThreshold) {
+			data := types.ScrutinizerOnProcessData{EntityID: p.EntityID, ProcessID: pid}
+			s.resultsPool = append(s.resultsPool, &data)
+		}
+		return
+	}
 	// if all keys have been revealed, compute the results
 	if p.KeyIndex < 1 {
 		data := types.ScrutinizerOnProcessData{EntityID: p.EntityID, ProcessID: pid}
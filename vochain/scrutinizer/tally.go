@@ -0,0 +1,187 @@
+package scrutinizer
+
+import (
+	"fmt"
+	"math/bits"
+
+	"gitlab.com/vocdoni/go-dvote/types"
+)
+
+// tallyStrategy computes the amounts a vote adds to the ProcessVotes results of a process.
+// The votes must be already checked against the process ballot rules.
+type tallyStrategy interface {
+	counts(votes []int, weight uint64) ([]tallyCount, error)
+}
+
+// tallyCount is the amount added by a vote to a position of the results
+type tallyCount struct {
+	question int
+	option   int
+	amount   uint64
+}
+
+// addVote adds the counts of a vote to the results, or subtracts them if subtract is true (used
+// for the overwritten votes). If any result would overflow, the results are not modified.
+func addVote(tally tallyStrategy, pv ProcessVotes, votes []int, weight uint64, subtract bool) error {
+	counts, err := tally.counts(votes, weight)
+	if err != nil {
+		return err
+	}
+	// the same position might be counted several times by a vote
+	results := make(map[[2]int]uint64, len(counts))
+	for _, c := range counts {
+		pos := [2]int{c.question, c.option}
+		if _, ok := results[pos]; !ok {
+			results[pos] = pv[c.question][c.option]
+		}
+		var overflow uint64
+		if subtract {
+			results[pos], overflow = bits.Sub64(results[pos], c.amount, 0)
+		} else {
+			results[pos], overflow = bits.Add64(results[pos], c.amount, 0)
+		}
+		if overflow != 0 {
+			return fmt.Errorf("results overflow on question %d option %d", c.question, c.option)
+		}
+	}
+	for pos, r := range results {
+		pv[pos[0]][pos[1]] = r
+	}
+	return nil
+}
+
+// newTallyStrategy returns the tally strategy of a ballot rules tally mode
+func newTallyStrategy(rules *types.BallotRules) tallyStrategy {
+	switch rules.Mode() {
+	case types.TallyApproval:
+		return approvalTally{}
+	case types.TallyRankedChoice:
+		return rankedChoiceTally{}
+	case types.TallyQuadratic:
+		return quadraticTally{}
+	default:
+		return pluralityTally{}
+	}
+}
+
+// pluralityTally counts the option chosen for each question: [question][option]
+type pluralityTally struct{}
+
+func (pluralityTally) counts(votes []int, weight uint64) ([]tallyCount, error) {
+	counts := make([]tallyCount, 0, len(votes))
+	for question, opt := range votes {
+		counts = append(counts, tallyCount{question, opt, weight})
+	}
+	return counts, nil
+}
+
+// approvalTally counts the approvals of each option: [0][option]
+type approvalTally struct{}
+
+func (approvalTally) counts(votes []int, weight uint64) ([]tallyCount, error) {
+	counts := make([]tallyCount, 0, len(votes))
+	for opt, approved := range votes {
+		if approved == 1 {
+			counts = append(counts, tallyCount{0, opt, weight})
+		}
+	}
+	return counts, nil
+}
+
+// quadraticTally counts the votes given to each option: [0][option]
+type quadraticTally struct{}
+
+func (quadraticTally) counts(votes []int, weight uint64) ([]tallyCount, error) {
+	counts := make([]tallyCount, 0, len(votes))
+	for opt, n := range votes {
+		hi, amount := bits.Mul64(uint64(n), weight)
+		if hi != 0 {
+			return nil, fmt.Errorf("vote weight overflow on option %d", opt)
+		}
+		counts = append(counts, tallyCount{0, opt, amount})
+	}
+	return counts, nil
+}
+
+// rankedChoiceTally counts the position in which each option is ranked: [position][option].
+// The winner is computed with instantRunoff once the process is finished.
+type rankedChoiceTally struct{}
+
+func (rankedChoiceTally) counts(votes []int, weight uint64) ([]tallyCount, error) {
+	counts := make([]tallyCount, 0, len(votes))
+	for position, opt := range votes {
+		counts = append(counts, tallyCount{position, opt, weight})
+	}
+	return counts, nil
+}
+
+// rankedBallot is a ranked-choice vote with its weight. The sum of the weights of all the ballots
+// must fit on an uint64 (see ComputeResult).
+type rankedBallot struct {
+	preferences []int
+	weight      uint64
+}
+
+// instantRunoff computes the rounds of a ranked-choice process with the given number of options.
+// On each round the votes are counted for the preferred option not yet eliminated. If an option
+// has more than half of the votes it wins, otherwise the option with less votes is eliminated.
+// If all the remaining options have the same votes there is no winner.
+// Ties for the elimination are broken deterministically: the option with less votes on the
+// previous rounds is eliminated, comparing from the most recent round backwards (see
+// fewerVotesBefore), and if the options had the same votes on every round, the lowest one.
+func instantRunoff(ballots []rankedBallot, options int) *types.RankedChoiceResults {
+	results := &types.RankedChoiceResults{Winner: -1, Eliminated: []int{}}
+	eliminated := make([]bool, options)
+	for {
+		round := make([]uint64, options)
+		var total uint64
+		for _, b := range ballots {
+			for _, opt := range b.preferences {
+				if !eliminated[opt] {
+					round[opt] += b.weight
+					total += b.weight
+					break
+				}
+			}
+		}
+		results.Rounds = append(results.Rounds, round)
+		if total == 0 {
+			return results
+		}
+		for opt, votes := range round {
+			if votes*2 > total {
+				results.Winner = opt
+				return results
+			}
+		}
+		lowest, tie := -1, true
+		for opt, votes := range round {
+			if eliminated[opt] {
+				continue
+			}
+			if lowest >= 0 && votes != round[lowest] {
+				tie = false
+			}
+			if lowest < 0 || votes < round[lowest] ||
+				(votes == round[lowest] && fewerVotesBefore(results.Rounds, opt, lowest)) {
+				lowest = opt
+			}
+		}
+		if tie {
+			return results
+		}
+		eliminated[lowest] = true
+		results.Eliminated = append(results.Eliminated, lowest)
+	}
+}
+
+// fewerVotesBefore returns true if option a had less votes than option b on the latest round
+// before the current one (the last of rounds) in which their votes differ
+func fewerVotesBefore(rounds [][]uint64, a, b int) bool {
+	for i := len(rounds) - 2; i >= 0; i-- {
+		if rounds[i][a] != rounds[i][b] {
+			return rounds[i][a] < rounds[i][b]
+		}
+	}
+	return false
+}
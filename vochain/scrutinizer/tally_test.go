@@ -0,0 +1,157 @@
+package scrutinizer
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"gitlab.com/vocdoni/go-dvote/types"
+)
+
+func TestTallyStrategies(t *testing.T) {
+	for _, tc := range []struct {
+		mode   string
+		votes  [][]int
+		weight uint64
+		want   [][]uint64
+	}{
+		{types.TallyPlurality, [][]int{{0, 1}, {2, 1}}, 1, [][]uint64{{1, 0, 1}, {0, 2}}},
+		{types.TallyApproval, [][]int{{1, 0, 1}, {1, 1, 0}}, 2, [][]uint64{{4, 2, 2}}},
+		{types.TallyQuadratic, [][]int{{3, 0, 1}, {0, 2, 2}}, 1, [][]uint64{{3, 2, 3}}},
+		{types.TallyRankedChoice, [][]int{{2, 0}, {2, 1, 0}}, 1, [][]uint64{{0, 0, 2}, {1, 1}, {1}}},
+	} {
+		pv := emptyProcess()
+		tally := newTallyStrategy(&types.BallotRules{TallyMode: tc.mode})
+		for _, v := range tc.votes {
+			if err := addVote(tally, pv, v, tc.weight, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+		pruneVoteResult(&pv)
+		if fmt.Sprint(pv) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: expected results %v, got %v", tc.mode, tc.want, pv)
+		}
+	}
+}
+
+func TestInstantRunoff(t *testing.T) {
+	ballots := []rankedBallot{
+		{preferences: []int{0, 1}, weight: 4},
+		{preferences: []int{1, 0}, weight: 3},
+		{preferences: []int{2, 1}, weight: 2},
+		{preferences: []int{3}, weight: 1},
+	}
+	// round 1: 4 3 2 1 (option 3 eliminated)
+	// round 2: 4 3 2 0 (option 2 eliminated)
+	// round 3: 4 5 0 0 (option 1 wins)
+	r := instantRunoff(ballots, 4)
+	if r.Winner != 1 {
+		t.Errorf("expected winner 1, got %d (rounds %v)", r.Winner, r.Rounds)
+	}
+	if len(r.Rounds) != 3 || fmt.Sprint(r.Eliminated) != "[3 2]" {
+		t.Errorf("unexpected rounds %v, eliminated %v", r.Rounds, r.Eliminated)
+	}
+
+	// tie for the elimination on the first round, the lowest option is eliminated
+	// round 1: 3 2 2 (option 1 eliminated)
+	// round 2: 3 0 4 (option 2 wins)
+	r = instantRunoff([]rankedBallot{
+		{preferences: []int{0}, weight: 3},
+		{preferences: []int{1, 2}, weight: 2},
+		{preferences: []int{2, 0}, weight: 2},
+	}, 3)
+	if r.Winner != 2 || fmt.Sprint(r.Eliminated) != "[1]" {
+		t.Errorf("expected winner 2 and option 1 eliminated, got %d and %v (rounds %v)", r.Winner, r.Eliminated, r.Rounds)
+	}
+	// tie for the elimination broken by the previous round votes
+	// round 1: 5 4 3 2 (option 3 eliminated)
+	// round 2: 6 4 4 0 (option 2 eliminated, it had less votes on round 1)
+	// round 3: 6 4 0 0 (option 0 wins)
+	r = instantRunoff([]rankedBallot{
+		{preferences: []int{0}, weight: 5},
+		{preferences: []int{1}, weight: 4},
+		{preferences: []int{2}, weight: 3},
+		{preferences: []int{3, 2}, weight: 1},
+		{preferences: []int{3, 0}, weight: 1},
+	}, 4)
+	if r.Winner != 0 || fmt.Sprint(r.Eliminated) != "[3 2]" {
+		t.Errorf("expected winner 0 and options 3 and 2 eliminated, got %d and %v (rounds %v)", r.Winner, r.Eliminated, r.Rounds)
+	}
+
+	// tie between the two remaining options
+	r = instantRunoff([]rankedBallot{
+		{preferences: []int{0}, weight: 1},
+		{preferences: []int{1}, weight: 1},
+	}, 2)
+	if r.Winner != -1 {
+		t.Errorf("expected a tie, got winner %d", r.Winner)
+	}
+
+	// no votes
+	if r = instantRunoff(nil, 3); r.Winner != -1 {
+		t.Errorf("expected no winner without votes, got %d", r.Winner)
+	}
+}
+
+func TestTallySubtract(t *testing.T) {
+	pv := emptyProcess()
+	tally := newTallyStrategy(&types.BallotRules{TallyMode: types.TallyQuadratic})
+	weight := uint64(3)
+	for _, v := range []struct {
+		votes    []int
+		subtract bool
+	}{
+		{[]int{2, 1}, false},
+		{[]int{0, 3}, false},
+		// an overwritten vote is subtracted from the results
+		{[]int{2, 1}, true},
+	} {
+		if err := addVote(tally, pv, v.votes, weight, v.subtract); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pruneVoteResult(&pv)
+	if fmt.Sprint(pv) != "[[0 9]]" {
+		t.Errorf("expected results [[0 9]], got %v", pv)
+	}
+
+	// subtracting a vote undoes its addition on every tally mode
+	for _, mode := range []string{types.TallyPlurality, types.TallyApproval, types.TallyQuadratic, types.TallyRankedChoice} {
+		pv := emptyProcess()
+		tally := newTallyStrategy(&types.BallotRules{TallyMode: mode})
+		if err := addVote(tally, pv, []int{1, 0, 1}, 5, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := addVote(tally, pv, []int{1, 0, 1}, 5, true); err != nil {
+			t.Fatal(err)
+		}
+		pruneVoteResult(&pv)
+		if len(pv) != 0 {
+			t.Errorf("%s: expected empty results, got %v", mode, pv)
+		}
+	}
+}
+
+func TestTallyOverflow(t *testing.T) {
+	pv := emptyProcess()
+	tally := newTallyStrategy(&types.BallotRules{TallyMode: types.TallyPlurality})
+	if err := addVote(tally, pv, []int{0, 1}, math.MaxUint64-1, false); err != nil {
+		t.Fatal(err)
+	}
+	// a vote which overflows any result is not counted at all
+	if err := addVote(tally, pv, []int{1, 1}, 2, false); err == nil {
+		t.Fatal("results overflow not detected")
+	}
+	if pv[0][1] != 0 || pv[1][1] != math.MaxUint64-1 {
+		t.Errorf("overflowing vote modified the results: %v %v", pv[0][:2], pv[1][:2])
+	}
+	// subtracting a vote which was not counted
+	if err := addVote(tally, pv, []int{1, 1}, 1, true); err == nil {
+		t.Fatal("results underflow not detected")
+	}
+
+	tally = newTallyStrategy(&types.BallotRules{TallyMode: types.TallyQuadratic})
+	if err := addVote(tally, emptyProcess(), []int{3}, math.MaxUint64/2, false); err == nil {
+		t.Fatal("quadratic vote weight overflow not detected")
+	}
+}
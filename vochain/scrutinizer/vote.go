@@ -4,10 +4,13 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/bits"
 
 	"github.com/dgraph-io/badger/v2"
 
+	"gitlab.com/vocdoni/go-dvote/crypto"
 	"gitlab.com/vocdoni/go-dvote/crypto/nacl"
+	"gitlab.com/vocdoni/go-dvote/crypto/threshold"
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/types"
 )
@@ -15,11 +18,20 @@ import (
 // ErrNoResultsYet is an error returned to indicate the process exist but it does not have yet reuslts
 var ErrNoResultsYet = fmt.Errorf("no results yet")
 
+// decodeEncryptionKey decodes a revealed encryption private key of a process. The threshold
+// encryption key is a scalar which cannot be used as a regular nacl key (see crypto/threshold).
+func decodeEncryptionKey(p *types.Process, index int) (crypto.Cipher, error) {
+	if p.IsThresholdEncrypted() && index == types.ThresholdKeyIndex {
+		return threshold.DecodePrivate(p.EncryptionPrivateKeys[index])
+	}
+	return nacl.DecodePrivate(p.EncryptionPrivateKeys[index])
+}
+
 // unmarshalVote decodes the base64 payload to a VotePackage struct type.
 // If the votePackage is encrypted the list of keys to decrypt it should be provided.
 // The order of the Keys must be as it was encrypted.
 // The function will reverse the order and use the decryption keys starting from the last one provided.
-func unmarshalVote(votePackage string, keys []string) (*types.VotePackage, error) {
+func unmarshalVote(votePackage string, keys []crypto.Cipher) (*types.VotePackage, error) {
 	rawVote, err := base64.StdEncoding.DecodeString(votePackage)
 	if err != nil {
 		return nil, err
@@ -28,12 +40,7 @@ func unmarshalVote(votePackage string, keys []string) (*types.VotePackage, error
 	// if encryption keys, decrypt the vote
 	if len(keys) > 0 {
 		for i := len(keys) - 1; i >= 0; i-- {
-			priv, err := nacl.DecodePrivate(keys[i])
-			if err != nil {
-				log.Warnf("cannot create private key cipher: (%s)", err)
-				continue
-			}
-			if rawVote, err = priv.Decrypt(rawVote); err != nil {
+			if rawVote, err = keys[i].Decrypt(rawVote); err != nil {
 				log.Warnf("cannot decrypt vote with index key %d", i)
 			}
 		}
@@ -44,17 +51,23 @@ func unmarshalVote(votePackage string, keys []string) (*types.VotePackage, error
 	return &vote, nil
 }
 
-func (s *Scrutinizer) addLiveResultsVote(envelope *types.Vote) error {
+// addLiveResultsVote counts a vote on the live results of its process.
+// If subtract is true, the vote is discounted instead (used for overwritten votes).
+func (s *Scrutinizer) addLiveResultsVote(envelope *types.Vote, subtract bool) error {
 	pid := envelope.ProcessID
 	if pid == nil {
 		return fmt.Errorf("cannot find process for envelope")
 	}
-	vote, err := unmarshalVote(envelope.VotePackage, []string{})
+	vote, err := unmarshalVote(envelope.VotePackage, nil)
 	if err != nil {
 		return err
 	}
-	if len(vote.Votes) > MaxQuestions {
-		return fmt.Errorf("too many questions on addVote")
+	p, err := s.ProcessInfo(pid)
+	if err != nil {
+		return fmt.Errorf("cannot get process %x: (%s)", pid, err)
+	}
+	if err := p.Ballot().CheckVotes(vote.Votes); err != nil {
+		return fmt.Errorf("invalid vote on process %x, skipping addVote: (%s)", pid, err)
 	}
 
 	process, err := s.Storage.Get(s.encode("liveProcess", pid))
@@ -67,12 +80,8 @@ func (s *Scrutinizer) addLiveResultsVote(envelope *types.Vote) error {
 		return fmt.Errorf("cannot unmarshal vote (%s)", err)
 	}
 
-	for question, opt := range vote.Votes {
-		if opt > MaxOptions {
-			log.Warn("option overflow on addVote")
-			continue
-		}
-		pv[question][opt]++
+	if err := addVote(newTallyStrategy(p.Ballot()), pv, vote.Votes, envelope.TallyWeight(), subtract); err != nil {
+		return fmt.Errorf("cannot count vote on process %x: (%s)", pid, err)
 	}
 
 	process, err = s.VochainState.Codec.MarshalBinaryBare(pv)
@@ -84,7 +93,7 @@ func (s *Scrutinizer) addLiveResultsVote(envelope *types.Vote) error {
 		return err
 	}
 
-	log.Debugf("addVote on process %x", pid)
+	log.Debugf("addVote on process %x (subtract: %t)", pid, subtract)
 	return nil
 }
 
@@ -107,26 +116,40 @@ func (s *Scrutinizer) ComputeResult(processID []byte) error {
 	}
 
 	// Compute the results
-	// If poll-vote, results have been computed during their arrival
+	// If poll-vote, results have been computed during their arrival.
+	// Ranked-choice requires the whole ballots, so the votes are always counted again.
 	isLive, err := s.isLiveResultsProcess(processID)
 	if err != nil {
 		return err
 	}
+	isRanked := p.Ballot().Mode() == types.TallyRankedChoice
 	var pv ProcessVotes
-	if isLive {
+	var ranked *types.RankedChoiceResults
+	if isLive && !isRanked {
 		if pv, err = s.computeLiveResults(processID); err != nil {
 			return err
 		}
+	} else {
+		if pv, ranked, err = s.computeNonLiveResults(processID, p); err != nil {
+			return err
+		}
+	}
+	if isLive {
 		// Delete liveResults temporary storage
 		if err = s.Storage.Del(s.encode("liveProcess", processID)); err != nil {
 			return err
 		}
-	} else {
-		if pv, err = s.computeNonLiveResults(processID, p); err != nil {
+	}
+
+	if ranked != nil {
+		rankedResult, err := s.VochainState.Codec.MarshalBinaryBare(ranked)
+		if err != nil {
+			return err
+		}
+		if err := s.Storage.Put(s.encode("rankedResults", processID), rankedResult); err != nil {
 			return err
 		}
 	}
-
 	result, err := s.VochainState.Codec.MarshalBinaryBare(pv)
 	if err != nil {
 		return err
@@ -135,6 +158,22 @@ func (s *Scrutinizer) ComputeResult(processID []byte) error {
 	return s.Storage.Put(s.encode("results", processID), result)
 }
 
+// RankedChoiceResult returns the instant runoff results of a finished ranked-choice process
+func (s *Scrutinizer) RankedChoiceResult(processID []byte) (*types.RankedChoiceResults, error) {
+	rankedBytes, err := s.Storage.Get(s.encode("rankedResults", processID))
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrNoResultsYet
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ranked types.RankedChoiceResults
+	if err := s.VochainState.Codec.UnmarshalBinaryBare(rankedBytes, &ranked); err != nil {
+		return nil, err
+	}
+	return &ranked, nil
+}
+
 // VoteResult returns the current result for a processId summarized in a two dimension int slice
 func (s *Scrutinizer) VoteResult(processID []byte) (ProcessVotes, error) {
 	// Check if process exist
@@ -184,9 +223,15 @@ func (s *Scrutinizer) computeLiveResults(processID []byte) (pv ProcessVotes, err
 	return
 }
 
-func (s *Scrutinizer) computeNonLiveResults(processID []byte, p *types.Process) (pv ProcessVotes, err error) {
+// computeNonLiveResults counts all the envelopes of a process. For ranked-choice processes
+// it also computes the instant runoff results.
+func (s *Scrutinizer) computeNonLiveResults(processID []byte, p *types.Process) (pv ProcessVotes, ranked *types.RankedChoiceResults, err error) {
 	pv = emptyProcess()
-	var nvotes int
+	var nvotes, invalid int
+	rules := p.Ballot()
+	tally := newTallyStrategy(rules)
+	var ballots []rankedBallot
+	var rankedWeight uint64
 	for _, e := range s.VochainState.EnvelopeList(processID, 0, 32<<18, false) { // 8.3M seems enough for now
 		v, err := s.VochainState.Envelope(processID, e, false)
 		if err != nil {
@@ -199,13 +244,18 @@ func (s *Scrutinizer) computeNonLiveResults(processID []byte, p *types.Process)
 			if len(p.EncryptionPrivateKeys) < len(v.EncryptionKeyIndexes) {
 				err = fmt.Errorf("encryptionKeyIndexes has too many fields")
 			} else {
-				keys := []string{}
+				keys := []crypto.Cipher{}
 				for _, k := range v.EncryptionKeyIndexes {
-					if k >= types.MaxKeyIndex {
+					if k < 0 || k >= types.MaxKeyIndex {
 						err = fmt.Errorf("key index overflow")
 						break
 					}
-					keys = append(keys, p.EncryptionPrivateKeys[k])
+					var key crypto.Cipher
+					if key, err = decodeEncryptionKey(p, k); err != nil {
+						err = fmt.Errorf("cannot create private key cipher: (%s)", err)
+						break
+					}
+					keys = append(keys, key)
 				}
 				if len(keys) == 0 || err != nil {
 					err = fmt.Errorf("no keys provided or wrong index")
@@ -214,23 +264,40 @@ func (s *Scrutinizer) computeNonLiveResults(processID []byte, p *types.Process)
 				}
 			}
 		} else {
-			vp, err = unmarshalVote(v.VotePackage, []string{})
+			vp, err = unmarshalVote(v.VotePackage, nil)
+		}
+		if err == nil {
+			// encrypted votes can only be checked once decrypted
+			err = rules.CheckVotes(vp.Votes)
 		}
 		if err != nil {
-			log.Warn(err)
+			log.Warnf("skipping vote %x: %s", e, err)
+			invalid++
 			continue
 		}
-		for question, opt := range vp.Votes {
-			if opt > MaxOptions {
-				log.Warn("option overflow on computeResult, skipping vote...")
-				continue
-			}
-			pv[question][opt]++
+		// the instant runoff rounds add the weights of all the ballots
+		total, overflow := bits.Add64(rankedWeight, v.TallyWeight(), 0)
+		if rules.Mode() == types.TallyRankedChoice && overflow != 0 {
+			log.Errorf("skipping vote %x: ranked-choice weights overflow", e)
+			invalid++
+			continue
+		}
+		if err := addVote(tally, pv, vp.Votes, v.TallyWeight(), false); err != nil {
+			log.Errorf("skipping vote %x: %s", e, err)
+			invalid++
+			continue
+		}
+		if rules.Mode() == types.TallyRankedChoice {
+			rankedWeight = total
+			ballots = append(ballots, rankedBallot{preferences: vp.Votes, weight: v.TallyWeight()})
 		}
 		nvotes++
 	}
+	if rules.Mode() == types.TallyRankedChoice {
+		ranked = instantRunoff(ballots, int(rules.MaxValue)+1)
+	}
 	pruneVoteResult(&pv)
-	log.Infof("computed results for process %x with %d votes", processID, nvotes)
+	log.Infof("computed results for process %x with %d votes (%d invalid)", processID, nvotes, invalid)
 	return
 }
 
@@ -252,7 +319,7 @@ func pruneVoteResult(pv *ProcessVotes) {
 	}
 
 	for i := 0; i <= min; i++ { // copy the options for each question but pruning options too
-		pvc = make([][]uint32, i+1)
+		pvc = make([][]uint64, i+1)
 		for i2 := 0; i2 <= i; i2++ { // copy only the first non-zero values
 			j2 := MaxOptions - 1
 			for ; j2 >= 0; j2-- {
@@ -260,7 +327,7 @@ func pruneVoteResult(pv *ProcessVotes) {
 					break
 				}
 			}
-			pvc[i2] = make([]uint32, j2+1)
+			pvc[i2] = make([]uint64, j2+1)
 			copy(pvc[i2], pvv[i2])
 		}
 	}
@@ -0,0 +1,576 @@
+package vochain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	amino "github.com/tendermint/go-amino"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+	tmstate "github.com/tendermint/tendermint/state"
+	tmstore "github.com/tendermint/tendermint/store"
+	tmtypes "github.com/tendermint/tendermint/types"
+	tmversion "github.com/tendermint/tendermint/version"
+	tmdb "github.com/tendermint/tm-db"
+
+	"gitlab.com/vocdoni/go-dvote/config"
+	"gitlab.com/vocdoni/go-dvote/data"
+	"gitlab.com/vocdoni/go-dvote/log"
+	"gitlab.com/vocdoni/go-dvote/statedb"
+	"gitlab.com/vocdoni/go-dvote/statedb/gravitonstate"
+	"gitlab.com/vocdoni/go-dvote/util"
+)
+
+const (
+	// SnapshotsToKeep is the number of snapshots kept on disk, the older ones are removed
+	SnapshotsToKeep = 3
+	// SnapshotRetrieveTimeout is the maximum time to retrieve a snapshot from the remote storage
+	SnapshotRetrieveTimeout = 10 * time.Minute
+
+	snapshotFilePrefix = "snapshot-"
+	snapshotFileSuffix = ".gz"
+)
+
+// StateSnapshot is a copy of all the vochain state trees after committing a block.
+// It does not contain the Tendermint blocks nor the Tendermint state.
+type StateSnapshot struct {
+	// Height is the height of the last block executed on the snapshot state
+	Height int64
+	// AppHash is the hash returned by Commit for Height, included in the header of the next block
+	AppHash []byte
+	Trees   []SnapshotTree
+}
+
+// SnapshotTree contains all the keys and values of a state tree
+type SnapshotTree struct {
+	Name   string
+	Keys   [][]byte
+	Values [][]byte
+}
+
+// snapshotSource holds the immutable trees of a committed state version, so the snapshot
+// can be copied in background while the next blocks are executed
+type snapshotSource struct {
+	height  int64
+	appHash []byte
+	trees   map[string]statedb.StateTree
+}
+
+// snapshotSource returns the roots of the last committed state trees. It must be called after
+// Save and before the next block starts, otherwise the app hash would not match the trees.
+// Only the graviton backend is supported: the iavl tree hashes include the version in
+// which each node was written, so a restored iavl state would never match the app hash.
+func (v *State) snapshotSource() (*snapshotSource, error) {
+	if _, ok := v.Store.(*gravitonstate.GravitonState); !ok {
+		return nil, fmt.Errorf("snapshots require the %s state backend", StateBackendGraviton)
+	}
+	header := v.Header(true)
+	if header == nil {
+		return nil, fmt.Errorf("cannot get state height")
+	}
+	src := &snapshotSource{height: header.Height, trees: make(map[string]statedb.StateTree)}
+	v.RLock()
+	defer v.RUnlock()
+	src.appHash = v.Store.Hash()
+	for _, name := range []string{AppTree, ProcessTree, VoteTree} {
+		// a new tree instance loaded from the committed root, it is not shared with the
+		// state queries and does not change when the next versions are committed
+		t := v.Store.TreeWithRoot(v.Store.ImmutableTree(name).Hash())
+		if t == nil {
+			return nil, fmt.Errorf("cannot load committed %s tree", name)
+		}
+		src.trees[name] = t
+	}
+	return src, nil
+}
+
+// copy iterates the source trees and returns their keys and values
+func (src *snapshotSource) copy() *StateSnapshot {
+	s := &StateSnapshot{Height: src.height, AppHash: src.appHash}
+	for _, name := range []string{AppTree, ProcessTree, VoteTree} {
+		st := SnapshotTree{Name: name}
+		src.trees[name].Iterate(nil, func(key, value []byte) bool {
+			// keys and values might be reused by the iterator
+			st.Keys = append(st.Keys, append([]byte{}, key...))
+			st.Values = append(st.Values, append([]byte{}, value...))
+			return false
+		})
+		s.Trees = append(s.Trees, st)
+	}
+	return s
+}
+
+// Snapshot returns a snapshot of the last committed state, see snapshotSource.
+// The blocks are not included: a node restoring the snapshot needs the block store
+// up to the snapshot height (see BootstrapFromSnapshot).
+func (v *State) Snapshot() (*StateSnapshot, error) {
+	src, err := v.snapshotSource()
+	if err != nil {
+		return nil, err
+	}
+	return src.copy(), nil
+}
+
+// Marshal encodes the snapshot as a gzip compressed amino binary
+func (s *StateSnapshot) Marshal() ([]byte, error) {
+	raw, err := amino.NewCodec().MarshalBinaryBare(s)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalSnapshot decodes a snapshot encoded with StateSnapshot.Marshal
+func UnmarshalSnapshot(b []byte) (*StateSnapshot, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress snapshot: (%s)", err)
+	}
+	raw, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress snapshot: (%s)", err)
+	}
+	var s StateSnapshot
+	if err := amino.NewCodec().UnmarshalBinaryBare(raw, &s); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal snapshot: (%s)", err)
+	}
+	return &s, nil
+}
+
+// SaveSnapshot writes the snapshot to dir as snapshot-<height>.gz and removes
+// the older snapshots, keeping the last SnapshotsToKeep
+func SaveSnapshot(dir string, s *StateSnapshot) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	b, err := s.Marshal()
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal snapshot: (%s)", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s%d%s", snapshotFilePrefix, s.Height, snapshotFileSuffix))
+	// write to a temporary file first, so a partial snapshot is never found on dir
+	if err := ioutil.WriteFile(path+".tmp", b, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return "", err
+	}
+	heights, err := ListSnapshots(dir)
+	if err != nil {
+		return path, err
+	}
+	for i := 0; i < len(heights)-SnapshotsToKeep; i++ {
+		old := filepath.Join(dir, fmt.Sprintf("%s%d%s", snapshotFilePrefix, heights[i], snapshotFileSuffix))
+		if err := os.Remove(old); err != nil {
+			log.Warnf("cannot remove old snapshot %s: (%s)", old, err)
+		}
+	}
+	return path, nil
+}
+
+// ListSnapshots returns the heights of the snapshots stored on dir in ascending order
+func ListSnapshots(dir string) ([]int64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	heights := []int64{}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, snapshotFilePrefix) || !strings.HasSuffix(name, snapshotFileSuffix) {
+			continue
+		}
+		h, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, snapshotFilePrefix), snapshotFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		heights = append(heights, h)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+// LoadSnapshot reads a snapshot from a local file or, if uri has the storage URI prefix
+// (e.g. ipfs://), from the remote storage. storage can be nil for local files.
+func LoadSnapshot(uri string, storage data.Storage) (*StateSnapshot, error) {
+	var b []byte
+	var err error
+	if storage != nil && strings.HasPrefix(uri, storage.URIprefix()) {
+		ctx, cancel := context.WithTimeout(context.Background(), SnapshotRetrieveTimeout)
+		defer cancel()
+		b, err = storage.Retrieve(ctx, uri[len(storage.URIprefix()):])
+	} else if strings.Contains(uri, "://") {
+		return nil, fmt.Errorf("no remote storage available to retrieve %s", uri)
+	} else {
+		b, err = ioutil.ReadFile(uri)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot retrieve snapshot: (%s)", err)
+	}
+	return UnmarshalSnapshot(b)
+}
+
+// RestoreSnapshot imports the snapshot state into the empty graviton state database of dataDir.
+// The snapshot must match the trusted height and app hash, which must be obtained from a
+// trusted source (e.g. the AppHash of the header of block trustedHeight+1). The state is
+// verified in memory before writing anything to dataDir.
+func RestoreSnapshot(dataDir string, s *StateSnapshot, trustedHeight int64, trustedHash []byte) error {
+	if s.Height != trustedHeight {
+		return fmt.Errorf("snapshot height %d does not match the trusted height %d", s.Height, trustedHeight)
+	}
+	if !bytes.Equal(s.AppHash, trustedHash) {
+		return fmt.Errorf("snapshot app hash %x does not match the trusted app hash %x", s.AppHash, trustedHash)
+	}
+	// verify the snapshot on a memory database
+	mem, err := openStateDB("", StateBackendGraviton, "mem")
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+	if err := mem.LoadVersion(0); err != nil {
+		return err
+	}
+	if err := importSnapshot(mem, s); err != nil {
+		return err
+	}
+	hash, err := mem.Commit()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, trustedHash) {
+		return fmt.Errorf("snapshot state app hash %x does not match the trusted app hash %x", hash, trustedHash)
+	}
+
+	store, err := OpenStateDB(dataDir, StateBackendGraviton)
+	if err != nil {
+		return fmt.Errorf("cannot open state: (%s)", err)
+	}
+	defer store.Close()
+	if err := store.LoadVersion(0); err != nil {
+		return fmt.Errorf("cannot load state: (%s)", err)
+	}
+	for _, name := range []string{AppTree, ProcessTree, VoteTree} {
+		if store.Tree(name).Count() > 0 {
+			return fmt.Errorf("state is not empty")
+		}
+	}
+	if err := importSnapshot(store, s); err != nil {
+		return err
+	}
+	// The state is committed twice, so loading the previous version on startup
+	// (see NewStateWithBackend) also returns the snapshot state.
+	for i := 0; i < 2; i++ {
+		if hash, err = store.Commit(); err != nil {
+			return fmt.Errorf("cannot commit state: (%s)", err)
+		}
+	}
+	if !bytes.Equal(hash, trustedHash) {
+		return fmt.Errorf("restored state app hash %x does not match the trusted app hash %x", hash, trustedHash)
+	}
+	return nil
+}
+
+// importSnapshot adds the snapshot trees to the working state of store
+func importSnapshot(store statedb.StateDB, s *StateSnapshot) error {
+	if len(s.Trees) != 3 {
+		return fmt.Errorf("snapshot has %d trees, expected 3", len(s.Trees))
+	}
+	for _, st := range s.Trees {
+		if st.Name != AppTree && st.Name != ProcessTree && st.Name != VoteTree {
+			return fmt.Errorf("unknown snapshot tree %s", st.Name)
+		}
+		if len(st.Keys) != len(st.Values) {
+			return fmt.Errorf("snapshot tree %s has %d keys and %d values", st.Name, len(st.Keys), len(st.Values))
+		}
+		t := store.Tree(st.Name)
+		for i := range st.Keys {
+			if err := t.Add(st.Keys[i], st.Values[i]); err != nil {
+				return fmt.Errorf("cannot import snapshot tree %s: (%s)", st.Name, err)
+			}
+		}
+	}
+	// the header height must match the snapshot height
+	cdc := amino.NewCodec()
+	RegisterAmino(cdc)
+	var header tmtypes.Header
+	if err := cdc.UnmarshalBinaryBare(store.Tree(AppTree).Get(headerKey), &header); err != nil {
+		return fmt.Errorf("cannot get snapshot state height: (%s)", err)
+	}
+	if header.Height != s.Height {
+		return fmt.Errorf("snapshot state is at height %d, expected %d", header.Height, s.Height)
+	}
+	return nil
+}
+
+// BootstrapFromSnapshot restores the vochain state from the snapshot configured on
+// vochaincfg.SnapshotBootstrap (a local file or a remote storage URI) if the state is empty.
+// Only the graviton state backend is supported (see snapshotSource).
+//
+// Snapshots do not include the blocks and Tendermint v0.33 has no state sync, so the node
+// block store is obtained in one of these ways:
+//   - it already contains the blocks up to the snapshot height (e.g. copied from another node),
+//     Tendermint replays the blocks after the snapshot height on startup
+//   - it is empty and vochaincfg.SnapshotTrustedRPC is set, the block at the snapshot height and
+//     the Tendermint state are fetched from that node and verified against the trusted app hash
+//     (see bootstrapBlockStore), so Tendermint starts from the snapshot height
+//
+// In both cases the node then continues with the block sync.
+func BootstrapFromSnapshot(vochaincfg *config.VochainCfg, storage data.Storage) error {
+	if vochaincfg.SnapshotBootstrap == "" {
+		return nil
+	}
+	if err := vochaincfg.ValidSnapshots(); err != nil {
+		return err
+	}
+	dataDir := vochaincfg.DataDir + "/data"
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	store, err := OpenStateDB(dataDir, StateBackendGraviton)
+	if err != nil {
+		return fmt.Errorf("cannot open state: (%s)", err)
+	}
+	err = store.LoadVersion(0)
+	empty := err == nil && store.Tree(AppTree).Get(headerKey) == nil
+	store.Close()
+	if err != nil {
+		return fmt.Errorf("cannot load state: (%s)", err)
+	}
+	if !empty {
+		log.Infof("vochain state already initialized, skipping snapshot bootstrap")
+		return nil
+	}
+
+	trustedHash, err := hex.DecodeString(util.TrimHex(vochaincfg.SnapshotTrustedHash))
+	if err != nil || len(trustedHash) == 0 {
+		return fmt.Errorf("snapshot bootstrap requires a trusted height and app hash")
+	}
+	bsdb, err := tmdb.NewGoLevelDB("blockstore", dataDir)
+	if err != nil {
+		return fmt.Errorf("cannot open block store: (%s)", err)
+	}
+	storeHeight := tmstore.NewBlockStore(bsdb).Height()
+	bsdb.Close()
+	var blocks *trustedBlocks
+	switch {
+	case storeHeight >= vochaincfg.SnapshotTrustedHeight:
+	case storeHeight == 0 && vochaincfg.SnapshotTrustedRPC != "":
+		// fetch and verify the blocks before restoring the state, so a failure leaves the node empty
+		log.Infof("fetching block %d from %s", vochaincfg.SnapshotTrustedHeight, vochaincfg.SnapshotTrustedRPC)
+		if blocks, err = fetchTrustedBlocks(vochaincfg.SnapshotTrustedRPC,
+			vochaincfg.SnapshotTrustedHeight, trustedHash); err != nil {
+			return fmt.Errorf("cannot fetch snapshot blocks: (%s)", err)
+		}
+	default:
+		return fmt.Errorf("block store is at height %d, the snapshot at height %d requires the blocks "+
+			"up to its height or an empty block store and a trusted RPC endpoint",
+			storeHeight, vochaincfg.SnapshotTrustedHeight)
+	}
+
+	log.Infof("loading vochain snapshot from %s", vochaincfg.SnapshotBootstrap)
+	s, err := LoadSnapshot(vochaincfg.SnapshotBootstrap, storage)
+	if err != nil {
+		return err
+	}
+	if err := RestoreSnapshot(dataDir, s, vochaincfg.SnapshotTrustedHeight, trustedHash); err != nil {
+		return fmt.Errorf("cannot restore snapshot: (%s)", err)
+	}
+	if blocks != nil {
+		if err := bootstrapBlockStore(dataDir, blocks); err != nil {
+			return fmt.Errorf("cannot bootstrap block store: (%s)", err)
+		}
+		log.Infof("block store bootstrapped at height %d", blocks.block.Height)
+	}
+	log.Infof("vochain state restored from snapshot at height %d with app hash %x", s.Height, s.AppHash)
+	return nil
+}
+
+// trustedBlocks holds the Tendermint data needed to start a node from a snapshot height
+type trustedBlocks struct {
+	block *tmtypes.Block
+	// next is the header of the block after the snapshot, it includes the snapshot app hash
+	next *tmtypes.Header
+	// nextCommit is the commit of block, included as last commit in the next block
+	nextCommit     *tmtypes.Commit
+	lastValidators *tmtypes.ValidatorSet
+	validators     *tmtypes.ValidatorSet
+	nextValidators *tmtypes.ValidatorSet
+	params         tmtypes.ConsensusParams
+}
+
+// fetchTrustedBlocks gets the blocks at height and height+1 and the validator sets from the
+// Tendermint RPC endpoint of a trusted node. Everything is verified against the hashes of the
+// next block header, which must include the trusted app hash and be signed by more than 2/3
+// of its validators. The validators proposer priorities cannot be verified, so the endpoint
+// must be trusted.
+func fetchTrustedBlocks(endpoint string, height int64, trustedHash []byte) (*trustedBlocks, error) {
+	c, err := rpchttp.New(endpoint, "/websocket")
+	if err != nil {
+		return nil, err
+	}
+	next := height + 1
+	commit, err := c.Commit(&next)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get commit %d: (%s)", next, err)
+	}
+	if commit.SignedHeader.Commit == nil {
+		return nil, fmt.Errorf("no commit for height %d", next)
+	}
+	res, err := c.Block(&next)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get block %d: (%s)", next, err)
+	}
+	if res.Block == nil || res.Block.Height != next || res.Block.LastCommit == nil {
+		return nil, fmt.Errorf("wrong block %d", next)
+	}
+	tb := &trustedBlocks{next: &res.Block.Header, nextCommit: res.Block.LastCommit}
+	if !bytes.Equal(tb.next.AppHash, trustedHash) {
+		return nil, fmt.Errorf("app hash %x of block %d does not match the trusted app hash %x", tb.next.AppHash, next, trustedHash)
+	}
+	if err := res.Block.ValidateBasic(); err != nil {
+		return nil, fmt.Errorf("invalid block %d: (%s)", next, err)
+	}
+	if res, err = c.Block(&height); err != nil {
+		return nil, fmt.Errorf("cannot get block %d: (%s)", height, err)
+	}
+	tb.block = res.Block
+	if tb.block == nil || tb.block.Height != height || !bytes.Equal(tb.block.Hash(), tb.next.LastBlockID.Hash) {
+		return nil, fmt.Errorf("block %d does not match the trusted header", height)
+	}
+	for _, v := range []struct {
+		height int64
+		set    **tmtypes.ValidatorSet
+		hash   []byte
+	}{
+		{height, &tb.lastValidators, tb.block.ValidatorsHash},
+		{next, &tb.validators, tb.next.ValidatorsHash},
+		{next + 1, &tb.nextValidators, tb.next.NextValidatorsHash},
+	} {
+		if *v.set, err = fetchValidators(c, v.height); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal((*v.set).Hash(), v.hash) {
+			return nil, fmt.Errorf("validators of height %d do not match the trusted header", v.height)
+		}
+	}
+	if err := tb.validators.VerifyCommit(tb.next.ChainID, commit.SignedHeader.Commit.BlockID, next,
+		commit.SignedHeader.Commit); err != nil {
+		return nil, fmt.Errorf("cannot verify commit %d: (%s)", next, err)
+	}
+	if !bytes.Equal(commit.SignedHeader.Commit.BlockID.Hash, tb.next.Hash()) {
+		return nil, fmt.Errorf("commit %d does not match the trusted header", next)
+	}
+	if err := tb.lastValidators.VerifyCommit(tb.next.ChainID, tb.next.LastBlockID, height, tb.nextCommit); err != nil {
+		return nil, fmt.Errorf("cannot verify commit %d: (%s)", height, err)
+	}
+	params, err := c.ConsensusParams(&next)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get consensus params %d: (%s)", next, err)
+	}
+	tb.params = params.ConsensusParams
+	if !bytes.Equal(tb.params.Hash(), tb.next.ConsensusHash) {
+		return nil, fmt.Errorf("consensus params of height %d do not match the trusted header", next)
+	}
+	return tb, nil
+}
+
+// fetchValidators returns the validator set of height, keeping the proposer priorities
+// returned by the endpoint (NewValidatorSet would reset them)
+func fetchValidators(c *rpchttp.HTTP, height int64) (*tmtypes.ValidatorSet, error) {
+	const perPage = 100
+	vals := []*tmtypes.Validator{}
+	for page := 1; ; page++ {
+		res, err := c.Validators(&height, page, perPage)
+		if err != nil {
+			// the last page was full, the set hash check finds any missing validator
+			if page > 1 {
+				break
+			}
+			return nil, fmt.Errorf("cannot get validators %d: (%s)", height, err)
+		}
+		vals = append(vals, res.Validators...)
+		if len(res.Validators) < perPage {
+			break
+		}
+	}
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("no validators for height %d", height)
+	}
+	return &tmtypes.ValidatorSet{Validators: vals}, nil
+}
+
+// bootstrapBlockStore saves the trusted block and the Tendermint state at the block height
+// on the empty Tendermint databases of dataDir, so Tendermint starts at that height. The
+// state store keeps the validators of the last, current and next heights (as Tendermint
+// would after executing the block), so the ABCI handshake and the block sync can go on.
+func bootstrapBlockStore(dataDir string, tb *trustedBlocks) error {
+	height := tb.block.Height
+	sdb, err := tmdb.NewGoLevelDB("state", dataDir)
+	if err != nil {
+		return fmt.Errorf("cannot open state store: (%s)", err)
+	}
+	defer sdb.Close()
+	if tmstate.LoadState(sdb).LastBlockHeight != 0 {
+		return fmt.Errorf("tendermint state is not empty")
+	}
+	state := tmstate.State{
+		Version: tmstate.Version{
+			Consensus: tb.next.Version,
+			Software:  tmversion.TMCoreSemVer,
+		},
+		ChainID:         tb.next.ChainID,
+		LastBlockID:     tb.next.LastBlockID,
+		LastBlockTime:   tb.block.Time,
+		LastResultsHash: tb.next.LastResultsHash,
+		AppHash:         tb.next.AppHash,
+		ConsensusParams: tb.params,
+		Validators:      tb.validators,
+		LastValidators:  tb.lastValidators,
+	}
+	// SaveState only stores the validators of the height after the next one, so the state is
+	// saved at the previous heights first to store the validators of height and height+1
+	for _, s := range []struct {
+		height int64
+		vals   *tmtypes.ValidatorSet
+	}{
+		{height - 2, tb.lastValidators},
+		{height - 1, tb.validators},
+		{height, tb.nextValidators},
+	} {
+		state.LastBlockHeight = s.height
+		state.NextValidators = s.vals
+		state.LastHeightValidatorsChanged = s.height + 2
+		state.LastHeightConsensusParamsChanged = s.height + 1
+		tmstate.SaveState(sdb, state)
+	}
+
+	bsdb, err := tmdb.NewGoLevelDB("blockstore", dataDir)
+	if err != nil {
+		return fmt.Errorf("cannot open block store: (%s)", err)
+	}
+	defer bsdb.Close()
+	bs := tmstore.NewBlockStore(bsdb)
+	if bs.Height() != 0 {
+		return fmt.Errorf("block store is not empty")
+	}
+	// the seen commit of the block is the last commit of the next block
+	bs.SaveBlock(tb.block, tb.block.MakePartSet(tmtypes.BlockPartSizeBytes), tb.nextCommit)
+	return nil
+}
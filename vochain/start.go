@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"gitlab.com/vocdoni/go-dvote/config"
+	"gitlab.com/vocdoni/go-dvote/statedb/iavlstate"
 	"gitlab.com/vocdoni/go-dvote/util"
 
 	amino "github.com/tendermint/go-amino"
@@ -31,10 +32,27 @@ import (
 // NewVochain starts a node with an ABCI application
 func NewVochain(vochaincfg *config.VochainCfg, genesis []byte) *BaseApplication {
 	// creating new vochain app
-	app, err := NewBaseApplication(vochaincfg.DataDir + "/data")
+	app, err := NewBaseApplication(vochaincfg.DataDir+"/data", vochaincfg.StateBackend)
 	if err != nil {
 		log.Fatalf("cannot init vochain application: %s", err)
 	}
+	if vochaincfg.PruneKeepRecent > 0 {
+		if iavl, ok := app.State.Store.(*iavlstate.IavlState); ok {
+			if err := iavl.SetPruning(vochaincfg.PruneKeepRecent, vochaincfg.PruneKeepEvery); err != nil {
+				log.Fatalf("cannot enable state pruning: %s", err)
+			}
+			log.Infof("state pruning enabled, keeping %d recent versions and every %d versions",
+				vochaincfg.PruneKeepRecent, vochaincfg.PruneKeepEvery)
+		} else {
+			log.Warnf("state pruning is only supported by the %s backend, disabling it", StateBackendIavl)
+		}
+	}
+	if vochaincfg.SnapshotInterval > 0 {
+		if err := vochaincfg.ValidSnapshots(); err != nil {
+			log.Fatal(err)
+		}
+		app.SetSnapshots(vochaincfg.DataDir+"/snapshots", vochaincfg.SnapshotInterval)
+	}
 	log.Info("creating tendermint node and application")
 	app.Node, err = newTendermint(app, vochaincfg, genesis)
 	if err != nil {
@@ -159,7 +177,8 @@ func newTendermint(app *BaseApplication, localConfig *config.VochainCfg, genesis
 
 	// mempool config
 	tconfig.Mempool.Size = localConfig.MempoolSize
-	tconfig.Mempool.Recheck = false
+	// rechecking evicts the transactions invalidated by the last block (see RecheckTx)
+	tconfig.Mempool.Recheck = true
 
 	// enable cleveldb if available
 	if checkDBavailable(string(db.CLevelDBBackend)) {
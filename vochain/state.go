@@ -5,13 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"sync"
-	"time"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
 	amino "github.com/tendermint/go-amino"
 	ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	"github.com/tendermint/tendermint/crypto/merkle"
 	tmtypes "github.com/tendermint/tendermint/types"
+	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/statedb"
+	"gitlab.com/vocdoni/go-dvote/statedb/gravitonstate"
 	"gitlab.com/vocdoni/go-dvote/statedb/iavlstate"
 	"gitlab.com/vocdoni/go-dvote/types"
 	"gitlab.com/vocdoni/go-dvote/util"
@@ -19,10 +22,18 @@ import (
 
 const (
 	// db names
-	AppTree                 = "app"
-	ProcessTree             = "process"
-	VoteTree                = "vote"
-	voteCachePurgeThreshold = time.Duration(time.Second * 600)
+	AppTree     = "app"
+	ProcessTree = "process"
+	VoteTree    = "vote"
+
+	// state backends
+	StateBackendIavl     = "iavl"
+	StateBackendGraviton = "graviton"
+
+	// ProofOpStateRoots is the type of the proof operation which contains the roots of
+	// all the state trees (JSON map of tree name to hex root). The AppHash is the hash
+	// of the concatenated roots, sorted by tree name (see StateRootsOp).
+	ProofOpStateRoots = "vochain:roots"
 )
 
 var (
@@ -30,10 +41,22 @@ var (
 	headerKey    = []byte("header")
 	oracleKey    = []byte("oracle")
 	validatorKey = []byte("validator")
+	noncePrefix  = []byte("nonce_")
+	// the allow and deny lists of the open-poll processes are stored as prefix+pid+address keys
+	allowListPrefix = []byte("allow_")
+	denyListPrefix  = []byte("deny_")
+	// the blind census CA public keys are stored as prefix+hash(key), pointing to the process
+	censusCAPrefix = []byte("censusca_")
+	// the privileged transactions being co-signed are stored as prefix+hash(signedBytes)
+	coSignPrefix = []byte("cosign_")
+
+	oracleThresholdsKey  = []byte("oracleThresholds")
+	ballotRulesHeightKey = []byte("ballotRulesHeight")
 )
 
 var (
 	ErrProcessNotFound = fmt.Errorf("process not found")
+	ErrKeyNotFound     = fmt.Errorf("key not found")
 )
 
 // PrefixDBCacheSize is the size of the cache for the MutableTree IAVL databases
@@ -42,27 +65,43 @@ var PrefixDBCacheSize = 0
 // EventListener is an interface used for executing custom functions during the
 // events of the block creation process.
 // The order in which events are executed is: Rollback, OnVote or OnProcess, Commit.
+// If a vote replaces a previous vote of the same voter, OnVoteOverwrite is executed
+// instead of OnVote.
 // The process is concurrency safe, meaning that there cannot be two sequences
 // happening in parallel.
 type EventListener interface {
 	OnVote(*types.Vote)
+	OnVoteOverwrite(previous, vote *types.Vote)
 	OnProcess(pid, eid []byte, mkroot, mkuri string)
 	OnCancel(pid []byte)
+	OnPause(pid []byte)
+	OnResume(pid []byte)
+	OnCensusUpdate(pid []byte, mkroot, mkuri string)
 	OnProcessKeys(pid []byte, encryptionPub, commitment string)
 	OnRevealKeys(pid []byte, encryptionPriv, reveal string)
 	Commit(height int64)
 	Rollback()
 }
 
+// TxListener is an interface used for receiving the reference of each delivered
+// transaction, including the ones rejected by the application. The references are
+// only built if there is any TxListener (see AddTxListener).
+type TxListener interface {
+	OnTx(ref *types.TxReference)
+}
+
 // State represents the state of the vochain application
 type State struct {
-	Store         statedb.StateDB
-	voteCache     map[string]*types.VoteProof
-	voteCacheLock sync.RWMutex
+	Store     statedb.StateDB
+	voteCache *voteCache
 	ImmutableState
 	Codec *amino.Codec
 
 	eventListeners []EventListener
+	txListeners    []TxListener
+	// validatorUpdates keeps the validators added, modified or removed (power 0)
+	// during the current block, so they can be sent to Tendermint on EndBlock
+	validatorUpdates []types.GenesisValidator
 }
 
 // ImmutableState holds the latest trees version saved on disk
@@ -73,24 +112,16 @@ type ImmutableState struct {
 	sync.RWMutex
 }
 
-// NewState creates a new State
+// NewState creates a new State using the default state backend (iavl)
 func NewState(dataDir string, codec *amino.Codec) (*State, error) {
+	return NewStateWithBackend(dataDir, StateBackendIavl, codec)
+}
+
+// NewStateWithBackend creates a new State using the given state backend (iavl or graviton)
+func NewStateWithBackend(dataDir, backend string, codec *amino.Codec) (*State, error) {
 	var err error
 	vs := &State{}
-	//vs.Store = new(gravitonstate.GravitonState)
-	vs.Store = new(iavlstate.IavlState)
-
-	if err = vs.Store.Init(dataDir, "disk"); err != nil {
-		return nil, err
-	}
-
-	if err := vs.Store.AddTree(AppTree); err != nil {
-		return nil, err
-	}
-	if err := vs.Store.AddTree(ProcessTree); err != nil {
-		return nil, err
-	}
-	if err := vs.Store.AddTree(VoteTree); err != nil {
+	if vs.Store, err = OpenStateDB(dataDir, backend); err != nil {
 		return nil, err
 	}
 
@@ -100,17 +131,60 @@ func NewState(dataDir string, codec *amino.Codec) (*State, error) {
 	}
 
 	vs.Codec = codec
-	vs.voteCache = make(map[string]*types.VoteProof)
-	log.Infof("application trees successfully loaded at version %d", vs.Store.Version())
+	if vs.voteCache, err = newVoteCache(dataDir); err != nil {
+		return nil, err
+	}
+	log.Infof("application trees successfully loaded at version %d using %s backend", vs.Store.Version(), backend)
 	return vs, nil
 }
 
+// OpenStateDB initializes the state database of the given backend on dataDir and
+// adds the vochain trees. The caller must load the desired version afterwards.
+func OpenStateDB(dataDir, backend string) (statedb.StateDB, error) {
+	return openStateDB(dataDir, backend, "disk")
+}
+
+// openStateDB is like OpenStateDB but allows choosing the storage type (disk or mem)
+func openStateDB(dataDir, backend, storageType string) (statedb.StateDB, error) {
+	var store statedb.StateDB
+	switch backend {
+	case StateBackendIavl, "":
+		store = new(iavlstate.IavlState)
+	case StateBackendGraviton:
+		store = new(gravitonstate.GravitonState)
+	default:
+		return nil, fmt.Errorf("unknown state backend %q", backend)
+	}
+	if err := store.Init(dataDir, storageType); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{AppTree, ProcessTree, VoteTree} {
+		if err := store.AddTree(name); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
 // AddEventListener adds a new event listener, to receive method calls on block
 // events as documented in EventListener.
 func (v *State) AddEventListener(l EventListener) {
 	v.eventListeners = append(v.eventListeners, l)
 }
 
+// AddTxListener adds a new transaction listener, to receive the reference of the
+// delivered transactions as documented in TxListener.
+func (v *State) AddTxListener(l TxListener) {
+	v.txListeners = append(v.txListeners, l)
+}
+
+// notifyTx sends the reference of a delivered transaction to the transaction listeners
+func (v *State) notifyTx(ref *types.TxReference) {
+	for _, l := range v.txListeners {
+		l.OnTx(ref)
+	}
+}
+
 // AddOracle adds a trusted oracle given its address if not exists
 func (v *State) AddOracle(address string) error {
 	var err error
@@ -173,6 +247,233 @@ func (v *State) Oracles(isQuery bool) ([]string, error) {
 	return oracles, err
 }
 
+// SetOracleThresholds sets the number of oracle signatures required by each transaction class
+func (v *State) SetOracleThresholds(t types.OracleThresholds) error {
+	thresholdsBytes, err := v.Codec.MarshalBinaryBare(t)
+	if err != nil {
+		return fmt.Errorf("cannot marshal oracle thresholds: (%s)", err)
+	}
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(oracleThresholdsKey, thresholdsBytes)
+}
+
+// OracleThresholds returns the number of oracle signatures required by each transaction class
+func (v *State) OracleThresholds(isQuery bool) (types.OracleThresholds, error) {
+	var thresholdsBytes []byte
+	var t types.OracleThresholds
+	v.RLock()
+	defer v.RUnlock()
+	if isQuery {
+		thresholdsBytes = v.Store.ImmutableTree(AppTree).Get(oracleThresholdsKey)
+	} else {
+		thresholdsBytes = v.Store.Tree(AppTree).Get(oracleThresholdsKey)
+	}
+	if thresholdsBytes == nil {
+		return t, nil
+	}
+	err := v.Codec.UnmarshalBinaryBare(thresholdsBytes, &t)
+	return t, err
+}
+
+// SetBallotRulesHeight sets the height from which the processes created without ballot rules
+// get the default ones (see types.GenesisAppState)
+func (v *State) SetBallotRulesHeight(height int64) error {
+	heightBytes, err := v.Codec.MarshalBinaryBare(height)
+	if err != nil {
+		return fmt.Errorf("cannot marshal ballot rules height: (%s)", err)
+	}
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(ballotRulesHeightKey, heightBytes)
+}
+
+// BallotRulesHeight returns the height from which the processes created without ballot rules
+// get the default ones, zero if they never do
+func (v *State) BallotRulesHeight(isQuery bool) (int64, error) {
+	var heightBytes []byte
+	var height int64
+	v.RLock()
+	defer v.RUnlock()
+	if isQuery {
+		heightBytes = v.Store.ImmutableTree(AppTree).Get(ballotRulesHeightKey)
+	} else {
+		heightBytes = v.Store.Tree(AppTree).Get(ballotRulesHeightKey)
+	}
+	if heightBytes == nil {
+		return 0, nil
+	}
+	err := v.Codec.UnmarshalBinaryBare(heightBytes, &height)
+	return height, err
+}
+
+// NonceUsed returns true if the nonce has already been used by the signer address
+func (v *State) NonceUsed(addr ethcommon.Address, nonce string, isQuery bool) bool {
+	v.RLock()
+	defer v.RUnlock()
+	if isQuery {
+		return v.Store.ImmutableTree(AppTree).Get(nonceID(addr, nonce)) != nil
+	}
+	return v.Store.Tree(AppTree).Get(nonceID(addr, nonce)) != nil
+}
+
+// AddNonce marks the nonce as used by the signer address
+func (v *State) AddNonce(addr ethcommon.Address, nonce string) error {
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(nonceID(addr, nonce), []byte{1})
+}
+
+// CoSignedTx returns the co-signing entry of a privileged transaction, or nil if
+// no oracle has co-signed it yet (see CoSignTx)
+func (v *State) CoSignedTx(signedBytes []byte, isQuery bool) (*types.CoSignedTx, error) {
+	var cosignedBytes []byte
+	v.RLock()
+	defer v.RUnlock()
+	if isQuery {
+		cosignedBytes = v.Store.ImmutableTree(AppTree).Get(coSignID(signedBytes))
+	} else {
+		cosignedBytes = v.Store.Tree(AppTree).Get(coSignID(signedBytes))
+	}
+	if cosignedBytes == nil {
+		return nil, nil
+	}
+	var c types.CoSignedTx
+	if err := v.Codec.UnmarshalBinaryBare(cosignedBytes, &c); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal co-signed transaction: (%s)", err)
+	}
+	return &c, nil
+}
+
+// SetCoSignedTx stores the co-signing entry of a privileged transaction
+func (v *State) SetCoSignedTx(signedBytes []byte, c *types.CoSignedTx) error {
+	cosignedBytes, err := v.Codec.MarshalBinaryBare(c)
+	if err != nil {
+		return fmt.Errorf("cannot marshal co-signed transaction: (%s)", err)
+	}
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(coSignID(signedBytes), cosignedBytes)
+}
+
+// PendingCoSignedTxs returns the privileged transactions which are still waiting
+// for the signatures of other oracles
+func (v *State) PendingCoSignedTxs(isQuery bool) []*types.CoSignedTx {
+	var pending []*types.CoSignedTx
+	fn := func(key, value []byte) bool {
+		var c types.CoSignedTx
+		if err := v.Codec.UnmarshalBinaryBare(value, &c); err != nil {
+			log.Errorf("cannot unmarshal co-signed transaction %x: (%s)", key, err)
+			return false
+		}
+		if !c.Executed {
+			pending = append(pending, &c)
+		}
+		return false
+	}
+	v.RLock()
+	defer v.RUnlock()
+	if isQuery {
+		v.Store.ImmutableTree(AppTree).Iterate(coSignPrefix, fn)
+	} else {
+		v.Store.Tree(AppTree).Iterate(coSignPrefix, fn)
+	}
+	return pending
+}
+
+// AddProcessAddressLists stores the allow and deny lists of an open-poll process. The process must
+// have its AllowListSize and DenyListSize set accordingly (see IsAddressAllowed).
+func (v *State) AddProcessAddressLists(pid []byte, allow, deny []ethcommon.Address) error {
+	v.Lock()
+	defer v.Unlock()
+	for _, addr := range allow {
+		if err := v.Store.Tree(AppTree).Add(addressListKey(allowListPrefix, pid, addr), []byte{1}); err != nil {
+			return err
+		}
+	}
+	for _, addr := range deny {
+		if err := v.Store.Tree(AppTree).Add(addressListKey(denyListPrefix, pid, addr), []byte{1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsAddressAllowed indicates whether an address can vote on an open-poll process according to its
+// allow and deny lists. If the process has an allow list, only its addresses can vote.
+func (v *State) IsAddressAllowed(pid []byte, process *types.Process, addr ethcommon.Address, isQuery bool) bool {
+	v.RLock()
+	defer v.RUnlock()
+	tree := v.Store.Tree(AppTree)
+	if isQuery {
+		tree = v.Store.ImmutableTree(AppTree)
+	}
+	if process.AllowListSize > 0 && tree.Get(addressListKey(allowListPrefix, pid, addr)) == nil {
+		return false
+	}
+	return process.DenyListSize == 0 || tree.Get(addressListKey(denyListPrefix, pid, addr)) == nil
+}
+
+// AddCensusCAKey registers the blind census CA public key of a process, so it cannot be used
+// by any other process (see CensusCAProcess)
+func (v *State) AddCensusCAKey(pid []byte, ca *types.CensusCA) error {
+	key, err := censusCAKey(ca)
+	if err != nil {
+		return err
+	}
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(key, pid)
+}
+
+// CensusCAProcess returns the ID of the process which uses the blind census CA public key,
+// or nil if the key has not been used yet
+func (v *State) CensusCAProcess(ca *types.CensusCA, isQuery bool) []byte {
+	key, err := censusCAKey(ca)
+	if err != nil {
+		return nil
+	}
+	v.RLock()
+	defer v.RUnlock()
+	if isQuery {
+		return v.Store.ImmutableTree(AppTree).Get(key)
+	}
+	return v.Store.Tree(AppTree).Get(key)
+}
+
+// censusCAKey = censusCAPrefix + hash( public key )
+func censusCAKey(ca *types.CensusCA) ([]byte, error) {
+	pub, err := hex.DecodeString(util.TrimHex(ca.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 0, len(censusCAPrefix)+32)
+	key = append(key, censusCAPrefix...)
+	return append(key, ethereum.HashRaw(pub)...), nil
+}
+
+// coSignID = coSignPrefix + hash( signedBytes )
+func coSignID(signedBytes []byte) []byte {
+	id := make([]byte, 0, len(coSignPrefix)+32)
+	id = append(id, coSignPrefix...)
+	return append(id, ethereum.HashRaw(signedBytes)...)
+}
+
+// addressListKey = prefix + pid + address
+func addressListKey(prefix, pid []byte, addr ethcommon.Address) []byte {
+	key := make([]byte, 0, len(prefix)+len(pid)+ethcommon.AddressLength)
+	key = append(key, prefix...)
+	key = append(key, pid...)
+	return append(key, addr.Bytes()...)
+}
+
+// nonceID = noncePrefix + hash( address+nonce )
+func nonceID(addr ethcommon.Address, nonce string) []byte {
+	id := make([]byte, 0, len(noncePrefix)+32)
+	id = append(id, noncePrefix...)
+	return append(id, ethereum.HashRaw(append(addr.Bytes(), nonce...))...)
+}
+
 func hexPubKeyToTendermintEd25519(pubKey string) (types.PubKey, error) {
 	var tmkey ed25519.PubKeyEd25519
 	pubKeyBytes, err := hex.DecodeString(pubKey)
@@ -186,18 +487,28 @@ func hexPubKeyToTendermintEd25519(pubKey string) (types.PubKey, error) {
 	return tmkey, nil
 }
 
-// AddValidator adds a tendemint validator if it is not already added
+// AddValidator adds a tendemint validator if it is not already added.
+// If the validator exists but with a different power, its power is updated.
 func (v *State) AddValidator(pubKey types.PubKey, power int64) error {
 	var err error
+	if power <= 0 {
+		return fmt.Errorf("validator power must be positive")
+	}
 	addr := pubKey.Address().String()
 	v.Lock()
 	defer v.Unlock()
 	validatorsBytes := v.Store.Tree(AppTree).Get(validatorKey)
 	var validators []types.GenesisValidator
 	v.Codec.UnmarshalBinaryBare(validatorsBytes, &validators)
-	for _, v := range validators {
-		if v.PubKey.Address().String() == addr {
-			return nil
+	found := false
+	for i, val := range validators {
+		if val.PubKey.Address().String() == addr {
+			if val.Power == power {
+				return nil
+			}
+			validators[i].Power = power
+			found = true
+			break
 		}
 	}
 	newVal := types.GenesisValidator{
@@ -205,24 +516,34 @@ func (v *State) AddValidator(pubKey types.PubKey, power int64) error {
 		PubKey:  pubKey,
 		Power:   power,
 	}
-	validators = append(validators, newVal)
+	if !found {
+		validators = append(validators, newVal)
+	}
 
 	validatorsBytes, err = v.Codec.MarshalBinaryBare(validators)
 	if err != nil {
 		return fmt.Errorf("cannot marshal validators: %v", err)
 	}
-	return v.Store.Tree(AppTree).Add(validatorKey, validatorsBytes)
+	if err := v.Store.Tree(AppTree).Add(validatorKey, validatorsBytes); err != nil {
+		return err
+	}
+	v.addValidatorUpdate(newVal)
+	return nil
 }
 
 // RemoveValidator removes a tendermint validator if exists
 func (v *State) RemoveValidator(address string) error {
-	v.RLock()
+	v.Lock()
+	defer v.Unlock()
 	validatorsBytes := v.Store.Tree(AppTree).Get(validatorKey)
-	v.RUnlock()
 	var validators []types.GenesisValidator
 	v.Codec.UnmarshalBinaryBare(validatorsBytes, &validators)
 	for i, val := range validators {
 		if val.Address.String() == address {
+			// tendermint cannot work with an empty validator set
+			if len(validators) == 1 {
+				return errors.New("cannot remove the last validator")
+			}
 			// remove validator
 			copy(validators[i:], validators[i+1:])
 			validators[len(validators)-1] = types.GenesisValidator{}
@@ -231,14 +552,39 @@ func (v *State) RemoveValidator(address string) error {
 			if err != nil {
 				return errors.New("cannot marshal validators")
 			}
-			v.Lock()
-			defer v.Unlock()
-			return v.Store.Tree(AppTree).Add(validatorKey, validatorsBytes)
+			if err := v.Store.Tree(AppTree).Add(validatorKey, validatorsBytes); err != nil {
+				return err
+			}
+			val.Power = 0
+			v.addValidatorUpdate(val)
+			return nil
 		}
 	}
 	return errors.New("validator not found")
 }
 
+// addValidatorUpdate records a validator change for the current block.
+// Only the last change of each validator is kept. The caller must hold the lock.
+func (v *State) addValidatorUpdate(val types.GenesisValidator) {
+	for i, u := range v.validatorUpdates {
+		if u.Address.String() == val.Address.String() {
+			v.validatorUpdates[i] = val
+			return
+		}
+	}
+	v.validatorUpdates = append(v.validatorUpdates, val)
+}
+
+// ValidatorUpdates returns the validators changed during the current block.
+// A power of zero means the validator has been removed.
+func (v *State) ValidatorUpdates() []types.GenesisValidator {
+	v.RLock()
+	defer v.RUnlock()
+	updates := make([]types.GenesisValidator, len(v.validatorUpdates))
+	copy(updates, v.validatorUpdates)
+	return updates
+}
+
 // Validators returns a list of the validators saved on persistent storage
 func (v *State) Validators(isQuery bool) ([]types.GenesisValidator, error) {
 	var validatorBytes []byte
@@ -283,6 +629,76 @@ func (v *State) AddProcessKeys(tx *types.AdminTx) error {
 	return nil
 }
 
+// AddProcessKeyDeal adds the contribution of a keykeeper to the threshold encryption key of a process,
+// and updates the encryption public key
+func (v *State) AddProcessKeyDeal(tx *types.AdminTx) error {
+	pid, err := hex.DecodeString(tx.ProcessID)
+	if err != nil {
+		return err
+	}
+	process, err := v.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	deal := *tx.KeyDeal
+	deal.Complaints, deal.RevealedShares = nil, nil
+	process.KeyDeals[tx.KeyIndex] = deal
+	log.Debugf("added key deal %d for process %x", tx.KeyIndex, pid)
+	return v.setThresholdKeyDeals(process, pid)
+}
+
+// AddProcessKeyComplaint adds the complaint of a keykeeper about the share received from a dealer.
+// The dealer does not contribute to the threshold encryption key until the disputed share is revealed.
+func (v *State) AddProcessKeyComplaint(tx *types.AdminTx) error {
+	pid, err := hex.DecodeString(tx.ProcessID)
+	if err != nil {
+		return err
+	}
+	process, err := v.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	deal := &process.KeyDeals[tx.DealerIndex]
+	deal.Complaints = append(deal.Complaints, tx.KeyIndex)
+	log.Infof("keykeeper %d complained about key deal %d for process %x", tx.KeyIndex, tx.DealerIndex, pid)
+	return v.setThresholdKeyDeals(process, pid)
+}
+
+// RevealProcessKeyDealShare adds a disputed share revealed by its dealer
+func (v *State) RevealProcessKeyDealShare(tx *types.AdminTx) error {
+	pid, err := hex.DecodeString(tx.ProcessID)
+	if err != nil {
+		return err
+	}
+	process, err := v.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	deal := &process.KeyDeals[tx.DealerIndex]
+	if len(deal.RevealedShares) == 0 {
+		deal.RevealedShares = make([]string, types.MaxKeyIndex)
+	}
+	deal.RevealedShares[tx.KeyIndex] = tx.KeyShare
+	log.Debugf("revealed key share %d of key deal %d for process %x", tx.KeyIndex, tx.DealerIndex, pid)
+	return v.setThresholdKeyDeals(process, pid)
+}
+
+// setThresholdKeyDeals updates the threshold encryption public key of a process once its key
+// deals have changed, and stores the process
+func (v *State) setThresholdKeyDeals(process *types.Process, pid []byte) error {
+	if err := updateThresholdPublicKey(process); err != nil {
+		return err
+	}
+	log.Debugf("threshold encryption key for process %x: %s", pid, process.EncryptionPublicKeys[types.ThresholdKeyIndex])
+	if err := v.setProcess(process, pid); err != nil {
+		return err
+	}
+	for _, l := range v.eventListeners {
+		l.OnProcessKeys(pid, process.EncryptionPublicKeys[types.ThresholdKeyIndex], "")
+	}
+	return nil
+}
+
 // RevealProcessKeys reveals the keys of a process
 func (v *State) RevealProcessKeys(tx *types.AdminTx) error {
 	pid, err := hex.DecodeString(tx.ProcessID)
@@ -300,6 +716,13 @@ func (v *State) RevealProcessKeys(tx *types.AdminTx) error {
 	if len(tx.EncryptionPrivateKey) > 0 {
 		process.EncryptionPrivateKeys[tx.KeyIndex] = tx.EncryptionPrivateKey
 		log.Debugf("revealed encryption key for process %x: %s", pid, tx.EncryptionPrivateKey)
+		// once enough key shares are revealed, the threshold private key can be recovered
+		if process.IsThresholdEncrypted() && process.RevealedKeyShares() == int(process.KeyThreshold) {
+			if err := combineKeyShares(process); err != nil {
+				return fmt.Errorf("cannot recover threshold encryption key: (%s)", err)
+			}
+			log.Infof("recovered threshold encryption key for process %x", pid)
+		}
 	}
 	if process.KeyIndex < 1 {
 		return fmt.Errorf("no more keys to reveal, keyIndex is < 1")
@@ -372,10 +795,16 @@ func (v *State) PauseProcess(pid []byte) error {
 		return nil
 	}
 	process.Paused = true
-	return v.setProcess(&process, pid)
+	if err := v.setProcess(&process, pid); err != nil {
+		return err
+	}
+	for _, l := range v.eventListeners {
+		l.OnPause(pid)
+	}
+	return nil
 }
 
-// ResumeProcess sets the process paused atribute to true
+// ResumeProcess sets the process paused atribute to false
 func (v *State) ResumeProcess(pid []byte) error {
 	process, err := v.Process(pid, false)
 	if err != nil {
@@ -386,7 +815,38 @@ func (v *State) ResumeProcess(pid []byte) error {
 		return nil
 	}
 	process.Paused = false
-	return v.setProcess(process, pid)
+	if err := v.setProcess(process, pid); err != nil {
+		return err
+	}
+	for _, l := range v.eventListeners {
+		l.OnResume(pid)
+	}
+	return nil
+}
+
+// UpdateCensus replaces the census merkle root and URI of a process, keeping the previous ones on the
+// process history. If acceptPrevious is true, the census proofs against the previous roots are still valid.
+func (v *State) UpdateCensus(pid []byte, mkroot, mkuri string, acceptPrevious bool) error {
+	process, err := v.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	// the URIs were not stored by older versions, keep them aligned with the roots
+	for len(process.PreviousMkURIs) < len(process.PreviousMkRoots) {
+		process.PreviousMkURIs = append(process.PreviousMkURIs, "")
+	}
+	process.PreviousMkRoots = append(process.PreviousMkRoots, process.MkRoot)
+	process.PreviousMkURIs = append(process.PreviousMkURIs, process.MkURI)
+	process.MkRoot = mkroot
+	process.MkURI = mkuri
+	process.AcceptPreviousMkRoots = acceptPrevious
+	if err := v.setProcess(process, pid); err != nil {
+		return err
+	}
+	for _, l := range v.eventListeners {
+		l.OnCensusUpdate(pid, mkroot, mkuri)
+	}
+	return nil
 }
 
 // Process returns a process info given a processId if exists
@@ -422,6 +882,24 @@ func (v *State) CountProcesses(isQuery bool) int64 {
 
 }
 
+// ProcessList returns the identifiers of all the processes the vochain has
+func (v *State) ProcessList(isQuery bool) [][]byte {
+	var pids [][]byte
+	fn := func(key, value []byte) bool {
+		// keys might be reused by the iterator
+		pids = append(pids, append([]byte{}, key...))
+		return false
+	}
+	v.RLock()
+	defer v.RUnlock()
+	if isQuery {
+		v.Store.ImmutableTree(ProcessTree).Iterate(nil, fn)
+	} else {
+		v.Store.Tree(ProcessTree).Iterate(nil, fn)
+	}
+	return pids
+}
+
 // set process stores in the database the process
 func (v *State) setProcess(process *types.Process, pid []byte) error {
 	if process == nil {
@@ -439,12 +917,24 @@ func (v *State) setProcess(process *types.Process, pid []byte) error {
 	return nil
 }
 
-// AddVote adds a new vote to a process if the process exists and the vote is not already submmited
+// AddVote adds a new vote to a process. If the voter already voted, the previous vote is
+// replaced and its overwrite counter increased. The number of overwrites allowed by the
+// process must be checked before (see VoteTxCheck).
 func (v *State) AddVote(vote *types.Vote) error {
 	vid, err := v.voteID(vote.ProcessID, vote.Nullifier)
 	if err != nil {
 		return err
 	}
+	var previous *types.Vote
+	v.RLock()
+	previousBytes := v.Store.Tree(VoteTree).Get(vid)
+	v.RUnlock()
+	if previousBytes != nil {
+		if err := v.Codec.UnmarshalBinaryBare(previousBytes, &previous); err != nil {
+			return fmt.Errorf("cannot unmarshal vote with id (%x)", vid)
+		}
+		vote.OverwriteCount = previous.OverwriteCount + 1
+	}
 	// save block number
 	vote.Height = v.Header(false).Height
 	newVoteBytes, err := v.Codec.MarshalBinaryBare(vote)
@@ -458,7 +948,11 @@ func (v *State) AddVote(vote *types.Vote) error {
 		return err
 	}
 	for _, l := range v.eventListeners {
-		l.OnVote(vote)
+		if previous != nil {
+			l.OnVoteOverwrite(previous, vote)
+		} else {
+			l.OnVote(vote)
+		}
 	}
 	return nil
 }
@@ -509,6 +1003,31 @@ func (v *State) Envelope(processID, nullifier []byte, isQuery bool) (_ *types.Vo
 	return vote, nil
 }
 
+// VoteOverwrites returns the number of times the vote of a voter has been replaced.
+// If the voter has not voted yet exists is false.
+func (v *State) VoteOverwrites(processID, nullifier []byte, isQuery bool) (count uint32, exists bool, err error) {
+	vid, err := v.voteID(processID, nullifier)
+	if err != nil {
+		return 0, false, err
+	}
+	var voteBytes []byte
+	v.RLock()
+	if isQuery {
+		voteBytes = v.Store.ImmutableTree(VoteTree).Get(vid)
+	} else {
+		voteBytes = v.Store.Tree(VoteTree).Get(vid)
+	}
+	v.RUnlock()
+	if len(voteBytes) == 0 {
+		return 0, false, nil
+	}
+	var vote types.Vote
+	if err := v.Codec.UnmarshalBinaryBare(voteBytes, &vote); err != nil {
+		return 0, true, fmt.Errorf("cannot unmarshal vote with id (%x)", vid)
+	}
+	return vote.OverwriteCount, true, nil
+}
+
 // EnvelopeExists returns true if the envelope identified with voteID exists
 func (v *State) EnvelopeExists(processID, nullifier []byte) bool {
 	voteID, err := v.voteID(processID, nullifier)
@@ -604,6 +1123,52 @@ func (v *State) AppHash(isQuery bool) []byte {
 	return header.AppHash
 }
 
+// QueryProof returns the last committed value of key on the tree treeName and the height of
+// the block whose execution produced it. If prove is true, it also returns the proof operations
+// needed to verify the value against the AppHash: the first one proves the value against the
+// tree root, the second one contains all the tree roots (see ProofOpStateRoots).
+// The operations can be verified with ProofRuntime and ProofKeyPath(treeName, key).
+// The AppHash of this state is included on the header of the next block (height+1).
+func (v *State) QueryProof(treeName string, key []byte, prove bool) ([]byte, []merkle.ProofOp, int64, error) {
+	switch treeName {
+	case AppTree, ProcessTree, VoteTree:
+	default:
+		return nil, nil, 0, fmt.Errorf("unknown tree %s", treeName)
+	}
+	v.RLock()
+	defer v.RUnlock()
+	var header tmtypes.Header
+	if err := v.Codec.UnmarshalBinaryBare(v.Store.ImmutableTree(AppTree).Get(headerKey), &header); err != nil {
+		return nil, nil, 0, fmt.Errorf("cannot get vochain height: (%s)", err)
+	}
+	tree := v.Store.ImmutableTree(treeName)
+	value := tree.Get(key)
+	if value == nil {
+		return nil, nil, header.Height, ErrKeyNotFound
+	}
+	if !prove {
+		return value, nil, header.Height, nil
+	}
+
+	proof, err := tree.Proof(key)
+	if err != nil || proof == nil {
+		return nil, nil, header.Height, fmt.Errorf("cannot generate proof: (%v)", err)
+	}
+	roots := make(map[string]string, 3)
+	for _, name := range []string{AppTree, ProcessTree, VoteTree} {
+		roots[name] = hex.EncodeToString(v.Store.ImmutableTree(name).Hash())
+	}
+	var op merkle.ProofOp
+	switch v.Store.(type) {
+	case *gravitonstate.GravitonState:
+		op = gravitonstate.NewValueOp(key, tree.Hash(), proof).ProofOp()
+	default:
+		op = merkle.ProofOp{Type: iavlstate.ProofType, Key: key, Data: proof}
+	}
+	ops := []merkle.ProofOp{op, NewStateRootsOp(treeName, roots).ProofOp()}
+	return value, ops, header.Height, nil
+}
+
 // Save persistent save of vochain mem trees
 func (v *State) Save() []byte {
 	v.Lock()
@@ -611,6 +1176,7 @@ func (v *State) Save() []byte {
 	if err != nil {
 		panic(fmt.Sprintf("cannot commit state trees: (%s)", err))
 	}
+	v.validatorUpdates = nil
 	v.Unlock()
 	if h := v.Header(false); h != nil {
 		for _, l := range v.eventListeners {
@@ -627,6 +1193,7 @@ func (v *State) Rollback() {
 	}
 	v.Lock()
 	defer v.Unlock()
+	v.validatorUpdates = nil
 	if err := v.Store.Rollback(); err != nil {
 		panic(fmt.Sprintf("cannot rollback state tree: (%s)", err))
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
 	amino "github.com/tendermint/go-amino"
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/types"
@@ -65,3 +66,35 @@ func TestState(t *testing.T) {
 	}
 
 }
+
+func TestAddressLists(t *testing.T) {
+	s, err := NewState(t.TempDir(), amino.NewCodec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs := make([]ethcommon.Address, 3)
+	for i := range addrs {
+		addrs[i] = ethcommon.BytesToAddress(util.RandomBytes(ethcommon.AddressLength))
+	}
+	pid, otherPid := util.RandomBytes(32), util.RandomBytes(32)
+	p := &types.Process{Type: types.OpenPoll, AllowListSize: 2, DenyListSize: 1}
+	if err := s.AddProcessAddressLists(pid, addrs[:2], addrs[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	s.Save()
+	for _, isQuery := range []bool{false, true} {
+		if !s.IsAddressAllowed(pid, p, addrs[0], isQuery) {
+			t.Errorf("address of the allow list not allowed (query: %t)", isQuery)
+		}
+		if s.IsAddressAllowed(pid, p, addrs[1], isQuery) {
+			t.Errorf("address of the deny list allowed (query: %t)", isQuery)
+		}
+		if s.IsAddressAllowed(pid, p, addrs[2], isQuery) {
+			t.Errorf("address out of the allow list allowed (query: %t)", isQuery)
+		}
+		// the lists belong to a single process
+		if !s.IsAddressAllowed(otherPid, &types.Process{Type: types.OpenPoll, DenyListSize: 1}, addrs[1], isQuery) {
+			t.Errorf("address denied on another process (query: %t)", isQuery)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package vochain
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"strconv"
 	"time"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	tmtypes "github.com/tendermint/tendermint/types"
 	"gitlab.com/vocdoni/go-dvote/crypto/ethereum"
 	"gitlab.com/vocdoni/go-dvote/crypto/nacl"
 	"gitlab.com/vocdoni/go-dvote/crypto/snarks"
+	"gitlab.com/vocdoni/go-dvote/crypto/threshold"
 	"gitlab.com/vocdoni/go-dvote/log"
 	"gitlab.com/vocdoni/go-dvote/types"
 	"gitlab.com/vocdoni/go-dvote/util"
@@ -38,25 +44,35 @@ func AddTx(gtx GenericTX, state *State, commit bool) ([]byte, error) {
 			return []byte{}, err
 		}
 		if commit {
+			var err error
 			switch tx.Type {
 			case "addOracle":
-				return []byte{}, state.AddOracle(tx.Address)
+				err = state.AddOracle(tx.Address)
 			case "removeOracle":
-				return []byte{}, state.RemoveOracle(tx.Address)
+				err = state.RemoveOracle(tx.Address)
 			case "addValidator":
-				pk, err := hexPubKeyToTendermintEd25519(tx.PubKey)
-				if err == nil {
-					return []byte{}, state.AddValidator(pk, tx.Power)
+				var pk types.PubKey
+				if pk, err = hexPubKeyToTendermintEd25519(tx.PubKey); err == nil {
+					err = state.AddValidator(pk, tx.Power)
 				}
-				return []byte{}, err
-
 			case "removeValidator":
-				return []byte{}, state.RemoveValidator(tx.Address)
+				err = state.RemoveValidator(tx.Address)
 			case types.TxAddProcessKeys:
-				return []byte{}, state.AddProcessKeys(tx)
+				err = state.AddProcessKeys(tx)
 			case types.TxRevealProcessKeys:
-				return []byte{}, state.RevealProcessKeys(tx)
+				err = state.RevealProcessKeys(tx)
+			case types.TxAddProcessKeyDeal:
+				err = state.AddProcessKeyDeal(tx)
+			case types.TxAddProcessKeyComplaint:
+				err = state.AddProcessKeyComplaint(tx)
+			case types.TxRevealProcessKeyDealShare:
+				err = state.RevealProcessKeyDealShare(tx)
+			}
+			if err != nil {
+				return []byte{}, err
 			}
+			// the nonce is only used once the transaction is executed
+			return []byte{}, useNonce(state, tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce)
 		}
 	case "CancelProcessTx":
 		tx := gtx.(*types.CancelProcessTx)
@@ -68,7 +84,47 @@ func AddTx(gtx GenericTX, state *State, commit bool) ([]byte, error) {
 			if err != nil {
 				return []byte{}, err
 			}
-			return []byte{}, state.CancelProcess(pid)
+			if err := state.CancelProcess(pid); err != nil {
+				return []byte{}, err
+			}
+			return []byte{}, useNonce(state, tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce)
+		}
+
+	case "PauseProcessTx":
+		tx := gtx.(*types.PauseProcessTx)
+		if err := PauseProcessTxCheck(tx, state); err != nil {
+			return []byte{}, err
+		}
+		if commit {
+			pid, err := hex.DecodeString(tx.ProcessID)
+			if err != nil {
+				return []byte{}, err
+			}
+			if tx.Type == types.TxPauseProcess {
+				err = state.PauseProcess(pid)
+			} else {
+				err = state.ResumeProcess(pid)
+			}
+			if err != nil {
+				return []byte{}, err
+			}
+			return []byte{}, useNonce(state, tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce)
+		}
+
+	case "UpdateCensusTx":
+		tx := gtx.(*types.UpdateCensusTx)
+		if err := UpdateCensusTxCheck(tx, state); err != nil {
+			return []byte{}, err
+		}
+		if commit {
+			pid, err := hex.DecodeString(tx.ProcessID)
+			if err != nil {
+				return []byte{}, err
+			}
+			if err := state.UpdateCensus(pid, tx.MkRoot, tx.MkURI, tx.AcceptPreviousMkRoots); err != nil {
+				return []byte{}, err
+			}
+			return []byte{}, useNonce(state, tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce)
 		}
 
 	case "NewProcessTx":
@@ -79,17 +135,71 @@ func AddTx(gtx GenericTX, state *State, commit bool) ([]byte, error) {
 				if err != nil {
 					return []byte{}, err
 				}
-				return []byte{}, state.AddProcess(*p, pid, tx.MkURI)
+				if err := state.AddProcessAddressLists(pid, addressList(tx.AllowList), addressList(tx.DenyList)); err != nil {
+					return []byte{}, err
+				}
+				if p.CensusCA != nil && p.CensusCA.Type == types.CensusCABlindRSA {
+					if err := state.AddCensusCAKey(pid, p.CensusCA); err != nil {
+						return []byte{}, err
+					}
+				}
+				if err := state.AddProcess(*p, pid, tx.MkURI); err != nil {
+					return []byte{}, err
+				}
+				return []byte{}, useNonce(state, tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), tx.Nonce)
 			}
 		} else {
 			return []byte{}, err
 		}
+	case "CoSignTx":
+		tx := gtx.(*types.CoSignTx)
+		cosignedTx, cosigned, err := CoSignTxCheck(tx, state)
+		if err != nil {
+			return []byte{}, err
+		}
+		if cosigned.Executed {
+			// the transaction is checked and executed as if it was sent with all the signatures
+			if _, err := AddTx(cosignedTx, state, commit); err != nil {
+				return []byte{}, err
+			}
+		}
+		if commit {
+			return []byte{}, state.SetCoSignedTx(tx.SignedBytes, cosigned)
+		}
 	default:
 		return []byte{}, fmt.Errorf("transaction type invalid")
 	}
 	return []byte{}, nil
 }
 
+// RecheckTx validates again a transaction of the mempool once a new block has been committed,
+// so the transactions which are no longer valid are evicted before being proposed on a block.
+// Votes are lazily checked (see VoteTxRecheck), while the rest of transactions are fully checked.
+func RecheckTx(gtx GenericTX, state *State) error {
+	switch gtx.TxType() {
+	case "VoteTx":
+		return VoteTxRecheck(gtx.(*types.VoteTx), state)
+	case "AdminTx":
+		return AdminTxCheck(gtx.(*types.AdminTx), state)
+	case "CancelProcessTx":
+		return CancelProcessTxCheck(gtx.(*types.CancelProcessTx), state)
+	case "PauseProcessTx":
+		return PauseProcessTxCheck(gtx.(*types.PauseProcessTx), state)
+	case "UpdateCensusTx":
+		return UpdateCensusTxCheck(gtx.(*types.UpdateCensusTx), state)
+	case "NewProcessTx":
+		_, err := NewProcessTxCheck(gtx.(*types.NewProcessTx), state)
+		return err
+	case "CoSignTx":
+		cosignedTx, cosigned, err := CoSignTxCheck(gtx.(*types.CoSignTx), state)
+		if err != nil || !cosigned.Executed {
+			return err
+		}
+		return RecheckTx(cosignedTx, state)
+	}
+	return fmt.Errorf("transaction type invalid")
+}
+
 // UnmarshalTx splits a tx into method and args parts and does some basic checks
 func UnmarshalTx(content []byte) (GenericTX, error) {
 	var txType types.Tx
@@ -124,14 +234,15 @@ func UnmarshalTx(content []byte) (GenericTX, error) {
 		if err := json.Unmarshal(content, &tx); err != nil {
 			return nil, fmt.Errorf("cannot parse AdminTx")
 		}
-		signature := tx.Signature
-		tx.Signature = ""
+		// co-signers sign the same bytes, so all the signatures are removed
+		signature, signatures := tx.Signature, tx.Signatures
+		tx.Signature, tx.Signatures = "", nil
 		signedBytes, err := json.Marshal(tx)
 		if err != nil {
 			return nil, fmt.Errorf("cannot marshal voteTX (%s)", err)
 		}
 		tx.SignedBytes = signedBytes
-		tx.Signature = signature
+		tx.Signature, tx.Signatures = signature, signatures
 		tx.EncryptionPrivateKey = util.TrimHex(tx.EncryptionPrivateKey)
 		tx.ProcessID = util.TrimHex(tx.ProcessID)
 		tx.EncryptionPublicKey = util.TrimHex(tx.EncryptionPublicKey)
@@ -144,14 +255,15 @@ func UnmarshalTx(content []byte) (GenericTX, error) {
 		if err := json.Unmarshal(content, &tx); err != nil {
 			return nil, fmt.Errorf("cannot parse NewProcessTx")
 		}
-		signature := tx.Signature
-		tx.Signature = ""
+		// co-signers sign the same bytes, so all the signatures are removed
+		signature, signatures := tx.Signature, tx.Signatures
+		tx.Signature, tx.Signatures = "", nil
 		signedBytes, err := json.Marshal(tx)
 		if err != nil {
 			return nil, fmt.Errorf("cannot marshal: (%s)", err)
 		}
 		tx.SignedBytes = signedBytes
-		tx.Signature = signature
+		tx.Signature, tx.Signatures = signature, signatures
 		tx.EntityID = util.TrimHex(tx.EntityID)
 		tx.ProcessID = util.TrimHex(tx.ProcessID)
 		tx.MkRoot = util.TrimHex(tx.MkRoot)
@@ -162,20 +274,127 @@ func UnmarshalTx(content []byte) (GenericTX, error) {
 		if err := json.Unmarshal(content, &tx); err != nil {
 			return nil, fmt.Errorf("cannot parse CancelProcessTx")
 		}
-		signature := tx.Signature
-		tx.Signature = ""
+		// co-signers sign the same bytes, so all the signatures are removed
+		signature, signatures := tx.Signature, tx.Signatures
+		tx.Signature, tx.Signatures = "", nil
 		signedBytes, err := json.Marshal(tx)
 		if err != nil {
 			return nil, fmt.Errorf("cannot marshal: (%s)", err)
 		}
 		tx.SignedBytes = signedBytes
-		tx.Signature = signature
+		tx.Signature, tx.Signatures = signature, signatures
+		tx.ProcessID = util.TrimHex(tx.ProcessID)
+		return &tx, nil
+
+	case "PauseProcessTx":
+		var tx types.PauseProcessTx
+		if err := json.Unmarshal(content, &tx); err != nil {
+			return nil, fmt.Errorf("cannot parse PauseProcessTx")
+		}
+		// co-signers sign the same bytes, so all the signatures are removed
+		signature, signatures := tx.Signature, tx.Signatures
+		tx.Signature, tx.Signatures = "", nil
+		signedBytes, err := json.Marshal(tx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot marshal: (%s)", err)
+		}
+		tx.SignedBytes = signedBytes
+		tx.Signature, tx.Signatures = signature, signatures
 		tx.ProcessID = util.TrimHex(tx.ProcessID)
 		return &tx, nil
+
+	case "UpdateCensusTx":
+		var tx types.UpdateCensusTx
+		if err := json.Unmarshal(content, &tx); err != nil {
+			return nil, fmt.Errorf("cannot parse UpdateCensusTx")
+		}
+		// co-signers sign the same bytes, so all the signatures are removed
+		signature, signatures := tx.Signature, tx.Signatures
+		tx.Signature, tx.Signatures = "", nil
+		signedBytes, err := json.Marshal(tx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot marshal: (%s)", err)
+		}
+		tx.SignedBytes = signedBytes
+		tx.Signature, tx.Signatures = signature, signatures
+		tx.ProcessID = util.TrimHex(tx.ProcessID)
+		tx.MkRoot = util.TrimHex(tx.MkRoot)
+		return &tx, nil
+
+	case "CoSignTx":
+		var tx types.CoSignTx
+		if err := json.Unmarshal(content, &tx); err != nil {
+			return nil, fmt.Errorf("cannot parse CoSignTx")
+		}
+		// the oracles co-sign the signed bytes of the privileged transaction
+		cosignedTx, err := UnmarshalTx(tx.Tx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse co-signed transaction: (%s)", err)
+		}
+		if tx.SignedBytes, _, _, err = oracleTxFields(cosignedTx); err != nil {
+			return nil, err
+		}
+		return &tx, nil
 	}
 	return nil, fmt.Errorf("invalid transaction type")
 }
 
+// newTxReference builds the index reference of a delivered transaction.
+// tx might be nil if the raw transaction cannot be unmarshaled and data is the
+// result of AddTx (the nullifier for votes). Height and Index are set by the caller.
+func newTxReference(rawTx []byte, tx GenericTX, data []byte, txErr error) *types.TxReference {
+	ref := &types.TxReference{Hash: fmt.Sprintf("%x", tmtypes.Tx(rawTx).Hash())}
+	if txErr != nil {
+		ref.Code = 1
+		ref.Error = txErr.Error()
+	}
+	var signedBytes []byte
+	var signatures []string
+	switch tx := tx.(type) {
+	case *types.VoteTx:
+		ref.Type, ref.ProcessID = tx.Type, tx.ProcessID
+		if len(data) > 0 {
+			ref.Nullifier = fmt.Sprintf("%x", data)
+		}
+		if tx.Signer != "" {
+			// already recovered by VoteTxCheck
+			ref.Signers = []string{tx.Signer}
+		} else {
+			signedBytes, signatures = tx.SignedBytes, txSignatures(tx.Signature, nil)
+		}
+	case *types.AdminTx:
+		ref.Type, ref.ProcessID = tx.Type, tx.ProcessID
+		signedBytes, signatures = tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures)
+	case *types.NewProcessTx:
+		ref.Type, ref.ProcessID = tx.Type, tx.ProcessID
+		signedBytes, signatures = tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures)
+	case *types.CancelProcessTx:
+		ref.Type, ref.ProcessID = tx.Type, tx.ProcessID
+		signedBytes, signatures = tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures)
+	case *types.PauseProcessTx:
+		ref.Type, ref.ProcessID = tx.Type, tx.ProcessID
+		signedBytes, signatures = tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures)
+	case *types.UpdateCensusTx:
+		ref.Type, ref.ProcessID = tx.Type, tx.ProcessID
+		signedBytes, signatures = tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures)
+	case *types.CoSignTx:
+		ref.Type = tx.Type
+		if cosignedTx, err := UnmarshalTx(tx.Tx); err == nil {
+			cosignedRef := newTxReference(tx.Tx, cosignedTx, nil, nil)
+			ref.ProcessID, ref.Signers = cosignedRef.ProcessID, cosignedRef.Signers
+		}
+	}
+	for _, signature := range signatures {
+		addr, err := ethereum.AddrFromSignature(signedBytes, signature)
+		if err != nil {
+			// snark votes and invalid transactions might not have a recoverable signer
+			continue
+		}
+		ref.Signers = append(ref.Signers, addr.Hex())
+	}
+	return ref
+}
+
 // VoteTxCheck is an abstraction of ABCI checkTx for submitting a vote
 // All hexadecimal strings should be already sanitized (without 0x)
 func VoteTxCheck(tx *types.VoteTx, state *State, forCommit bool) (*types.Vote, error) {
@@ -194,105 +413,335 @@ func VoteTxCheck(tx *types.VoteTx, state *State, forCommit bool) (*types.Vote, e
 	if header == nil {
 		return nil, fmt.Errorf("cannot obtain state header")
 	}
-	height := header.Height
-	endBlock := process.StartBlock + process.NumberOfBlocks
+	if err := CheckVoteWindow(process, header.Height, header.Time); err != nil {
+		return nil, err
+	}
+
+	switch process.Type {
+
+	case types.SnarkVote:
+		// Snark votes are anonymous, the voter proves its census membership with a
+		// zk-SNARK and provides its own nullifier, so no signature is required.
+		var vote types.Vote
+		vote.ProcessID = pid
+		vote.VotePackage = tx.VotePackage
+		if err := checkEncryptionKeyIndexes(process, tx.EncryptionKeyIndexes); err != nil {
+			return nil, err
+		}
+		vote.EncryptionKeyIndexes = tx.EncryptionKeyIndexes
+		if !util.IsHexEncodedStringWithLength(tx.Nullifier, types.VoteNullifierSize) {
+			return nil, fmt.Errorf("malformed nullifier")
+		}
+		if vote.Nullifier, err = hex.DecodeString(tx.Nullifier); err != nil {
+			return nil, err
+		}
+		if err := checkVoteOverwrite(state, process, vote.ProcessID, vote.Nullifier, forCommit); err != nil {
+			return nil, err
+		}
 
-	if (height >= process.StartBlock && height <= endBlock) && !process.Canceled && !process.Paused {
+		// The nullifier is the cache identifier, so the proof is only verified once
+		// (unless the census has been updated since then). The cached proof is bound
+		// to the transaction digest, since another transaction with the same nullifier
+		// might carry a different vote package or proof.
+		uid := tx.UniqID(process.Type)
+		digest := voteTxDigest(tx)
+		if forCommit {
+			if vp := popVoteProof(state, process, uid, digest); vp != nil {
+				return &vote, nil
+			}
+		}
+		if tx.ZkProof == nil {
+			return nil, fmt.Errorf("missing zk-SNARK census proof")
+		}
+		root, err := checkSnarkProof(process, vote.ProcessID, vote.Nullifier, vote.VotePackage, tx.ZkProof)
+		if err != nil {
+			return nil, fmt.Errorf("cannot check zk-SNARK proof: (%s)", err)
+		}
+		if root == "" {
+			return nil, fmt.Errorf("zk-SNARK proof not valid")
+		}
+		if !forCommit {
+			state.VoteCacheAdd(uid, &types.VoteProof{MkRoot: root, Nullifier: vote.Nullifier, TxDigest: digest})
+		}
+		return &vote, nil
 
-		// Check in case of keys required, they have been sent by some keykeeper
-		if process.RequireKeys() && process.KeyIndex < 1 {
-			return nil, fmt.Errorf("no keys available, voting is not possible")
+	case types.PollVote, types.PetitionSign, types.EncryptedPoll, types.WeightedPoll, types.OpenPoll:
+		var vote types.Vote
+		vote.ProcessID, err = hex.DecodeString(tx.ProcessID)
+		if err != nil {
+			return nil, err
 		}
+		vote.VotePackage = tx.VotePackage
 
-		switch process.Type {
+		if types.ProcessIsEncrypted[process.Type] {
+			if err := checkEncryptionKeyIndexes(process, tx.EncryptionKeyIndexes); err != nil {
+				return nil, err
+			}
+			vote.EncryptionKeyIndexes = tx.EncryptionKeyIndexes
+		} else if err := checkVotePackage(process, vote.VotePackage); err != nil {
+			// encrypted vote packages are checked by the scrutinizer once decrypted
+			return nil, fmt.Errorf("invalid vote package: (%s)", err)
+		}
 
-		case types.SnarkVote:
-			// TODO check snark
-			return nil, fmt.Errorf("snark vote not implemented")
+		// In order to avoid double vote check (on checkTx and deliverTx), we use a vote cache.
+		// An element can only be added to the vote cache during checkTx and it is consumed on deliverTx.
+		// The votes which are not included in the blockchain after voteCacheTTL blocks will be removed from the cache.
+		// On checkTx the vote is always verified and its cache entry overwritten, since the mempool
+		// does not survive a restart while the cache does, so a cache hit is not a duplicated vote.
+		// The cached proof is only trusted for the same transaction (the signature prefix
+		// identifier is not enough, the vote package or weight might differ) and if the
+		// census has not been updated since then.
+		uid := tx.UniqID(process.Type)
+		digest := voteTxDigest(tx)
+		var vp *types.VoteProof
+		if forCommit {
+			vp = popVoteProof(state, process, uid, digest)
+		}
 
-		case types.PollVote, types.PetitionSign, types.EncryptedPoll:
-			var vote types.Vote
-			vote.ProcessID, err = hex.DecodeString(tx.ProcessID)
-			if err != nil {
+		if vp != nil {
+			// if vote is in cache, lazy check
+			if err := checkVoteOverwrite(state, process, vote.ProcessID, vp.Nullifier, forCommit); err != nil {
 				return nil, err
 			}
-			vote.VotePackage = tx.VotePackage
+			if addr, err := ethereum.AddrFromPublicKey(vp.PubKey); err == nil {
+				tx.Signer = addr.Hex()
+			}
+		} else {
+			// if not in cache, extract pubKey, generate nullifier and check merkle proof
+			vp = &types.VoteProof{TxDigest: digest}
 
-			if types.ProcessIsEncrypted[process.Type] {
-				if len(tx.EncryptionKeyIndexes) == 0 {
-					return nil, fmt.Errorf("no key indexes provided on vote package")
-				}
-				vote.EncryptionKeyIndexes = tx.EncryptionKeyIndexes
+			log.Debugf("vote Payload: %s", tx.SignedBytes)
+			vp.PubKey, err = ethereum.PubKeyFromSignature(tx.SignedBytes, tx.Signature)
+			if err != nil {
+				return nil, fmt.Errorf("cannot extract public key from signature (%s)", err)
 			}
+			addr, err := ethereum.AddrFromPublicKey(vp.PubKey)
+			if err != nil {
+				return nil, fmt.Errorf("cannot extract address from public key: (%s)", err)
+			}
+			log.Debugf("extracted public key: %s", vp.PubKey)
+			tx.Signer = addr.Hex()
 
-			// In order to avoid double vote check (on checkTx and deliverTx), we use a memory vote cache.
-			// An element can only be added to the vote cache during checkTx.
-			// Every 60 seconds (6 blocks) the old votes which are not yet in the blockchain will be removed from the cache.
-			// If the same vote (but different transaction) is send to the mempool, the cache will detect it and vote will be discarted.
-			uid := tx.UniqID(process.Type)
-			vp := state.VoteCacheGet(uid)
+			// assign a nullifier
+			vp.Nullifier = GenerateNullifier(addr, vote.ProcessID)
+			log.Debugf("generated new vote nullifier: %x", vp.Nullifier)
 
-			if forCommit && vp != nil {
-				// if vote is in cache, lazy check and remove it from cache
-				defer state.VoteCacheDel(uid)
-				if state.EnvelopeExists(vote.ProcessID, vp.Nullifier) {
-					return nil, fmt.Errorf("vote already exists")
-				}
-			} else {
-				if vp != nil {
-					return nil, fmt.Errorf("vote already exist in cache")
-				}
-				// if not in cache, extract pubKey, generate nullifier and check merkle proof
-				vp = new(types.VoteProof)
+			// check if vote exists and can be overwritten
+			if err := checkVoteOverwrite(state, process, vote.ProcessID, vp.Nullifier, forCommit); err != nil {
+				return nil, err
+			}
 
-				log.Debugf("vote Payload: %s", tx.SignedBytes)
-				vp.PubKey, err = ethereum.PubKeyFromSignature(tx.SignedBytes, tx.Signature)
-				if err != nil {
-					return nil, fmt.Errorf("cannot extract public key from signature (%s)", err)
+			// open-poll processes have no census, any address allowed by the oracle lists can vote
+			if process.Type == types.OpenPoll {
+				if !state.IsAddressAllowed(vote.ProcessID, process, addr, false) {
+					return nil, fmt.Errorf("address %s is not allowed to vote", addr.Hex())
 				}
-				addr, err := ethereum.AddrFromPublicKey(vp.PubKey)
-				if err != nil {
-					return nil, fmt.Errorf("cannot extract address from public key: (%s)", err)
-				}
-				log.Debugf("extracted public key: %s", vp.PubKey)
-
-				// assign a nullifier
-				vp.Nullifier = GenerateNullifier(addr, vote.ProcessID)
-				log.Debugf("generated new vote nullifier: %x", vp.Nullifier)
-
-				// check if vote exists
-				if state.EnvelopeExists(vote.ProcessID, vp.Nullifier) {
-					return nil, fmt.Errorf("vote already exists")
+				if tx.Weight != "" {
+					return nil, fmt.Errorf("vote weight is only allowed on weighted processes")
 				}
-
-				// check merkle proof
-				vp.Proof = tx.Proof
-				pubKeyDec, err := hex.DecodeString(vp.PubKey)
-				if err != nil {
+			} else if process.TokenCensus != nil {
+				if err := checkVoteTokenBalance(tx, process, addr, vp); err != nil {
 					return nil, err
 				}
-				vp.PubKeyDigest = snarks.Poseidon.Hash(pubKeyDec)
-				if len(vp.PubKeyDigest) != 32 {
-					return nil, fmt.Errorf("cannot compute Poseidon hash: (%s)", err)
+			} else if process.CensusCA != nil {
+				if tx.Weight != "" {
+					return nil, fmt.Errorf("vote weight is only allowed on weighted processes")
 				}
-				valid, err := checkMerkleProof(process.MkRoot, vp.Proof, vp.PubKeyDigest)
-				if err != nil {
-					return nil, fmt.Errorf("cannot check merkle proof: (%s)", err)
-				}
-				if !valid {
-					return nil, fmt.Errorf("proof not valid")
+				if err := checkCAProof(process.CensusCA, vote.ProcessID, addr, tx.CAProof); err != nil {
+					return nil, err
 				}
-				vp.Created = time.Now()
+			} else if err := checkVoteCensusProof(tx, process, vp); err != nil {
+				return nil, err
+			}
+			if !forCommit {
 				state.VoteCacheAdd(uid, vp)
 			}
-			vote.Nullifier = vp.Nullifier
-			return &vote, nil
+		}
+		vote.Nullifier = vp.Nullifier
+		vote.Weight = vp.Weight
+		return &vote, nil
+
+	default:
+		return nil, fmt.Errorf("invalid process type")
+	}
+}
+
+// voteTxDigest returns the hash of the whole vote transaction, used to bind a cached
+// vote proof to the exact transaction it was verified for
+func voteTxDigest(tx *types.VoteTx) []byte {
+	txBytes, err := json.Marshal(tx)
+	if err != nil {
+		log.Warnf("cannot marshal vote transaction: (%s)", err)
+		return nil
+	}
+	return ethereum.HashRaw(txBytes)
+}
+
+// popVoteProof fetches and removes the cached vote proof of a transaction on deliverTx.
+// Returns nil if the proof was verified for a different transaction with the same
+// identifier or if the census has been updated since then, so a full check is required.
+func popVoteProof(state *State, process *types.Process, uid string, digest []byte) *types.VoteProof {
+	vp := state.VoteCachePop(uid)
+	if vp == nil || len(digest) == 0 || !bytes.Equal(vp.TxDigest, digest) {
+		return nil
+	}
+	if !process.IsValidCensusRoot(vp.MkRoot) {
+		return nil
+	}
+	return vp
+}
+
+// checkVoteTokenBalance checks the ethereum storage proof of the voter token balance, which is
+// stored on vp as the voting weight
+func checkVoteTokenBalance(tx *types.VoteTx, process *types.Process, addr ethcommon.Address, vp *types.VoteProof) error {
+	if tx.EthProof == nil {
+		return fmt.Errorf("missing ethereum storage proof")
+	}
+	if tx.Weight != "" {
+		return fmt.Errorf("vote weight is the token balance, it cannot be provided")
+	}
+	accountProof, err := decodeHexList(tx.EthProof.AccountProof)
+	if err != nil {
+		return fmt.Errorf("cannot decode account proof: (%s)", err)
+	}
+	storageProof, err := decodeHexList(tx.EthProof.StorageProof)
+	if err != nil {
+		return fmt.Errorf("cannot decode storage proof: (%s)", err)
+	}
+	tc := process.TokenCensus
+	balance, err := ethereum.VerifyTokenBalance(ethcommon.HexToHash(tc.StateRoot),
+		ethcommon.HexToAddress(tc.TokenAddress), addr, tc.StorageSlot, accountProof, storageProof)
+	if err != nil {
+		return err
+	}
+	weight := new(big.Int).Div(balance, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(tc.Decimals)), nil))
+	if weight.Sign() == 0 {
+		return fmt.Errorf("not enough token balance for voting")
+	}
+	if weight.Cmp(new(big.Int).SetUint64(types.MaxVoteWeight)) > 0 {
+		return fmt.Errorf("token balance too big, the process decimals should be increased")
+	}
+	vp.Weight = weight.Uint64()
+	return nil
+}
+
+// checkVoteCensusProof checks the merkle census proof of a vote, whose public key and
+// nullifier are already set on vp. The proof data and the voting weight are stored on vp.
+func checkVoteCensusProof(tx *types.VoteTx, process *types.Process, vp *types.VoteProof) error {
+	vp.Proof = tx.Proof
+	pubKeyDec, err := hex.DecodeString(vp.PubKey)
+	if err != nil {
+		return err
+	}
+	vp.PubKeyDigest = snarks.Poseidon.Hash(pubKeyDec)
+	if len(vp.PubKeyDigest) != 32 {
+		return fmt.Errorf("cannot compute Poseidon hash: (%s)", err)
+	}
+	// on weighted processes the census claim value is the voting weight
+	claimValue := []byte{}
+	if process.Type == types.WeightedPoll {
+		if vp.Weight, err = strconv.ParseUint(tx.Weight, 10, 64); err != nil || vp.Weight == 0 {
+			return fmt.Errorf("invalid vote weight %q", tx.Weight)
+		}
+		if vp.Weight > types.MaxVoteWeight {
+			return fmt.Errorf("vote weight must not be greater than %d", uint64(types.MaxVoteWeight))
+		}
+		claimValue = types.EncodeCensusWeight(vp.Weight)
+	} else if tx.Weight != "" {
+		return fmt.Errorf("vote weight is only allowed on weighted processes")
+	}
+	if vp.MkRoot, err = checkCensusProof(process, vp.Proof, vp.PubKeyDigest, claimValue); err != nil {
+		return fmt.Errorf("cannot check merkle proof: (%s)", err)
+	}
+	if vp.MkRoot == "" {
+		return fmt.Errorf("proof not valid")
+	}
+	return nil
+}
 
-		default:
-			return nil, fmt.Errorf("invalid process type")
+// CheckVoteWindow returns an error if the process does not accept votes on a block of the given
+// height and time, either because of the block (or time) frame, because it is canceled or paused,
+// or because the required keys are not yet available
+func CheckVoteWindow(process *types.Process, height int64, blockTime time.Time) error {
+	if !process.Started(height, blockTime) || process.Finished(height, blockTime) || process.Canceled || process.Paused {
+		return fmt.Errorf("cannot add vote, invalid block frame or process canceled/paused")
+	}
+	// Check in case of keys required, they have been sent by some keykeeper
+	if process.RequireKeys() && process.KeyIndex < 1 {
+		return fmt.Errorf("no keys available, voting is not possible")
+	}
+	// threshold keys require at least KeyThreshold dealers, so no KeyThreshold-1 keykeepers can decrypt the votes
+	if process.IsThresholdEncrypted() && process.KeyDealers() < int(process.KeyThreshold) {
+		return fmt.Errorf("threshold encryption key not generated yet, voting is not possible")
+	}
+	return nil
+}
+
+// VoteTxRecheck validates again a vote of the mempool once a new block has been committed.
+// The vote proof stored on the vote cache by VoteTxCheck is reused, so the signature and the
+// census proof are not verified again. If the vote is no longer valid, it is removed from the
+// vote cache so the voter can send it again.
+func VoteTxRecheck(tx *types.VoteTx, state *State) error {
+	pid, err := hex.DecodeString(tx.ProcessID)
+	if err != nil {
+		return err
+	}
+	process, err := state.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	if process == nil {
+		return fmt.Errorf("process with id (%x) does not exist", pid)
+	}
+	header := state.Header(false)
+	if header == nil {
+		return fmt.Errorf("cannot obtain state header")
+	}
+	uid := tx.UniqID(process.Type)
+	vp := state.VoteCacheGet(uid)
+	// the vote can only be included on the next block, whose time is not known yet
+	if err := CheckVoteWindow(process, header.Height+1, header.Time); err != nil {
+		if vp != nil {
+			state.VoteCacheDel(uid)
 		}
+		return err
+	}
+	if vp != nil && (!bytes.Equal(vp.TxDigest, voteTxDigest(tx)) || !process.IsValidCensusRoot(vp.MkRoot)) {
+		// the vote proof belongs to another transaction or the census has been updated,
+		// so the vote proof must be verified again
+		state.VoteCacheDel(uid)
+		vp = nil
+	}
+	if vp == nil {
+		// the vote proof has been purged from the cache, a full check is required
+		_, err := VoteTxCheck(tx, state, false)
+		return err
+	}
+	if err := checkVoteOverwrite(state, process, pid, vp.Nullifier, false); err != nil {
+		state.VoteCacheDel(uid)
+		return err
 	}
-	return nil, fmt.Errorf("cannot add vote, invalid block frame or process canceled/paused")
+	return nil
+}
+
+// checkVoteOverwrite returns an error if the voter already voted and the process does not
+// allow to overwrite the vote once more. On checkTx the committed state is used, while on
+// deliverTx the working state is used so the votes of the same block are also considered.
+func checkVoteOverwrite(state *State, process *types.Process, pid, nullifier []byte, forCommit bool) error {
+	count, exists, err := state.VoteOverwrites(pid, nullifier, !forCommit)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	if process.MaxVoteOverwrites == 0 {
+		return fmt.Errorf("vote already exists")
+	}
+	if count >= process.MaxVoteOverwrites {
+		return fmt.Errorf("vote already overwritten %d times", count)
+	}
+	return nil
 }
 
 // NewProcessTxCheck is an abstraction of ABCI checkTx for creating a new process
@@ -324,20 +773,37 @@ func NewProcessTxCheck(tx *types.NewProcessTx, state *State) (*types.Process, er
 	if header == nil {
 		return nil, fmt.Errorf("cannot fetch state header")
 	}
-	// start and endblock sanity check
-	if tx.StartBlock < header.Height {
-		return nil, fmt.Errorf("cannot add process with start block lower or equal than the current tendermint height")
-	}
-	if tx.NumberOfBlocks <= 0 {
-		return nil, fmt.Errorf("cannot add process with duration lower or equal than the current tendermint height")
+	// start and end sanity check, a process is either scheduled by block heights or by timestamps
+	if tx.StartTime > 0 || tx.EndTime > 0 {
+		if tx.StartBlock != 0 || tx.NumberOfBlocks != 0 {
+			return nil, fmt.Errorf("cannot add process scheduled by both block heights and timestamps")
+		}
+		if tx.StartTime < header.Time.Unix() {
+			return nil, fmt.Errorf("cannot add process with start time lower than the current block time")
+		}
+		if tx.EndTime <= tx.StartTime {
+			return nil, fmt.Errorf("cannot add process with end time lower or equal than its start time")
+		}
+	} else {
+		if tx.StartBlock < header.Height {
+			return nil, fmt.Errorf("cannot add process with start block lower or equal than the current tendermint height")
+		}
+		if tx.NumberOfBlocks <= 0 {
+			return nil, fmt.Errorf("cannot add process with duration lower or equal than the current tendermint height")
+		}
 	}
 
-	authorized, addr, err := verifySignatureAgainstOracles(oracles, tx.SignedBytes, tx.Signature)
+	thresholds, err := state.OracleThresholds(false)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("cannot get oracle thresholds: (%s)", err)
 	}
-	if !authorized {
-		return nil, fmt.Errorf("unauthorized to create a process, recovered addr: %s\nProcessTX: %s", addr.Hex(), tx.SignedBytes)
+	signers, err := verifyOracleSignatures(oracles, tx.SignedBytes,
+		txSignatures(tx.Signature, tx.Signatures), int(thresholds.NewProcess))
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized to create a process: (%s)\nProcessTX: %s", err, tx.SignedBytes)
+	}
+	if err := checkNonce(state, signers, tx.Nonce); err != nil {
+		return nil, err
 	}
 	// get process
 	_, err = state.Process(pid, false)
@@ -346,17 +812,88 @@ func NewProcessTxCheck(tx *types.NewProcessTx, state *State) (*types.Process, er
 	}
 	// check type
 	switch tx.ProcessType {
-	case types.SnarkVote, types.PollVote, types.PetitionSign, types.EncryptedPoll:
+	case types.SnarkVote, types.PollVote, types.PetitionSign, types.EncryptedPoll, types.WeightedPoll, types.OpenPoll:
 		// ok
 	default:
 		return nil, fmt.Errorf("process type (%s) not valid", tx.ProcessType)
 	}
+	// open-poll processes have no census, but the oracle might restrict who can vote
+	if tx.ProcessType == types.OpenPoll {
+		if tx.MkRoot != "" {
+			return nil, fmt.Errorf("census root is not allowed on %s processes", types.OpenPoll)
+		}
+		if err := checkAddressList(tx.AllowList); err != nil {
+			return nil, fmt.Errorf("invalid allow list: (%s)", err)
+		}
+		if err := checkAddressList(tx.DenyList); err != nil {
+			return nil, fmt.Errorf("invalid deny list: (%s)", err)
+		}
+	} else if len(tx.AllowList)+len(tx.DenyList) > 0 {
+		return nil, fmt.Errorf("address lists are only allowed on %s processes", types.OpenPoll)
+	}
+	if tx.TokenCensus != nil {
+		if err := checkTokenCensus(tx); err != nil {
+			return nil, fmt.Errorf("invalid token census: (%s)", err)
+		}
+	}
+	if tx.CensusCA != nil {
+		if err := checkCensusCA(tx, state); err != nil {
+			return nil, fmt.Errorf("invalid census CA: (%s)", err)
+		}
+		tx.CensusCA.PublicKey = util.TrimHex(tx.CensusCA.PublicKey)
+	}
+	// snark votes require the circuit verification key and a census root usable as circuit input
+	if tx.ProcessType == types.SnarkVote {
+		if err := snarks.ValidateVerificationKey(tx.ZkVerificationKey, snarkVoteInputs); err != nil {
+			return nil, fmt.Errorf("invalid zk-SNARK verification key: (%s)", err)
+		}
+		if !isSnarkCensusRoot(tx.MkRoot) {
+			return nil, fmt.Errorf("census root is not a valid zk-SNARK field element")
+		}
+	} else if tx.ZkVerificationKey != nil {
+		return nil, fmt.Errorf("verification key is only allowed on %s processes", types.SnarkVote)
+	}
+	if tx.BallotRules != nil {
+		if err := tx.BallotRules.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid ballot rules: (%s)", err)
+		}
+	}
+	if tx.KeyThreshold > 0 {
+		if !types.ProcessIsEncrypted[tx.ProcessType] {
+			return nil, fmt.Errorf("key threshold is only allowed on encrypted processes")
+		}
+		if tx.KeyThreshold >= types.MaxKeyIndex {
+			return nil, fmt.Errorf("key threshold must be lower than %d", types.MaxKeyIndex)
+		}
+	}
 	p := &types.Process{
-		EntityID:       eid,
-		MkRoot:         tx.MkRoot,
-		NumberOfBlocks: tx.NumberOfBlocks,
-		StartBlock:     tx.StartBlock,
-		Type:           tx.ProcessType,
+		AllowListSize:     len(addressList(tx.AllowList)),
+		BallotRules:       tx.BallotRules,
+		CensusCA:          tx.CensusCA,
+		DenyListSize:      len(addressList(tx.DenyList)),
+		EndTime:           tx.EndTime,
+		EntityID:          eid,
+		KeyThreshold:      tx.KeyThreshold,
+		MaxVoteOverwrites: tx.MaxVoteOverwrites,
+		MkRoot:            tx.MkRoot,
+		MkURI:             tx.MkURI,
+		NumberOfBlocks:    tx.NumberOfBlocks,
+		StartBlock:        tx.StartBlock,
+		StartTime:         tx.StartTime,
+		TokenCensus:       tx.TokenCensus,
+		Type:              tx.ProcessType,
+		ZkVerificationKey: tx.ZkVerificationKey,
+	}
+	// the processes created without ballot rules from the genesis ballot rules height get the
+	// default ones, the older ones keep no rules so their votes are replayed without checks
+	if p.BallotRules == nil {
+		height, err := state.BallotRulesHeight(false)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get ballot rules height: (%s)", err)
+		}
+		if height > 0 && header.Height >= height {
+			p.BallotRules = types.DefaultBallotRules()
+		}
 	}
 
 	if p.RequireKeys() {
@@ -365,6 +902,14 @@ func NewProcessTxCheck(tx *types.NewProcessTx, state *State) (*types.Process, er
 		p.EncryptionPrivateKeys = make([]string, types.MaxKeyIndex)
 		p.CommitmentKeys = make([]string, types.MaxKeyIndex)
 		p.RevealKeys = make([]string, types.MaxKeyIndex)
+		if p.IsThresholdEncrypted() {
+			p.KeyDeals = make([]types.KeyDeal, types.MaxKeyIndex)
+		}
+	}
+	// the keykeepers must publish their keys before the threshold key generation phases
+	if p.IsThresholdEncrypted() && p.KeyGenerationPhase(header.Height, header.Time) != types.KeyPhaseKeys {
+		return nil, fmt.Errorf("threshold encrypted processes must start more than %d blocks (or %d seconds) later",
+			3*types.KeyGenerationPhaseBlocks, 3*types.KeyGenerationPhaseSeconds)
 	}
 	return p, nil
 }
@@ -384,13 +929,18 @@ func CancelProcessTxCheck(tx *types.CancelProcessTx, state *State) error {
 	if err != nil || len(oracles) == 0 {
 		return fmt.Errorf("cannot check authorization against a nil or empty oracle list")
 	}
-	// check signature
-	authorized, addr, err := verifySignatureAgainstOracles(oracles, tx.SignedBytes, tx.Signature)
+	// check signatures
+	thresholds, err := state.OracleThresholds(false)
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot get oracle thresholds: (%s)", err)
+	}
+	signers, err := verifyOracleSignatures(oracles, tx.SignedBytes,
+		txSignatures(tx.Signature, tx.Signatures), int(thresholds.CancelProcess))
+	if err != nil {
+		return fmt.Errorf("unauthorized to cancel a process: (%s)\nProcessTx: %s", err, tx.SignedBytes)
 	}
-	if !authorized {
-		return fmt.Errorf("unauthorized to cancel a process, recovered addr: %s\nProcessTx: %s", addr.Hex(), tx.SignedBytes)
+	if err := checkNonce(state, signers, tx.Nonce); err != nil {
+		return err
 	}
 	// get process
 	process, err := state.Process(pid, false)
@@ -401,18 +951,151 @@ func CancelProcessTxCheck(tx *types.CancelProcessTx, state *State) error {
 	if process.Canceled {
 		return fmt.Errorf("cannot cancel an already canceled process")
 	}
-	endBlock := process.StartBlock + process.NumberOfBlocks
 	var height int64
+	var blockTime time.Time
 	if h := state.Header(false); h != nil {
-		height = h.Height
+		height, blockTime = h.Height, h.Time
 	}
 
-	if endBlock < height {
+	if process.Finished(height, blockTime) {
 		return fmt.Errorf("cannot cancel a finalized process")
 	}
 	return nil
 }
 
+// PauseProcessTxCheck is an abstraction of ABCI checkTx for pausing or resuming an existing process
+func PauseProcessTxCheck(tx *types.PauseProcessTx, state *State) error {
+	// check format
+	if !util.IsHexEncodedStringWithLength(tx.ProcessID, types.ProcessIDsize) {
+		return fmt.Errorf("malformed processId")
+	}
+	if tx.Type != types.TxPauseProcess && tx.Type != types.TxResumeProcess {
+		return fmt.Errorf("invalid pause process tx type %s", tx.Type)
+	}
+	pid, err := hex.DecodeString(tx.ProcessID)
+	if err != nil {
+		return err
+	}
+	// get oracles
+	oracles, err := state.Oracles(false)
+	if err != nil || len(oracles) == 0 {
+		return fmt.Errorf("cannot check authorization against a nil or empty oracle list")
+	}
+	// check signatures
+	thresholds, err := state.OracleThresholds(false)
+	if err != nil {
+		return fmt.Errorf("cannot get oracle thresholds: (%s)", err)
+	}
+	signers, err := verifyOracleSignatures(oracles, tx.SignedBytes,
+		txSignatures(tx.Signature, tx.Signatures), int(thresholds.PauseProcess))
+	if err != nil {
+		return fmt.Errorf("unauthorized to %s: (%s)\nProcessTx: %s", tx.Type, err, tx.SignedBytes)
+	}
+	if err := checkNonce(state, signers, tx.Nonce); err != nil {
+		return err
+	}
+	// get process
+	process, err := state.Process(pid, false)
+	if err != nil {
+		return fmt.Errorf("cannot %s: %s", tx.Type, err)
+	}
+	if process.Canceled {
+		return fmt.Errorf("cannot %s a canceled process", tx.Type)
+	}
+	var height int64
+	var blockTime time.Time
+	if h := state.Header(false); h != nil {
+		height, blockTime = h.Height, h.Time
+	}
+	if process.Finished(height, blockTime) {
+		return fmt.Errorf("cannot %s a finalized process", tx.Type)
+	}
+	if tx.Type == types.TxPauseProcess && process.Paused {
+		return fmt.Errorf("process already paused")
+	}
+	if tx.Type == types.TxResumeProcess && !process.Paused {
+		return fmt.Errorf("cannot resume a non paused process")
+	}
+	return nil
+}
+
+// UpdateCensusTxCheck is an abstraction of ABCI checkTx for updating the census of an existing process
+func UpdateCensusTxCheck(tx *types.UpdateCensusTx, state *State) error {
+	// check format
+	if !util.IsHexEncodedStringWithLength(tx.ProcessID, types.ProcessIDsize) {
+		return fmt.Errorf("malformed processId")
+	}
+	if tx.Type != types.TxUpdateCensus {
+		return fmt.Errorf("invalid update census tx type %s", tx.Type)
+	}
+	if len(tx.MkRoot) == 0 {
+		return fmt.Errorf("missing census root")
+	}
+	if _, err := hex.DecodeString(tx.MkRoot); err != nil {
+		return fmt.Errorf("malformed census root")
+	}
+	pid, err := hex.DecodeString(tx.ProcessID)
+	if err != nil {
+		return err
+	}
+	// get oracles
+	oracles, err := state.Oracles(false)
+	if err != nil || len(oracles) == 0 {
+		return fmt.Errorf("cannot check authorization against a nil or empty oracle list")
+	}
+	// check signatures
+	thresholds, err := state.OracleThresholds(false)
+	if err != nil {
+		return fmt.Errorf("cannot get oracle thresholds: (%s)", err)
+	}
+	signers, err := verifyOracleSignatures(oracles, tx.SignedBytes,
+		txSignatures(tx.Signature, tx.Signatures), int(thresholds.UpdateCensus))
+	if err != nil {
+		return fmt.Errorf("unauthorized to update the census: (%s)\nProcessTx: %s", err, tx.SignedBytes)
+	}
+	if err := checkNonce(state, signers, tx.Nonce); err != nil {
+		return err
+	}
+	// get process
+	process, err := state.Process(pid, false)
+	if err != nil {
+		return fmt.Errorf("cannot update the census: %s", err)
+	}
+	if process.Canceled {
+		return fmt.Errorf("cannot update the census of a canceled process")
+	}
+	if process.Type == types.OpenPoll {
+		return fmt.Errorf("%s processes have no census", types.OpenPoll)
+	}
+	if process.TokenCensus != nil || process.CensusCA != nil {
+		return fmt.Errorf("the process census is not a merkle tree")
+	}
+	var height int64
+	var blockTime time.Time
+	if h := state.Header(false); h != nil {
+		height, blockTime = h.Height, h.Time
+	}
+	if process.Finished(height, blockTime) {
+		return fmt.Errorf("cannot update the census of a finalized process")
+	}
+	if tx.MkRoot == process.MkRoot {
+		return fmt.Errorf("census root is already the current one")
+	}
+	if process.Type == types.SnarkVote && !isSnarkCensusRoot(tx.MkRoot) {
+		return fmt.Errorf("census root is not a valid zk-SNARK field element")
+	}
+	return nil
+}
+
+// processKeysTxTypes are the admin transactions sent by the keykeepers for managing the keys of a process
+var processKeysTxTypes = map[string]bool{
+	types.TxAddProcessKeys:            true,
+	types.TxRevealProcessKeys:         true,
+	types.TxAddProcessKeyDeal:         true,
+	types.TxAddProcessKeyComplaint:    true,
+	types.TxRevealProcessKeyDealShare: true,
+}
+
 // AdminTxCheck is an abstraction of ABCI checkTx for an admin transaction
 func AdminTxCheck(tx *types.AdminTx, state *State) error {
 	// get oracles
@@ -421,14 +1104,54 @@ func AdminTxCheck(tx *types.AdminTx, state *State) error {
 		return fmt.Errorf("cannot check authorization against a nil or empty oracle list")
 	}
 
-	if authorized, addr, err := verifySignatureAgainstOracles(oracles, tx.SignedBytes, tx.Signature); err != nil {
+	thresholds, err := state.OracleThresholds(false)
+	if err != nil {
+		return fmt.Errorf("cannot get oracle thresholds: (%s)", err)
+	}
+	threshold := thresholds.Admin
+	if processKeysTxTypes[tx.Type] {
+		threshold = thresholds.ProcessKeys
+	}
+	signers, err := verifyOracleSignatures(oracles, tx.SignedBytes, txSignatures(tx.Signature, tx.Signatures), int(threshold))
+	if err != nil {
+		return fmt.Errorf("unauthorized to perform an adminTx: (%s)", err)
+	}
+	if err := checkNonce(state, signers, tx.Nonce); err != nil {
 		return err
-	} else if !authorized {
-		return fmt.Errorf("unauthorized to perform an adminTx, address: %s", addr.Hex())
 	}
 
 	switch {
-	case tx.Type == types.TxAddProcessKeys || tx.Type == types.TxRevealProcessKeys:
+	case tx.Type == types.TxRemoveOracle:
+		// the remaining oracles must be enough for reaching the signature thresholds
+		if len(oracles)-1 < thresholds.Max() {
+			return fmt.Errorf("cannot remove oracle, the signature thresholds require at least %d oracles", thresholds.Max())
+		}
+	case tx.Type == types.TxAddValidator:
+		if _, err := hexPubKeyToTendermintEd25519(tx.PubKey); err != nil {
+			return fmt.Errorf("invalid validator public key: (%s)", err)
+		}
+		if tx.Power <= 0 {
+			return fmt.Errorf("validator power must be positive")
+		}
+	case tx.Type == types.TxRemoveValidator:
+		validators, err := state.Validators(false)
+		if err != nil {
+			return fmt.Errorf("cannot get validators: (%s)", err)
+		}
+		found := false
+		for _, v := range validators {
+			if v.Address.String() == tx.Address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("validator %s not found", tx.Address)
+		}
+		if len(validators) == 1 {
+			return fmt.Errorf("cannot remove the last validator")
+		}
+	case processKeysTxTypes[tx.Type]:
 		pid, err := hex.DecodeString(tx.ProcessID)
 		if err != nil {
 			return err
@@ -451,27 +1174,71 @@ func AdminTxCheck(tx *types.AdminTx, state *State) error {
 			return fmt.Errorf("cannot get blockchain header")
 		}
 		// Specific checks
-		if tx.Type == types.TxAddProcessKeys {
+		if tx.Type != types.TxRevealProcessKeys {
 			// endblock is always greater than start block so that case is also included here
-			if header.Height > process.StartBlock {
+			started := header.Height > process.StartBlock
+			if process.IsTimeScheduled() {
+				started = process.Started(header.Height, header.Time)
+			}
+			if started {
 				return fmt.Errorf("cannot add process keys in a started or finished process")
 			}
 			// process is not canceled
 			if process.Canceled {
 				return fmt.Errorf("cannot add process keys in a canceled process")
 			}
+		}
+		if tx.Type == types.TxAddProcessKeys {
+			if tx.KeyIndex < 1 || tx.KeyIndex >= types.MaxKeyIndex {
+				return fmt.Errorf("invalid key index")
+			}
 			if len(process.EncryptionPublicKeys[tx.KeyIndex])+len(process.CommitmentKeys[tx.KeyIndex]) > 0 {
 				return fmt.Errorf("keys for process %s already revealed", tx.ProcessID)
 			}
+			// the keykeepers of a threshold key are fixed once the key deals phase starts
+			if process.IsThresholdEncrypted() && process.KeyGenerationPhase(header.Height, header.Time) != types.KeyPhaseKeys {
+				return fmt.Errorf("cannot add process keys once the threshold key generation started")
+			}
 			// check included keys and keyindex are valid
 			if err := checkAddProcessKeys(tx, process); err != nil {
 				return err
 			}
 		}
+		if tx.Type == types.TxAddProcessKeyDeal {
+			if err := checkKeyGenerationPhase(process, header, types.KeyPhaseDeals); err != nil {
+				return err
+			}
+			if err := checkAddProcessKeyDeal(tx, process); err != nil {
+				return err
+			}
+		}
+		if tx.Type == types.TxAddProcessKeyComplaint {
+			if err := checkKeyGenerationPhase(process, header, types.KeyPhaseComplaints); err != nil {
+				return err
+			}
+			if err := checkAddProcessKeyComplaint(tx, process); err != nil {
+				return err
+			}
+		}
+		if tx.Type == types.TxRevealProcessKeyDealShare {
+			if err := checkKeyGenerationPhase(process, header, types.KeyPhaseReveals); err != nil {
+				return err
+			}
+			if err := checkRevealProcessKeyDealShare(tx, process); err != nil {
+				return err
+			}
+		}
 		if tx.Type == types.TxRevealProcessKeys {
-			if header.Height < process.StartBlock+process.NumberOfBlocks && !process.Canceled {
+			if process.IsTimeScheduled() {
+				if !process.Finished(header.Height, header.Time) && !process.Canceled {
+					return fmt.Errorf("cannot reveal keys before the process is finished (%d < %d)", header.Time.Unix(), process.EndTime)
+				}
+			} else if header.Height < process.StartBlock+process.NumberOfBlocks && !process.Canceled {
 				return fmt.Errorf("cannot reveal keys before the process is finished (%d < %d)", header.Height, process.StartBlock+process.NumberOfBlocks)
 			}
+			if tx.KeyIndex < 1 || tx.KeyIndex >= types.MaxKeyIndex {
+				return fmt.Errorf("invalid key index")
+			}
 			if len(process.EncryptionPrivateKeys[tx.KeyIndex])+len(process.RevealKeys[tx.KeyIndex]) > 0 {
 				return fmt.Errorf("keys for process %s already revealed", tx.ProcessID)
 			}
@@ -484,6 +1251,113 @@ func AdminTxCheck(tx *types.AdminTx, state *State) error {
 	return nil
 }
 
+// CoSignTxCheck is an abstraction of ABCI checkTx for co-signing a privileged transaction.
+// It returns the co-signed transaction with all the signatures collected so far, and its
+// updated state entry. If the signatures reach the threshold of the transaction class, the
+// entry is marked as executed and the caller must check and execute the transaction.
+func CoSignTxCheck(tx *types.CoSignTx, state *State) (GenericTX, *types.CoSignedTx, error) {
+	cosignedTx, err := UnmarshalTx(tx.Tx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse co-signed transaction: (%s)", err)
+	}
+	signedBytes, signatures, nonce, err := oracleTxFields(cosignedTx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(signatures) != 1 {
+		return nil, nil, fmt.Errorf("co-signed transaction must be signed only by the sender oracle")
+	}
+	oracles, err := state.Oracles(false)
+	if err != nil || len(oracles) == 0 {
+		return nil, nil, fmt.Errorf("cannot check authorization against a nil or empty oracle list")
+	}
+	signers, err := verifyOracleSignatures(oracles, signedBytes, signatures, 1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unauthorized to co-sign a transaction: (%s)", err)
+	}
+	if err := checkNonce(state, signers, nonce); err != nil {
+		return nil, nil, err
+	}
+	cosigned, err := state.CoSignedTx(signedBytes, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cosigned == nil {
+		cosigned = &types.CoSignedTx{Tx: tx.Tx}
+	}
+	if cosigned.Executed {
+		return nil, nil, fmt.Errorf("co-signed transaction already executed")
+	}
+	isOracle := make(map[ethcommon.Address]bool, len(oracles))
+	for _, oracle := range oracles {
+		isOracle[ethcommon.HexToAddress(oracle)] = true
+	}
+	// the signatures of the removed oracles are discarded
+	merged := []string{}
+	for _, signature := range cosigned.Signatures {
+		addr, err := ethereum.AddrFromSignature(signedBytes, signature)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot extract address from signature: (%s)", err)
+		}
+		if addr == signers[0] {
+			return nil, nil, fmt.Errorf("transaction already co-signed by %s", addr.Hex())
+		}
+		if isOracle[addr] {
+			merged = append(merged, signature)
+		}
+	}
+	merged = append(merged, signatures[0])
+	thresholds, err := state.OracleThresholds(false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get oracle thresholds: (%s)", err)
+	}
+	setTxSignatures(cosignedTx, merged)
+	if len(merged) < oracleTxThreshold(cosignedTx, thresholds) {
+		return cosignedTx, &types.CoSignedTx{Signatures: merged, Tx: cosigned.Tx}, nil
+	}
+	return cosignedTx, &types.CoSignedTx{Executed: true}, nil
+}
+
+// checkEncryptionKeyIndexes returns an error if the key indexes used for encrypting a vote are not
+// valid. Threshold encrypted processes have a single encryption key.
+func checkEncryptionKeyIndexes(process *types.Process, indexes []int) error {
+	if len(indexes) == 0 {
+		return fmt.Errorf("no key indexes provided on vote package")
+	}
+	if process.IsThresholdEncrypted() && (len(indexes) != 1 || indexes[0] != types.ThresholdKeyIndex) {
+		return fmt.Errorf("threshold encrypted votes must use only the key index %d", types.ThresholdKeyIndex)
+	}
+	return nil
+}
+
+// checkNonce returns an error if the nonce is missing or already used by any of the signer addresses
+func checkNonce(state *State, signers []ethcommon.Address, nonce string) error {
+	if nonce == "" {
+		return fmt.Errorf("missing nonce")
+	}
+	for _, addr := range signers {
+		if state.NonceUsed(addr, nonce, false) {
+			return fmt.Errorf("nonce %s already used by %s", nonce, addr.Hex())
+		}
+	}
+	return nil
+}
+
+// useNonce marks the nonce of a transaction as used by all its signers, so the
+// transaction cannot be replayed. It must be called once the transaction is executed.
+func useNonce(state *State, signedBytes []byte, signatures []string, nonce string) error {
+	for _, signature := range signatures {
+		addr, err := ethereum.AddrFromSignature(signedBytes, signature)
+		if err != nil {
+			return fmt.Errorf("cannot extract address from signature: (%s)", err)
+		}
+		if err := state.AddNonce(addr, nonce); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func checkAddProcessKeys(tx *types.AdminTx, process *types.Process) error {
 	// check if at leat 1 key is provided and the keyIndex do not over/under flow
 	if len(tx.CommitmentKey)+len(tx.EncryptionPublicKey) == 0 || tx.KeyIndex < 1 || tx.KeyIndex > types.MaxKeyIndex {
@@ -497,6 +1371,112 @@ func checkAddProcessKeys(tx *types.AdminTx, process *types.Process) error {
 	return nil
 }
 
+// checkAddProcessKeyDeal checks the contribution of a keykeeper to the threshold encryption key
+func checkAddProcessKeyDeal(tx *types.AdminTx, process *types.Process) error {
+	if !process.IsThresholdEncrypted() {
+		return fmt.Errorf("process does not use a threshold encryption key")
+	}
+	if tx.KeyDeal == nil || tx.KeyIndex < 1 || tx.KeyIndex >= types.MaxKeyIndex {
+		return fmt.Errorf("no key deal provided or invalid key index")
+	}
+	// only the keykeepers which have published their keys can deal
+	if len(process.EncryptionPublicKeys[tx.KeyIndex]) < 1 {
+		return fmt.Errorf("key index %d does not exist", tx.KeyIndex)
+	}
+	if len(process.KeyDeals[tx.KeyIndex].Commitments) > 0 {
+		return fmt.Errorf("key deal for index %d already exist", tx.KeyIndex)
+	}
+	if len(tx.KeyDeal.Commitments) != int(process.KeyThreshold) {
+		return fmt.Errorf("the key deal must have %d commitments", process.KeyThreshold)
+	}
+	for _, c := range tx.KeyDeal.Commitments {
+		p, err := threshold.DecodePoint(c)
+		if err != nil {
+			return fmt.Errorf("invalid key deal commitment: (%s)", err)
+		}
+		if !p.InPrimeSubgroup() {
+			return fmt.Errorf("invalid key deal commitment: point is not on the prime order subgroup")
+		}
+	}
+	if len(tx.KeyDeal.Shares) != types.MaxKeyIndex {
+		return fmt.Errorf("the key deal must have %d shares", types.MaxKeyIndex)
+	}
+	for i, s := range tx.KeyDeal.Shares {
+		if s == "" {
+			continue
+		}
+		if i == types.ThresholdKeyIndex || len(process.EncryptionPublicKeys[i]) < 1 {
+			return fmt.Errorf("key share for a non existing key index %d", i)
+		}
+		if _, err := hex.DecodeString(s); err != nil {
+			return fmt.Errorf("cannot decode key share %d: (%s)", i, err)
+		}
+	}
+	return nil
+}
+
+// checkKeyGenerationPhase returns an error if the threshold key generation of a process is not
+// on the given phase
+func checkKeyGenerationPhase(process *types.Process, header *tmtypes.Header, phase int) error {
+	if !process.IsThresholdEncrypted() {
+		return fmt.Errorf("process does not use a threshold encryption key")
+	}
+	if current := process.KeyGenerationPhase(header.Height, header.Time); current != phase {
+		return fmt.Errorf("threshold key generation is on phase %d, not %d", current, phase)
+	}
+	return nil
+}
+
+// checkAddProcessKeyComplaint checks the complaint of the keykeeper KeyIndex about the share
+// dealt by the keykeeper DealerIndex
+func checkAddProcessKeyComplaint(tx *types.AdminTx, process *types.Process) error {
+	if tx.KeyIndex < 1 || tx.KeyIndex >= types.MaxKeyIndex || len(process.EncryptionPublicKeys[tx.KeyIndex]) < 1 {
+		return fmt.Errorf("key index %d does not exist", tx.KeyIndex)
+	}
+	if tx.DealerIndex < 1 || tx.DealerIndex >= types.MaxKeyIndex || tx.DealerIndex == tx.KeyIndex {
+		return fmt.Errorf("invalid dealer index %d", tx.DealerIndex)
+	}
+	deal := &process.KeyDeals[tx.DealerIndex]
+	if len(deal.Commitments) == 0 {
+		return fmt.Errorf("key deal for index %d does not exist", tx.DealerIndex)
+	}
+	if deal.HasComplaint(tx.KeyIndex) {
+		return fmt.Errorf("complaint about key deal %d already exist", tx.DealerIndex)
+	}
+	return nil
+}
+
+// checkRevealProcessKeyDealShare checks the share revealed by the keykeeper DealerIndex in response
+// to the complaint of the keykeeper KeyIndex, against the dealer commitments
+func checkRevealProcessKeyDealShare(tx *types.AdminTx, process *types.Process) error {
+	if tx.DealerIndex < 1 || tx.DealerIndex >= types.MaxKeyIndex {
+		return fmt.Errorf("invalid dealer index %d", tx.DealerIndex)
+	}
+	deal := &process.KeyDeals[tx.DealerIndex]
+	if !deal.HasComplaint(tx.KeyIndex) {
+		return fmt.Errorf("no complaint about key deal %d from index %d", tx.DealerIndex, tx.KeyIndex)
+	}
+	// revealing threshold shares would reveal the dealer secret, such a dealer is disqualified
+	if len(deal.Complaints) >= int(process.KeyThreshold) {
+		return fmt.Errorf("key deal %d has too many complaints", tx.DealerIndex)
+	}
+	if tx.KeyIndex < len(deal.RevealedShares) && deal.RevealedShares[tx.KeyIndex] != "" {
+		return fmt.Errorf("key share %d of key deal %d already revealed", tx.KeyIndex, tx.DealerIndex)
+	}
+	share, err := threshold.DecodeScalar(tx.KeyShare)
+	if err != nil {
+		return fmt.Errorf("cannot decode key share: (%s)", err)
+	}
+	commitments, err := decodeKeyDeal(deal)
+	if err != nil {
+		return err
+	}
+	if !threshold.VerifyShare(commitments, tx.KeyIndex, share) {
+		return fmt.Errorf("the revealed key share does not match with the key deal %d", tx.DealerIndex)
+	}
+	return nil
+}
+
 func checkRevealProcessKeys(tx *types.AdminTx, process *types.Process) error {
 	// check if at leat 1 key is provided and the keyIndex do not over/under flow
 	if len(tx.RevealKey)+len(tx.EncryptionPrivateKey) == 0 || tx.KeyIndex < 1 || tx.KeyIndex > types.MaxKeyIndex {
@@ -506,8 +1486,20 @@ func checkRevealProcessKeys(tx *types.AdminTx, process *types.Process) error {
 	if len(process.EncryptionPublicKeys[tx.KeyIndex]) < 1 || len(process.CommitmentKeys[tx.KeyIndex]) < 1 {
 		return fmt.Errorf("key index %d does not exist", tx.KeyIndex)
 	}
-	// check keys actually work
-	if len(tx.EncryptionPrivateKey) > 0 {
+	// check keys actually work, threshold encrypted processes reveal a key share instead of the private key
+	if len(tx.EncryptionPrivateKey) > 0 && process.IsThresholdEncrypted() {
+		share, err := threshold.DecodeScalar(tx.EncryptionPrivateKey)
+		if err != nil {
+			return fmt.Errorf("cannot decode key share: (%s)", err)
+		}
+		deals, err := processKeyDeals(process)
+		if err != nil {
+			return err
+		}
+		if !threshold.ScalarBaseMult(share).Equal(threshold.PublicShare(deals, tx.KeyIndex)) {
+			return fmt.Errorf("the provided key share does not match with the key deals on index %d", tx.KeyIndex)
+		}
+	} else if len(tx.EncryptionPrivateKey) > 0 {
 		if priv, err := nacl.DecodePrivate(tx.EncryptionPrivateKey); err == nil {
 			pub := priv.Public().Bytes()
 			if fmt.Sprintf("%x", pub) != process.EncryptionPublicKeys[tx.KeyIndex] {
@@ -45,6 +45,24 @@ var (
 		Name:      "vote_cache",
 		Help:      "Size of the current vote cache",
 	})
+	// VochainVoteCacheHits ...
+	VochainVoteCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vochain",
+		Name:      "vote_cache_hits",
+		Help:      "Number of delivered votes found on the vote cache",
+	})
+	// VochainVoteCacheMisses ...
+	VochainVoteCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vochain",
+		Name:      "vote_cache_misses",
+		Help:      "Number of delivered votes not found on the vote cache",
+	})
+	// VochainVoteCacheEvictions ...
+	VochainVoteCacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vochain",
+		Name:      "vote_cache_evictions",
+		Help:      "Number of vote cache entries removed because expired or because the cache was full",
+	})
 )
 
 func (vi *VochainInfo) registerMetrics(ma *metrics.Agent) {
@@ -54,6 +72,9 @@ func (vi *VochainInfo) registerMetrics(ma *metrics.Agent) {
 	ma.Register(VochainProcessTree)
 	ma.Register(VochainVoteTree)
 	ma.Register(VochainVoteCache)
+	ma.Register(VochainVoteCacheHits)
+	ma.Register(VochainVoteCacheMisses)
+	ma.Register(VochainVoteCacheEvictions)
 
 }
 
@@ -64,6 +85,12 @@ func (vi *VochainInfo) getMetrics() {
 	VochainProcessTree.Set(float64(p))
 	VochainVoteTree.Set(float64(v))
 	VochainVoteCache.Set(float64(vi.VoteCacheSize()))
+	// the vote cache stats are cumulative, so only the increment is added to the counters
+	stats, last := vi.VoteCacheStats(), vi.metricsCacheStats
+	VochainVoteCacheHits.Add(float64(stats.Hits - last.Hits))
+	VochainVoteCacheMisses.Add(float64(stats.Misses - last.Misses))
+	VochainVoteCacheEvictions.Add(float64(stats.Evictions - last.Evictions))
+	vi.metricsCacheStats = stats
 }
 
 // CollectMetrics constantly updates the metric values for prometheus
@@ -17,14 +17,17 @@ type VochainInfo struct {
 	processTreeSize uint64
 	mempoolSize     int
 	voteCacheSize   int
-	avg1            int32
-	avg10           int32
-	avg60           int32
-	avg360          int32
-	avg1440         int32
-	vnode           *vochain.BaseApplication
-	close           chan bool
-	lock            sync.RWMutex
+	voteCacheStats  vochain.VoteCacheStats
+	// metricsCacheStats are the vote cache counters already added to the metrics
+	metricsCacheStats vochain.VoteCacheStats
+	avg1              int32
+	avg10             int32
+	avg60             int32
+	avg360            int32
+	avg1440           int32
+	vnode             *vochain.BaseApplication
+	close             chan bool
+	lock              sync.RWMutex
 }
 
 // NewVochainInfo creates a new VochainInfo type
@@ -79,6 +82,13 @@ func (vi *VochainInfo) VoteCacheSize() int {
 	return vi.voteCacheSize
 }
 
+// VoteCacheStats returns the hit, miss and eviction counters of the vote cache
+func (vi *VochainInfo) VoteCacheStats() vochain.VoteCacheStats {
+	vi.lock.RLock()
+	defer vi.lock.RUnlock()
+	return vi.voteCacheStats
+}
+
 // Peers returns the current list of connected peers
 func (vi *VochainInfo) Peers() (peers []string) {
 	for _, p := range vi.vnode.Node.Switch().Peers().List() {
@@ -161,8 +171,9 @@ func (vi *VochainInfo) Start(sleepSecs int64) {
 			vi.vnode.State.RLock()
 			vi.processTreeSize = vi.vnode.State.Store.Tree(vochain.ProcessTree).Count()
 			vi.voteTreeSize = vi.vnode.State.Store.Tree(vochain.VoteTree).Count()
-			vi.voteCacheSize = vi.vnode.State.VoteCacheSize()
 			vi.vnode.State.RUnlock()
+			vi.voteCacheSize = vi.vnode.State.VoteCacheSize()
+			vi.voteCacheStats = vi.vnode.State.VoteCacheStats()
 			vi.mempoolSize = vi.vnode.Node.Mempool().Size()
 			vi.lock.Unlock()
 